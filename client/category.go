@@ -8,13 +8,15 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 type CategoryClient interface {
 	CategoryList(token string) ([]*model.Category, error)
 	AddCategory(token, name string) (respCode int, err error)
 	UpdateCategory(token, id, name string) (respCode int, err error)
-	DeleteCategory(token, id string) (respCode int, err error)
+	DeleteCategory(token, id string, opts model.CategoryDelete) (respCode int, err error)
 }
 
 type categoryClient struct {
@@ -25,18 +27,13 @@ func NewCategoryClient() *categoryClient {
 }
 
 func (c *categoryClient) CategoryList(token string) ([]*model.Category, error) {
-	client, err := GetClientWithCookie(token)
-	if err != nil {
-		return nil, err
-	}
-
 	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/category/list"), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
 		return nil, err
 	}
@@ -62,11 +59,6 @@ func (c *categoryClient) CategoryList(token string) ([]*model.Category, error) {
 }
 
 func (c *categoryClient) AddCategory(token, name string) (respCode int, err error) {
-	client, err := GetClientWithCookie(token)
-	if err != nil {
-		return -1, err
-	}
-
 	datajson := map[string]string{
 		"name": name,
 	}
@@ -82,7 +74,7 @@ func (c *categoryClient) AddCategory(token, name string) (respCode int, err erro
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
 		return -1, err
 	}
@@ -97,13 +89,9 @@ func (c *categoryClient) AddCategory(token, name string) (respCode int, err erro
 }
 
 func (c *categoryClient) UpdateCategory(token, id, name string) (respCode int, err error) {
-	client, err := GetClientWithCookie(token)
-	if err != nil {
-		return -1, err
-	}
-
+	// A merge patch leaves the rest of the category alone
 	datajson := map[string]string{
-		"title": name,
+		"name": name,
 	}
 
 	data, err := json.Marshal(datajson)
@@ -111,13 +99,13 @@ func (c *categoryClient) UpdateCategory(token, id, name string) (respCode int, e
 		return -1, err
 	}
 
-	req, err := http.NewRequest("PUT", config.SetUrl("/api/v1/category/update/"+id), bytes.NewBuffer(data))
+	req, err := http.NewRequest("PATCH", config.SetUrl("/api/v1/category/"+id), bytes.NewBuffer(data))
 	if err != nil {
 		return -1, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	req.Header.Set("Content-Type", model.MergePatchType)
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
 		return -1, err
 	}
@@ -131,25 +119,37 @@ func (c *categoryClient) UpdateCategory(token, id, name string) (respCode int, e
 	return resp.StatusCode, nil
 }
 
-func (c *categoryClient) DeleteCategory(token, id string) (respCode int, err error) {
-	client, err := GetClientWithCookie(token)
-	if err != nil {
-		return -1, err
+func (c *categoryClient) DeleteCategory(token, id string, opts model.CategoryDelete) (respCode int, err error) {
+	query := url.Values{}
+	if opts.Mode != "" {
+		query.Set("mode", string(opts.Mode))
+	}
+	if opts.Mode == model.CategoryDeleteReassign {
+		query.Set("reassign_to", strconv.Itoa(opts.ReassignTo))
+	}
+
+	path := "/api/v1/category/delete/" + id
+	if len(query) > 0 {
+		path += "?" + query.Encode()
 	}
 
-	req, err := http.NewRequest("DELETE", config.SetUrl("/api/v1/category/delete/"+id), nil)
+	req, err := http.NewRequest("DELETE", config.SetUrl(path), nil)
 	if err != nil {
 		return -1, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
 		return -1, err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		return resp.StatusCode, model.ErrCategoryNotEmpty
+	}
+
 	if resp.StatusCode != 200 {
 		return -1, errors.New("status code not 200")
 	}
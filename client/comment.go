@@ -0,0 +1,91 @@
+package client
+
+import (
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/model"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+type CommentClient interface {
+	CommentList(token string, taskID int) ([]*model.Comment, error)
+	AddComment(token string, taskID int, body string) (respCode int, err error)
+}
+
+type commentClient struct {
+}
+
+func NewCommentClient() *commentClient {
+	return &commentClient{}
+}
+
+func (cc *commentClient) CommentList(token string, taskID int) ([]*model.Comment, error) {
+	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/task/"+strconv.Itoa(taskID)+"/comments"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, errors.New("status code not 200")
+	}
+
+	var comments []*model.Comment
+	err = json.Unmarshal(b, &comments)
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func (cc *commentClient) AddComment(token string, taskID int, body string) (respCode int, err error) {
+	data, err := json.Marshal(model.CommentRequest{Body: body})
+	if err != nil {
+		return -1, err
+	}
+
+	req, err := http.NewRequest("POST", config.SetUrl("/api/v1/task/"+strconv.Itoa(taskID)+"/comments"), bytes.NewBuffer(data))
+	if err != nil {
+		return -1, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return -1, err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode != 200 {
+		var errResp model.ErrorResponse
+		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Error != "" {
+			return resp.StatusCode, errors.New(errResp.Error)
+		}
+		return resp.StatusCode, errors.New("Failed to add comment with status: " + strconv.Itoa(resp.StatusCode))
+	}
+
+	return resp.StatusCode, nil
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"a21hc3NpZ25tZW50/config"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -17,10 +18,12 @@ func GetClientWithCookie(token string, cookies ...*http.Cookie) (*http.Client, e
 		Value: token,
 	})
 
-	jar.SetCookies(&url.URL{
-		Scheme: "http",
-		Host:   "localhost:8080",
-	}, cookies)
+	// The cookies go to the API server, wherever BASE_URL puts it
+	api, err := url.Parse(config.SetUrl("/"))
+	if err != nil {
+		return nil, err
+	}
+	jar.SetCookies(api, cookies)
 
 	c := &http.Client{
 		Jar: jar,
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/model"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+type SearchClient interface {
+	Search(token, query string) ([]model.SearchResult, error)
+}
+
+type searchClient struct {
+}
+
+func NewSearchClient() *searchClient {
+	return &searchClient{}
+}
+
+// Search returns the tasks matching query, best first. A query without words
+// fails with model.ErrInvalidSearch.
+func (s *searchClient) Search(token, query string) ([]model.SearchResult, error) {
+	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/search?q="+url.QueryEscape(query)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusBadRequest {
+		return nil, model.ErrInvalidSearch
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New("status code not 200")
+	}
+
+	var results []model.SearchResult
+	err = json.Unmarshal(b, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
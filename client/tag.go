@@ -0,0 +1,53 @@
+package client
+
+import (
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/model"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+type TagClient interface {
+	TagList(token string) ([]*model.Tag, error)
+}
+
+type tagClient struct {
+}
+
+func NewTagClient() *tagClient {
+	return &tagClient{}
+}
+
+func (t *tagClient) TagList(token string) ([]*model.Tag, error) {
+	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/tag/list"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, errors.New("status code not 200")
+	}
+
+	var tags []*model.Tag
+	err = json.Unmarshal(b, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -8,14 +8,18 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 type TaskClient interface {
-	TaskList(token string) ([]*model.Task, error)
+	TaskList(token string, opts TaskListOptions) ([]*model.TaskResponse, error)
+	TaskPage(token string, opts TaskListOptions) (model.TaskListPage, error)
 	AddTask(token string, task model.Task) (respCode int, err error)
 	UpdateTask(token string, task model.Task) (respCode int, err error)
-	DeleteTask(token string, id int) (respCode int, err error)
+	TransitionTask(token string, id int, to model.TaskTransition) (respCode int, err error)
+	DeleteTask(token string, id int) (trashID int, err error)
 }
 
 type taskClient struct {
@@ -25,49 +29,134 @@ func NewTaskClient() *taskClient {
 	return &taskClient{}
 }
 
-func (t *taskClient) TaskList(token string) ([]*model.Task, error) {
-	client, err := GetClientWithCookie(token)
+// TaskListOptions are the filters, order and page of GET /api/v1/task/list.
+// Zero fields are left out.
+type TaskListOptions struct {
+	View         string // the query of a saved view, the fields below replace its parameters
+	Statuses     []string
+	PriorityMin  int
+	PriorityMax  int
+	CategoryID   int
+	DeadlineFrom model.Deadline
+	DeadlineTo   model.Deadline
+	Query        string // words every title must have, see model.ParseSearchQuery
+	Tags         []string
+	Match        model.TagMatch
+	Sort         model.TaskSort
+	Desc         bool
+	Limit        int    // TaskPage only
+	Cursor       string // TaskPage only, the NextCursor of the page before
+}
+
+func (o TaskListOptions) values() (url.Values, error) {
+	v, err := url.ParseQuery(o.View)
 	if err != nil {
 		return nil, err
 	}
+	set := func(key string, n int) {
+		if n != 0 {
+			v.Set(key, strconv.Itoa(n))
+		}
+	}
+	if len(o.Statuses) > 0 {
+		v.Set("status", strings.Join(o.Statuses, ","))
+	}
+	set("priority_min", o.PriorityMin)
+	set("priority_max", o.PriorityMax)
+	set("category_id", o.CategoryID)
+	if !o.DeadlineFrom.IsZero() {
+		v.Set("deadline_from", o.DeadlineFrom.String())
+	}
+	if !o.DeadlineTo.IsZero() {
+		v.Set("deadline_to", o.DeadlineTo.String())
+	}
+	if o.Query != "" {
+		v.Set("q", o.Query)
+	}
+	if len(o.Tags) > 0 {
+		v.Set("tag", strings.Join(o.Tags, ","))
+	}
+	if o.Match != "" {
+		v.Set("match", string(o.Match))
+	}
+	if o.Sort != "" {
+		v.Set("sort", string(o.Sort))
+	}
+	if o.Desc {
+		v.Set("order", "desc")
+	}
+	set("limit", o.Limit)
+	if o.Cursor != "" {
+		v.Set("cursor", o.Cursor)
+	}
+	return v, nil
+}
 
-	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/task/list"), nil)
+// getTaskList fetches the task list for opts and decodes it into out.
+func getTaskList(token string, opts TaskListOptions, out interface{}) error {
+	values, err := opts.values()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	target := config.SetUrl("/api/v1/task/list")
+	if query := values.Encode(); query != "" {
+		target += "?" + query
+	}
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	defer resp.Body.Close()
 
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
+	if resp.StatusCode == http.StatusBadRequest {
+		var e model.ErrorResponse
+		if json.Unmarshal(b, &e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+	}
 	if resp.StatusCode != 200 {
-		return nil, errors.New("status code not 200")
+		return errors.New("status code not 200")
 	}
 
-	var tasks []*model.Task
-	err = json.Unmarshal(b, &tasks)
-	if err != nil {
+	return json.Unmarshal(b, out)
+}
+
+// TaskList returns every task matching opts, Limit and Cursor are ignored.
+func (t *taskClient) TaskList(token string, opts TaskListOptions) ([]*model.TaskResponse, error) {
+	opts.Limit, opts.Cursor = 0, ""
+
+	var tasks []*model.TaskResponse
+	if err := getTaskList(token, opts, &tasks); err != nil {
 		return nil, err
 	}
-
 	return tasks, nil
 }
 
-func (t *taskClient) AddTask(token string, task model.Task) (respCode int, err error) {
-	client, err := GetClientWithCookie(token)
-	if err != nil {
-		return -1, err
+// TaskPage returns a page of the tasks matching opts, of the API's default
+// size when Limit is 0.
+func (t *taskClient) TaskPage(token string, opts TaskListOptions) (model.TaskListPage, error) {
+	if opts.Limit == 0 {
+		opts.Limit = model.DefaultTaskPage
 	}
 
+	var page model.TaskListPage
+	err := getTaskList(token, opts, &page)
+	return page, err
+}
+
+func (t *taskClient) AddTask(token string, task model.Task) (respCode int, err error) {
 	datajson := map[string]interface{}{
 		"title":       task.Title,
 		"deadline":    task.Deadline,
@@ -75,6 +164,9 @@ func (t *taskClient) AddTask(token string, task model.Task) (respCode int, err e
 		"status":      task.Status,
 		"category_id": task.CategoryID,
 		"user_id":     task.UserID,
+		"parent_id":   task.ParentID,
+		"recurrence":  task.Recurrence,
+		"tag_ids":     task.TagIDs,
 	}
 
 	data, err := json.Marshal(datajson)
@@ -88,7 +180,7 @@ func (t *taskClient) AddTask(token string, task model.Task) (respCode int, err e
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
 		return -1, err
 	}
@@ -114,11 +206,6 @@ func (t *taskClient) AddTask(token string, task model.Task) (respCode int, err e
 }
 
 func (t *taskClient) UpdateTask(token string, task model.Task) (respCode int, err error) {
-	client, err := GetClientWithCookie(token)
-	if err != nil {
-		return -1, err
-	}
-
 	datajson := map[string]interface{}{
 		"title":       task.Title,
 		"deadline":    task.Deadline,
@@ -126,6 +213,10 @@ func (t *taskClient) UpdateTask(token string, task model.Task) (respCode int, er
 		"status":      task.Status,
 		"category_id": task.CategoryID,
 		"user_id":     task.UserID,
+		"parent_id":   task.ParentID,
+		"position":    task.Position,
+		"recurrence":  task.Recurrence,
+		"tag_ids":     task.TagIDs,
 	}
 
 	data, err := json.Marshal(datajson)
@@ -139,7 +230,11 @@ func (t *taskClient) UpdateTask(token string, task model.Task) (respCode int, er
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	// Refuse to overwrite changes made since the task was read
+	if task.Version > 0 {
+		req.Header.Set("If-Match", strconv.Quote(strconv.Itoa(task.Version)))
+	}
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
 		return -1, err
 	}
@@ -153,19 +248,52 @@ func (t *taskClient) UpdateTask(token string, task model.Task) (respCode int, er
 	return resp.StatusCode, nil
 }
 
-func (t *taskClient) DeleteTask(token string, id int) (respCode int, err error) {
-	client, err := GetClientWithCookie(token)
+func (t *taskClient) TransitionTask(token string, id int, to model.TaskTransition) (respCode int, err error) {
+	data, err := json.Marshal(to)
 	if err != nil {
 		return -1, err
 	}
 
+	req, err := http.NewRequest("POST", config.SetUrl("/api/v1/task/"+strconv.Itoa(id)+"/transition"), bytes.NewBuffer(data))
+	if err != nil {
+		return -1, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return -1, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	// Kembalikan pesan error dari API, misalnya transition yang tidak diizinkan
+	if resp.StatusCode != 200 {
+		var errResp model.ErrorResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+			return resp.StatusCode, errors.New(errResp.Error)
+		}
+		return resp.StatusCode, errors.New("Failed to move task with status: " + strconv.Itoa(resp.StatusCode))
+	}
+
+	return resp.StatusCode, nil
+}
+
+// DeleteTask moves the task to the trash and returns the entry that restores
+// it.
+func (t *taskClient) DeleteTask(token string, id int) (trashID int, err error) {
 	req, err := http.NewRequest("DELETE", config.SetUrl("/api/v1/task/delete/"+strconv.Itoa(id)), nil)
 	if err != nil {
 		return -1, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
 		return -1, err
 	}
@@ -176,5 +304,10 @@ func (t *taskClient) DeleteTask(token string, id int) (respCode int, err error)
 		return -1, errors.New("status code not 200")
 	}
 
-	return resp.StatusCode, nil
+	var trashed model.TrashedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&trashed); err != nil {
+		return -1, err
+	}
+
+	return trashed.TrashID, nil
 }
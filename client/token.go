@@ -0,0 +1,220 @@
+package client
+
+import (
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/model"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// tokenEntry is what the client knows about an access token it has seen:
+// the refresh token issued with it, or the token that replaced it.
+type tokenEntry struct {
+	refreshToken string
+	replacedBy   string
+	expiry       time.Time
+}
+
+// tokenStore remembers token pairs handed out by the API so that callers only
+// ever deal with access tokens. Refreshes are serialised because a refresh
+// token is single use and a second exchange would revoke the whole session.
+type tokenStore struct {
+	mu      sync.Mutex
+	entries map[string]tokenEntry
+}
+
+var tokens = &tokenStore{entries: map[string]tokenEntry{}}
+
+// tokensFromCookies extracts the pair the API sets on login and refresh.
+func tokensFromCookies(resp *http.Response) (access, refresh string) {
+	for _, cookie := range resp.Cookies() {
+		switch cookie.Name {
+		case "session_token":
+			access = cookie.Value
+		case "refresh_token":
+			refresh = cookie.Value
+		}
+	}
+	return access, refresh
+}
+
+// remember records a pair found in the Set-Cookie headers of resp.
+func (s *tokenStore) remember(resp *http.Response) {
+	access, refresh := tokensFromCookies(resp)
+	if access == "" || refresh == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	s.entries[access] = tokenEntry{
+		refreshToken: refresh,
+		expiry:       time.Now().Add(model.RefreshTokenTTL),
+	}
+}
+
+// forget drops token and everything that replaced it.
+func (s *tokenStore) forget(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token != "" {
+		entry, ok := s.entries[token]
+		if !ok {
+			return
+		}
+		delete(s.entries, token)
+		token = entry.replacedBy
+	}
+}
+
+// prune drops entries whose refresh token can no longer be used. Callers hold mu.
+func (s *tokenStore) prune() {
+	now := time.Now()
+	for token, entry := range s.entries {
+		if entry.expiry.Before(now) {
+			delete(s.entries, token)
+		}
+	}
+}
+
+// refresh returns the newest access token descended from token, exchanging
+// the refresh token with the API when token has not been replaced yet.
+func (s *tokenStore) refresh(token string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		entry, ok := s.entries[token]
+		if !ok {
+			return "", model.ErrTokenExpired
+		}
+		if entry.replacedBy == "" {
+			break
+		}
+		token = entry.replacedBy
+	}
+
+	// The newest token may still be valid if another caller refreshed it
+	if !accessTokenExpired(token) {
+		return token, nil
+	}
+
+	entry := s.entries[token]
+	data, err := json.Marshal(model.RefreshRequest{RefreshToken: entry.refreshToken})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", config.SetUrl("/api/v1/user/refresh"), bytes.NewBuffer(data))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// The session is gone for good, nothing left to refresh
+		delete(s.entries, token)
+		return "", model.ErrTokenExpired
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("refresh failed with status: " + strconv.Itoa(resp.StatusCode))
+	}
+
+	access, refresh := tokensFromCookies(resp)
+	if access == "" || refresh == "" {
+		return "", errors.New("refresh response is missing tokens")
+	}
+
+	s.entries[access] = tokenEntry{
+		refreshToken: refresh,
+		expiry:       time.Now().Add(model.RefreshTokenTTL),
+	}
+	s.entries[token] = tokenEntry{
+		replacedBy: access,
+		expiry:     entry.expiry,
+	}
+
+	return access, nil
+}
+
+// accessTokenExpired reads the exp claim without verifying the signature,
+// the API remains the judge of validity.
+func accessTokenExpired(token string) bool {
+	claims := &model.Claims{}
+	_, _, err := new(jwt.Parser).ParseUnverified(token, claims)
+	if err != nil {
+		return true
+	}
+	return claims.ExpiresAt != 0 && time.Unix(claims.ExpiresAt, 0).Before(time.Now())
+}
+
+// sendWithRefresh sends req authenticated as token. When the API reports the
+// access token has expired the pair is refreshed once and req is replayed.
+func sendWithRefresh(token string, req *http.Request) (*http.Response, error) {
+	client, err := GetClientWithCookie(token)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var errResp model.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error != model.ErrTokenExpired.Error() {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+
+	newToken, err := tokens.refresh(token)
+	if err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client, err = GetClientWithCookie(newToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Do(retry)
+}
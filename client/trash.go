@@ -0,0 +1,108 @@
+package client
+
+import (
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/model"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+type TrashClient interface {
+	TrashList(token string) ([]model.TrashItem, error)
+	RestoreTrash(token string, id int) error
+	PurgeTrash(token string, id int) error
+}
+
+type trashClient struct {
+}
+
+func NewTrashClient() *trashClient {
+	return &trashClient{}
+}
+
+func (t *trashClient) TrashList(token string) ([]model.TrashItem, error) {
+	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/trash/list"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, errors.New("status code not 200")
+	}
+
+	var items []model.TrashItem
+	err = json.Unmarshal(b, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// RestoreTrash answers an entry that cannot be restored with the reason the
+// API gave.
+func (t *trashClient) RestoreTrash(token string, id int) error {
+	req, err := http.NewRequest("POST", config.SetUrl("/api/v1/trash/restore/"+strconv.Itoa(id)), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		var body model.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+			return model.ErrRestoreConflict
+		}
+		return errors.New(body.Error)
+	}
+
+	if resp.StatusCode != 200 {
+		return errors.New("status code not 200")
+	}
+
+	return nil
+}
+
+func (t *trashClient) PurgeTrash(token string, id int) error {
+	req, err := http.NewRequest("DELETE", config.SetUrl("/api/v1/trash/purge/"+strconv.Itoa(id)), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errors.New("status code not 200")
+	}
+
+	return nil
+}
@@ -12,10 +12,13 @@ import (
 )
 
 type UserClient interface {
-	Login(email, password string) (respCode int, err error)
+	Login(email, password string, device model.Device) (token string, respCode int, err error)
 	Register(fullname, email, password string) (respCode int, err error)
 	GetUserByEmail(email string, token string) (model.User, error)
 	GetUserTaskCategory(token string) (*[]model.UserTaskCategory, error)
+	Refresh(token string) (newToken string, err error)
+	Logout(token string) (respCode int, err error)
+	LogoutAll(token string) (respCode int, err error)
 }
 
 type userClient struct {
@@ -25,7 +28,7 @@ func NewUserClient() *userClient {
 	return &userClient{}
 }
 
-func (u *userClient) Login(email, password string) (respCode int, err error) {
+func (u *userClient) Login(email, password string, device model.Device) (token string, respCode int, err error) {
 	datajson := map[string]string{
 		"email":    email,
 		"password": password,
@@ -33,21 +36,29 @@ func (u *userClient) Login(email, password string) (respCode int, err error) {
 
 	data, err := json.Marshal(datajson)
 	if err != nil {
-		return -1, err
+		return "", -1, err
 	}
 
 	req, err := http.NewRequest("POST", config.SetUrl("/api/v1/user/login"), bytes.NewBuffer(data))
 	if err != nil {
-		return -1, err
+		return "", -1, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
+	// Session dicatat atas nama browser pengguna, bukan web server
+	if device.UserAgent != "" {
+		req.Header.Set("User-Agent", device.UserAgent)
+	}
+	if device.IP != "" {
+		req.Header.Set("X-Forwarded-For", device.IP)
+	}
+
 	client := &http.Client{}
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return -1, err
+		return "", -1, err
 	}
 
 	defer resp.Body.Close()
@@ -55,19 +66,27 @@ func (u *userClient) Login(email, password string) (respCode int, err error) {
 	// Baca response body untuk mendapatkan pesan error
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return resp.StatusCode, err
+		return "", resp.StatusCode, err
 	}
 
 	// Jika status bukan 200 OK, kembalikan error dengan pesan dari response
 	if resp.StatusCode != http.StatusOK {
 		var errResp model.ErrorResponse
 		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
-			return resp.StatusCode, errors.New(errResp.Error)
+			return "", resp.StatusCode, errors.New(errResp.Error)
 		}
-		return resp.StatusCode, errors.New("Login failed with status: " + strconv.Itoa(resp.StatusCode))
+		return "", resp.StatusCode, errors.New("Login failed with status: " + strconv.Itoa(resp.StatusCode))
 	}
 
-	return resp.StatusCode, nil
+	tokens.remember(resp)
+
+	token, _ = tokensFromCookies(resp)
+
+	return token, resp.StatusCode, nil
+}
+
+func (u *userClient) Refresh(token string) (string, error) {
+	return tokens.refresh(token)
 }
 
 func (u *userClient) Register(fullname, email, password string) (respCode int, err error) {
@@ -117,11 +136,6 @@ func (u *userClient) Register(fullname, email, password string) (respCode int, e
 }
 
 func (u *userClient) GetUserTaskCategory(token string) (*[]model.UserTaskCategory, error) {
-	client, err := GetClientWithCookie(token)
-	if err != nil {
-		return nil, err
-	}
-
 	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/user/tasks"), nil)
 	if err != nil {
 		return nil, err
@@ -129,7 +143,7 @@ func (u *userClient) GetUserTaskCategory(token string) (*[]model.UserTaskCategor
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
+	resp, err := sendWithRefresh(token, req)
 
 	if err != nil {
 		return nil, err
@@ -156,18 +170,13 @@ func (u *userClient) GetUserTaskCategory(token string) (*[]model.UserTaskCategor
 }
 
 func (u *userClient) GetUserByEmail(email string, token string) (model.User, error) {
-	client, err := GetClientWithCookie(token)
-	if err != nil {
-		return model.User{}, err
-	}
-
 	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/user/profile/"+email), nil)
 	if err != nil {
 		return model.User{}, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	resp, err := sendWithRefresh(token, req)
 	if err != nil {
 		return model.User{}, err
 	}
@@ -191,3 +200,49 @@ func (u *userClient) GetUserByEmail(email string, token string) (model.User, err
 
 	return user, nil
 }
+
+func (u *userClient) Logout(token string) (respCode int, err error) {
+	req, err := http.NewRequest("POST", config.SetUrl("/api/v1/user/logout"), nil)
+	if err != nil {
+		return -1, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return -1, err
+	}
+
+	defer resp.Body.Close()
+
+	tokens.forget(token)
+
+	if resp.StatusCode != 200 {
+		return resp.StatusCode, errors.New("Logout failed with status: " + strconv.Itoa(resp.StatusCode))
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (u *userClient) LogoutAll(token string) (respCode int, err error) {
+	req, err := http.NewRequest("POST", config.SetUrl("/api/v1/user/logout/all"), nil)
+	if err != nil {
+		return -1, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return -1, err
+	}
+
+	defer resp.Body.Close()
+
+	tokens.forget(token)
+
+	if resp.StatusCode != 200 {
+		return resp.StatusCode, errors.New("Logout failed with status: " + strconv.Itoa(resp.StatusCode))
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/model"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+type ViewClient interface {
+	ViewList(token string) ([]model.SavedViewResponse, error)
+}
+
+type viewClient struct {
+}
+
+func NewViewClient() *viewClient {
+	return &viewClient{}
+}
+
+func (v *viewClient) ViewList(token string) ([]model.SavedViewResponse, error) {
+	req, err := http.NewRequest("GET", config.SetUrl("/api/v1/view/list"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sendWithRefresh(token, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, errors.New("status code not 200")
+	}
+
+	var views []model.SavedViewResponse
+	err = json.Unmarshal(b, &views)
+	if err != nil {
+		return nil, err
+	}
+
+	return views, nil
+}
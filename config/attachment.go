@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// BlobStore returns the store attachment content is kept in, named by
+// APP_BLOB_STORE: "fs" (default, files below BlobDir) or "bbolt" (a bucket
+// in the file at BlobDBPath, for small files only).
+func BlobStore() string {
+	return getenv("APP_BLOB_STORE", "fs")
+}
+
+// BlobDir returns the directory of the fs blob store, APP_BLOB_DIR.
+func BlobDir() string {
+	return getenv("APP_BLOB_DIR", "attachments")
+}
+
+// BlobDBPath returns the file of the bbolt blob store, APP_BLOB_DB.
+func BlobDBPath() string {
+	return getenv("APP_BLOB_DB", "blobs.db")
+}
+
+// AttachmentMaxSize returns the largest file in bytes that may be attached,
+// APP_ATTACHMENT_MAX_SIZE, 10 MiB by default.
+func AttachmentMaxSize() int64 {
+	return getenvSize("APP_ATTACHMENT_MAX_SIZE", 10<<20)
+}
+
+// AttachmentQuota returns how many bytes of attachments a user may keep in
+// total, APP_ATTACHMENT_QUOTA, 100 MiB by default. Zero means no quota.
+func AttachmentQuota() int64 {
+	return getenvSize("APP_ATTACHMENT_QUOTA", 100<<20)
+}
+
+func getenvSize(key string, fallback int64) int64 {
+	size, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil || size < 0 {
+		return fallback
+	}
+	return size
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"os"
+	"strconv"
+)
+
+// DBDriver returns the storage backend named by APP_DB_DRIVER, either
+// "bbolt" (default, a local file.db) or "postgres".
+func DBDriver() string {
+	if driver := os.Getenv("APP_DB_DRIVER"); driver != "" {
+		return driver
+	}
+	return "bbolt"
+}
+
+// PostgresCredential reads the Postgres connection settings from the
+// environment, falling back to a local development database.
+func PostgresCredential() model.Credential {
+	port, err := strconv.Atoi(os.Getenv("APP_DB_PORT"))
+	if err != nil {
+		port = 5432
+	}
+
+	return model.Credential{
+		Host:         getenv("APP_DB_HOST", "localhost"),
+		Username:     getenv("APP_DB_USER", "postgres"),
+		Password:     os.Getenv("APP_DB_PASSWORD"),
+		DatabaseName: getenv("APP_DB_NAME", "task_tracker"),
+		Port:         port,
+		Schema:       getenv("APP_DB_SCHEMA", "public"),
+		SSLMode:      getenv("APP_DB_SSLMODE", "disable"),
+	}
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey is one HMAC secret used to sign session tokens. A key with a
+// RetiresAt in the future still verifies tokens but no longer signs them.
+type SigningKey struct {
+	ID        string    `json:"kid"`
+	Secret    []byte    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+	RetiresAt time.Time `json:"retires_at,omitempty"`
+}
+
+// KeySet is the content of the key file.
+type KeySet struct {
+	Current string       `json:"current"`
+	Keys    []SigningKey `json:"keys"`
+}
+
+// KeyRing holds the keys tokens are signed and verified with. Keys come from
+// JWT_SECRET when set, otherwise from the JSON key file named by JWT_KEYS_FILE
+// (default jwt-keys.json), which is created on first use. The file is
+// re-read when it changes so a rotation reaches a running server.
+type KeyRing struct {
+	mu        sync.Mutex
+	loaded    bool
+	static    bool
+	path      string
+	modTime   time.Time
+	checkedAt time.Time
+	set       KeySet
+}
+
+// JwtKeys is the key ring shared by token issuing and the Auth middleware.
+var JwtKeys = &KeyRing{}
+
+// NewKeyRing returns a key ring backed by the key file at path, ignoring
+// JWT_SECRET and JWT_KEYS_FILE.
+func NewKeyRing(path string) *KeyRing {
+	return &KeyRing{path: path}
+}
+
+// minSecretLen is the shortest secret a token is signed with.
+const minSecretLen = 32
+
+// keyReloadInterval bounds how often the key file is checked for changes.
+const keyReloadInterval = 30 * time.Second
+
+// KeysFilePath returns where the key file lives.
+func KeysFilePath() string {
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		return path
+	}
+	return "jwt-keys.json"
+}
+
+// Load loads the keys now rather than on the first token, so a server with
+// unusable keys fails at startup.
+func (r *KeyRing) Load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.ensureLoaded()
+}
+
+// Sign signs claims with the current key and records its ID in the kid header.
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.ensureLoaded(); err != nil {
+		return "", err
+	}
+
+	key, ok := r.set.find(r.set.Current)
+	if !ok {
+		return "", errors.New("no current signing key")
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	t.Header["kid"] = key.ID
+	return t.SignedString(key.Secret)
+}
+
+// Keyfunc resolves the verification key named by a token's kid header. It is
+// meant to be passed to jwt.Parse and friends.
+func (r *KeyRing) Keyfunc(t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no key id")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	key, ok := r.set.find(kid)
+	if !ok || key.retired(time.Now()) {
+		return nil, errors.New("unknown signing key")
+	}
+	return key.Secret, nil
+}
+
+// ensureLoaded loads the keys on first use and picks up changes to the key
+// file afterwards. Callers hold mu.
+func (r *KeyRing) ensureLoaded() error {
+	if r.loaded && r.static {
+		return nil
+	}
+
+	now := time.Now()
+	if r.loaded && now.Sub(r.checkedAt) < keyReloadInterval {
+		return nil
+	}
+	r.checkedAt = now
+
+	if !r.loaded && r.path == "" {
+		if secret := os.Getenv("JWT_SECRET"); secret != "" {
+			if len(secret) < minSecretLen {
+				return fmt.Errorf("JWT_SECRET is shorter than %d bytes", minSecretLen)
+			}
+			sum := sha256.Sum256([]byte(secret))
+			kid := "env-" + hex.EncodeToString(sum[:4])
+			r.set = KeySet{
+				Current: kid,
+				Keys:    []SigningKey{{ID: kid, Secret: []byte(secret)}},
+			}
+			r.loaded = true
+			r.static = true
+			return nil
+		}
+		r.path = KeysFilePath()
+	}
+
+	info, err := os.Stat(r.path)
+	if os.IsNotExist(err) {
+		if r.loaded {
+			// Keep serving the keys we have rather than logging everyone out
+			return nil
+		}
+		set, err := NewKeySet()
+		if err != nil {
+			return err
+		}
+		if err := SaveKeySet(r.path, set); err != nil {
+			return err
+		}
+		fmt.Printf("Generated new JWT signing key in %s\n", r.path)
+		info, err = os.Stat(r.path)
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if r.loaded && info.ModTime().Equal(r.modTime) {
+		return nil
+	}
+
+	set, err := LoadKeySet(r.path)
+	if err != nil {
+		if r.loaded {
+			fmt.Printf("Warning: keeping previous JWT keys, could not reload %s: %v\n", r.path, err)
+			return nil
+		}
+		return err
+	}
+
+	r.set = set
+	r.modTime = info.ModTime()
+	r.loaded = true
+	return nil
+}
+
+func (s KeySet) find(kid string) (SigningKey, bool) {
+	for _, key := range s.Keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+func (k SigningKey) retired(now time.Time) bool {
+	return !k.RetiresAt.IsZero() && !k.RetiresAt.After(now)
+}
+
+// NewSigningKey generates a random 256-bit key.
+func NewSigningKey() (SigningKey, error) {
+	secret := make([]byte, minSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return SigningKey{}, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return SigningKey{}, err
+	}
+
+	return SigningKey{
+		ID:        hex.EncodeToString(id),
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// NewKeySet returns a key set holding a single fresh key.
+func NewKeySet() (KeySet, error) {
+	key, err := NewSigningKey()
+	if err != nil {
+		return KeySet{}, err
+	}
+	return KeySet{Current: key.ID, Keys: []SigningKey{key}}, nil
+}
+
+// LoadKeySet reads and validates a key file.
+func LoadKeySet(path string) (KeySet, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return KeySet{}, fmt.Errorf("error reading key file: %v", err)
+	}
+
+	var set KeySet
+	if err := json.Unmarshal(b, &set); err != nil {
+		return KeySet{}, fmt.Errorf("error decoding key file: %v", err)
+	}
+
+	current, ok := set.find(set.Current)
+	if !ok {
+		return KeySet{}, fmt.Errorf("key file has no key %q", set.Current)
+	}
+	if len(current.Secret) < minSecretLen {
+		return KeySet{}, fmt.Errorf("current key %q is shorter than %d bytes", current.ID, minSecretLen)
+	}
+
+	return set, nil
+}
+
+// SaveKeySet writes the key file readable by the owner only. It writes to a
+// temporary file first so a running server never reads a partial file.
+func SaveKeySet(path string, set KeySet) error {
+	b, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".jwt-keys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// RotateKeySet makes a fresh key current. The previous current key keeps
+// verifying tokens until grace has passed, and keys whose grace period is
+// over are dropped.
+func RotateKeySet(set KeySet, grace time.Duration) (KeySet, SigningKey, error) {
+	key, err := NewSigningKey()
+	if err != nil {
+		return KeySet{}, SigningKey{}, err
+	}
+
+	now := time.Now()
+	rotated := KeySet{Current: key.ID}
+	for _, old := range set.Keys {
+		if old.retired(now) {
+			continue
+		}
+		if old.RetiresAt.IsZero() {
+			old.RetiresAt = now.Add(grace)
+		}
+		rotated.Keys = append(rotated.Keys, old)
+	}
+	rotated.Keys = append(rotated.Keys, key)
+
+	return rotated, key, nil
+}
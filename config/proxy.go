@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// TrustedProxies returns the addresses or CIDRs whose X-Forwarded-For header
+// is believed for the client IP of a session, APP_TRUSTED_PROXIES as a comma
+// separated list. By default only loopback is trusted, where the web front
+// end calls the API from with the IP of the browser.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("APP_TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if len(proxies) == 0 {
+		return []string{"127.0.0.1", "::1"}
+	}
+	return proxies
+}
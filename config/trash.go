@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// TrashRetention returns how long deleted tasks and categories stay in the
+// trash before they are purged, APP_TRASH_RETENTION as a Go duration, 30 days
+// by default. Zero keeps them until the trash is emptied by hand.
+func TrashRetention() time.Duration {
+	return getenvDuration("APP_TRASH_RETENTION", 30*24*time.Hour)
+}
+
+// TrashPurgeInterval returns how often expired trash is purged,
+// APP_TRASH_PURGE_INTERVAL, hourly by default.
+func TrashPurgeInterval() time.Duration {
+	interval := getenvDuration("APP_TRASH_PURGE_INTERVAL", time.Hour)
+	if interval <= 0 {
+		return time.Hour
+	}
+	return interval
+}
+
+func getenvDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d < 0 {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,59 @@
+// Package blob stores the content of attachments. Records describing a file
+// live in the db.Store, the bytes live in a blob Store under a key the
+// record keeps, so large files stay out of the database.
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"a21hc3NpZ25tZW50/config"
+)
+
+var (
+	// ErrNotFound is returned by Open for a key that has no blob.
+	ErrNotFound = errors.New("blob not found")
+	// ErrTooLarge is returned by Put for content over the store's size limit.
+	ErrTooLarge = errors.New("blob too large")
+	// ErrInvalidKey is returned for a key that could escape the store.
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store keeps blobs by key. Keys are slash-separated, the first segment
+// groups the blobs of one user so List can find them. Put replaces an
+// existing blob, Delete of a missing key is not an error.
+type Store interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	List(prefix string) ([]string, error)
+	Close() error
+}
+
+// New returns the blob store named by APP_BLOB_STORE, "fs" (default) or
+// "bbolt".
+func New() (Store, error) {
+	switch driver := config.BlobStore(); driver {
+	case "fs":
+		return NewFS(config.BlobDir())
+	case "bbolt":
+		return NewBolt(config.BlobDBPath())
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", driver)
+	}
+}
+
+// checkKey rejects empty keys and ones with empty, "." or ".." segments.
+func checkKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
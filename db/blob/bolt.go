@@ -0,0 +1,102 @@
+package blob
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// BoltMaxSize is the largest blob a Bolt store keeps. Every blob is written
+// in one transaction and read into memory, so it is meant for small files
+// such as screenshots only.
+const BoltMaxSize = 1 << 20
+
+var blobsBucket = []byte("Blobs")
+
+// Bolt stores blobs as values of a bucket in a bbolt file of its own, so a
+// deployment can keep everything in files without an attachments directory.
+type Bolt struct {
+	db *bbolt.DB
+}
+
+// NewBolt opens or creates the bbolt file at path.
+func NewBolt(path string) (*Bolt, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening blob database: %v", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(blobsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+// Put fails with ErrTooLarge for content over BoltMaxSize.
+func (s *Bolt) Put(key string, r io.Reader) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	content, err := io.ReadAll(io.LimitReader(r, BoltMaxSize+1))
+	if err != nil {
+		return err
+	}
+	if len(content) > BoltMaxSize {
+		return ErrTooLarge
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(blobsBucket).Put([]byte(key), content)
+	})
+}
+
+func (s *Bolt) Open(key string) (io.ReadCloser, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	var content []byte
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(blobsBucket).Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		// v is only valid inside the transaction
+		content = append([]byte(nil), v...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (s *Bolt) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(blobsBucket).Delete([]byte(key))
+	})
+}
+
+func (s *Bolt) List(prefix string) ([]string, error) {
+	var keys []string
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(blobsBucket).Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (s *Bolt) Close() error {
+	return s.db.Close()
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"time"
 
+	"a21hc3NpZ25tZW50/db"
 	"a21hc3NpZ25tZW50/model"
 
 	"go.etcd.io/bbolt"
@@ -18,7 +19,8 @@ type Data struct {
 	DB *bbolt.DB
 }
 
-func InitDB() (*Data, error) {
+// OpenDB opens the file named by APP_DB_PATH without migrating it.
+func OpenDB() (*bbolt.DB, error) {
 	dbPath := os.Getenv("APP_DB_PATH")
 	if dbPath == "" {
 		dbPath = "file.db"
@@ -28,865 +30,2200 @@ func InitDB() (*Data, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %v", err)
 	}
+	return db, nil
+}
 
-	err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("Tasks"))
-		if err != nil {
-			return fmt.Errorf("create tasks bucket: %v", err)
-		}
-
-		categoriesBucket, err := tx.CreateBucketIfNotExists([]byte("Categories"))
-		if err != nil {
-			return fmt.Errorf("create categories bucket: %v", err)
-		}
+func InitDB() (*Data, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, err
+	}
 
-		// Add default categories if bucket is empty
-		catCount := 0
-		categoriesBucket.ForEach(func(_, _ []byte) error {
-			catCount++
-			return nil
-		})
+	applied, err := Migrate(db, false)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, m := range applied {
+		fmt.Printf("Applied migration %d (%s): %s\n", m.Version, m.Name, m.Summary)
+	}
 
-		if catCount == 0 {
+	err = db.View(func(tx *bbolt.Tx) error {
+		if k, _ := tx.Bucket([]byte("Categories")).Cursor().First(); k == nil {
 			// No default system categories needed anymore
 			// Each user will get their own categories when they register
 			fmt.Println("DEBUG: No default system categories created, users will get individual categories")
 		}
-
-		_, err = tx.CreateBucketIfNotExists([]byte("Users"))
-		if err != nil {
-			return fmt.Errorf("create users bucket: %v", err)
-		}
-
-		_, err = tx.CreateBucketIfNotExists([]byte("Sessions"))
-		if err != nil {
-			return fmt.Errorf("create sessions bucket: %v", err)
-		}
 		return nil
 	})
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return &Data{DB: db}, nil
 }
 
-func (data *Data) StoreTask(task model.Task) error {
-	// Check if we need to generate an ID
-	if task.ID <= 0 {
-		return data.DB.Update(func(tx *bbolt.Tx) error {
-			b := tx.Bucket([]byte("Tasks"))
-
-			// Find the highest existing ID
-			maxID := 0
-			err := b.ForEach(func(k, v []byte) error {
-				id := btoi(k)
-				if id > maxID {
-					maxID = id
-				}
-				return nil
-			})
-			if err != nil {
-				return err
-			}
+// dataBuckets hold the records the index buckets point to.
+var dataBuckets = []string{"Tasks", "Categories", "Users", "Sessions", "Tags", "Comments", "Attachments", "TimeEntries", "Views", "Trash"}
 
-			// Assign the next ID
-			task.ID = maxID + 1
+// userBuckets hold one record per user, keyed by user ID. Later migrations
+// create them.
+var userBuckets = []string{"Workflows", "Dependencies"}
 
-			// Marshal and store
-			taskJSON, err := json.Marshal(task)
-			if err != nil {
-				return err
-			}
-			return b.Put([]byte(fmt.Sprintf("%d", task.ID)), taskJSON)
-		})
+func createBuckets(tx *bbolt.Tx) error {
+	for _, name := range dataBuckets {
+		if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return fmt.Errorf("create %s bucket: %v", name, err)
+		}
 	}
-
-	// If already has an ID
-	taskJSON, err := json.Marshal(task)
-	if err != nil {
-		return err
+	for _, name := range indexBuckets {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return fmt.Errorf("create %s bucket: %v", name, err)
+		}
 	}
-	return data.DB.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Tasks"))
-		return b.Put([]byte(fmt.Sprintf("%d", task.ID)), taskJSON)
-	})
+	return nil
 }
 
-func (data *Data) StoreCategory(category model.Category) error {
-	// Check if we need to generate an ID
-	if category.ID <= 0 {
-		return data.DB.Update(func(tx *bbolt.Tx) error {
-			b := tx.Bucket([]byte("Categories"))
-
-			// Find the highest existing ID
-			maxID := 0
-			err := b.ForEach(func(k, v []byte) error {
-				id := btoi(k)
-				if id > maxID {
-					maxID = id
-				}
-				return nil
-			})
-			if err != nil {
-				return err
-			}
+// reindex rewrites Tasks, Categories and Users under big-endian keys and
+// rebuilds every index bucket from the records. Files written before it use
+// decimal string keys and have no indexes.
+func reindex(tx *bbolt.Tx) (string, error) {
+	for _, name := range indexBuckets {
+		if err := tx.DeleteBucket(name); err != nil && err != bbolt.ErrBucketNotFound {
+			return "", err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+	}
 
-			// Assign the next ID
-			category.ID = maxID + 1
+	counts := map[string]int{}
+	rekey := func(name string, put func(k, v []byte) error) error {
+		var keys, values [][]byte
+		err := tx.Bucket([]byte(name)).ForEach(func(k, v []byte) error {
+			keys = append(keys, append([]byte(nil), k...))
+			values = append(values, append([]byte(nil), v...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 
-			// Marshal and store
-			categoryJSON, err := json.Marshal(category)
-			if err != nil {
+		if err := tx.DeleteBucket([]byte(name)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket([]byte(name)); err != nil {
+			return err
+		}
+
+		for i, v := range values {
+			if err := put(keys[i], v); err != nil {
 				return err
 			}
-			return b.Put([]byte(fmt.Sprintf("%d", category.ID)), categoryJSON)
-		})
+		}
+		counts[name] = len(values)
+		return nil
 	}
 
-	// If already has an ID
-	categoryJSON, err := json.Marshal(category)
+	err := rekey("Users", func(k, v []byte) error {
+		var user model.User
+		if err := json.Unmarshal(v, &user); err != nil {
+			return nil // Skip badly formatted user records
+		}
+		if user.ID == 0 {
+			user.ID = legacyKey(k)
+		}
+		return putUser(tx, user)
+	})
 	if err != nil {
-		return err
+		return "", err
 	}
-	return data.DB.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Categories"))
-		return b.Put([]byte(fmt.Sprintf("%d", category.ID)), categoryJSON)
-	})
-}
-
-func (data *Data) UpdateTask(id int, task model.Task) error {
-	return data.StoreTask(task) // Reuse StoreTask as it will replace the existing entry
-}
-
-func (data *Data) UpdateCategory(id int, category model.Category) error {
-	return data.StoreCategory(category) // Reuse StoreCategory as it will replace the existing entry
-}
-
-func (data *Data) DeleteTask(id int) error {
-	return data.DB.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Tasks"))
-		return b.Delete([]byte(fmt.Sprintf("%d", id)))
-	})
-}
-
-func (data *Data) DeleteCategory(id int) error {
-	return data.DB.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Categories"))
-		return b.Delete([]byte(fmt.Sprintf("%d", id)))
-	})
-}
 
-func (data *Data) GetTaskByID(id int) (*model.Task, error) {
-	var task model.Task
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Tasks"))
-		v := b.Get([]byte(fmt.Sprintf("%d", id)))
-		if v == nil {
-			return fmt.Errorf("record not found")
+	err = rekey("Categories", func(k, v []byte) error {
+		var category model.Category
+		if err := json.Unmarshal(v, &category); err != nil {
+			return nil // Skip badly formatted category records
+		}
+		if category.ID == 0 {
+			category.ID = legacyKey(k)
 		}
-		return json.Unmarshal(v, &task)
+		return putCategory(tx, category)
 	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return &task, nil
-}
 
-func (data *Data) GetCategoryByID(id int) (*model.Category, error) {
-	var category model.Category
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Categories"))
-		v := b.Get([]byte(fmt.Sprintf("%d", id)))
-		if v == nil {
-			return fmt.Errorf("record not found")
+	err = rekey("Tasks", func(k, v []byte) error {
+		task, err := decodeTask(v)
+		if err != nil {
+			return nil // Skip badly formatted task records
+		}
+		if task.ID == 0 {
+			task.ID = legacyKey(k)
 		}
-		return json.Unmarshal(v, &category)
+		return putTask(tx, task)
 	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return &category, nil
-}
 
-func (data *Data) GetTasks() ([]model.Task, error) {
-	var tasks []model.Task
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Tasks"))
-		return b.ForEach(func(k, v []byte) error {
-			var task model.Task
-			if err := json.Unmarshal(v, &task); err != nil {
-				log.Println("Error unmarshaling task:", err)
-				return nil // Continue despite error
-			}
-			tasks = append(tasks, task)
-			return nil
-		})
+	// Sessions keep their token keys, only the indexes are new
+	var sessions []model.Session
+	err = tx.Bucket([]byte("Sessions")).ForEach(func(_, v []byte) error {
+		var s model.Session
+		if err := json.Unmarshal(v, &s); err == nil {
+			sessions = append(sessions, s)
+		}
+		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error fetching tasks: %v", err)
+		return "", err
 	}
-	return tasks, nil
+	for _, s := range sessions {
+		if err := putSession(tx, s); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("rekeyed %d users, %d categories, %d tasks and indexed %d sessions",
+		counts["Users"], counts["Categories"], counts["Tasks"], len(sessions)), nil
 }
 
-func (data *Data) GetTasksByUserID(userID int) ([]model.Task, error) {
-	var tasks []model.Task
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Tasks"))
-		return b.ForEach(func(k, v []byte) error {
-			var task model.Task
-			if err := json.Unmarshal(v, &task); err != nil {
-				log.Println("Error unmarshaling task:", err)
-				return nil // Continue despite error
-			}
-			if task.UserID == userID {
-				tasks = append(tasks, task)
-			}
-			return nil
-		})
-	})
+// legacyKey parses the decimal string keys used before big-endian keys.
+func legacyKey(b []byte) int {
+	i, err := strconv.Atoi(string(b))
 	if err != nil {
-		return nil, fmt.Errorf("error fetching tasks: %v", err)
+		return 0
 	}
-	return tasks, nil
+	return i
 }
 
-func (data *Data) GetCategories() ([]model.Category, error) {
-	var categories []model.Category
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Categories"))
-		return b.ForEach(func(k, v []byte) error {
-			var category model.Category
-			if err := json.Unmarshal(v, &category); err != nil {
-				log.Println("Error unmarshaling category:", err)
-				return nil // Continue despite error
+// putTask writes task under its ID and moves its index entries along.
+func putTask(tx *bbolt.Tx, task model.Task) error {
+	b := tx.Bucket([]byte("Tasks"))
+	key := itob(task.ID)
+
+	if old := b.Get(key); old != nil {
+		var prev model.Task
+		if err := json.Unmarshal(old, &prev); err == nil {
+			if err := unindexTask(tx, prev); err != nil {
+				return err
 			}
-			categories = append(categories, category)
-			return nil
-		})
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error fetching categories: %v", err)
+		}
 	}
-	return categories, nil
-}
 
-// GetCategoriesByUserID returns categories for specific user only
-func (data *Data) GetCategoriesByUserID(userID int) ([]model.Category, error) {
-	var categories []model.Category
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Categories"))
-		return b.ForEach(func(k, v []byte) error {
-			var category model.Category
-			if err := json.Unmarshal(v, &category); err != nil {
-				log.Println("Error unmarshaling category:", err)
-				return nil // Continue despite error
-			}
-			// Only include categories that belong to this specific user
-			if category.UserID == userID {
-				categories = append(categories, category)
-			}
-			return nil
-		})
-	})
+	taskJSON, err := json.Marshal(task)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching categories for user %d: %v", userID, err)
+		return err
+	}
+	if err := b.Put(key, taskJSON); err != nil {
+		return err
+	}
+	if err := bumpSequence(b, task.ID); err != nil {
+		return err
 	}
-	return categories, nil
-}
 
-func (data *Data) Reset() error {
-	return data.DB.Update(func(tx *bbolt.Tx) error {
-		if err := tx.DeleteBucket([]byte("Tasks")); err != nil {
-			return err
-		}
-		if err := tx.DeleteBucket([]byte("Categories")); err != nil {
+	if err := indexAdd(tx, tasksByUser, itob(task.UserID), key); err != nil {
+		return err
+	}
+	for _, tagID := range task.TagIDs {
+		if err := indexAdd(tx, tasksByTag, itob(tagID), key); err != nil {
 			return err
 		}
-		if err := tx.DeleteBucket([]byte("Users")); err != nil {
+	}
+	if err := indexAdd(tx, tasksByCategory, itob(task.CategoryID), key); err != nil {
+		return err
+	}
+	if err := indexSort(tx, task); err != nil {
+		return err
+	}
+	return indexSearch(tx, task)
+}
+
+func unindexTask(tx *bbolt.Tx, task model.Task) error {
+	key := itob(task.ID)
+	if err := indexRemove(tx, tasksByUser, itob(task.UserID), key); err != nil {
+		return err
+	}
+	for _, tagID := range task.TagIDs {
+		if err := indexRemove(tx, tasksByTag, itob(tagID), key); err != nil {
 			return err
 		}
-
-		return nil
-	})
+	}
+	if err := indexRemove(tx, tasksByCategory, itob(task.CategoryID), key); err != nil {
+		return err
+	}
+	return unindexSort(tx, task)
 }
 
-func (data *Data) CloseDB() error {
-	return data.DB.Close()
+// checkCategoryName fails with model.ErrCategoryExists when another category
+// of the user of category has its name.
+func checkCategoryName(tx *bbolt.Tx, category model.Category) error {
+	for _, k := range indexKeys(tx, categoriesByUser, itob(category.UserID)) {
+		other, err := getCategory(tx, int(binary.BigEndian.Uint64(k)))
+		if err == nil && other.ID != category.ID && model.SameCategoryName(other.Name, category.Name) {
+			return model.ErrCategoryExists
+		}
+	}
+	return nil
 }
 
-func (data *Data) GetTaskListByCategory(categoryID int) ([]model.TaskCategory, error) {
-	var taskCategories []model.TaskCategory
-	category, err := data.GetCategoryByID(categoryID)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching category: %v", err)
+// putCategory writes category under its ID and moves its index entry along.
+// A rename indexes its tasks for search again.
+func putCategory(tx *bbolt.Tx, category model.Category) error {
+	b := tx.Bucket([]byte("Categories"))
+	key := itob(category.ID)
+
+	renamed := false
+	if old := b.Get(key); old != nil {
+		var prev model.Category
+		if err := json.Unmarshal(old, &prev); err == nil {
+			if err := indexRemove(tx, categoriesByUser, itob(prev.UserID), key); err != nil {
+				return err
+			}
+			renamed = prev.Name != category.Name
+		}
 	}
 
-	err = data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Tasks"))
-		if b == nil {
-			return fmt.Errorf("tasks bucket not found")
-		}
-		return b.ForEach(func(k, v []byte) error {
-			var task model.Task
-			if err := json.Unmarshal(v, &task); err != nil {
-				log.Printf("Error unmarshaling task: %v", err)
-				return nil // Continue processing next item in case of error
-			}
-			if task.CategoryID == categoryID {
-				taskCategories = append(taskCategories, model.TaskCategory{
-					ID:       task.ID,
-					Title:    task.Title,
-					Category: category.Name,
-				})
-			}
-			return nil
-		})
-	})
+	categoryJSON, err := json.Marshal(category)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching tasks for category %d: %v", categoryID, err)
+		return err
 	}
-	if len(taskCategories) == 0 {
-		return nil, fmt.Errorf("no tasks found for category ID: %d", categoryID)
+	if err := b.Put(key, categoryJSON); err != nil {
+		return err
 	}
-	return taskCategories, nil
-}
+	if err := bumpSequence(b, category.ID); err != nil {
+		return err
+	}
+
+	if err := indexAdd(tx, categoriesByUser, itob(category.UserID), key); err != nil {
+		return err
+	}
+	if renamed {
+		for _, task := range tasksByKeys(tx, indexKeys(tx, tasksByCategory, key)) {
+			if err := indexSearch(tx, task); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// putUser writes user under its ID and records its email.
+func putUser(tx *bbolt.Tx, user model.User) error {
+	b := tx.Bucket([]byte("Users"))
+	key := itob(user.ID)
+
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("error marshaling user: %v", err)
+	}
+	if err := b.Put(key, userJSON); err != nil {
+		return err
+	}
+	if err := bumpSequence(b, user.ID); err != nil {
+		return err
+	}
+
+	return tx.Bucket(usersByEmail).Put([]byte(user.Email), key)
+}
+
+// checkTaskCategory enforces that task points at a category its user may use.
+func checkTaskCategory(tx *bbolt.Tx, task model.Task) error {
+	v := tx.Bucket([]byte("Categories")).Get(itob(task.CategoryID))
+	if v == nil {
+		return model.ErrCategoryNotFound
+	}
+
+	var category model.Category
+	if err := json.Unmarshal(v, &category); err != nil {
+		return err
+	}
+	if !model.CategoryAllows(category, task.UserID) {
+		return model.ErrCategoryNotOwned
+	}
+	return nil
+}
+
+// checkTaskTags enforces that every tag of task exists and belongs to its
+// user.
+func checkTaskTags(tx *bbolt.Tx, task model.Task) error {
+	for _, id := range task.TagIDs {
+		tag, err := getTag(tx, id)
+		if err == model.ErrRecordNotFound {
+			return model.ErrTagNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := model.CheckTaskTag(task, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subtasksOf lists the subtasks of a task, found through the tasks of its user.
+func subtasksOf(tx *bbolt.Tx, task model.Task) []model.Task {
+	var subtasks []model.Task
+	for _, t := range tasksByKeys(tx, indexKeys(tx, tasksByUser, itob(task.UserID))) {
+		if t.ParentID == task.ID {
+			subtasks = append(subtasks, t)
+		}
+	}
+	return subtasks
+}
+
+// checkTaskParent enforces that a subtask points at a top-level task of its
+// user and has no subtasks of its own. A task without an ID yet has none.
+func checkTaskParent(tx *bbolt.Tx, task model.Task) error {
+	if task.ParentID == 0 {
+		return nil
+	}
+	parent, err := getTask(tx, task.ParentID)
+	if err == model.ErrRecordNotFound {
+		return model.ErrParentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return model.CheckParent(task, parent, task.ID > 0 && len(subtasksOf(tx, task)) > 0)
+}
+
+// checkTaskRefs enforces the category, parent and tags of task.
+func checkTaskRefs(tx *bbolt.Tx, task model.Task) error {
+	if err := checkTaskCategory(tx, task); err != nil {
+		return err
+	}
+	if err := checkTaskParent(tx, task); err != nil {
+		return err
+	}
+	return checkTaskTags(tx, task)
+}
+
+func (data *Data) StoreTask(task model.Task) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		if err := checkTaskRefs(tx, task); err != nil {
+			return err
+		}
+		_, err := storeTask(tx, task)
+		return err
+	})
+}
+
+// storeTask writes a checked task and returns it as stored.
+func storeTask(tx *bbolt.Tx, task model.Task) (model.Task, error) {
+	// Check if we need to generate an ID
+	if task.ID <= 0 {
+		id, err := tx.Bucket([]byte("Tasks")).NextSequence()
+		if err != nil {
+			return task, err
+		}
+		task.ID = int(id)
+	}
+
+	// Storing over an existing task replaces it without a version check
+	task.Version = 1
+	if prev, err := getTask(tx, task.ID); err == nil {
+		task.Version = prev.Version + 1
+	}
+	task.UpdatedAt = db.Now()
+
+	return task, putTask(tx, task)
+}
+
+func (data *Data) StoreCategory(category model.Category) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		// Check if we need to generate an ID
+		if category.ID <= 0 {
+			id, err := tx.Bucket([]byte("Categories")).NextSequence()
+			if err != nil {
+				return err
+			}
+			category.ID = int(id)
+		}
+		if err := checkCategoryName(tx, category); err != nil {
+			return err
+		}
+
+		// Storing over an existing category replaces it without a version check
+		category.Version = 1
+		if prev, err := getCategory(tx, category.ID); err == nil {
+			category.Version = prev.Version + 1
+		}
+		category.UpdatedAt = db.Now()
+
+		return putCategory(tx, category)
+	})
+}
+
+// UpdateTask replaces the task stored under id. A non-zero task.Version must
+// match the stored one, so a writer working from a stale read fails instead
+// of overwriting the change it missed.
+func (data *Data) UpdateTask(id int, task model.Task) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		task.ID = id
+		prev, err := checkTaskUpdate(tx, task)
+		if err != nil {
+			return err
+		}
+		_, err = updateTask(tx, prev, task)
+		return err
+	})
+}
+
+// checkTaskUpdate checks task as the replacement of the stored task with its
+// ID, which it returns.
+func checkTaskUpdate(tx *bbolt.Tx, task model.Task) (model.Task, error) {
+	prev, err := getTask(tx, task.ID)
+	if err != nil {
+		return prev, err
+	}
+	if task.Version != 0 && task.Version != prev.Version {
+		return prev, model.ErrVersionConflict
+	}
+	return prev, checkTaskRefs(tx, task)
+}
+
+// updateTask writes a checked task over prev and returns it as stored.
+func updateTask(tx *bbolt.Tx, prev, task model.Task) (model.Task, error) {
+	task.Version = prev.Version + 1
+	task.UpdatedAt = db.Now()
+	return task, putTask(tx, task)
+}
+
+// UpdateCategory replaces the category stored under id, with the same version
+// check as UpdateTask.
+func (data *Data) UpdateCategory(id int, category model.Category) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		prev, err := getCategory(tx, id)
+		if err != nil {
+			return err
+		}
+		if category.Version != 0 && category.Version != prev.Version {
+			return model.ErrVersionConflict
+		}
+
+		category.ID = id
+		if err := checkCategoryName(tx, category); err != nil {
+			return err
+		}
+		category.Version = prev.Version + 1
+		category.UpdatedAt = db.Now()
+		return putCategory(tx, category)
+	})
+}
+
+func (data *Data) DeleteTask(id int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		return deleteTask(tx, id)
+	})
+}
+
+// WriteTasks checks every write before making it, so a refused write leaves
+// nothing behind and the transaction can go on with the next one.
+func (data *Data) WriteTasks(writes []model.TaskWrite, atomic bool) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		for i := range writes {
+			w := &writes[i]
+			if w.Err != nil {
+				continue
+			}
+
+			var prev model.Task
+			switch w.Op {
+			case model.TaskOpCreate:
+				w.Err = checkTaskRefs(tx, w.Task)
+			case model.TaskOpDelete:
+				if prev, w.Err = getTask(tx, w.Task.ID); w.Err == nil && w.Task.Version != 0 && w.Task.Version != prev.Version {
+					w.Err = model.ErrVersionConflict
+				}
+			default:
+				prev, w.Err = checkTaskUpdate(tx, w.Task)
+			}
+			if w.Err != nil {
+				if atomic {
+					return model.ErrBulkAborted
+				}
+				continue
+			}
+
+			var err error
+			switch w.Op {
+			case model.TaskOpCreate:
+				w.Task, err = storeTask(tx, w.Task)
+			case model.TaskOpDelete:
+				var entry model.TrashEntry
+				entry, err = trashTask(tx, prev)
+				w.Task, w.TrashID = prev, entry.ID
+			default:
+				w.Task, err = updateTask(tx, prev, w.Task)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteTask removes a task together with its subtasks, their comments, time
+// entries and attachment records.
+func deleteTask(tx *bbolt.Tx, id int) error {
+	b := tx.Bucket([]byte("Tasks"))
+	v := b.Get(itob(id))
+	if v == nil {
+		return nil
+	}
+
+	var task model.Task
+	if err := json.Unmarshal(v, &task); err == nil {
+		for _, subtask := range subtasksOf(tx, task) {
+			if err := deleteTask(tx, subtask.ID); err != nil {
+				return err
+			}
+		}
+		if err := unindexTask(tx, task); err != nil {
+			return err
+		}
+		if err := unindexSearch(tx, id); err != nil {
+			return err
+		}
+		if err := deleteComments(tx, id); err != nil {
+			return err
+		}
+		if err := deleteAttachments(tx, id); err != nil {
+			return err
+		}
+		if err := deleteTimeEntries(tx, id); err != nil {
+			return err
+		}
+		err := updateDependencies(tx, task.UserID, func(deps []model.Dependency) []model.Dependency {
+			return withoutDependencies(deps, func(d model.Dependency) bool {
+				return d.TaskID == id || d.BlockedByID == id
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return b.Delete(itob(id))
+}
+
+// DeleteCategory removes a category and, depending on opts, rejects the delete
+// while it has tasks, deletes them too or moves them to another category. The
+// tasks and the category change in the same transaction.
+func (data *Data) DeleteCategory(id int, opts model.CategoryDelete) error {
+	if err := opts.Validate(id); err != nil {
+		return err
+	}
+
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte("Categories")).Get(itob(id)) == nil {
+			return nil
+		}
+
+		tasks := tasksByKeys(tx, indexKeys(tx, tasksByCategory, itob(id)))
+		if len(tasks) > 0 {
+			switch opts.Mode {
+			case model.CategoryDeleteCascade:
+				for _, task := range tasks {
+					if err := deleteTask(tx, task.ID); err != nil {
+						return err
+					}
+				}
+			case model.CategoryDeleteReassign:
+				if err := reassignTasks(tx, tasks, opts.ReassignTo); err != nil {
+					return err
+				}
+			default:
+				return model.ErrCategoryNotEmpty
+			}
+		}
+
+		return deleteCategory(tx, id)
+	})
+}
+
+// reassignTasks moves tasks to the category with id to.
+func reassignTasks(tx *bbolt.Tx, tasks []model.Task, to int) error {
+	for _, task := range tasks {
+		task.CategoryID = to
+		if err := checkTaskCategory(tx, task); err != nil {
+			return err
+		}
+		task.Version++
+		task.UpdatedAt = db.Now()
+		if err := putTask(tx, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteCategory(tx *bbolt.Tx, id int) error {
+	b := tx.Bucket([]byte("Categories"))
+	v := b.Get(itob(id))
+	if v == nil {
+		return nil
+	}
+
+	var category model.Category
+	if err := json.Unmarshal(v, &category); err == nil {
+		if err := indexRemove(tx, categoriesByUser, itob(category.UserID), itob(id)); err != nil {
+			return err
+		}
+	}
+	return b.Delete(itob(id))
+}
+
+func getTask(tx *bbolt.Tx, id int) (model.Task, error) {
+	var task model.Task
+	v := tx.Bucket([]byte("Tasks")).Get(itob(id))
+	if v == nil {
+		return task, model.ErrRecordNotFound
+	}
+	err := json.Unmarshal(v, &task)
+	return task, err
+}
+
+func getCategory(tx *bbolt.Tx, id int) (model.Category, error) {
+	var category model.Category
+	v := tx.Bucket([]byte("Categories")).Get(itob(id))
+	if v == nil {
+		return category, model.ErrRecordNotFound
+	}
+	err := json.Unmarshal(v, &category)
+	return category, err
+}
+
+func (data *Data) GetTaskByID(id int) (*model.Task, error) {
+	var task model.Task
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		task, err = getTask(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (data *Data) GetCategoryByID(id int) (*model.Category, error) {
+	var category model.Category
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		category, err = getCategory(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (data *Data) GetTasks() ([]model.Task, error) {
+	var tasks []model.Task
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Tasks"))
+		return b.ForEach(func(k, v []byte) error {
+			var task model.Task
+			if err := json.Unmarshal(v, &task); err != nil {
+				log.Println("Error unmarshaling task:", err)
+				return nil // Continue despite error
+			}
+			tasks = append(tasks, task)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tasks: %v", err)
+	}
+	return tasks, nil
+}
+
+// tasksByKeys loads the tasks stored under keys, in the order given.
+func tasksByKeys(tx *bbolt.Tx, keys [][]byte) []model.Task {
+	b := tx.Bucket([]byte("Tasks"))
+
+	var tasks []model.Task
+	for _, k := range keys {
+		v := b.Get(k)
+		if v == nil {
+			continue
+		}
+		var task model.Task
+		if err := json.Unmarshal(v, &task); err != nil {
+			log.Println("Error unmarshaling task:", err)
+			continue // Continue despite error
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+func (data *Data) GetTasksByUserID(userID int) ([]model.Task, error) {
+	var tasks []model.Task
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		tasks = tasksByKeys(tx, indexKeys(tx, tasksByUser, itob(userID)))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tasks: %v", err)
+	}
+	return tasks, nil
+}
+
+func (data *Data) GetCategories() ([]model.Category, error) {
+	var categories []model.Category
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Categories"))
+		return b.ForEach(func(k, v []byte) error {
+			var category model.Category
+			if err := json.Unmarshal(v, &category); err != nil {
+				log.Println("Error unmarshaling category:", err)
+				return nil // Continue despite error
+			}
+			categories = append(categories, category)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching categories: %v", err)
+	}
+	return categories, nil
+}
+
+// GetCategoriesByUserID returns categories for specific user only
+func (data *Data) GetCategoriesByUserID(userID int) ([]model.Category, error) {
+	var categories []model.Category
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Categories"))
+		for _, k := range indexKeys(tx, categoriesByUser, itob(userID)) {
+			v := b.Get(k)
+			if v == nil {
+				continue
+			}
+			var category model.Category
+			if err := json.Unmarshal(v, &category); err != nil {
+				log.Println("Error unmarshaling category:", err)
+				continue // Continue despite error
+			}
+			categories = append(categories, category)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching categories for user %d: %v", userID, err)
+	}
+	return categories, nil
+}
+
+// Reset drops every record and index and leaves empty buckets behind.
+func (data *Data) Reset() error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		for _, name := range dataBuckets {
+			if err := tx.DeleteBucket([]byte(name)); err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		for _, name := range indexBuckets {
+			if err := tx.DeleteBucket(name); err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		for _, name := range userBuckets {
+			if err := tx.DeleteBucket([]byte(name)); err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		return createBuckets(tx)
+	})
+}
+
+func (data *Data) CloseDB() error {
+	return data.DB.Close()
+}
+
+func (data *Data) GetTaskListByCategory(categoryID int) ([]model.TaskCategory, error) {
+	var taskCategories []model.TaskCategory
+	category, err := data.GetCategoryByID(categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching category: %v", err)
+	}
+
+	err = data.DB.View(func(tx *bbolt.Tx) error {
+		for _, task := range tasksByKeys(tx, indexKeys(tx, tasksByCategory, itob(categoryID))) {
+			taskCategories = append(taskCategories, model.TaskCategory{
+				ID:       task.ID,
+				Title:    task.Title,
+				Category: category.Name,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tasks for category %d: %v", categoryID, err)
+	}
+	if len(taskCategories) == 0 {
+		return nil, fmt.Errorf("no tasks found for category ID: %d", categoryID)
+	}
+	return taskCategories, nil
+}
+
+// GetTaskListByCategoryAndUser returns tasks filtered by both category and user
+func (data *Data) GetTaskListByCategoryAndUser(categoryID, userID int) ([]model.TaskCategory, error) {
+	var taskCategories []model.TaskCategory
+	category, err := data.GetCategoryByID(categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching category: %v", err)
+	}
+
+	err = data.DB.View(func(tx *bbolt.Tx) error {
+		for _, task := range tasksByKeys(tx, indexKeys(tx, tasksByCategory, itob(categoryID))) {
+			// Filter by both category and user
+			if task.UserID == userID {
+				taskCategories = append(taskCategories, model.TaskCategory{
+					ID:       task.ID,
+					Title:    task.Title,
+					Category: category.Name,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tasks for category %d and user %d: %v", categoryID, userID, err)
+	}
+	return taskCategories, nil
+}
+
+func (data *Data) GetUserByEmail(email string) (model.User, error) {
+	var user model.User
+
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		key := tx.Bucket(usersByEmail).Get([]byte(email))
+		if key == nil {
+			return nil // Not found, user stays empty
+		}
+
+		v := tx.Bucket([]byte("Users")).Get(key)
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &user)
+	})
+
+	if err != nil {
+		return model.User{}, err // Return the error if the transaction failed
+	}
+	return user, nil // Return the found user, or an empty User struct if not found
+}
+
+func (data *Data) CreateUser(user model.User) (model.User, error) {
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		usersBucket := tx.Bucket([]byte("Users"))
+		if usersBucket == nil {
+			return fmt.Errorf("users bucket not found")
+		}
+
+		// Cek email sudah ada atau belum
+		if tx.Bucket(usersByEmail).Get([]byte(user.Email)) != nil {
+			return fmt.Errorf("email already exists")
+		}
+
+		id, err := usersBucket.NextSequence()
+		if err != nil {
+			return fmt.Errorf("error allocating user ID: %v", err)
+		}
+		user.ID = int(id)
+
+		return putUser(tx, user)
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+
+	// Create default categories for the new user
+	err = data.CreateDefaultCategoriesForUser(user.ID)
+	if err != nil {
+		// Log error but don't fail user creation
+		fmt.Printf("Warning: Failed to create default categories for user %d: %v\n", user.ID, err)
+	}
+
+	return user, nil
+}
+
+func (data *Data) UpdateUser(user model.User) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		v := tx.Bucket([]byte("Users")).Get(itob(user.ID))
+		if v == nil {
+			return model.ErrRecordNotFound
+		}
+
+		var old model.User
+		if err := json.Unmarshal(v, &old); err != nil {
+			return err
+		}
+		if old.Email != user.Email {
+			if tx.Bucket(usersByEmail).Get([]byte(user.Email)) != nil {
+				return fmt.Errorf("email already exists")
+			}
+			if err := tx.Bucket(usersByEmail).Delete([]byte(old.Email)); err != nil {
+				return err
+			}
+		}
+
+		user.UpdatedAt = db.Now()
+		return putUser(tx, user)
+	})
+}
+
+func (data *Data) GetUserTaskCategory() ([]model.UserTaskCategory, error) {
+	var results []model.UserTaskCategory
+
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		usersBucket := tx.Bucket([]byte("Users"))
+		categoriesBucket := tx.Bucket([]byte("Categories"))
+
+		if usersBucket == nil || categoriesBucket == nil {
+			return fmt.Errorf("one or more required buckets do not exist")
+		}
+
+		return usersBucket.ForEach(func(k, userValue []byte) error {
+			var user model.User
+			if err := json.Unmarshal(userValue, &user); err != nil {
+				fmt.Printf("ERROR - Failed to unmarshal user data: %v\n", err)
+				return nil // skip badly formatted user records
+			}
+
+			// Only the tasks of this user are read, through the index
+			for _, task := range tasksByKeys(tx, indexKeys(tx, tasksByUser, k)) {
+				categoryName := "Unknown"
+				if catValue := categoriesBucket.Get(itob(task.CategoryID)); catValue != nil {
+					var category model.Category
+					if err := json.Unmarshal(catValue, &category); err != nil {
+						fmt.Printf("ERROR - Failed to unmarshal category data for ID %d: %v\n", task.CategoryID, err)
+					} else {
+						categoryName = category.Name
+					}
+				}
+
+				results = append(results, model.UserTaskCategory{
+					ID:       int(user.ID),
+					Fullname: user.Fullname,
+					Email:    user.Email,
+					Task:     task.Title,
+					Deadline: task.Deadline,
+					Priority: task.Priority,
+					Status:   task.Status,
+					Category: categoryName,
+				})
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		fmt.Printf("ERROR in GetUserTaskCategory: %v\n", err)
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// putSession writes session under its token and keeps the email and refresh
+// token indexes in step with it.
+func putSession(tx *bbolt.Tx, session model.Session) error {
+	b := tx.Bucket([]byte("Sessions"))
+	key := []byte(session.Token)
+
+	if old := b.Get(key); old != nil {
+		var prev model.Session
+		if err := json.Unmarshal(old, &prev); err == nil {
+			if err := unindexSession(tx, prev); err != nil {
+				return err
+			}
+		}
+	}
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(key, sessionJSON); err != nil {
+		return err
+	}
+
+	if err := indexAdd(tx, sessionsByEmail, []byte(session.Email), key); err != nil {
+		return err
+	}
+	if session.RefreshToken != "" {
+		return tx.Bucket(sessionsByRefresh).Put([]byte(session.RefreshToken), key)
+	}
+	return nil
+}
+
+func unindexSession(tx *bbolt.Tx, session model.Session) error {
+	if err := indexRemove(tx, sessionsByEmail, []byte(session.Email), []byte(session.Token)); err != nil {
+		return err
+	}
+	if session.RefreshToken != "" {
+		return tx.Bucket(sessionsByRefresh).Delete([]byte(session.RefreshToken))
+	}
+	return nil
+}
+
+// deleteSession removes the session stored under token and its index entries.
+func deleteSession(tx *bbolt.Tx, token []byte) error {
+	b := tx.Bucket([]byte("Sessions"))
+	v := b.Get(token)
+	if v == nil {
+		return nil
+	}
+
+	var session model.Session
+	if err := json.Unmarshal(v, &session); err == nil {
+		if err := unindexSession(tx, session); err != nil {
+			return err
+		}
+	}
+	return b.Delete(token)
+}
+
+// deleteSessionsWhere removes every session matching match. It scans the
+// bucket, which is fine for the rare revocations that need it.
+func deleteSessionsWhere(tx *bbolt.Tx, match func(model.Session) bool) error {
+	var tokens [][]byte
+	err := tx.Bucket([]byte("Sessions")).ForEach(func(k, v []byte) error {
+		var s model.Session
+		if err := json.Unmarshal(v, &s); err != nil {
+			return nil // Skip badly formatted session records
+		}
+		if match(s) {
+			tokens = append(tokens, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if err := deleteSession(tx, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionsOfEmail loads the sessions recorded for email, in token order.
+func sessionsOfEmail(tx *bbolt.Tx, email string) []model.Session {
+	b := tx.Bucket([]byte("Sessions"))
+
+	var sessions []model.Session
+	for _, k := range indexKeys(tx, sessionsByEmail, []byte(email)) {
+		v := b.Get(k)
+		if v == nil {
+			continue
+		}
+		var s model.Session
+		if err := json.Unmarshal(v, &s); err != nil {
+			continue // Skip badly formatted session records
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+func (data *Data) AddSession(session model.Session) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Sessions"))
+
+		// Every login gets its own ID, rotated sessions keep the one they had
+		if session.ID <= 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			session.ID = int(id)
+		}
+
+		return putSession(tx, session)
+	})
+}
+
+func (data *Data) DeleteSession(token string) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		return deleteSession(tx, []byte(token))
+	})
+}
+
+// GetSessionsByEmail returns every live session of a user, one per login.
+// Sessions whose refresh token was already rotated are left out.
+func (data *Data) GetSessionsByEmail(email string) ([]model.Session, error) {
+	sessions := []model.Session{}
 
-// GetTaskListByCategoryAndUser returns tasks filtered by both category and user
-func (data *Data) GetTaskListByCategoryAndUser(categoryID, userID int) ([]model.TaskCategory, error) {
-	var taskCategories []model.TaskCategory
-	category, err := data.GetCategoryByID(categoryID)
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		for _, s := range sessionsOfEmail(tx, email) {
+			if !s.Rotated {
+				sessions = append(sessions, s)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error fetching category: %v", err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// TouchSession records that the session behind token was just used.
+func (data *Data) TouchSession(token string, at time.Time) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Sessions"))
+		v := b.Get([]byte(token))
+		if v == nil {
+			return fmt.Errorf("session not found")
+		}
+
+		var session model.Session
+		if err := json.Unmarshal(v, &session); err != nil {
+			return err
+		}
+		session.LastSeen = at
+
+		sessionJSON, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(token), sessionJSON)
+	})
+}
+
+// DeleteSessionsByID removes the session with the given ID together with the
+// records it was rotated from, which share the ID.
+func (data *Data) DeleteSessionsByID(id int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		return deleteSessionsWhere(tx, func(s model.Session) bool { return s.ID == id })
+	})
+}
+
+// DeleteSessionsByEmail removes every session of a user, logging them out on all devices.
+func (data *Data) DeleteSessionsByEmail(email string) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		for _, s := range sessionsOfEmail(tx, email) {
+			if err := deleteSession(tx, []byte(s.Token)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (data *Data) UpdateSession(session model.Session) error {
+	return data.AddSession(session) // Reuse AddSession as it will overwrite the existing entry
+}
+
+func (data *Data) SessionByToken(token string) (model.Session, error) {
+	var session model.Session
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Sessions"))
+		sessionData := b.Get([]byte(token))
+		if sessionData == nil {
+			return fmt.Errorf("session not found")
+		}
+		return json.Unmarshal(sessionData, &session)
+	})
+	if err != nil {
+		return model.Session{}, err
+	}
+	return session, nil
+}
+
+func (data *Data) TokenExpired(session model.Session) bool {
+	return session.Expiry.Before(time.Now())
+}
+
+func (data *Data) TokenValidity(token string) (model.Session, error) {
+	session, err := data.SessionByToken(token)
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	if data.TokenExpired(session) {
+		err := data.DeleteSession(token)
+		if err != nil {
+			return model.Session{}, err
+		}
+		return model.Session{}, fmt.Errorf("session expired")
+	}
+
+	return session, nil
+}
+
+func (data *Data) GetFirstSession() (model.Session, error) {
+	var session model.Session
+	found := false // Flag to check if at least one session found
+
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Sessions"))
+		if b == nil {
+			return fmt.Errorf("sessions bucket not found")
+		}
+
+		// Use Cursor to iterate through the bucket
+		c := b.Cursor()
+		k, v := c.First() // Retrieve the first session
+		if k != nil {
+			err := json.Unmarshal(v, &session)
+			if err != nil {
+				return err // Return unmarshaling error
+			}
+			found = true // Set found true as we have at least one session
+		}
+		return nil
+	})
+
+	if err != nil {
+		return model.Session{}, err // Return error encountered during the View transaction
+	}
+
+	if !found {
+		return model.Session{}, fmt.Errorf("no sessions found") // No session was found
+	}
+
+	return session, nil // Return the first session found
+}
+
+func (data *Data) SessionAvailEmail(email string) (model.Session, error) {
+	var session model.Session
+	found := false // Flag to check if at least one session matches the email
+
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		for _, s := range sessionsOfEmail(tx, email) {
+			if !s.Rotated {
+				session = s
+				found = true
+				break // Stop the iteration as we found the session
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return model.Session{}, err // Return error encountered during the View transaction
+	}
+
+	if !found {
+		return model.Session{}, fmt.Errorf("no session available for email: %s", email) // No session was found for the given email
+	}
+
+	return session, nil // Return the found session
+}
+
+func (data *Data) SessionAvailToken(token string) (model.Session, error) {
+	var session model.Session
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Sessions"))
+		if b == nil {
+			return fmt.Errorf("sessions bucket not found")
+		}
+
+		sessionData := b.Get([]byte(token))
+		if sessionData == nil {
+			return fmt.Errorf("no session available for token: %s", token) // No session was found for the given token
+		}
+
+		return json.Unmarshal(sessionData, &session)
+	})
+
+	if err != nil {
+		return model.Session{}, err // Return error encountered during the View transaction or unmarshaling
+	}
+
+	return session, nil // Return the found session
+}
+
+// SessionByRefreshToken finds the session that was issued the given refresh
+// token, including sessions whose refresh token has already been rotated.
+func (data *Data) SessionByRefreshToken(refreshToken string) (model.Session, error) {
+	var session model.Session
+	found := false
+
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		if refreshToken == "" {
+			return nil
+		}
+
+		token := tx.Bucket(sessionsByRefresh).Get([]byte(refreshToken))
+		if token == nil {
+			return nil
+		}
+
+		v := tx.Bucket([]byte("Sessions")).Get(token)
+		if v == nil {
+			return nil
+		}
+		if err := json.Unmarshal(v, &session); err != nil {
+			return err
+		}
+		found = true
+		return nil
+	})
+
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	if !found {
+		return model.Session{}, fmt.Errorf("no session available for refresh token")
+	}
+
+	return session, nil
+}
+
+// RotateSession marks old as rotated and stores next in the same transaction,
+// so a refresh token can never be exchanged twice.
+func (data *Data) RotateSession(old model.Session, next model.Session) error {
+	old.Rotated = true
+
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Sessions"))
+		current := b.Get([]byte(old.Token))
+		if current == nil {
+			return fmt.Errorf("session not found")
+		}
+
+		var stored model.Session
+		if err := json.Unmarshal(current, &stored); err != nil {
+			return err
+		}
+		if stored.Rotated {
+			return fmt.Errorf("session already rotated")
+		}
+
+		if err := putSession(tx, old); err != nil {
+			return err
+		}
+		return putSession(tx, next)
+	})
+}
+
+// DeleteSessionFamily removes every session rotated from the same login.
+func (data *Data) DeleteSessionFamily(familyID string) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		return deleteSessionsWhere(tx, func(s model.Session) bool { return s.FamilyID == familyID })
+	})
+}
+
+// GetUsers retrieves all users from the database
+func (data *Data) GetUsers() ([]model.User, error) {
+	users := []model.User{}
+
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Users"))
+		if b == nil {
+			return fmt.Errorf("users bucket not found")
+		}
+
+		// Iterate over all users in the bucket
+		return b.ForEach(func(k, v []byte) error {
+			var user model.User
+			if err := json.Unmarshal(v, &user); err != nil {
+				// Log the error but continue with the next user
+				fmt.Printf("Error unmarshaling user data: %v\n", err)
+				return nil
+			}
+			// Add the user to the slice
+			users = append(users, user)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	err = data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Tasks"))
-		if b == nil {
-			return fmt.Errorf("tasks bucket not found")
-		}
-		return b.ForEach(func(k, v []byte) error {
-			var task model.Task
-			if err := json.Unmarshal(v, &task); err != nil {
-				log.Printf("Error unmarshaling task: %v", err)
-				return nil // Continue processing next item in case of error
+	fmt.Printf("DEBUG - Retrieved %d users from database\n", len(users))
+	return users, nil
+}
+
+// CreateDefaultCategoriesForUser creates default categories for a new user
+func (data *Data) CreateDefaultCategoriesForUser(userID int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("Categories"))
+		if b == nil {
+			return fmt.Errorf("categories bucket not found")
+		}
+
+		// If user already has categories, don't create more
+		if existing := indexKeys(tx, categoriesByUser, itob(userID)); len(existing) > 0 {
+			fmt.Printf("DEBUG: User %d already has %d categories, skipping creation\n", userID, len(existing))
+			return nil
+		}
+
+		initialCategories := []string{"Work", "Personal", "Study", "Health", "Home"}
+
+		for _, categoryName := range initialCategories {
+			id, err := b.NextSequence() // Globally unique ID
+			if err != nil {
+				return fmt.Errorf("error allocating category ID: %v", err)
+			}
+
+			category := model.Category{
+				ID:        int(id),
+				Name:      categoryName,
+				UserID:    userID,
+				Version:   1,
+				UpdatedAt: db.Now(),
 			}
-			// Filter by both category and user
-			if task.CategoryID == categoryID && task.UserID == userID {
-				taskCategories = append(taskCategories, model.TaskCategory{
-					ID:       task.ID,
-					Title:    task.Title,
-					Category: category.Name,
-				})
+			if err := putCategory(tx, category); err != nil {
+				return fmt.Errorf("error storing category: %v", err)
 			}
-			return nil
-		})
+		}
+
+		fmt.Printf("DEBUG: Successfully created %d categories for user %d\n", len(initialCategories), userID)
+		return nil
 	})
-	if err != nil {
-		return nil, fmt.Errorf("error fetching tasks for category %d and user %d: %v", categoryID, userID, err)
-	}
-	return taskCategories, nil
 }
 
-func (data *Data) GetUserByEmail(email string) (model.User, error) {
-	var user model.User
-	found := false // Flag to check if the user is found
+// GetWorkflow returns the workflow a user configured, model.ErrRecordNotFound
+// if there is none.
+func (data *Data) GetWorkflow(userID int) (*model.Workflow, error) {
+	var workflow *model.Workflow
 
 	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Users"))
-		if b == nil {
-			return fmt.Errorf("users bucket not found")
+		v := tx.Bucket([]byte("Workflows")).Get(itob(userID))
+		if v == nil {
+			return model.ErrRecordNotFound
 		}
+		return json.Unmarshal(v, &workflow)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
 
-		c := b.Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var u model.User
-			if err := json.Unmarshal(v, &u); err != nil {
-				continue // Skip on unmarshal error
-			}
-			if u.Email == email {
-				user = u
-				found = true
-				break // Stop the loop once the user is found
-			}
+func (data *Data) SaveWorkflow(workflow model.Workflow) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		workflowJSON, err := json.Marshal(workflow)
+		if err != nil {
+			return fmt.Errorf("error marshaling workflow: %v", err)
 		}
-		return nil // Return nil error from the View transaction
+		return tx.Bucket([]byte("Workflows")).Put(itob(workflow.UserID), workflowJSON)
 	})
+}
+
+func getDependencies(tx *bbolt.Tx, userID int) ([]model.Dependency, error) {
+	var deps []model.Dependency
+	v := tx.Bucket([]byte("Dependencies")).Get(itob(userID))
+	if v == nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(v, &deps); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
 
+// updateDependencies rewrites the edges of a user, dropping the record once
+// none are left.
+func updateDependencies(tx *bbolt.Tx, userID int, update func([]model.Dependency) []model.Dependency) error {
+	deps, err := getDependencies(tx, userID)
 	if err != nil {
-		return model.User{}, err // Return the error if the transaction failed
+		return err
 	}
-	if !found {
-		return model.User{}, nil // Return an empty User struct and nil error if not found
+	deps = update(deps)
+
+	b := tx.Bucket([]byte("Dependencies"))
+	if len(deps) == 0 {
+		return b.Delete(itob(userID))
+	}
+	model.SortDependencies(deps)
+	depsJSON, err := json.Marshal(deps)
+	if err != nil {
+		return err
 	}
-	return user, nil // Return the found user and nil error
+	return b.Put(itob(userID), depsJSON)
 }
 
-func (data *Data) CreateUser(user model.User) (model.User, error) {
-	err := data.DB.Update(func(tx *bbolt.Tx) error {
-		usersBucket := tx.Bucket([]byte("Users"))
-		if usersBucket == nil {
-			return fmt.Errorf("users bucket not found")
+func withoutDependencies(deps []model.Dependency, drop func(model.Dependency) bool) []model.Dependency {
+	kept := deps[:0]
+	for _, d := range deps {
+		if !drop(d) {
+			kept = append(kept, d)
 		}
+	}
+	return kept
+}
 
-		// Cek email sudah ada atau belum
-		emailExists := false
-		_ = usersBucket.ForEach(func(k, v []byte) error {
-			var u model.User
-			if err := json.Unmarshal(v, &u); err == nil {
-				if u.Email == user.Email {
-					emailExists = true
-				}
+func (data *Data) GetDependencies(userID int) ([]model.Dependency, error) {
+	var deps []model.Dependency
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		deps, err = getDependencies(tx, userID)
+		return err
+	})
+	return deps, err
+}
+
+// AddDependency records that dep.TaskID waits on dep.BlockedByID. The cycle
+// check runs in the same transaction as the write.
+func (data *Data) AddDependency(dep model.Dependency) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		for _, id := range []int{dep.TaskID, dep.BlockedByID} {
+			task, err := getTask(tx, id)
+			if err != nil {
+				return err
+			}
+			if task.UserID != dep.UserID {
+				return fmt.Errorf("%w: task %d belongs to a different user", model.ErrInvalidDependency, id)
 			}
-			return nil
-		})
-		if emailExists {
-			return fmt.Errorf("email already exists")
 		}
 
-		// Find the highest existing ID
-		maxID := 0
-		err := usersBucket.ForEach(func(k, v []byte) error {
-			id := btoi(k)
-			if id > maxID {
-				maxID = id
-			}
-			return nil
-		})
+		deps, err := getDependencies(tx, dep.UserID)
 		if err != nil {
-			return fmt.Errorf("error reading user IDs: %v", err)
+			return err
+		}
+		for _, d := range deps {
+			if d == dep {
+				return nil
+			}
 		}
+		if err := model.CheckDependency(deps, dep); err != nil {
+			return err
+		}
+
+		return updateDependencies(tx, dep.UserID, func(deps []model.Dependency) []model.Dependency {
+			return append(deps, dep)
+		})
+	})
+}
+
+func (data *Data) RemoveDependency(dep model.Dependency) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		return updateDependencies(tx, dep.UserID, func(deps []model.Dependency) []model.Dependency {
+			return withoutDependencies(deps, func(d model.Dependency) bool { return d == dep })
+		})
+	})
+}
 
-		// Set the new user's ID to maxID + 1
-		newUserID := maxID + 1
-		user.ID = newUserID // Assuming User.ID is of type int
+func getTag(tx *bbolt.Tx, id int) (model.Tag, error) {
+	var tag model.Tag
+	v := tx.Bucket([]byte("Tags")).Get(itob(id))
+	if v == nil {
+		return tag, model.ErrRecordNotFound
+	}
+	err := json.Unmarshal(v, &tag)
+	return tag, err
+}
 
-		userJSON, err := json.Marshal(user)
+func tagsOfUser(tx *bbolt.Tx, userID int) []model.Tag {
+	var tags []model.Tag
+	for _, k := range indexKeys(tx, tagsByUser, itob(userID)) {
+		tag, err := getTag(tx, int(binary.BigEndian.Uint64(k)))
 		if err != nil {
-			return fmt.Errorf("error marshaling user: %v", err)
+			continue
 		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
 
-		// Store the new user with the new ID (pakai string angka sebagai key)
-		return usersBucket.Put([]byte(strconv.Itoa(newUserID)), userJSON)
-	})
-	if err != nil {
-		return model.User{}, err
+// putTag writes tag under its ID once no other tag of its user has the name.
+func putTag(tx *bbolt.Tx, tag model.Tag) error {
+	for _, other := range tagsOfUser(tx, tag.UserID) {
+		if other.ID != tag.ID && model.SameTagName(other.Name, tag.Name) {
+			return model.ErrTagExists
+		}
 	}
 
-	// Create default categories for the new user
-	err = data.CreateDefaultCategoriesForUser(user.ID)
+	b := tx.Bucket([]byte("Tags"))
+	tagJSON, err := json.Marshal(tag)
 	if err != nil {
-		// Log error but don't fail user creation
-		fmt.Printf("Warning: Failed to create default categories for user %d: %v\n", user.ID, err)
+		return err
 	}
-
-	return user, nil
+	if err := b.Put(itob(tag.ID), tagJSON); err != nil {
+		return err
+	}
+	return indexAdd(tx, tagsByUser, itob(tag.UserID), itob(tag.ID))
 }
 
-// itob converts an integer to a byte slice
-func itob(v int) []byte {
-	b := make([]byte, 8)
-	binary.BigEndian.PutUint64(b, uint64(v))
-	return b
+func (data *Data) GetTagsByUserID(userID int) ([]model.Tag, error) {
+	var tags []model.Tag
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		tags = tagsOfUser(tx, userID)
+		return nil
+	})
+	return tags, err
 }
 
-// btoi converts a byte slice to an integer
-func btoi(b []byte) int {
-	// Convert string representation to integer
-	s := string(b)
-	i, err := strconv.Atoi(s)
+func (data *Data) GetTagByID(id int) (*model.Tag, error) {
+	var tag model.Tag
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		tag, err = getTag(tx, id)
+		return err
+	})
 	if err != nil {
-		// Return 0 if conversion fails
-		return 0
+		return nil, err
 	}
-	return i
+	return &tag, nil
 }
 
-func (data *Data) GetUserTaskCategory() ([]model.UserTaskCategory, error) {
-	var results []model.UserTaskCategory
-
-	// Debug counts
-	var userCount, taskCount, categoryCount, matchedTaskCount int
-
-	fmt.Println("DEBUG - Starting GetUserTaskCategory method...")
-
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		usersBucket := tx.Bucket([]byte("Users"))
-		tasksBucket := tx.Bucket([]byte("Tasks"))
-		categoriesBucket := tx.Bucket([]byte("Categories"))
+func (data *Data) CreateTag(tag model.Tag) (model.Tag, error) {
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		id, err := tx.Bucket([]byte("Tags")).NextSequence()
+		if err != nil {
+			return err
+		}
+		tag.ID = int(id)
+		return putTag(tx, tag)
+	})
+	if err != nil {
+		return model.Tag{}, err
+	}
+	return tag, nil
+}
 
-		if usersBucket == nil || tasksBucket == nil || categoriesBucket == nil {
-			fmt.Println("ERROR - One or more required buckets do not exist")
-			return fmt.Errorf("one or more required buckets do not exist")
+// UpdateTag renames or recolors a tag, it keeps its user.
+func (data *Data) UpdateTag(tag model.Tag) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		prev, err := getTag(tx, tag.ID)
+		if err != nil {
+			return err
 		}
+		tag.UserID = prev.UserID
+		return putTag(tx, tag)
+	})
+}
 
-		// Count users
-		usersBucket.ForEach(func(k, v []byte) error {
-			userCount++
+// DeleteTag removes a tag and takes it off its tasks in one transaction.
+func (data *Data) DeleteTag(id int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		tag, err := getTag(tx, id)
+		if err == model.ErrRecordNotFound {
 			return nil
-		})
+		}
+		if err != nil {
+			return err
+		}
 
-		// Count tasks
-		tasksBucket.ForEach(func(k, v []byte) error {
-			taskCount++
-			return nil
-		})
+		for _, task := range tasksByKeys(tx, indexKeys(tx, tasksByTag, itob(id))) {
+			var tagIDs []int
+			for _, tagID := range task.TagIDs {
+				if tagID != id {
+					tagIDs = append(tagIDs, tagID)
+				}
+			}
+			task.TagIDs = tagIDs
+			task.Version++
+			task.UpdatedAt = db.Now()
+			if err := putTask(tx, task); err != nil {
+				return err
+			}
+		}
 
-		// Count categories
-		categoriesBucket.ForEach(func(k, v []byte) error {
-			categoryCount++
-			return nil
-		})
+		if err := indexRemove(tx, tagsByUser, itob(tag.UserID), itob(id)); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("Tags")).Delete(itob(id))
+	})
+}
 
-		fmt.Printf("DEBUG - Database counts: Users=%d, Tasks=%d, Categories=%d\n", userCount, taskCount, categoryCount)
+func getComment(tx *bbolt.Tx, id int) (model.Comment, error) {
+	var comment model.Comment
+	v := tx.Bucket([]byte("Comments")).Get(itob(id))
+	if v == nil {
+		return comment, model.ErrRecordNotFound
+	}
+	err := json.Unmarshal(v, &comment)
+	return comment, err
+}
 
-		// List all users IDs first
-		fmt.Println("DEBUG - Listing all user IDs in database:")
-		usersBucket.ForEach(func(k, v []byte) error {
-			var user model.User
-			if err := json.Unmarshal(v, &user); err == nil {
-				fmt.Printf("  - User found: ID=%d, Email=%s\n", user.ID, user.Email)
-			}
-			return nil
-		})
+func putComment(tx *bbolt.Tx, comment model.Comment) error {
+	commentJSON, err := json.Marshal(comment)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket([]byte("Comments")).Put(itob(comment.ID), commentJSON); err != nil {
+		return err
+	}
+	if err := indexAdd(tx, commentsByTask, itob(comment.TaskID), itob(comment.ID)); err != nil {
+		return err
+	}
+	return reindexSearch(tx, comment.TaskID)
+}
 
-		// List all tasks with their UserIDs
-		fmt.Println("DEBUG - Listing all tasks with their UserIDs:")
-		tasksBucket.ForEach(func(k, v []byte) error {
-			var task model.Task
-			if err := json.Unmarshal(v, &task); err == nil {
-				fmt.Printf("  - Task found: ID=%d, Title=%s, UserID=%d, CategoryID=%d\n",
-					task.ID, task.Title, task.UserID, task.CategoryID)
-			}
-			return nil
-		})
+// deleteComments removes the comments of a task.
+func deleteComments(tx *bbolt.Tx, taskID int) error {
+	b := tx.Bucket([]byte("Comments"))
+	for _, k := range indexKeys(tx, commentsByTask, itob(taskID)) {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	if tx.Bucket(commentsByTask).Bucket(itob(taskID)) == nil {
+		return nil
+	}
+	return tx.Bucket(commentsByTask).DeleteBucket(itob(taskID))
+}
 
-		return usersBucket.ForEach(func(_, userValue []byte) error {
-			var user model.User
-			if err := json.Unmarshal(userValue, &user); err != nil {
-				fmt.Printf("ERROR - Failed to unmarshal user data: %v\n", err)
-				return nil // skip badly formatted user records
+func (data *Data) GetComments(taskID int) ([]model.Comment, error) {
+	var comments []model.Comment
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		for _, k := range indexKeys(tx, commentsByTask, itob(taskID)) {
+			comment, err := getComment(tx, int(binary.BigEndian.Uint64(k)))
+			if err != nil {
+				return err
 			}
+			comments = append(comments, comment)
+		}
+		return nil
+	})
+	return comments, err
+}
 
-			fmt.Printf("DEBUG - Processing user: ID=%d, Email=%s\n", user.ID, user.Email)
-			userTaskCount := 0
-
-			// Now fetch tasks for the user
-			err := tasksBucket.ForEach(func(_, taskValue []byte) error {
-				var task model.Task
-				if err := json.Unmarshal(taskValue, &task); err != nil {
-					fmt.Printf("ERROR - Failed to unmarshal task data: %v\n", err)
-					return nil // skip badly formatted task records
-				}
+func (data *Data) GetCommentByID(id int) (*model.Comment, error) {
+	var comment model.Comment
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		comment, err = getComment(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
 
-				fmt.Printf("DEBUG - Checking task: ID=%d, Title=%s, UserID=%d, Against User.ID=%d\n",
-					task.ID, task.Title, task.UserID, user.ID)
-
-				if task.UserID == user.ID { // Check if the task belongs to the user
-					userTaskCount++
-					matchedTaskCount++
-
-					categoryName := "Unknown"
-					catValue := categoriesBucket.Get([]byte(fmt.Sprintf("%d", task.CategoryID)))
-					if catValue != nil {
-						var category model.Category
-						if err := json.Unmarshal(catValue, &category); err != nil {
-							fmt.Printf("ERROR - Failed to unmarshal category data for ID %d: %v\n", task.CategoryID, err)
-						} else {
-							categoryName = category.Name
-							fmt.Printf("DEBUG - Found category: ID=%d, Name=%s\n", task.CategoryID, categoryName)
-						}
-					} else {
-						fmt.Printf("WARNING - No category found with ID: %d\n", task.CategoryID)
-					}
+func (data *Data) AddComment(comment model.Comment) (model.Comment, error) {
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		if _, err := getTask(tx, comment.TaskID); err != nil {
+			return err
+		}
+		id, err := tx.Bucket([]byte("Comments")).NextSequence()
+		if err != nil {
+			return err
+		}
+		comment.ID = int(id)
+		comment.CreatedAt = db.Now()
+		comment.UpdatedAt = comment.CreatedAt
+		comment.History = nil
+		return putComment(tx, comment)
+	})
+	if err != nil {
+		return model.Comment{}, err
+	}
+	return comment, nil
+}
 
-					result := model.UserTaskCategory{
-						ID:       int(user.ID),
-						Fullname: user.Fullname,
-						Email:    user.Email,
-						Task:     task.Title,
-						Deadline: task.Deadline,
-						Priority: task.Priority,
-						Status:   task.Status,
-						Category: categoryName,
-					}
-					results = append(results, result)
-					fmt.Printf("DEBUG - Added task to results: Task=%s, UserID=%d, Category=%s\n",
-						task.Title, user.ID, categoryName)
-				}
-				return nil
-			})
+// UpdateComment replaces the body of a comment, reading and revising it in
+// one transaction so concurrent edits all end up in the history.
+func (data *Data) UpdateComment(id int, body string) (model.Comment, error) {
+	var comment model.Comment
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		var err error
+		comment, err = getComment(tx, id)
+		if err != nil {
+			return err
+		}
+		comment.Revise(body, db.Now())
+		return putComment(tx, comment)
+	})
+	if err != nil {
+		return model.Comment{}, err
+	}
+	return comment, nil
+}
 
-			fmt.Printf("DEBUG - User ID=%d has %d tasks\n", user.ID, userTaskCount)
+func (data *Data) DeleteComment(id int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		comment, err := getComment(tx, id)
+		if err == model.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
 			return err
-		})
+		}
+		if err := indexRemove(tx, commentsByTask, itob(comment.TaskID), itob(id)); err != nil {
+			return err
+		}
+		if err := tx.Bucket([]byte("Comments")).Delete(itob(id)); err != nil {
+			return err
+		}
+		return reindexSearch(tx, comment.TaskID)
 	})
+}
+
+// attachmentRecord is how an attachment is stored, with the blob key the API
+// leaves out.
+type attachmentRecord struct {
+	model.Attachment
+	Key string `json:"key"`
+}
+
+func getAttachment(tx *bbolt.Tx, id int) (model.Attachment, error) {
+	var record attachmentRecord
+	v := tx.Bucket([]byte("Attachments")).Get(itob(id))
+	if v == nil {
+		return model.Attachment{}, model.ErrRecordNotFound
+	}
+	err := json.Unmarshal(v, &record)
+	record.Attachment.Key = record.Key
+	return record.Attachment, err
+}
+
+func attachmentsByKeys(tx *bbolt.Tx, keys [][]byte) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	for _, k := range keys {
+		attachment, err := getAttachment(tx, int(binary.BigEndian.Uint64(k)))
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
 
+func putAttachment(tx *bbolt.Tx, attachment model.Attachment) error {
+	attachmentJSON, err := json.Marshal(attachmentRecord{attachment, attachment.Key})
 	if err != nil {
-		fmt.Printf("ERROR in GetUserTaskCategory: %v\n", err)
-		return nil, err
+		return err
+	}
+	if err := tx.Bucket([]byte("Attachments")).Put(itob(attachment.ID), attachmentJSON); err != nil {
+		return err
 	}
+	if err := indexAdd(tx, attachmentsByTask, itob(attachment.TaskID), itob(attachment.ID)); err != nil {
+		return err
+	}
+	return indexAdd(tx, attachmentsByUser, itob(attachment.UserID), itob(attachment.ID))
+}
 
-	fmt.Printf("DEBUG - GetUserTaskCategory finished. Found %d users, %d total tasks, and %d tasks matched to users. Returning %d results.\n",
-		userCount, taskCount, matchedTaskCount, len(results))
-
-	return results, nil
+// deleteAttachment removes an attachment record and its index entries.
+func deleteAttachment(tx *bbolt.Tx, attachment model.Attachment) error {
+	if err := indexRemove(tx, attachmentsByTask, itob(attachment.TaskID), itob(attachment.ID)); err != nil {
+		return err
+	}
+	if err := indexRemove(tx, attachmentsByUser, itob(attachment.UserID), itob(attachment.ID)); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("Attachments")).Delete(itob(attachment.ID))
 }
 
-func (data *Data) AddSession(session model.Session) error {
-	sessionJSON, err := json.Marshal(session)
+// deleteAttachments removes the attachment records of a task.
+func deleteAttachments(tx *bbolt.Tx, taskID int) error {
+	attachments, err := attachmentsByKeys(tx, indexKeys(tx, attachmentsByTask, itob(taskID)))
 	if err != nil {
 		return err
 	}
-	return data.DB.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Sessions"))
-		return b.Put([]byte(session.Token), sessionJSON)
-	})
+	for _, attachment := range attachments {
+		if err := deleteAttachment(tx, attachment); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (data *Data) DeleteSession(token string) error {
-	return data.DB.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Sessions"))
-		return b.Delete([]byte(token))
+func (data *Data) GetAttachments(taskID int) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		attachments, err = attachmentsByKeys(tx, indexKeys(tx, attachmentsByTask, itob(taskID)))
+		return err
 	})
+	return attachments, err
 }
 
-func (data *Data) UpdateSession(session model.Session) error {
-	return data.AddSession(session) // Reuse AddSession as it will overwrite the existing entry
+func (data *Data) GetAttachmentsByUserID(userID int) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		attachments, err = attachmentsByKeys(tx, indexKeys(tx, attachmentsByUser, itob(userID)))
+		return err
+	})
+	return attachments, err
 }
 
-func (data *Data) SessionByToken(token string) (model.Session, error) {
-	var session model.Session
+func (data *Data) GetAttachmentByID(id int) (*model.Attachment, error) {
+	var attachment model.Attachment
 	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Sessions"))
-		sessionData := b.Get([]byte(token))
-		if sessionData == nil {
-			return fmt.Errorf("session not found")
-		}
-		return json.Unmarshal(sessionData, &session)
+		var err error
+		attachment, err = getAttachment(tx, id)
+		return err
 	})
 	if err != nil {
-		return model.Session{}, err
+		return nil, err
 	}
-	return session, nil
-}
-
-func (data *Data) TokenExpired(session model.Session) bool {
-	return session.Expiry.Before(time.Now())
+	return &attachment, nil
 }
 
-func (data *Data) TokenValidity(token string) (model.Session, error) {
-	session, err := data.SessionByToken(token)
-	if err != nil {
-		return model.Session{}, err
-	}
+// AddAttachment checks the quota against the user's attachments in the same
+// transaction it stores the record in, so parallel uploads cannot overshoot.
+func (data *Data) AddAttachment(attachment model.Attachment, quota int64) (model.Attachment, error) {
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		if _, err := getTask(tx, attachment.TaskID); err != nil {
+			return err
+		}
+		if quota > 0 {
+			existing, err := attachmentsByKeys(tx, indexKeys(tx, attachmentsByUser, itob(attachment.UserID)))
+			if err != nil {
+				return err
+			}
+			used := attachment.Size
+			for _, a := range existing {
+				used += a.Size
+			}
+			if used > quota {
+				return model.ErrQuotaExceeded
+			}
+		}
 
-	if data.TokenExpired(session) {
-		err := data.DeleteSession(token)
+		id, err := tx.Bucket([]byte("Attachments")).NextSequence()
 		if err != nil {
-			return model.Session{}, err
+			return err
 		}
-		return model.Session{}, fmt.Errorf("session expired")
+		attachment.ID = int(id)
+		attachment.CreatedAt = db.Now()
+		return putAttachment(tx, attachment)
+	})
+	if err != nil {
+		return model.Attachment{}, err
 	}
-
-	return session, nil
+	return attachment, nil
 }
 
-func (data *Data) GetFirstSession() (model.Session, error) {
-	var session model.Session
-	found := false // Flag to check if at least one session found
-
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Sessions"))
-		if b == nil {
-			return fmt.Errorf("sessions bucket not found")
+func (data *Data) DeleteAttachment(id int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		attachment, err := getAttachment(tx, id)
+		if err == model.ErrRecordNotFound {
+			return nil
 		}
-
-		// Use Cursor to iterate through the bucket
-		c := b.Cursor()
-		k, v := c.First() // Retrieve the first session
-		if k != nil {
-			err := json.Unmarshal(v, &session)
-			if err != nil {
-				return err // Return unmarshaling error
-			}
-			found = true // Set found true as we have at least one session
+		if err != nil {
+			return err
 		}
-		return nil
+		return deleteAttachment(tx, attachment)
 	})
+}
 
-	if err != nil {
-		return model.Session{}, err // Return error encountered during the View transaction
+func getTimeEntry(tx *bbolt.Tx, id int) (model.TimeEntry, error) {
+	var entry model.TimeEntry
+	v := tx.Bucket([]byte("TimeEntries")).Get(itob(id))
+	if v == nil {
+		return entry, model.ErrRecordNotFound
 	}
+	err := json.Unmarshal(v, &entry)
+	return entry, err
+}
 
-	if !found {
-		return model.Session{}, fmt.Errorf("no sessions found") // No session was found
+func timeEntriesByKeys(tx *bbolt.Tx, keys [][]byte) ([]model.TimeEntry, error) {
+	var entries []model.TimeEntry
+	for _, k := range keys {
+		entry, err := getTimeEntry(tx, int(binary.BigEndian.Uint64(k)))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
 	}
-
-	return session, nil // Return the first session found
+	return entries, nil
 }
 
-func (data *Data) SessionAvailEmail(email string) (model.Session, error) {
-	var session model.Session
-	found := false // Flag to check if at least one session matches the email
+func putTimeEntry(tx *bbolt.Tx, entry model.TimeEntry) error {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket([]byte("TimeEntries")).Put(itob(entry.ID), entryJSON); err != nil {
+		return err
+	}
+	if err := indexAdd(tx, timeEntriesByUser, itob(entry.UserID), itob(entry.ID)); err != nil {
+		return err
+	}
+	return indexAdd(tx, timeEntriesByTask, itob(entry.TaskID), itob(entry.ID))
+}
 
-	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Sessions"))
-		if b == nil {
-			return fmt.Errorf("sessions bucket not found")
+// runningTimeEntry returns the running timer of a user other than entry
+// except, model.ErrRecordNotFound if there is none.
+func runningTimeEntry(tx *bbolt.Tx, userID, except int) (model.TimeEntry, error) {
+	entries, err := timeEntriesByKeys(tx, indexKeys(tx, timeEntriesByUser, itob(userID)))
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	for _, entry := range entries {
+		if entry.Running() && entry.ID != except {
+			return entry, nil
 		}
+	}
+	return model.TimeEntry{}, model.ErrRecordNotFound
+}
 
-		c := b.Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var s model.Session
-			if err := json.Unmarshal(v, &s); err != nil {
-				continue // Skip badly formatted session records
-			}
-			if s.Email == email {
-				session = s
-				found = true
-				break // Stop the iteration as we found the session
-			}
-		}
+// checkTimer refuses a running entry while the user has another one.
+func checkTimer(tx *bbolt.Tx, entry model.TimeEntry) error {
+	if !entry.Running() {
 		return nil
-	})
-
+	}
+	_, err := runningTimeEntry(tx, entry.UserID, entry.ID)
+	if err == model.ErrRecordNotFound {
+		return nil
+	}
 	if err != nil {
-		return model.Session{}, err // Return error encountered during the View transaction
+		return err
 	}
+	return model.ErrTimerRunning
+}
 
-	if !found {
-		return model.Session{}, fmt.Errorf("no session available for email: %s", email) // No session was found for the given email
+func deleteTimeEntry(tx *bbolt.Tx, entry model.TimeEntry) error {
+	if err := indexRemove(tx, timeEntriesByUser, itob(entry.UserID), itob(entry.ID)); err != nil {
+		return err
 	}
+	if err := indexRemove(tx, timeEntriesByTask, itob(entry.TaskID), itob(entry.ID)); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("TimeEntries")).Delete(itob(entry.ID))
+}
 
-	return session, nil // Return the found session
+// deleteTimeEntries removes the time entries of a task.
+func deleteTimeEntries(tx *bbolt.Tx, taskID int) error {
+	entries, err := timeEntriesByKeys(tx, indexKeys(tx, timeEntriesByTask, itob(taskID)))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := deleteTimeEntry(tx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (data *Data) SessionAvailToken(token string) (model.Session, error) {
-	var session model.Session
+func (data *Data) GetTimeEntries(userID int, from, to time.Time) ([]model.TimeEntry, error) {
+	var entries []model.TimeEntry
 	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Sessions"))
-		if b == nil {
-			return fmt.Errorf("sessions bucket not found")
+		all, err := timeEntriesByKeys(tx, indexKeys(tx, timeEntriesByUser, itob(userID)))
+		if err != nil {
+			return err
 		}
-
-		sessionData := b.Get([]byte(token))
-		if sessionData == nil {
-			return fmt.Errorf("no session available for token: %s", token) // No session was found for the given token
+		for _, entry := range all {
+			if entry.Start.Before(to) && (entry.Running() || entry.End.After(from)) {
+				entries = append(entries, entry)
+			}
 		}
+		return nil
+	})
+	return entries, err
+}
 
-		return json.Unmarshal(sessionData, &session)
+func (data *Data) GetTimeEntriesByTaskID(taskID int) ([]model.TimeEntry, error) {
+	var entries []model.TimeEntry
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		entries, err = timeEntriesByKeys(tx, indexKeys(tx, timeEntriesByTask, itob(taskID)))
+		return err
 	})
+	return entries, err
+}
 
+func (data *Data) GetTimeEntryByID(id int) (*model.TimeEntry, error) {
+	var entry model.TimeEntry
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		entry, err = getTimeEntry(tx, id)
+		return err
+	})
 	if err != nil {
-		return model.Session{}, err // Return error encountered during the View transaction or unmarshaling
+		return nil, err
 	}
-
-	return session, nil // Return the found session
+	return &entry, nil
 }
 
-// GetUsers retrieves all users from the database
-func (data *Data) GetUsers() ([]model.User, error) {
-	users := []model.User{}
-
+func (data *Data) GetRunningTimeEntry(userID int) (*model.TimeEntry, error) {
+	var entry model.TimeEntry
 	err := data.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Users"))
-		if b == nil {
-			return fmt.Errorf("users bucket not found")
-		}
-
-		// Iterate over all users in the bucket
-		return b.ForEach(func(k, v []byte) error {
-			var user model.User
-			if err := json.Unmarshal(v, &user); err != nil {
-				// Log the error but continue with the next user
-				fmt.Printf("Error unmarshaling user data: %v\n", err)
-				return nil
-			}
-			// Add the user to the slice
-			users = append(users, user)
-			return nil
-		})
+		var err error
+		entry, err = runningTimeEntry(tx, userID, 0)
+		return err
 	})
-
 	if err != nil {
 		return nil, err
 	}
+	return &entry, nil
+}
 
-	fmt.Printf("DEBUG - Retrieved %d users from database\n", len(users))
-	return users, nil
+func (data *Data) AddTimeEntry(entry model.TimeEntry) (model.TimeEntry, error) {
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		if _, err := getTask(tx, entry.TaskID); err != nil {
+			return err
+		}
+		if err := checkTimer(tx, entry); err != nil {
+			return err
+		}
+		id, err := tx.Bucket([]byte("TimeEntries")).NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.ID = int(id)
+		return putTimeEntry(tx, entry)
+	})
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	return entry, nil
 }
 
-// CreateDefaultCategoriesForUser creates default categories for a new user
-func (data *Data) CreateDefaultCategoriesForUser(userID int) error {
-	fmt.Printf("DEBUG: CreateDefaultCategoriesForUser called for userID: %d\n", userID)
+// UpdateTimeEntry changes the times and note of an entry, it stays with its
+// task and user.
+func (data *Data) UpdateTimeEntry(entry model.TimeEntry) error {
 	return data.DB.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("Categories"))
-		if b == nil {
-			return fmt.Errorf("categories bucket not found")
+		existing, err := getTimeEntry(tx, entry.ID)
+		if err != nil {
+			return err
 		}
+		entry.TaskID, entry.UserID = existing.TaskID, existing.UserID
+		if err := checkTimer(tx, entry); err != nil {
+			return err
+		}
+		return putTimeEntry(tx, entry)
+	})
+}
 
-		// First, check if user already has ANY categories (including checking each one individually)
-		userCategories := []model.Category{}
-		err := b.ForEach(func(k, v []byte) error {
-			var category model.Category
-			if err := json.Unmarshal(v, &category); err == nil {
-				if category.UserID == userID {
-					userCategories = append(userCategories, category)
-					fmt.Printf("DEBUG: Found existing category for user %d: %s (ID: %d)\n", userID, category.Name, category.ID)
-				}
-			}
-			return nil
-		})
+func (data *Data) StopTimer(userID int, end time.Time) (model.TimeEntry, error) {
+	var entry model.TimeEntry
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		var err error
+		entry, err = runningTimeEntry(tx, userID, 0)
+		if err == model.ErrRecordNotFound {
+			return model.ErrNoTimerRunning
+		}
 		if err != nil {
-			return fmt.Errorf("error checking existing categories: %v", err)
+			return err
 		}
+		entry.End = &end
+		return putTimeEntry(tx, entry)
+	})
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	return entry, nil
+}
 
-		// If user already has categories, don't create more
-		if len(userCategories) > 0 {
-			fmt.Printf("DEBUG: User %d already has %d categories, skipping creation\n", userID, len(userCategories))
+func (data *Data) DeleteTimeEntry(id int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		entry, err := getTimeEntry(tx, id)
+		if err == model.ErrRecordNotFound {
 			return nil
 		}
-
-		fmt.Printf("DEBUG: User %d has no categories, creating initial categories\n", userID)
-
-		// Get the highest existing category ID to avoid conflicts between users
-		maxID := 0
-		err = b.ForEach(func(k, v []byte) error {
-			id := btoi(k)
-			if id > maxID {
-				maxID = id
-			}
-			return nil
-		})
 		if err != nil {
-			return fmt.Errorf("error reading category IDs: %v", err)
-		}
-
-		fmt.Printf("DEBUG: Current maxID in database: %d\n", maxID)
-
-		initialCategories := []string{"Work", "Personal", "Study", "Health", "Home"}
-
-		for i, categoryName := range initialCategories {
-			categoryID := maxID + i + 1 // Globally unique ID
-			category := model.Category{
-				ID:     categoryID,
-				Name:   categoryName,
-				UserID: userID,
-			}
-
-			fmt.Printf("DEBUG: Creating category: %s (ID: %d) for user %d\n", category.Name, category.ID, userID)
-			catJSON, err := json.Marshal(category)
-			if err != nil {
-				return fmt.Errorf("error marshaling category: %v", err)
-			}
-			err = b.Put([]byte(fmt.Sprintf("%d", category.ID)), catJSON)
-			if err != nil {
-				return fmt.Errorf("error storing category: %v", err)
-			}
+			return err
 		}
-
-		fmt.Printf("DEBUG: Successfully created %d categories for user %d\n", len(initialCategories), userID)
-		return nil
+		return deleteTimeEntry(tx, entry)
 	})
 }
@@ -0,0 +1,138 @@
+package filebased
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"a21hc3NpZ25tZW50/model"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+	benchUsers = 200
+	benchTasks = 20000
+)
+
+// openBench opens a fresh database in a temp dir seeded with benchTasks tasks
+// spread over benchUsers users, all written in a single transaction.
+func openBench(b *testing.B) *Data {
+	b.Helper()
+	b.Setenv("APP_DB_PATH", filepath.Join(b.TempDir(), "bench.db"))
+
+	data, err := InitDB()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { data.CloseDB() })
+
+	err = data.DB.Update(func(tx *bbolt.Tx) error {
+		for u := 1; u <= benchUsers; u++ {
+			user := model.User{ID: u, Fullname: fmt.Sprintf("User %d", u), Email: fmt.Sprintf("user%d@mail.com", u)}
+			if err := putUser(tx, user); err != nil {
+				return err
+			}
+			if err := putCategory(tx, model.Category{ID: u, Name: "Work", UserID: u}); err != nil {
+				return err
+			}
+		}
+		for i := 1; i <= benchTasks; i++ {
+			u := i%benchUsers + 1
+			task := model.Task{ID: i, Title: fmt.Sprintf("Task %d", i), Status: "In Progress", Priority: 1, CategoryID: u, UserID: u}
+			if err := putTask(tx, task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkGetTasksByUserID(b *testing.B) {
+	data := openBench(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tasks, err := data.GetTasksByUserID(i%benchUsers + 1)
+		if err != nil || len(tasks) != benchTasks/benchUsers {
+			b.Fatalf("got %d tasks, err %v", len(tasks), err)
+		}
+	}
+}
+
+// BenchmarkGetTasksByUserIDScan is the full-bucket scan the index replaces.
+func BenchmarkGetTasksByUserIDScan(b *testing.B) {
+	data := openBench(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		all, err := data.GetTasks()
+		if err != nil {
+			b.Fatal(err)
+		}
+		userID := i%benchUsers + 1
+		var tasks []model.Task
+		for _, task := range all {
+			if task.UserID == userID {
+				tasks = append(tasks, task)
+			}
+		}
+		if len(tasks) != benchTasks/benchUsers {
+			b.Fatalf("got %d tasks", len(tasks))
+		}
+	}
+}
+
+func BenchmarkGetUserByEmail(b *testing.B) {
+	data := openBench(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		email := fmt.Sprintf("user%d@mail.com", i%benchUsers+1)
+		user, err := data.GetUserByEmail(email)
+		if err != nil || user.Email != email {
+			b.Fatalf("got %q, err %v", user.Email, err)
+		}
+	}
+}
+
+// BenchmarkGetUserByEmailScan is the full-bucket scan the index replaces.
+func BenchmarkGetUserByEmailScan(b *testing.B) {
+	data := openBench(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		email := fmt.Sprintf("user%d@mail.com", i%benchUsers+1)
+		users, err := data.GetUsers()
+		if err != nil {
+			b.Fatal(err)
+		}
+		found := false
+		for _, user := range users {
+			if user.Email == email {
+				found = true
+				break
+			}
+		}
+		if !found {
+			b.Fatalf("user %s not found", email)
+		}
+	}
+}
+
+func BenchmarkStoreTask(b *testing.B) {
+	data := openBench(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		u := i%benchUsers + 1
+		task := model.Task{Title: "New task", Status: "In Progress", Priority: 1, CategoryID: u, UserID: u}
+		if err := data.StoreTask(task); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
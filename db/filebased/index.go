@@ -0,0 +1,88 @@
+package filebased
+
+import (
+	"encoding/binary"
+
+	"go.etcd.io/bbolt"
+)
+
+// Index buckets map an owner to the keys of the records it owns. The nested
+// ones hold one sub-bucket per owner whose keys are the record keys, so a
+// lookup reads only the matching records instead of the whole bucket. They are
+// written in the same transaction as the records they point to.
+var (
+	usersByEmail      = []byte("UsersByEmail")           // email -> user key
+	tasksByUser       = []byte("TasksByUser")            // user key -> {task key}
+	tasksByCategory   = []byte("TasksByCategory")        // category key -> {task key}
+	categoriesByUser  = []byte("CategoriesByUser")       // user key -> {category key}
+	sessionsByEmail   = []byte("SessionsByEmail")        // email -> {token}
+	sessionsByRefresh = []byte("SessionsByRefreshToken") // refresh token -> token
+	tagsByUser        = []byte("TagsByUser")             // user key -> {tag key}
+	tasksByTag        = []byte("TasksByTag")             // tag key -> {task key}
+	commentsByTask    = []byte("CommentsByTask")         // task key -> {comment key}
+	attachmentsByTask = []byte("AttachmentsByTask")      // task key -> {attachment key}
+	attachmentsByUser = []byte("AttachmentsByUser")      // user key -> {attachment key}
+	timeEntriesByUser = []byte("TimeEntriesByUser")      // user key -> {time entry key}
+	timeEntriesByTask = []byte("TimeEntriesByTask")      // task key -> {time entry key}
+	viewsByUser       = []byte("ViewsByUser")            // user key -> {view key}
+	trashByUser       = []byte("TrashByUser")            // user key -> {trash entry key}
+
+	indexBuckets = [][]byte{usersByEmail, tasksByUser, tasksByCategory, categoriesByUser, sessionsByEmail, sessionsByRefresh, tagsByUser, tasksByTag, commentsByTask, attachmentsByTask, attachmentsByUser, timeEntriesByUser, timeEntriesByTask, searchTerms, searchDocs, tasksByDeadline, tasksByPriority, viewsByUser, trashByUser}
+	emptyValue   = []byte{}
+)
+
+// itob converts an integer to a big-endian key, so keys sort by ID
+func itob(v int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+// indexAdd records key under owner in the nested index bucket name.
+func indexAdd(tx *bbolt.Tx, name, owner, key []byte) error {
+	sub, err := tx.Bucket(name).CreateBucketIfNotExists(owner)
+	if err != nil {
+		return err
+	}
+	return sub.Put(key, emptyValue)
+}
+
+// indexRemove drops key from owner, and owner itself once it is empty.
+func indexRemove(tx *bbolt.Tx, name, owner, key []byte) error {
+	index := tx.Bucket(name)
+	sub := index.Bucket(owner)
+	if sub == nil {
+		return nil
+	}
+	if err := sub.Delete(key); err != nil {
+		return err
+	}
+	if k, _ := sub.Cursor().First(); k == nil {
+		return index.DeleteBucket(owner)
+	}
+	return nil
+}
+
+// indexKeys returns the keys recorded under owner, in key order.
+func indexKeys(tx *bbolt.Tx, name, owner []byte) [][]byte {
+	sub := tx.Bucket(name).Bucket(owner)
+	if sub == nil {
+		return nil
+	}
+
+	var keys [][]byte
+	sub.ForEach(func(k, _ []byte) error {
+		keys = append(keys, append([]byte(nil), k...))
+		return nil
+	})
+	return keys
+}
+
+// bumpSequence keeps the bucket sequence at or past id, so NextSequence never
+// hands out an ID that was stored explicitly.
+func bumpSequence(b *bbolt.Bucket, id int) error {
+	if uint64(id) > b.Sequence() {
+		return b.SetSequence(uint64(id))
+	}
+	return nil
+}
@@ -0,0 +1,504 @@
+package filebased
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// The Meta bucket records which data layout a file uses.
+var (
+	metaBucket       = []byte("Meta")
+	schemaVersionKey = []byte("schema_version")
+)
+
+// Migration moves a file from schema Version-1 to Version. Up runs inside the
+// write transaction that also records the new version, and returns a short
+// summary of what it changed.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *bbolt.Tx) (string, error)
+}
+
+// MigrationResult is what Migrate reports for each migration it ran.
+type MigrationResult struct {
+	Version int
+	Name    string
+	Summary string
+}
+
+// migrations must stay ordered by Version, starting at 1 with no gaps. Never
+// edit one that has shipped, append a new one instead.
+var migrations = []Migration{
+	{Version: 1, Name: "create buckets", Up: createDataBuckets},
+	{Version: 2, Name: "big-endian keys and index buckets", Up: reindex},
+	{Version: 3, Name: "remove acv category", Up: removeAcvCategory},
+	{Version: 4, Name: "task and category versions", Up: initVersions},
+	{Version: 5, Name: "typed deadlines", Up: convertDeadlines},
+	{Version: 6, Name: "status workflows", Up: normalizeStatuses},
+	{Version: 7, Name: "task dependencies", Up: createDependencies},
+	{Version: 8, Name: "tags", Up: createTags},
+	{Version: 9, Name: "comments", Up: createComments},
+	{Version: 10, Name: "attachments", Up: createAttachments},
+	{Version: 11, Name: "time entries", Up: createTimeEntries},
+	{Version: 12, Name: "search index", Up: createSearchIndex},
+	{Version: 13, Name: "task sort indexes", Up: createSortIndexes},
+	{Version: 14, Name: "saved views", Up: createViews},
+	{Version: 15, Name: "trash", Up: createTrash},
+}
+
+// LatestSchemaVersion is the schema version this binary writes.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion reads the version recorded in the Meta bucket. Files written
+// before the Meta bucket existed are version 0.
+func SchemaVersion(db *bbolt.DB) (int, error) {
+	version := 0
+	err := db.View(func(tx *bbolt.Tx) error {
+		version = schemaVersion(tx)
+		return nil
+	})
+	return version, err
+}
+
+func schemaVersion(tx *bbolt.Tx) int {
+	b := tx.Bucket(metaBucket)
+	if b == nil {
+		return 0
+	}
+	v := b.Get(schemaVersionKey)
+	if len(v) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(v))
+}
+
+func setSchemaVersion(tx *bbolt.Tx, version int) error {
+	b, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return fmt.Errorf("create Meta bucket: %v", err)
+	}
+	return b.Put(schemaVersionKey, itob(version))
+}
+
+// Migrate applies every migration newer than the file's schema version, each
+// in its own transaction together with the version bump. With dryRun the
+// pending migrations run in a single transaction that is rolled back, so the
+// summaries show what would change without writing anything.
+//
+// A file whose version is newer than LatestSchemaVersion was written by a
+// newer binary, and Migrate refuses to touch it.
+func Migrate(db *bbolt.DB, dryRun bool) ([]MigrationResult, error) {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if current > LatestSchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than this binary supports (%d), refusing to start", current, LatestSchemaVersion())
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+
+	var results []MigrationResult
+
+	if dryRun {
+		tx, err := db.Begin(true)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		for _, m := range pending {
+			summary, err := m.Up(tx)
+			if err != nil {
+				return results, fmt.Errorf("migration %d (%s): %v", m.Version, m.Name, err)
+			}
+			results = append(results, MigrationResult{Version: m.Version, Name: m.Name, Summary: summary})
+		}
+		return results, nil
+	}
+
+	for _, m := range pending {
+		var summary string
+		err := db.Update(func(tx *bbolt.Tx) error {
+			var err error
+			summary, err = m.Up(tx)
+			if err != nil {
+				return err
+			}
+			return setSchemaVersion(tx, m.Version)
+		})
+		if err != nil {
+			return results, fmt.Errorf("migration %d (%s): %v", m.Version, m.Name, err)
+		}
+		results = append(results, MigrationResult{Version: m.Version, Name: m.Name, Summary: summary})
+	}
+
+	return results, nil
+}
+
+// createDataBuckets creates the buckets of the original layout.
+func createDataBuckets(tx *bbolt.Tx) (string, error) {
+	created := 0
+	for _, name := range []string{"Tasks", "Categories", "Users", "Sessions"} {
+		if tx.Bucket([]byte(name)) != nil {
+			continue
+		}
+		if _, err := tx.CreateBucket([]byte(name)); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+		created++
+	}
+	return fmt.Sprintf("created %d buckets", created), nil
+}
+
+// removeAcvCategory deletes the "acv" categories RunServer used to remove on
+// every start.
+func removeAcvCategory(tx *bbolt.Tx) (string, error) {
+	var acv []model.Category
+	err := tx.Bucket([]byte("Categories")).ForEach(func(_, v []byte) error {
+		var category model.Category
+		if err := json.Unmarshal(v, &category); err != nil {
+			return nil // Skip badly formatted category records
+		}
+		if category.Name == "acv" {
+			acv = append(acv, category)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, category := range acv {
+		if err := deleteCategory(tx, category.ID); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("deleted %d categories", len(acv)), nil
+}
+
+// initVersions gives tasks and categories written before versioning Version 1,
+// so the first ETag a client sees is "1" like for new records.
+func initVersions(tx *bbolt.Tx) (string, error) {
+	now := db.Now()
+
+	var tasks []model.Task
+	err := tx.Bucket([]byte("Tasks")).ForEach(func(_, v []byte) error {
+		task, err := decodeTask(v)
+		if err == nil && task.Version == 0 {
+			tasks = append(tasks, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, task := range tasks {
+		task.Version = 1
+		task.UpdatedAt = now
+		if err := putTask(tx, task); err != nil {
+			return "", err
+		}
+	}
+
+	var categories []model.Category
+	err = tx.Bucket([]byte("Categories")).ForEach(func(_, v []byte) error {
+		var category model.Category
+		if err := json.Unmarshal(v, &category); err == nil && category.Version == 0 {
+			categories = append(categories, category)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, category := range categories {
+		category.Version = 1
+		category.UpdatedAt = now
+		if err := putCategory(tx, category); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("versioned %d tasks and %d categories", len(tasks), len(categories)), nil
+}
+
+// legacyDeadlineLayouts are the formats free-form deadlines were written in,
+// besides the date-only and RFC 3339 ones model.ParseDeadline reads. Times
+// without an offset are taken as UTC.
+var legacyDeadlineLayouts = []string{
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+}
+
+var legacyDateLayouts = []string{"2006/01/02", "02/01/2006", "02-01-2006"}
+
+func parseLegacyDeadline(s string) (model.Deadline, bool) {
+	s = strings.TrimSpace(s)
+	if d, err := model.ParseDeadline(s); err == nil {
+		return d, true
+	}
+	for _, layout := range legacyDeadlineLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return model.Deadline{At: t}, true
+		}
+	}
+	for _, layout := range legacyDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return model.DateDeadline(t.Date()), true
+		}
+	}
+	return model.Deadline{}, false
+}
+
+// fixLegacyDeadline rewrites a string deadline of record in the format
+// model.Deadline reads. changed is false when it already was, readable is false
+// when it could not be parsed and was cleared.
+func fixLegacyDeadline(record map[string]json.RawMessage) (changed, readable bool, err error) {
+	var legacy string
+	if err := json.Unmarshal(record["deadline"], &legacy); err != nil {
+		return false, true, nil // null or missing, nothing to convert
+	}
+
+	deadline, ok := parseLegacyDeadline(legacy)
+	if ok && deadline.String() == legacy {
+		return false, true, nil
+	}
+
+	value, err := json.Marshal(deadline)
+	if err != nil {
+		return false, false, err
+	}
+	record["deadline"] = value
+	return true, ok, nil
+}
+
+// decodeTask reads a task record of any schema version. Before version 5 the
+// deadline was a free-form string, which is parsed like convertDeadlines does
+// so the earlier migrations keep tasks model.Task cannot read directly.
+func decodeTask(v []byte) (model.Task, error) {
+	var task model.Task
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(v, &record); err != nil {
+		return task, err
+	}
+	changed, _, err := fixLegacyDeadline(record)
+	if err != nil {
+		return task, err
+	}
+	if changed {
+		if v, err = json.Marshal(record); err != nil {
+			return task, err
+		}
+	}
+	err = json.Unmarshal(v, &task)
+	return task, err
+}
+
+// convertDeadlines rewrites the string deadlines of tasks in the format
+// model.Deadline reads. Deadlines that cannot be parsed are cleared, the task
+// itself is kept.
+func convertDeadlines(tx *bbolt.Tx) (string, error) {
+	b := tx.Bucket([]byte("Tasks"))
+
+	type rewrite struct {
+		key   []byte
+		value []byte
+	}
+	var rewrites []rewrite
+	converted, cleared := 0, 0
+
+	err := b.ForEach(func(k, v []byte) error {
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(v, &record); err != nil {
+			return nil // Skip badly formatted task records
+		}
+
+		changed, readable, err := fixLegacyDeadline(record)
+		if err != nil || !changed {
+			return err
+		}
+		if readable {
+			converted++
+		} else {
+			cleared++
+		}
+
+		if v, err = json.Marshal(record); err != nil {
+			return err
+		}
+		rewrites = append(rewrites, rewrite{append([]byte(nil), k...), v})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range rewrites {
+		if err := b.Put(r.key, r.value); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("converted %d deadlines, cleared %d unreadable ones", converted, cleared), nil
+}
+
+// legacyStatuses maps the lower-cased spellings free-form statuses ended up
+// with to the statuses of model.DefaultWorkflow.
+var legacyStatuses = map[string]string{
+	"not started":   "Not Started",
+	"todo":          "Not Started",
+	"to do":         "Not Started",
+	"belum dimulai": "Not Started",
+	"belum":         "Not Started",
+
+	"in progress":       "In Progress",
+	"in_progress":       "In Progress",
+	"in-progress":       "In Progress",
+	"doing":             "In Progress",
+	"progress":          "In Progress",
+	"sedang dikerjakan": "In Progress",
+
+	"completed": "Completed",
+	"complete":  "Completed",
+	"done":      "Completed",
+	"finished":  "Completed",
+	"selesai":   "Completed",
+}
+
+// normalizeStatuses creates the Workflows bucket and folds the statuses of
+// existing tasks into the default workflow. Completed tasks get CompletedAt
+// from their last write, the best guess there is.
+func normalizeStatuses(tx *bbolt.Tx) (string, error) {
+	if _, err := tx.CreateBucketIfNotExists([]byte("Workflows")); err != nil {
+		return "", fmt.Errorf("create Workflows bucket: %v", err)
+	}
+
+	var tasks []model.Task
+	unknown := 0
+	err := tx.Bucket([]byte("Tasks")).ForEach(func(_, v []byte) error {
+		var task model.Task
+		if err := json.Unmarshal(v, &task); err != nil {
+			return nil // Skip badly formatted task records
+		}
+
+		status, ok := legacyStatuses[strings.ToLower(strings.TrimSpace(task.Status))]
+		if !ok {
+			unknown++
+			return nil
+		}
+		changed := status != task.Status
+		task.Status = status
+		if status == "Completed" && task.CompletedAt == nil {
+			completedAt := task.UpdatedAt
+			task.CompletedAt = &completedAt
+			changed = true
+		}
+		if changed {
+			tasks = append(tasks, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, task := range tasks {
+		if err := putTask(tx, task); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("updated %d tasks, left %d with an unknown status", len(tasks), unknown), nil
+}
+
+// createDependencies creates the Dependencies bucket, which holds the
+// blocked-by edges between the tasks of a user under the user's ID.
+func createDependencies(tx *bbolt.Tx) (string, error) {
+	if tx.Bucket([]byte("Dependencies")) != nil {
+		return "created 0 buckets", nil
+	}
+	if _, err := tx.CreateBucket([]byte("Dependencies")); err != nil {
+		return "", fmt.Errorf("create Dependencies bucket: %v", err)
+	}
+	return "created 1 buckets", nil
+}
+
+// createTags creates the Tags bucket and its indexes, TagsByUser and
+// TasksByTag, which the tag_ids of tasks are indexed in.
+func createTags(tx *bbolt.Tx) (string, error) {
+	created := 0
+	for _, name := range [][]byte{[]byte("Tags"), tagsByUser, tasksByTag} {
+		if tx.Bucket(name) != nil {
+			continue
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+		created++
+	}
+	return fmt.Sprintf("created %d buckets", created), nil
+}
+
+// createComments creates the Comments bucket and its CommentsByTask index.
+func createComments(tx *bbolt.Tx) (string, error) {
+	created := 0
+	for _, name := range [][]byte{[]byte("Comments"), commentsByTask} {
+		if tx.Bucket(name) != nil {
+			continue
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+		created++
+	}
+	return fmt.Sprintf("created %d buckets", created), nil
+}
+
+// createAttachments creates the Attachments bucket and its AttachmentsByTask
+// and AttachmentsByUser indexes.
+func createAttachments(tx *bbolt.Tx) (string, error) {
+	created := 0
+	for _, name := range [][]byte{[]byte("Attachments"), attachmentsByTask, attachmentsByUser} {
+		if tx.Bucket(name) != nil {
+			continue
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+		created++
+	}
+	return fmt.Sprintf("created %d buckets", created), nil
+}
+
+// createTimeEntries creates the TimeEntries bucket and its TimeEntriesByUser
+// and TimeEntriesByTask indexes.
+func createTimeEntries(tx *bbolt.Tx) (string, error) {
+	created := 0
+	for _, name := range [][]byte{[]byte("TimeEntries"), timeEntriesByUser, timeEntriesByTask} {
+		if tx.Bucket(name) != nil {
+			continue
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+		created++
+	}
+	return fmt.Sprintf("created %d buckets", created), nil
+}
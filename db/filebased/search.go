@@ -0,0 +1,183 @@
+package filebased
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// The search index is inverted: SearchTerms holds one sub-bucket per user and
+// word, keyed by the user key followed by the word, so the words starting with
+// a prefix are neighbours a cursor can walk. SearchDocs keeps the words each
+// task was indexed under, so they can be taken out again when it changes.
+var (
+	searchTerms = []byte("SearchTerms") // user key + word -> {task key: fields}
+	searchDocs  = []byte("SearchDocs")  // task key -> searchDoc
+)
+
+type searchDoc struct {
+	UserID int                          `json:"user_id"`
+	Terms  map[string]model.SearchField `json:"terms"`
+}
+
+func searchTermKey(userID int, token string) []byte {
+	return append(itob(userID), token...)
+}
+
+// commentBodies returns the bodies of the comments on a task. Files from
+// before comments existed have none.
+func commentBodies(tx *bbolt.Tx, taskID int) ([]string, error) {
+	if tx.Bucket(commentsByTask) == nil || tx.Bucket([]byte("Comments")) == nil {
+		return nil, nil
+	}
+	var bodies []string
+	for _, k := range indexKeys(tx, commentsByTask, itob(taskID)) {
+		comment, err := getComment(tx, int(binary.BigEndian.Uint64(k)))
+		if err != nil {
+			return nil, err
+		}
+		bodies = append(bodies, comment.Body)
+	}
+	return bodies, nil
+}
+
+// indexSearch replaces the words task is indexed under. Files from before the
+// search index existed are left alone, the migration that creates it indexes
+// every task.
+func indexSearch(tx *bbolt.Tx, task model.Task) error {
+	if tx.Bucket(searchDocs) == nil {
+		return nil
+	}
+	if err := unindexSearch(tx, task.ID); err != nil {
+		return err
+	}
+
+	var category string
+	if c, err := getCategory(tx, task.CategoryID); err == nil {
+		category = c.Name
+	}
+	comments, err := commentBodies(tx, task.ID)
+	if err != nil {
+		return err
+	}
+
+	doc := searchDoc{UserID: task.UserID, Terms: model.SearchDocument(task.Title, category, comments)}
+	index := tx.Bucket(searchTerms)
+	for token, fields := range doc.Terms {
+		sub, err := index.CreateBucketIfNotExists(searchTermKey(task.UserID, token))
+		if err != nil {
+			return err
+		}
+		if err := sub.Put(itob(task.ID), []byte{byte(fields)}); err != nil {
+			return err
+		}
+	}
+
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(searchDocs).Put(itob(task.ID), docJSON)
+}
+
+// unindexSearch takes a task out of the search index.
+func unindexSearch(tx *bbolt.Tx, taskID int) error {
+	docs := tx.Bucket(searchDocs)
+	if docs == nil {
+		return nil
+	}
+	v := docs.Get(itob(taskID))
+	if v == nil {
+		return nil
+	}
+
+	var doc searchDoc
+	if err := json.Unmarshal(v, &doc); err == nil {
+		for token := range doc.Terms {
+			if err := indexRemove(tx, searchTerms, searchTermKey(doc.UserID, token), itob(taskID)); err != nil {
+				return err
+			}
+		}
+	}
+	return docs.Delete(itob(taskID))
+}
+
+// reindexSearch indexes a task again after its comments changed.
+func reindexSearch(tx *bbolt.Tx, taskID int) error {
+	task, err := getTask(tx, taskID)
+	if err == model.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return indexSearch(tx, task)
+}
+
+// createSearchIndex creates the SearchTerms and SearchDocs buckets and indexes
+// every task.
+func createSearchIndex(tx *bbolt.Tx) (string, error) {
+	for _, name := range [][]byte{searchTerms, searchDocs} {
+		if err := tx.DeleteBucket(name); err != nil && err != bbolt.ErrBucketNotFound {
+			return "", err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+	}
+
+	var tasks []model.Task
+	err := tx.Bucket([]byte("Tasks")).ForEach(func(_, v []byte) error {
+		if task, err := decodeTask(v); err == nil {
+			tasks = append(tasks, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, task := range tasks {
+		if err := indexSearch(tx, task); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("indexed %d tasks", len(tasks)), nil
+}
+
+func (data *Data) SearchTasks(userID int, terms []string) ([]model.SearchMatch, error) {
+	var matches []model.SearchMatch
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		// A term that is a prefix of another walks over the same words
+		seen := map[string]bool{}
+		c := tx.Bucket(searchTerms).Cursor()
+		for _, term := range terms {
+			prefix := searchTermKey(userID, term)
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				if v != nil || seen[string(k)] {
+					continue
+				}
+				seen[string(k)] = true
+
+				token := string(k[8:])
+				err := tx.Bucket(searchTerms).Bucket(k).ForEach(func(taskKey, fields []byte) error {
+					matches = append(matches, model.SearchMatch{
+						TaskID: int(binary.BigEndian.Uint64(taskKey)),
+						Token:  token,
+						Fields: model.SearchField(fields[0]),
+					})
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
@@ -0,0 +1,173 @@
+package filebased
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// Sort indexes hold one sub-bucket per user whose keys are the sort key of a
+// task followed by its key, so a cursor walks the tasks of a user in order.
+// The created order is the key order of TasksByUser.
+var (
+	tasksByDeadline = []byte("TasksByDeadline") // user key -> {deadline + task key}
+	tasksByPriority = []byte("TasksByPriority") // user key -> {priority + task key}
+)
+
+var sortIndexes = map[model.TaskSort][]byte{
+	model.SortDeadline: tasksByDeadline,
+	model.SortPriority: tasksByPriority,
+}
+
+// sortKey encodes k so that byte order is numeric order, negative keys
+// included, followed by the task key.
+func sortKey(k int64, taskID int) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(k)^1<<63)
+	binary.BigEndian.PutUint64(b[8:], uint64(taskID))
+	return b
+}
+
+// indexSort records task in the sort indexes. Files from before they existed
+// are left alone, the migration that creates them fills them.
+func indexSort(tx *bbolt.Tx, task model.Task) error {
+	for sort, name := range sortIndexes {
+		if tx.Bucket(name) == nil {
+			continue
+		}
+		key := sortKey(model.TaskQuery{Sort: sort}.SortKey(task), task.ID)
+		if err := indexAdd(tx, name, itob(task.UserID), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unindexSort(tx *bbolt.Tx, task model.Task) error {
+	for sort, name := range sortIndexes {
+		if tx.Bucket(name) == nil {
+			continue
+		}
+		key := sortKey(model.TaskQuery{Sort: sort}.SortKey(task), task.ID)
+		if err := indexRemove(tx, name, itob(task.UserID), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSortIndexes creates the TasksByDeadline and TasksByPriority indexes
+// and fills them.
+func createSortIndexes(tx *bbolt.Tx) (string, error) {
+	for _, name := range [][]byte{tasksByDeadline, tasksByPriority} {
+		if err := tx.DeleteBucket(name); err != nil && err != bbolt.ErrBucketNotFound {
+			return "", err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+	}
+
+	var tasks []model.Task
+	err := tx.Bucket([]byte("Tasks")).ForEach(func(_, v []byte) error {
+		if task, err := decodeTask(v); err == nil {
+			tasks = append(tasks, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, task := range tasks {
+		if err := indexSort(tx, task); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("indexed %d tasks", len(tasks)), nil
+}
+
+// GetTaskPage seeks past the cursor in the index of the query's order, so
+// the tasks come sorted and reading stops once the page is full. The total
+// is counted in a second walk over the tasks of the user, which a query
+// without a limit does not need.
+func (data *Data) GetTaskPage(userID int, query model.TaskQuery) (model.TaskPage, error) {
+	page := model.TaskPage{Tasks: []model.Task{}}
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		index, created := sortIndexes[query.Sort], query.Sort == model.SortCreated
+		if created {
+			index = tasksByUser
+		}
+		sub := tx.Bucket(index).Bucket(itob(userID))
+		if sub == nil {
+			return nil
+		}
+
+		tasks := tx.Bucket([]byte("Tasks"))
+		c := sub.Cursor()
+		k, next := c.First, c.Next
+		if query.Desc {
+			k, next = c.Last, c.Prev
+		}
+		key, _ := k()
+		if query.After != nil {
+			after := sortKey(query.After.Key, query.After.ID)
+			if created {
+				after = itob(query.After.ID)
+			}
+			key, _ = c.Seek(after)
+			switch {
+			case query.Desc && key == nil:
+				key, _ = c.Last()
+			case query.Desc, bytes.Equal(key, after):
+				key, _ = next()
+			}
+		}
+
+		for ; key != nil; key, _ = next() {
+			task, ok, err := matchTask(tasks, key, query)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if query.Limit > 0 && len(page.Tasks) == query.Limit {
+				page.Next = query.Cursor(page.Tasks[len(page.Tasks)-1])
+				break
+			}
+			page.Tasks = append(page.Tasks, task)
+		}
+
+		if query.Limit == 0 && query.After == nil {
+			page.Total = len(page.Tasks)
+			return nil
+		}
+		return sub.ForEach(func(key, _ []byte) error {
+			_, ok, err := matchTask(tasks, key, query)
+			if ok {
+				page.Total++
+			}
+			return err
+		})
+	})
+	return page, err
+}
+
+// matchTask reads the task an index key ends in and reports whether it
+// matches query. A key whose task is gone does not match.
+func matchTask(tasks *bbolt.Bucket, key []byte, query model.TaskQuery) (model.Task, bool, error) {
+	var task model.Task
+	v := tasks.Get(key[len(key)-8:])
+	if v == nil {
+		return task, false, nil
+	}
+	if err := json.Unmarshal(v, &task); err != nil {
+		return task, false, err
+	}
+	return task, query.Match(task), nil
+}
@@ -0,0 +1,352 @@
+package filebased
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+
+	"go.etcd.io/bbolt"
+)
+
+func getTrashEntry(tx *bbolt.Tx, id int) (model.TrashEntry, error) {
+	v := tx.Bucket([]byte("Trash")).Get(itob(id))
+	if v == nil {
+		return model.TrashEntry{}, model.ErrRecordNotFound
+	}
+	return db.UnmarshalTrash(v)
+}
+
+// putTrash stores entry under a new ID and returns it.
+func putTrash(tx *bbolt.Tx, entry model.TrashEntry) (model.TrashEntry, error) {
+	b := tx.Bucket([]byte("Trash"))
+	id, err := b.NextSequence()
+	if err != nil {
+		return entry, err
+	}
+	entry.ID = int(id)
+
+	entryJSON, err := db.MarshalTrash(entry)
+	if err != nil {
+		return entry, err
+	}
+	if err := b.Put(itob(entry.ID), entryJSON); err != nil {
+		return entry, err
+	}
+	return entry, indexAdd(tx, trashByUser, itob(entry.UserID), itob(entry.ID))
+}
+
+func deleteTrash(tx *bbolt.Tx, entry model.TrashEntry) error {
+	if err := indexRemove(tx, trashByUser, itob(entry.UserID), itob(entry.ID)); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("Trash")).Delete(itob(entry.ID))
+}
+
+// trashTask moves task and its subtasks to a new trash entry.
+func trashTask(tx *bbolt.Tx, task model.Task) (model.TrashEntry, error) {
+	entry := model.TrashEntry{UserID: task.UserID, DeletedAt: db.Now()}
+	if err := trashTasks(tx, &entry, []model.Task{task}); err != nil {
+		return entry, err
+	}
+	return putTrash(tx, entry)
+}
+
+// trashTasks moves tasks, their subtasks and the records hanging off them
+// into entry.
+func trashTasks(tx *bbolt.Tx, entry *model.TrashEntry, tasks []model.Task) error {
+	users := map[int]bool{}
+	add := func(task model.Task) error {
+		if entry.HasTask(task.ID) {
+			return nil
+		}
+		trashed := model.TrashedTask{Task: task}
+		for _, k := range indexKeys(tx, commentsByTask, itob(task.ID)) {
+			comment, err := getComment(tx, int(binary.BigEndian.Uint64(k)))
+			if err != nil {
+				return err
+			}
+			trashed.Comments = append(trashed.Comments, comment)
+		}
+		var err error
+		if trashed.Attachments, err = attachmentsByKeys(tx, indexKeys(tx, attachmentsByTask, itob(task.ID))); err != nil {
+			return err
+		}
+		entries, err := timeEntriesByKeys(tx, indexKeys(tx, timeEntriesByTask, itob(task.ID)))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			trashed.TimeEntries = append(trashed.TimeEntries, model.TrashedTimeEntry(e, entry.DeletedAt))
+		}
+		entry.Tasks = append(entry.Tasks, trashed)
+		users[task.UserID] = true
+		return nil
+	}
+	for _, task := range tasks {
+		if err := add(task); err != nil {
+			return err
+		}
+		for _, subtask := range subtasksOf(tx, task) {
+			if err := add(subtask); err != nil {
+				return err
+			}
+		}
+	}
+
+	for userID := range users {
+		deps, err := getDependencies(tx, userID)
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			if entry.HasTask(dep.TaskID) || entry.HasTask(dep.BlockedByID) {
+				entry.Dependencies = append(entry.Dependencies, dep)
+			}
+		}
+	}
+	model.SortDependencies(entry.Dependencies)
+
+	for _, t := range entry.Tasks {
+		if err := deleteTask(tx, t.Task.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (data *Data) TrashTask(id int) (model.TrashEntry, error) {
+	var entry model.TrashEntry
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		task, err := getTask(tx, id)
+		if err != nil {
+			return err
+		}
+		entry, err = trashTask(tx, task)
+		return err
+	})
+	return entry, err
+}
+
+// TrashCategory treats the tasks of the category like DeleteCategory, tasks
+// deleted with it going to its entry.
+func (data *Data) TrashCategory(id int, opts model.CategoryDelete) (model.TrashEntry, error) {
+	if err := opts.Validate(id); err != nil {
+		return model.TrashEntry{}, err
+	}
+
+	var entry model.TrashEntry
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		category, err := getCategory(tx, id)
+		if err != nil {
+			return err
+		}
+		entry = model.TrashEntry{UserID: category.UserID, Category: &category, DeletedAt: db.Now()}
+
+		tasks := tasksByKeys(tx, indexKeys(tx, tasksByCategory, itob(id)))
+		if len(tasks) > 0 {
+			switch opts.Mode {
+			case model.CategoryDeleteCascade:
+				err = trashTasks(tx, &entry, tasks)
+			case model.CategoryDeleteReassign:
+				err = reassignTasks(tx, tasks, opts.ReassignTo)
+			default:
+				err = model.ErrCategoryNotEmpty
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := deleteCategory(tx, id); err != nil {
+			return err
+		}
+		entry, err = putTrash(tx, entry)
+		return err
+	})
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	return entry, nil
+}
+
+func (data *Data) GetTrash(userID int) ([]model.TrashEntry, error) {
+	var entries []model.TrashEntry
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		for _, k := range indexKeys(tx, trashByUser, itob(userID)) {
+			entry, err := getTrashEntry(tx, int(binary.BigEndian.Uint64(k)))
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (data *Data) GetTrashEntry(id int) (*model.TrashEntry, error) {
+	var entry model.TrashEntry
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		entry, err = getTrashEntry(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RestoreTrash writes the records back in the transaction that drops the
+// entry, so a refused task leaves the entry as it was.
+func (data *Data) RestoreTrash(id int) (model.TrashEntry, error) {
+	var entry model.TrashEntry
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if entry, err = getTrashEntry(tx, id); err != nil {
+			return err
+		}
+
+		now := db.Now()
+		if category := entry.Category; category != nil {
+			restored := *category
+			restored.Version++
+			restored.UpdatedAt = now
+			if err := putCategory(tx, restored); err != nil {
+				return err
+			}
+		}
+
+		for _, t := range entry.RestoreOrder() {
+			task := t.Task
+			var tagIDs []int
+			for _, tagID := range task.TagIDs {
+				if _, err := getTag(tx, tagID); err == nil {
+					tagIDs = append(tagIDs, tagID)
+				}
+			}
+			task.TagIDs = tagIDs
+			if err := checkTaskRefs(tx, task); err != nil {
+				return err
+			}
+			task.Version++
+			task.UpdatedAt = now
+			if err := putTask(tx, task); err != nil {
+				return err
+			}
+
+			for _, comment := range t.Comments {
+				if err := putComment(tx, comment); err != nil {
+					return err
+				}
+			}
+			for _, attachment := range t.Attachments {
+				if err := putAttachment(tx, attachment); err != nil {
+					return err
+				}
+			}
+			for _, e := range t.TimeEntries {
+				if err := putTimeEntry(tx, e); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, dep := range entry.Dependencies {
+			if err := restoreDependency(tx, dep); err != nil {
+				return err
+			}
+		}
+		return deleteTrash(tx, entry)
+	})
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	return entry, nil
+}
+
+// restoreDependency adds dep back unless one of its tasks is gone, it is
+// there already or it would close a cycle.
+func restoreDependency(tx *bbolt.Tx, dep model.Dependency) error {
+	for _, id := range []int{dep.TaskID, dep.BlockedByID} {
+		if _, err := getTask(tx, id); err == model.ErrRecordNotFound {
+			return nil
+		}
+	}
+	deps, err := getDependencies(tx, dep.UserID)
+	if err != nil {
+		return err
+	}
+	for _, d := range deps {
+		if d == dep {
+			return nil
+		}
+	}
+	if model.CheckDependency(deps, dep) != nil {
+		return nil
+	}
+	return updateDependencies(tx, dep.UserID, func(deps []model.Dependency) []model.Dependency {
+		return append(deps, dep)
+	})
+}
+
+func (data *Data) PurgeTrash(id int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		entry, err := getTrashEntry(tx, id)
+		if err == model.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return deleteTrash(tx, entry)
+	})
+}
+
+// PurgeTrashBefore reads every entry, the trash has no index by time.
+func (data *Data) PurgeTrashBefore(t time.Time) ([]model.TrashEntry, error) {
+	var purged []model.TrashEntry
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		err := tx.Bucket([]byte("Trash")).ForEach(func(_, v []byte) error {
+			entry, err := db.UnmarshalTrash(v)
+			if err != nil {
+				return err
+			}
+			if entry.DeletedAt.Before(t) {
+				purged = append(purged, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, entry := range purged {
+			if err := deleteTrash(tx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// createTrash creates the Trash bucket and its TrashByUser index.
+func createTrash(tx *bbolt.Tx) (string, error) {
+	created := 0
+	for _, name := range [][]byte{[]byte("Trash"), trashByUser} {
+		if tx.Bucket(name) != nil {
+			continue
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+		created++
+	}
+	return fmt.Sprintf("created %d buckets", created), nil
+}
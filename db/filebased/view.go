@@ -0,0 +1,132 @@
+package filebased
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/model"
+
+	"go.etcd.io/bbolt"
+)
+
+func getView(tx *bbolt.Tx, id int) (model.SavedView, error) {
+	var view model.SavedView
+	v := tx.Bucket([]byte("Views")).Get(itob(id))
+	if v == nil {
+		return view, model.ErrRecordNotFound
+	}
+	err := json.Unmarshal(v, &view)
+	return view, err
+}
+
+func viewsOfUser(tx *bbolt.Tx, userID int) []model.SavedView {
+	var views []model.SavedView
+	for _, k := range indexKeys(tx, viewsByUser, itob(userID)) {
+		view, err := getView(tx, int(binary.BigEndian.Uint64(k)))
+		if err != nil {
+			continue
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// putView writes view under its ID once no other view of its user has the
+// name.
+func putView(tx *bbolt.Tx, view model.SavedView) error {
+	for _, other := range viewsOfUser(tx, view.UserID) {
+		if other.ID != view.ID && model.SameViewName(other.Name, view.Name) {
+			return model.ErrViewExists
+		}
+	}
+
+	viewJSON, err := json.Marshal(view)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket([]byte("Views")).Put(itob(view.ID), viewJSON); err != nil {
+		return err
+	}
+	return indexAdd(tx, viewsByUser, itob(view.UserID), itob(view.ID))
+}
+
+func (data *Data) GetViewsByUserID(userID int) ([]model.SavedView, error) {
+	var views []model.SavedView
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		views = viewsOfUser(tx, userID)
+		return nil
+	})
+	return views, err
+}
+
+func (data *Data) GetViewByID(id int) (*model.SavedView, error) {
+	var view model.SavedView
+	err := data.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		view, err = getView(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+func (data *Data) CreateView(view model.SavedView) (model.SavedView, error) {
+	err := data.DB.Update(func(tx *bbolt.Tx) error {
+		id, err := tx.Bucket([]byte("Views")).NextSequence()
+		if err != nil {
+			return err
+		}
+		view.ID = int(id)
+		return putView(tx, view)
+	})
+	if err != nil {
+		return model.SavedView{}, err
+	}
+	return view, nil
+}
+
+// UpdateView renames, re-queries or (un)pins a view, it keeps its user.
+func (data *Data) UpdateView(view model.SavedView) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		prev, err := getView(tx, view.ID)
+		if err != nil {
+			return err
+		}
+		view.UserID = prev.UserID
+		return putView(tx, view)
+	})
+}
+
+func (data *Data) DeleteView(id int) error {
+	return data.DB.Update(func(tx *bbolt.Tx) error {
+		view, err := getView(tx, id)
+		if err == model.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := indexRemove(tx, viewsByUser, itob(view.UserID), itob(id)); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("Views")).Delete(itob(id))
+	})
+}
+
+// createViews creates the Views bucket and its ViewsByUser index.
+func createViews(tx *bbolt.Tx) (string, error) {
+	created := 0
+	for _, name := range [][]byte{[]byte("Views"), viewsByUser} {
+		if tx.Bucket(name) != nil {
+			continue
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return "", fmt.Errorf("create %s bucket: %v", name, err)
+		}
+		created++
+	}
+	return fmt.Sprintf("created %d buckets", created), nil
+}
@@ -0,0 +1,1538 @@
+package memory
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+// Data keeps everything in maps. It behaves like the bbolt backend and is
+// meant for tests and throwaway local runs, nothing survives a restart.
+type Data struct {
+	mu          sync.RWMutex
+	tasks       map[int]model.Task
+	categories  map[int]model.Category
+	users       map[int]model.User
+	sessions    map[string]model.Session
+	workflows   map[int]model.Workflow
+	tags        map[int]model.Tag
+	comments    map[int]model.Comment
+	attachments map[int]model.Attachment
+	timeEntries map[int]model.TimeEntry
+	views       map[int]model.SavedView
+	trash       map[int]model.TrashEntry
+	// dependencies is a set of edges, the key holds the whole edge
+	dependencies map[model.Dependency]bool
+
+	// Sequences, the next generated ID is one past the largest seen
+	taskSeq       int
+	categorySeq   int
+	userSeq       int
+	sessionSeq    int
+	tagSeq        int
+	commentSeq    int
+	attachmentSeq int
+	timeEntrySeq  int
+	viewSeq       int
+	trashSeq      int
+}
+
+func InitDB() *Data {
+	return &Data{
+		tasks:       map[int]model.Task{},
+		categories:  map[int]model.Category{},
+		users:       map[int]model.User{},
+		sessions:    map[string]model.Session{},
+		workflows:   map[int]model.Workflow{},
+		tags:        map[int]model.Tag{},
+		comments:    map[int]model.Comment{},
+		attachments: map[int]model.Attachment{},
+		timeEntries: map[int]model.TimeEntry{},
+		views:       map[int]model.SavedView{},
+		trash:       map[int]model.TrashEntry{},
+
+		dependencies: map[model.Dependency]bool{},
+	}
+}
+
+func (data *Data) CloseDB() error {
+	return nil
+}
+
+// sortedTasks returns the tasks matching keep in ID order. Callers hold mu.
+func (data *Data) sortedTasks(keep func(model.Task) bool) []model.Task {
+	var tasks []model.Task
+	for _, task := range data.tasks {
+		if keep(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+// sortedCategories returns the categories matching keep in ID order. Callers hold mu.
+func (data *Data) sortedCategories(keep func(model.Category) bool) []model.Category {
+	var categories []model.Category
+	for _, category := range data.categories {
+		if keep(category) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].ID < categories[j].ID })
+	return categories
+}
+
+// sortedSessions returns the sessions matching keep in token order, the order
+// bbolt iterates the Sessions bucket in. Callers hold mu.
+func (data *Data) sortedSessions(keep func(model.Session) bool) []model.Session {
+	sessions := []model.Session{}
+	for _, session := range data.sessions {
+		if keep(session) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Token < sessions[j].Token })
+	return sessions
+}
+
+func nextID(seq *int, id int) int {
+	if id <= 0 {
+		*seq++
+		return *seq
+	}
+	if id > *seq {
+		*seq = id
+	}
+	return id
+}
+
+// checkTaskCategory enforces that task points at a category its user may use.
+// Callers hold mu.
+func (data *Data) checkTaskCategory(task model.Task) error {
+	category, ok := data.categories[task.CategoryID]
+	if !ok {
+		return model.ErrCategoryNotFound
+	}
+	if !model.CategoryAllows(category, task.UserID) {
+		return model.ErrCategoryNotOwned
+	}
+	return nil
+}
+
+// checkTaskParent enforces that a subtask points at a top-level task of its
+// user and has no subtasks of its own. A task without an ID yet has none.
+func (data *Data) checkTaskParent(task model.Task) error {
+	if task.ParentID == 0 {
+		return nil
+	}
+	parent, ok := data.tasks[task.ParentID]
+	if !ok {
+		return model.ErrParentNotFound
+	}
+	return model.CheckParent(task, parent, task.ID > 0 && len(data.subtasksOf(task.ID)) > 0)
+}
+
+// checkTaskTags enforces that every tag of task exists and belongs to its
+// user. Callers hold mu.
+func (data *Data) checkTaskTags(task model.Task) error {
+	for _, id := range task.TagIDs {
+		tag, ok := data.tags[id]
+		if !ok {
+			return model.ErrTagNotFound
+		}
+		if err := model.CheckTaskTag(task, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (data *Data) subtasksOf(id int) []model.Task {
+	return data.sortedTasks(func(t model.Task) bool { return t.ParentID == id })
+}
+
+// deleteTask removes a task together with its subtasks, and the edges,
+// comments, time entries and attachment records of both.
+func (data *Data) deleteTask(id int) {
+	for _, subtask := range data.subtasksOf(id) {
+		data.deleteTask(subtask.ID)
+	}
+	for dep := range data.dependencies {
+		if dep.TaskID == id || dep.BlockedByID == id {
+			delete(data.dependencies, dep)
+		}
+	}
+	for commentID, comment := range data.comments {
+		if comment.TaskID == id {
+			delete(data.comments, commentID)
+		}
+	}
+	for attachmentID, attachment := range data.attachments {
+		if attachment.TaskID == id {
+			delete(data.attachments, attachmentID)
+		}
+	}
+	for entryID, entry := range data.timeEntries {
+		if entry.TaskID == id {
+			delete(data.timeEntries, entryID)
+		}
+	}
+	delete(data.tasks, id)
+}
+
+// checkTaskRefs enforces the category, parent and tags of task. Callers
+// hold mu.
+func (data *Data) checkTaskRefs(task model.Task) error {
+	if err := data.checkTaskCategory(task); err != nil {
+		return err
+	}
+	if err := data.checkTaskParent(task); err != nil {
+		return err
+	}
+	return data.checkTaskTags(task)
+}
+
+func (data *Data) StoreTask(task model.Task) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	if err := data.checkTaskRefs(task); err != nil {
+		return err
+	}
+	data.storeTask(task)
+	return nil
+}
+
+// storeTask writes a checked task and returns it as stored. Callers hold mu.
+func (data *Data) storeTask(task model.Task) model.Task {
+	task.ID = nextID(&data.taskSeq, task.ID)
+	task.TagIDs = append([]int(nil), task.TagIDs...)
+
+	// Storing over an existing task replaces it without a version check
+	task.Version = data.tasks[task.ID].Version + 1
+	task.UpdatedAt = db.Now()
+	data.tasks[task.ID] = task
+	return task
+}
+
+// UpdateTask replaces the task stored under id. A non-zero task.Version must
+// match the stored one.
+func (data *Data) UpdateTask(id int, task model.Task) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	task.ID = id
+	prev, err := data.checkTaskUpdate(task)
+	if err != nil {
+		return err
+	}
+	data.updateTask(prev, task)
+	return nil
+}
+
+// checkTaskUpdate checks task as the replacement of the stored task with its
+// ID, which it returns. Callers hold mu.
+func (data *Data) checkTaskUpdate(task model.Task) (model.Task, error) {
+	prev, ok := data.tasks[task.ID]
+	if !ok {
+		return prev, model.ErrRecordNotFound
+	}
+	if task.Version != 0 && task.Version != prev.Version {
+		return prev, model.ErrVersionConflict
+	}
+	return prev, data.checkTaskRefs(task)
+}
+
+// updateTask writes a checked task over prev and returns it as stored.
+// Callers hold mu.
+func (data *Data) updateTask(prev, task model.Task) model.Task {
+	task.TagIDs = append([]int(nil), task.TagIDs...)
+	task.Version = prev.Version + 1
+	task.UpdatedAt = db.Now()
+	data.tasks[task.ID] = task
+	return task
+}
+
+// WriteTasks keeps a copy of what a task write touches while atomic, to put
+// back when a write is refused.
+func (data *Data) WriteTasks(writes []model.TaskWrite, atomic bool) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	var restore func()
+	if atomic {
+		restore = data.snapshotTasks()
+	}
+	for i := range writes {
+		w := &writes[i]
+		if w.Err != nil {
+			continue
+		}
+
+		var prev model.Task
+		switch w.Op {
+		case model.TaskOpCreate:
+			w.Err = data.checkTaskRefs(w.Task)
+		case model.TaskOpDelete:
+			var ok bool
+			if prev, ok = data.tasks[w.Task.ID]; !ok {
+				w.Err = model.ErrRecordNotFound
+			} else if w.Task.Version != 0 && w.Task.Version != prev.Version {
+				w.Err = model.ErrVersionConflict
+			}
+		default:
+			prev, w.Err = data.checkTaskUpdate(w.Task)
+		}
+		if w.Err != nil {
+			if atomic {
+				restore()
+				return model.ErrBulkAborted
+			}
+			continue
+		}
+
+		switch w.Op {
+		case model.TaskOpCreate:
+			w.Task = data.storeTask(w.Task)
+		case model.TaskOpDelete:
+			w.Task = prev
+			w.TrashID = data.trashTask(prev).ID
+		default:
+			w.Task = data.updateTask(prev, w.Task)
+		}
+	}
+	return nil
+}
+
+// snapshotTasks copies the tasks, what deleting one drops and the trash it
+// goes to, and returns the func that puts the copy back. Callers hold mu.
+func (data *Data) snapshotTasks() func() {
+	tasks, taskSeq := maps.Clone(data.tasks), data.taskSeq
+	dependencies, comments := maps.Clone(data.dependencies), maps.Clone(data.comments)
+	attachments, timeEntries := maps.Clone(data.attachments), maps.Clone(data.timeEntries)
+	trash, trashSeq := maps.Clone(data.trash), data.trashSeq
+	return func() {
+		data.tasks, data.taskSeq = tasks, taskSeq
+		data.dependencies, data.comments = dependencies, comments
+		data.attachments, data.timeEntries = attachments, timeEntries
+		data.trash, data.trashSeq = trash, trashSeq
+	}
+}
+
+func (data *Data) DeleteTask(id int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	data.deleteTask(id)
+	return nil
+}
+
+func (data *Data) GetTaskByID(id int) (*model.Task, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	task, ok := data.tasks[id]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	return &task, nil
+}
+
+func (data *Data) GetTasksByUserID(userID int) ([]model.Task, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedTasks(func(t model.Task) bool { return t.UserID == userID }), nil
+}
+
+func (data *Data) GetTaskListByCategory(categoryID int) ([]model.TaskCategory, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	category, ok := data.categories[categoryID]
+	if !ok {
+		return nil, fmt.Errorf("error fetching category: record not found")
+	}
+
+	var taskCategories []model.TaskCategory
+	for _, task := range data.sortedTasks(func(t model.Task) bool { return t.CategoryID == categoryID }) {
+		taskCategories = append(taskCategories, model.TaskCategory{ID: task.ID, Title: task.Title, Category: category.Name})
+	}
+	if len(taskCategories) == 0 {
+		return nil, fmt.Errorf("no tasks found for category ID: %d", categoryID)
+	}
+	return taskCategories, nil
+}
+
+// GetTaskListByCategoryAndUser returns tasks filtered by both category and user
+func (data *Data) GetTaskListByCategoryAndUser(categoryID, userID int) ([]model.TaskCategory, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	category, ok := data.categories[categoryID]
+	if !ok {
+		return nil, fmt.Errorf("error fetching category: record not found")
+	}
+
+	var taskCategories []model.TaskCategory
+	for _, task := range data.sortedTasks(func(t model.Task) bool { return t.CategoryID == categoryID && t.UserID == userID }) {
+		taskCategories = append(taskCategories, model.TaskCategory{ID: task.ID, Title: task.Title, Category: category.Name})
+	}
+	return taskCategories, nil
+}
+
+func (data *Data) StoreCategory(category model.Category) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	if data.categoryNameTaken(category) {
+		return model.ErrCategoryExists
+	}
+	category.ID = nextID(&data.categorySeq, category.ID)
+
+	// Storing over an existing category replaces it without a version check
+	category.Version = data.categories[category.ID].Version + 1
+	category.UpdatedAt = db.Now()
+	data.categories[category.ID] = category
+	return nil
+}
+
+// categoryNameTaken reports whether another category of the user of category
+// has its name. Callers hold mu.
+func (data *Data) categoryNameTaken(category model.Category) bool {
+	for _, other := range data.categories {
+		if other.ID != category.ID && other.UserID == category.UserID && model.SameCategoryName(other.Name, category.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateCategory replaces the category stored under id, with the same version
+// check as UpdateTask.
+func (data *Data) UpdateCategory(id int, category model.Category) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	prev, ok := data.categories[id]
+	if !ok {
+		return model.ErrRecordNotFound
+	}
+	if category.Version != 0 && category.Version != prev.Version {
+		return model.ErrVersionConflict
+	}
+	category.ID = id
+	if data.categoryNameTaken(category) {
+		return model.ErrCategoryExists
+	}
+
+	category.Version = prev.Version + 1
+	category.UpdatedAt = db.Now()
+	data.categories[id] = category
+	return nil
+}
+
+// DeleteCategory removes a category and, depending on opts, rejects the delete
+// while it has tasks, deletes them too or moves them to another category.
+func (data *Data) DeleteCategory(id int, opts model.CategoryDelete) error {
+	if err := opts.Validate(id); err != nil {
+		return err
+	}
+
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	if _, ok := data.categories[id]; !ok {
+		return nil
+	}
+
+	tasks := data.sortedTasks(func(t model.Task) bool { return t.CategoryID == id })
+	if len(tasks) > 0 {
+		switch opts.Mode {
+		case model.CategoryDeleteCascade:
+			for _, task := range tasks {
+				data.deleteTask(task.ID)
+			}
+		case model.CategoryDeleteReassign:
+			if err := data.reassignTasks(tasks, opts.ReassignTo); err != nil {
+				return err
+			}
+		default:
+			return model.ErrCategoryNotEmpty
+		}
+	}
+
+	delete(data.categories, id)
+	return nil
+}
+
+// reassignTasks moves tasks to the category with id to, checking every task
+// first so a failure leaves nothing half moved. Callers hold mu.
+func (data *Data) reassignTasks(tasks []model.Task, to int) error {
+	for _, task := range tasks {
+		task.CategoryID = to
+		if err := data.checkTaskCategory(task); err != nil {
+			return err
+		}
+	}
+	for _, task := range tasks {
+		task.CategoryID = to
+		task.Version++
+		task.UpdatedAt = db.Now()
+		data.tasks[task.ID] = task
+	}
+	return nil
+}
+
+func (data *Data) GetCategoryByID(id int) (*model.Category, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	category, ok := data.categories[id]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	return &category, nil
+}
+
+func (data *Data) GetCategories() ([]model.Category, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedCategories(func(model.Category) bool { return true }), nil
+}
+
+// GetCategoriesByUserID returns categories for specific user only
+func (data *Data) GetCategoriesByUserID(userID int) ([]model.Category, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedCategories(func(c model.Category) bool { return c.UserID == userID }), nil
+}
+
+func (data *Data) GetUserByEmail(email string) (model.User, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	for _, user := range data.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return model.User{}, nil // Return an empty User struct and nil error if not found
+}
+
+func (data *Data) CreateUser(user model.User) (model.User, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	for _, u := range data.users {
+		if u.Email == user.Email {
+			return model.User{}, fmt.Errorf("email already exists")
+		}
+	}
+
+	user.ID = nextID(&data.userSeq, 0)
+	data.users[user.ID] = user
+
+	data.createDefaultCategories(user.ID)
+	return user, nil
+}
+
+func (data *Data) UpdateUser(user model.User) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	if _, ok := data.users[user.ID]; !ok {
+		return model.ErrRecordNotFound
+	}
+	for _, u := range data.users {
+		if u.Email == user.Email && u.ID != user.ID {
+			return fmt.Errorf("email already exists")
+		}
+	}
+
+	user.UpdatedAt = db.Now()
+	data.users[user.ID] = user
+	return nil
+}
+
+// GetUsers retrieves all users from the database
+func (data *Data) GetUsers() ([]model.User, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	users := []model.User{}
+	for _, user := range data.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (data *Data) GetUserTaskCategory() ([]model.UserTaskCategory, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	users := make([]model.User, 0, len(data.users))
+	for _, user := range data.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	var results []model.UserTaskCategory
+	for _, user := range users {
+		for _, task := range data.sortedTasks(func(t model.Task) bool { return t.UserID == user.ID }) {
+			categoryName := "Unknown"
+			if category, ok := data.categories[task.CategoryID]; ok {
+				categoryName = category.Name
+			}
+
+			results = append(results, model.UserTaskCategory{
+				ID:       user.ID,
+				Fullname: user.Fullname,
+				Email:    user.Email,
+				Task:     task.Title,
+				Deadline: task.Deadline,
+				Priority: task.Priority,
+				Status:   task.Status,
+				Category: categoryName,
+			})
+		}
+	}
+	return results, nil
+}
+
+// CreateDefaultCategoriesForUser creates default categories for a user that has none
+func (data *Data) CreateDefaultCategoriesForUser(userID int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	data.createDefaultCategories(userID)
+	return nil
+}
+
+// createDefaultCategories gives userID the initial categories unless it
+// already has some. Callers hold mu.
+func (data *Data) createDefaultCategories(userID int) {
+	for _, category := range data.categories {
+		if category.UserID == userID {
+			return
+		}
+	}
+
+	for _, name := range []string{"Work", "Personal", "Study", "Health", "Home"} {
+		id := nextID(&data.categorySeq, 0)
+		data.categories[id] = model.Category{ID: id, Name: name, UserID: userID, Version: 1, UpdatedAt: db.Now()}
+	}
+}
+
+func (data *Data) AddSession(session model.Session) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	// Every login gets its own ID, rotated sessions keep the one they had
+	if session.ID <= 0 {
+		session.ID = nextID(&data.sessionSeq, 0)
+	}
+	data.sessions[session.Token] = session
+	return nil
+}
+
+func (data *Data) UpdateSession(session model.Session) error {
+	return data.AddSession(session) // Reuse AddSession as it will overwrite the existing entry
+}
+
+func (data *Data) DeleteSession(token string) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	delete(data.sessions, token)
+	return nil
+}
+
+func (data *Data) SessionAvailEmail(email string) (model.Session, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	sessions := data.sortedSessions(func(s model.Session) bool { return s.Email == email && !s.Rotated })
+	if len(sessions) == 0 {
+		return model.Session{}, fmt.Errorf("no session available for email: %s", email)
+	}
+	return sessions[0], nil
+}
+
+func (data *Data) SessionAvailToken(token string) (model.Session, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	session, ok := data.sessions[token]
+	if !ok {
+		return model.Session{}, fmt.Errorf("no session available for token: %s", token)
+	}
+	return session, nil
+}
+
+// SessionByRefreshToken finds the session that was issued the given refresh
+// token, including sessions whose refresh token has already been rotated.
+func (data *Data) SessionByRefreshToken(refreshToken string) (model.Session, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	for _, session := range data.sessions {
+		if session.RefreshToken != "" && session.RefreshToken == refreshToken {
+			return session, nil
+		}
+	}
+	return model.Session{}, fmt.Errorf("no session available for refresh token")
+}
+
+// RotateSession marks old as rotated and stores next under the same lock,
+// so a refresh token can never be exchanged twice.
+func (data *Data) RotateSession(old model.Session, next model.Session) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	stored, ok := data.sessions[old.Token]
+	if !ok {
+		return fmt.Errorf("session not found")
+	}
+	if stored.Rotated {
+		return fmt.Errorf("session already rotated")
+	}
+
+	old.Rotated = true
+	data.sessions[old.Token] = old
+	data.sessions[next.Token] = next
+	return nil
+}
+
+// TouchSession records that the session behind token was just used.
+func (data *Data) TouchSession(token string, at time.Time) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	session, ok := data.sessions[token]
+	if !ok {
+		return fmt.Errorf("session not found")
+	}
+	session.LastSeen = at
+	data.sessions[token] = session
+	return nil
+}
+
+// GetSessionsByEmail returns every live session of a user, one per login.
+// Sessions whose refresh token was already rotated are left out.
+func (data *Data) GetSessionsByEmail(email string) ([]model.Session, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedSessions(func(s model.Session) bool { return s.Email == email && !s.Rotated }), nil
+}
+
+// deleteSessions removes every session matching match. Callers hold mu.
+func (data *Data) deleteSessions(match func(model.Session) bool) {
+	for token, session := range data.sessions {
+		if match(session) {
+			delete(data.sessions, token)
+		}
+	}
+}
+
+// DeleteSessionsByID removes the session with the given ID together with the
+// records it was rotated from, which share the ID.
+func (data *Data) DeleteSessionsByID(id int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	data.deleteSessions(func(s model.Session) bool { return s.ID == id })
+	return nil
+}
+
+// DeleteSessionsByEmail removes every session of a user, logging them out on all devices.
+func (data *Data) DeleteSessionsByEmail(email string) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	data.deleteSessions(func(s model.Session) bool { return s.Email == email })
+	return nil
+}
+
+// DeleteSessionFamily removes every session rotated from the same login.
+func (data *Data) DeleteSessionFamily(familyID string) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	data.deleteSessions(func(s model.Session) bool { return s.FamilyID == familyID })
+	return nil
+}
+
+func (data *Data) GetWorkflow(userID int) (*model.Workflow, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	workflow, ok := data.workflows[userID]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	workflow = cloneWorkflow(workflow)
+	return &workflow, nil
+}
+
+func (data *Data) SaveWorkflow(workflow model.Workflow) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	data.workflows[workflow.UserID] = cloneWorkflow(workflow)
+	return nil
+}
+
+// cloneWorkflow copies the slices and map of w, so callers never share them
+// with the store like they would not with a serialized copy.
+func cloneWorkflow(w model.Workflow) model.Workflow {
+	w.Statuses = append([]model.WorkflowStatus(nil), w.Statuses...)
+	transitions := make(map[string][]string, len(w.Transitions))
+	for from, to := range w.Transitions {
+		transitions[from] = append([]string(nil), to...)
+	}
+	w.Transitions = transitions
+	return w
+}
+
+func (data *Data) GetDependencies(userID int) ([]model.Dependency, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.dependenciesOf(userID), nil
+}
+
+func (data *Data) dependenciesOf(userID int) []model.Dependency {
+	var deps []model.Dependency
+	for dep := range data.dependencies {
+		if dep.UserID == userID {
+			deps = append(deps, dep)
+		}
+	}
+	model.SortDependencies(deps)
+	return deps
+}
+
+func (data *Data) AddDependency(dep model.Dependency) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	for _, id := range []int{dep.TaskID, dep.BlockedByID} {
+		task, ok := data.tasks[id]
+		if !ok {
+			return model.ErrRecordNotFound
+		}
+		if task.UserID != dep.UserID {
+			return fmt.Errorf("%w: task %d belongs to a different user", model.ErrInvalidDependency, id)
+		}
+	}
+
+	if data.dependencies[dep] {
+		return nil
+	}
+	if err := model.CheckDependency(data.dependenciesOf(dep.UserID), dep); err != nil {
+		return err
+	}
+	data.dependencies[dep] = true
+	return nil
+}
+
+func (data *Data) RemoveDependency(dep model.Dependency) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	delete(data.dependencies, dep)
+	return nil
+}
+
+func (data *Data) GetTagsByUserID(userID int) ([]model.Tag, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.tagsOf(userID), nil
+}
+
+// tagsOf returns the tags of a user in ID order. Callers hold mu.
+func (data *Data) tagsOf(userID int) []model.Tag {
+	var tags []model.Tag
+	for _, tag := range data.tags {
+		if tag.UserID == userID {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].ID < tags[j].ID })
+	return tags
+}
+
+func (data *Data) GetTagByID(id int) (*model.Tag, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	tag, ok := data.tags[id]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	return &tag, nil
+}
+
+// putTag stores tag once no other tag of its user has the name. Callers hold
+// mu.
+func (data *Data) putTag(tag model.Tag) error {
+	for _, other := range data.tagsOf(tag.UserID) {
+		if other.ID != tag.ID && model.SameTagName(other.Name, tag.Name) {
+			return model.ErrTagExists
+		}
+	}
+	data.tags[tag.ID] = tag
+	return nil
+}
+
+func (data *Data) CreateTag(tag model.Tag) (model.Tag, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	tag.ID = data.tagSeq + 1
+	if err := data.putTag(tag); err != nil {
+		return model.Tag{}, err
+	}
+	data.tagSeq = tag.ID
+	return tag, nil
+}
+
+func (data *Data) UpdateTag(tag model.Tag) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	prev, ok := data.tags[tag.ID]
+	if !ok {
+		return model.ErrRecordNotFound
+	}
+	tag.UserID = prev.UserID
+	return data.putTag(tag)
+}
+
+func (data *Data) DeleteTag(id int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	for _, task := range data.sortedTasks(func(t model.Task) bool { return t.HasTags([]int{id}, model.TagMatchAny) }) {
+		var tagIDs []int
+		for _, tagID := range task.TagIDs {
+			if tagID != id {
+				tagIDs = append(tagIDs, tagID)
+			}
+		}
+		task.TagIDs = tagIDs
+		task.Version++
+		task.UpdatedAt = db.Now()
+		data.tasks[task.ID] = task
+	}
+	delete(data.tags, id)
+	return nil
+}
+
+func (data *Data) GetViewsByUserID(userID int) ([]model.SavedView, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.viewsOf(userID), nil
+}
+
+// viewsOf returns the views of a user in ID order. Callers hold mu.
+func (data *Data) viewsOf(userID int) []model.SavedView {
+	var views []model.SavedView
+	for _, view := range data.views {
+		if view.UserID == userID {
+			views = append(views, view)
+		}
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].ID < views[j].ID })
+	return views
+}
+
+func (data *Data) GetViewByID(id int) (*model.SavedView, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	view, ok := data.views[id]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	return &view, nil
+}
+
+// putView stores view once no other view of its user has the name. Callers
+// hold mu.
+func (data *Data) putView(view model.SavedView) error {
+	for _, other := range data.viewsOf(view.UserID) {
+		if other.ID != view.ID && model.SameViewName(other.Name, view.Name) {
+			return model.ErrViewExists
+		}
+	}
+	data.views[view.ID] = view
+	return nil
+}
+
+func (data *Data) CreateView(view model.SavedView) (model.SavedView, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	view.ID = data.viewSeq + 1
+	if err := data.putView(view); err != nil {
+		return model.SavedView{}, err
+	}
+	data.viewSeq = view.ID
+	return view, nil
+}
+
+func (data *Data) UpdateView(view model.SavedView) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	prev, ok := data.views[view.ID]
+	if !ok {
+		return model.ErrRecordNotFound
+	}
+	view.UserID = prev.UserID
+	return data.putView(view)
+}
+
+func (data *Data) DeleteView(id int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	delete(data.views, id)
+	return nil
+}
+
+// cloneComment copies the history of comment, so callers cannot change a
+// stored comment through it.
+func cloneComment(comment model.Comment) model.Comment {
+	comment.History = append([]model.CommentRevision(nil), comment.History...)
+	return comment
+}
+
+func (data *Data) GetComments(taskID int) ([]model.Comment, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	var comments []model.Comment
+	for _, comment := range data.comments {
+		if comment.TaskID == taskID {
+			comments = append(comments, cloneComment(comment))
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments, nil
+}
+
+func (data *Data) GetCommentByID(id int) (*model.Comment, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	comment, ok := data.comments[id]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	comment = cloneComment(comment)
+	return &comment, nil
+}
+
+func (data *Data) AddComment(comment model.Comment) (model.Comment, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	if _, ok := data.tasks[comment.TaskID]; !ok {
+		return model.Comment{}, model.ErrRecordNotFound
+	}
+	data.commentSeq++
+	comment.ID = data.commentSeq
+	comment.CreatedAt = db.Now()
+	comment.UpdatedAt = comment.CreatedAt
+	comment.History = nil
+	data.comments[comment.ID] = comment
+	return comment, nil
+}
+
+func (data *Data) UpdateComment(id int, body string) (model.Comment, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	comment, ok := data.comments[id]
+	if !ok {
+		return model.Comment{}, model.ErrRecordNotFound
+	}
+	comment = cloneComment(comment)
+	comment.Revise(body, db.Now())
+	data.comments[id] = comment
+	return cloneComment(comment), nil
+}
+
+func (data *Data) DeleteComment(id int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	delete(data.comments, id)
+	return nil
+}
+
+// sortedAttachments returns the attachments matching keep in ID order.
+// Callers hold mu.
+func (data *Data) sortedAttachments(keep func(model.Attachment) bool) []model.Attachment {
+	var attachments []model.Attachment
+	for _, attachment := range data.attachments {
+		if keep(attachment) {
+			attachments = append(attachments, attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments
+}
+
+func (data *Data) GetAttachments(taskID int) ([]model.Attachment, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedAttachments(func(a model.Attachment) bool { return a.TaskID == taskID }), nil
+}
+
+func (data *Data) GetAttachmentsByUserID(userID int) ([]model.Attachment, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedAttachments(func(a model.Attachment) bool { return a.UserID == userID }), nil
+}
+
+func (data *Data) GetAttachmentByID(id int) (*model.Attachment, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	attachment, ok := data.attachments[id]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	return &attachment, nil
+}
+
+func (data *Data) AddAttachment(attachment model.Attachment, quota int64) (model.Attachment, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	if _, ok := data.tasks[attachment.TaskID]; !ok {
+		return model.Attachment{}, model.ErrRecordNotFound
+	}
+	if quota > 0 {
+		used := attachment.Size
+		for _, a := range data.attachments {
+			if a.UserID == attachment.UserID {
+				used += a.Size
+			}
+		}
+		if used > quota {
+			return model.Attachment{}, model.ErrQuotaExceeded
+		}
+	}
+	data.attachmentSeq++
+	attachment.ID = data.attachmentSeq
+	attachment.CreatedAt = db.Now()
+	data.attachments[attachment.ID] = attachment
+	return attachment, nil
+}
+
+func (data *Data) DeleteAttachment(id int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	delete(data.attachments, id)
+	return nil
+}
+
+// cloneTimeEntry copies the End of entry, so callers cannot stop a stored
+// timer through it.
+func cloneTimeEntry(entry model.TimeEntry) model.TimeEntry {
+	if entry.End != nil {
+		end := *entry.End
+		entry.End = &end
+	}
+	return entry
+}
+
+// sortedTimeEntries returns the time entries matching keep in ID order.
+// Callers hold mu.
+func (data *Data) sortedTimeEntries(keep func(model.TimeEntry) bool) []model.TimeEntry {
+	var entries []model.TimeEntry
+	for _, entry := range data.timeEntries {
+		if keep(entry) {
+			entries = append(entries, cloneTimeEntry(entry))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// checkTimer refuses a running entry while the user has another one.
+// Callers hold mu.
+func (data *Data) checkTimer(entry model.TimeEntry) error {
+	if !entry.Running() {
+		return nil
+	}
+	for _, other := range data.timeEntries {
+		if other.UserID == entry.UserID && other.Running() && other.ID != entry.ID {
+			return model.ErrTimerRunning
+		}
+	}
+	return nil
+}
+
+func (data *Data) GetTimeEntries(userID int, from, to time.Time) ([]model.TimeEntry, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedTimeEntries(func(e model.TimeEntry) bool {
+		return e.UserID == userID && e.Start.Before(to) && (e.Running() || e.End.After(from))
+	}), nil
+}
+
+func (data *Data) GetTimeEntriesByTaskID(taskID int) ([]model.TimeEntry, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedTimeEntries(func(e model.TimeEntry) bool { return e.TaskID == taskID }), nil
+}
+
+func (data *Data) GetTimeEntryByID(id int) (*model.TimeEntry, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	entry, ok := data.timeEntries[id]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	entry = cloneTimeEntry(entry)
+	return &entry, nil
+}
+
+func (data *Data) GetRunningTimeEntry(userID int) (*model.TimeEntry, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	running := data.sortedTimeEntries(func(e model.TimeEntry) bool { return e.UserID == userID && e.Running() })
+	if len(running) == 0 {
+		return nil, model.ErrRecordNotFound
+	}
+	return &running[0], nil
+}
+
+func (data *Data) AddTimeEntry(entry model.TimeEntry) (model.TimeEntry, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	if _, ok := data.tasks[entry.TaskID]; !ok {
+		return model.TimeEntry{}, model.ErrRecordNotFound
+	}
+	if err := data.checkTimer(entry); err != nil {
+		return model.TimeEntry{}, err
+	}
+	data.timeEntrySeq++
+	entry.ID = data.timeEntrySeq
+	data.timeEntries[entry.ID] = cloneTimeEntry(entry)
+	return entry, nil
+}
+
+func (data *Data) UpdateTimeEntry(entry model.TimeEntry) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	existing, ok := data.timeEntries[entry.ID]
+	if !ok {
+		return model.ErrRecordNotFound
+	}
+	entry.TaskID, entry.UserID = existing.TaskID, existing.UserID
+	if err := data.checkTimer(entry); err != nil {
+		return err
+	}
+	data.timeEntries[entry.ID] = cloneTimeEntry(entry)
+	return nil
+}
+
+func (data *Data) StopTimer(userID int, end time.Time) (model.TimeEntry, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	for id, entry := range data.timeEntries {
+		if entry.UserID == userID && entry.Running() {
+			entry.End = &end
+			data.timeEntries[id] = entry
+			return cloneTimeEntry(entry), nil
+		}
+	}
+	return model.TimeEntry{}, model.ErrNoTimerRunning
+}
+
+func (data *Data) DeleteTimeEntry(id int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	delete(data.timeEntries, id)
+	return nil
+}
+
+// SearchTasks builds the documents of the user's tasks on every search, the
+// memory store keeps no index.
+func (data *Data) SearchTasks(userID int, terms []string) ([]model.SearchMatch, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	comments := map[int][]string{}
+	for _, comment := range data.comments {
+		comments[comment.TaskID] = append(comments[comment.TaskID], comment.Body)
+	}
+
+	var matches []model.SearchMatch
+	for _, task := range data.sortedTasks(func(t model.Task) bool { return t.UserID == userID }) {
+		doc := model.SearchDocument(task.Title, data.categories[task.CategoryID].Name, comments[task.ID])
+		matches = append(matches, model.MatchDocument(task.ID, doc, terms)...)
+	}
+	return matches, nil
+}
+
+func (data *Data) GetTaskPage(userID int, query model.TaskQuery) (model.TaskPage, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	tasks := data.sortedTasks(func(t model.Task) bool { return t.UserID == userID && query.Match(t) })
+	sort.SliceStable(tasks, func(i, j int) bool { return query.Less(tasks[i], tasks[j]) })
+	return query.Paginate(tasks), nil
+}
+
+// trashTask moves task and its subtasks to a new trash entry. Callers hold
+// mu.
+func (data *Data) trashTask(task model.Task) model.TrashEntry {
+	entry := model.TrashEntry{UserID: task.UserID, DeletedAt: db.Now()}
+	data.trashTasks(&entry, []model.Task{task})
+	return data.putTrash(entry)
+}
+
+// trashTasks moves tasks, their subtasks and the records hanging off them
+// into entry. Callers hold mu.
+func (data *Data) trashTasks(entry *model.TrashEntry, tasks []model.Task) {
+	add := func(task model.Task) {
+		if entry.HasTask(task.ID) {
+			return
+		}
+		trashed := model.TrashedTask{Task: task}
+		for _, comment := range data.comments {
+			if comment.TaskID == task.ID {
+				trashed.Comments = append(trashed.Comments, cloneComment(comment))
+			}
+		}
+		sort.Slice(trashed.Comments, func(i, j int) bool { return trashed.Comments[i].ID < trashed.Comments[j].ID })
+		trashed.Attachments = data.sortedAttachments(func(a model.Attachment) bool { return a.TaskID == task.ID })
+		for _, e := range data.sortedTimeEntries(func(e model.TimeEntry) bool { return e.TaskID == task.ID }) {
+			trashed.TimeEntries = append(trashed.TimeEntries, model.TrashedTimeEntry(e, entry.DeletedAt))
+		}
+		entry.Tasks = append(entry.Tasks, trashed)
+	}
+	for _, task := range tasks {
+		add(task)
+		for _, subtask := range data.subtasksOf(task.ID) {
+			add(subtask)
+		}
+	}
+
+	for dep := range data.dependencies {
+		if entry.HasTask(dep.TaskID) || entry.HasTask(dep.BlockedByID) {
+			entry.Dependencies = append(entry.Dependencies, dep)
+		}
+	}
+	model.SortDependencies(entry.Dependencies)
+	for _, t := range entry.Tasks {
+		data.deleteTask(t.Task.ID)
+	}
+}
+
+// putTrash stores entry under a new ID and returns it. Callers hold mu.
+func (data *Data) putTrash(entry model.TrashEntry) model.TrashEntry {
+	data.trashSeq++
+	entry.ID = data.trashSeq
+	data.trash[entry.ID] = entry
+	return entry
+}
+
+func (data *Data) TrashTask(id int) (model.TrashEntry, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	task, ok := data.tasks[id]
+	if !ok {
+		return model.TrashEntry{}, model.ErrRecordNotFound
+	}
+	return data.trashTask(task), nil
+}
+
+// TrashCategory treats the tasks of the category like DeleteCategory, tasks
+// deleted with it going to its entry.
+func (data *Data) TrashCategory(id int, opts model.CategoryDelete) (model.TrashEntry, error) {
+	if err := opts.Validate(id); err != nil {
+		return model.TrashEntry{}, err
+	}
+
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	category, ok := data.categories[id]
+	if !ok {
+		return model.TrashEntry{}, model.ErrRecordNotFound
+	}
+	entry := model.TrashEntry{UserID: category.UserID, Category: &category, DeletedAt: db.Now()}
+
+	tasks := data.sortedTasks(func(t model.Task) bool { return t.CategoryID == id })
+	if len(tasks) > 0 {
+		switch opts.Mode {
+		case model.CategoryDeleteCascade:
+			data.trashTasks(&entry, tasks)
+		case model.CategoryDeleteReassign:
+			if err := data.reassignTasks(tasks, opts.ReassignTo); err != nil {
+				return model.TrashEntry{}, err
+			}
+		default:
+			return model.TrashEntry{}, model.ErrCategoryNotEmpty
+		}
+	}
+
+	delete(data.categories, id)
+	return data.putTrash(entry), nil
+}
+
+func (data *Data) GetTrash(userID int) ([]model.TrashEntry, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	return data.sortedTrash(func(e model.TrashEntry) bool { return e.UserID == userID }), nil
+}
+
+// sortedTrash returns the trash entries matching keep in ID order. Callers
+// hold mu.
+func (data *Data) sortedTrash(keep func(model.TrashEntry) bool) []model.TrashEntry {
+	var entries []model.TrashEntry
+	for _, entry := range data.trash {
+		if keep(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+func (data *Data) GetTrashEntry(id int) (*model.TrashEntry, error) {
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	entry, ok := data.trash[id]
+	if !ok {
+		return nil, model.ErrRecordNotFound
+	}
+	return &entry, nil
+}
+
+// RestoreTrash writes the records back one by one and puts the copy from
+// before back when a task is refused.
+func (data *Data) RestoreTrash(id int) (model.TrashEntry, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	entry, ok := data.trash[id]
+	if !ok {
+		return model.TrashEntry{}, model.ErrRecordNotFound
+	}
+
+	restore := data.snapshotTasks()
+	if err := data.restoreTrash(entry); err != nil {
+		restore()
+		if entry.Category != nil {
+			delete(data.categories, entry.Category.ID)
+		}
+		return model.TrashEntry{}, err
+	}
+	delete(data.trash, id)
+	return entry, nil
+}
+
+// restoreTrash puts the records of entry back. Callers hold mu.
+func (data *Data) restoreTrash(entry model.TrashEntry) error {
+	now := db.Now()
+	if category := entry.Category; category != nil {
+		restored := *category
+		restored.Version++
+		restored.UpdatedAt = now
+		data.categories[restored.ID] = restored
+	}
+
+	for _, t := range entry.RestoreOrder() {
+		task := t.Task
+		var tagIDs []int
+		for _, tagID := range task.TagIDs {
+			if _, ok := data.tags[tagID]; ok {
+				tagIDs = append(tagIDs, tagID)
+			}
+		}
+		task.TagIDs = tagIDs
+		if err := data.checkTaskRefs(task); err != nil {
+			return err
+		}
+		task.Version++
+		task.UpdatedAt = now
+		data.tasks[task.ID] = task
+
+		for _, comment := range t.Comments {
+			data.comments[comment.ID] = cloneComment(comment)
+		}
+		for _, attachment := range t.Attachments {
+			data.attachments[attachment.ID] = attachment
+		}
+		for _, e := range t.TimeEntries {
+			data.timeEntries[e.ID] = cloneTimeEntry(e)
+		}
+	}
+
+	for _, dep := range entry.Dependencies {
+		_, hasTask := data.tasks[dep.TaskID]
+		_, hasBlocker := data.tasks[dep.BlockedByID]
+		if !hasTask || !hasBlocker || data.dependencies[dep] {
+			continue
+		}
+		if model.CheckDependency(data.dependenciesOf(dep.UserID), dep) == nil {
+			data.dependencies[dep] = true
+		}
+	}
+	return nil
+}
+
+func (data *Data) PurgeTrash(id int) error {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	delete(data.trash, id)
+	return nil
+}
+
+func (data *Data) PurgeTrashBefore(t time.Time) ([]model.TrashEntry, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	entries := data.sortedTrash(func(e model.TrashEntry) bool { return e.DeletedAt.Before(t) })
+	for _, entry := range entries {
+		delete(data.trash, entry.ID)
+	}
+	return entries, nil
+}
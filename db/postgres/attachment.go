@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+const attachmentColumns = "id, task_id, user_id, name, content_type, size, blob_key, created_at"
+
+// attachmentLockClass keys the advisory lock AddAttachment takes per user,
+// apart from the one AddDependency takes on the bare user ID.
+const attachmentLockClass = 3
+
+func scanAttachment(row interface{ Scan(...interface{}) error }) (model.Attachment, error) {
+	var a model.Attachment
+	err := row.Scan(&a.ID, &a.TaskID, &a.UserID, &a.Name, &a.ContentType, &a.Size, &a.Key, &a.CreatedAt)
+	a.CreatedAt = a.CreatedAt.UTC()
+	return a, err
+}
+
+func (data *Data) queryAttachments(where string, arg int) ([]model.Attachment, error) {
+	rows, err := data.DB.Query("SELECT "+attachmentColumns+" FROM attachments WHERE "+where+" = $1 ORDER BY id", arg)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching attachments: %v", err)
+	}
+	defer rows.Close()
+
+	var attachments []model.Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching attachments: %v", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+func (data *Data) GetAttachments(taskID int) ([]model.Attachment, error) {
+	return data.queryAttachments("task_id", taskID)
+}
+
+func (data *Data) GetAttachmentsByUserID(userID int) ([]model.Attachment, error) {
+	return data.queryAttachments("user_id", userID)
+}
+
+func (data *Data) GetAttachmentByID(id int) (*model.Attachment, error) {
+	attachment, err := scanAttachment(data.DB.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// AddAttachment holds a share lock on the task and an advisory lock on the
+// user, so the task cannot go away and parallel uploads cannot overshoot the
+// quota.
+func (data *Data) AddAttachment(attachment model.Attachment, quota int64) (model.Attachment, error) {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1, $2)", attachmentLockClass, attachment.UserID); err != nil {
+		return model.Attachment{}, err
+	}
+
+	var taskID int
+	err = tx.QueryRow("SELECT id FROM tasks WHERE id = $1 FOR SHARE", attachment.TaskID).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		return model.Attachment{}, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return model.Attachment{}, err
+	}
+
+	if quota > 0 {
+		var used int64
+		err := tx.QueryRow("SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = $1", attachment.UserID).Scan(&used)
+		if err != nil {
+			return model.Attachment{}, err
+		}
+		if used+attachment.Size > quota {
+			return model.Attachment{}, model.ErrQuotaExceeded
+		}
+	}
+
+	attachment.CreatedAt = db.Now()
+	err = tx.QueryRow(
+		`INSERT INTO attachments (task_id, user_id, name, content_type, size, blob_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		attachment.TaskID, attachment.UserID, attachment.Name, attachment.ContentType, attachment.Size, attachment.Key, attachment.CreatedAt,
+	).Scan(&attachment.ID)
+	if err != nil {
+		return model.Attachment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (data *Data) DeleteAttachment(id int) error {
+	_, err := data.DB.Exec("DELETE FROM attachments WHERE id = $1", id)
+	return err
+}
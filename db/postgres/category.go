@@ -0,0 +1,240 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+const categoryColumns = "id, name, user_id, version, updated_at"
+
+// categoryLockClass keys the advisory lock checkCategoryName takes per user,
+// so two writes of the user's categories check the names one after the other.
+const categoryLockClass = 4
+
+func scanCategory(row interface{ Scan(...interface{}) error }) (model.Category, error) {
+	var category model.Category
+	err := row.Scan(&category.ID, &category.Name, &category.UserID, &category.Version, &category.UpdatedAt)
+	category.UpdatedAt = category.UpdatedAt.UTC()
+	return category, err
+}
+
+// checkCategoryName fails with model.ErrCategoryExists when another category
+// of the user of category has its name. It holds the user's category lock
+// until tx ends, so the name is still free when tx writes it.
+func checkCategoryName(tx *sql.Tx, category model.Category) error {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1, $2)", categoryLockClass, category.UserID); err != nil {
+		return err
+	}
+	var taken bool
+	err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM categories WHERE user_id = $1 AND id <> $2 AND lower(trim(name)) = lower(trim($3)))",
+		category.UserID, category.ID, category.Name,
+	).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return model.ErrCategoryExists
+	}
+	return nil
+}
+
+func (data *Data) StoreCategory(category model.Category) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkCategoryName(tx, category); err != nil {
+		return err
+	}
+
+	// Check if we need to generate an ID
+	if category.ID <= 0 {
+		_, err := tx.Exec("INSERT INTO categories (name, user_id, version, updated_at) VALUES ($1, $2, 1, $3)", category.Name, category.UserID, db.Now())
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	// If already has an ID, insert or replace like a bucket Put
+	_, err = tx.Exec(
+		`INSERT INTO categories (`+categoryColumns+`) VALUES ($1, $2, $3, 1, $4)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			user_id = EXCLUDED.user_id,
+			version = categories.version + 1,
+			updated_at = EXCLUDED.updated_at`,
+		category.ID, category.Name, category.UserID, db.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	if err := syncSequence(tx, "categories"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateCategory replaces the category stored under id, with the same version
+// check as UpdateTask.
+func (data *Data) UpdateCategory(id int, category model.Category) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The name lock comes before the row lock, in the order StoreCategory
+	// takes them
+	category.ID = id
+	if err := checkCategoryName(tx, category); err != nil {
+		return err
+	}
+
+	var version int
+	err = tx.QueryRow("SELECT version FROM categories WHERE id = $1 FOR UPDATE", id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return model.ErrRecordNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if category.Version != 0 && category.Version != version {
+		return model.ErrVersionConflict
+	}
+
+	_, err = tx.Exec(
+		"UPDATE categories SET name = $2, user_id = $3, version = version + 1, updated_at = $4 WHERE id = $1",
+		id, category.Name, category.UserID, db.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteCategory removes a category and, depending on opts, rejects the delete
+// while it has tasks, deletes them too or moves them to another category. The
+// tasks and the category change in the same transaction.
+func (data *Data) DeleteCategory(id int, opts model.CategoryDelete) error {
+	if err := opts.Validate(id); err != nil {
+		return err
+	}
+
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The row lock makes tasks being added to this category wait for the delete
+	var exists bool
+	err = tx.QueryRow("SELECT true FROM categories WHERE id = $1 FOR UPDATE", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tasks, err := lockTasks(tx, "category_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if len(tasks) > 0 {
+		switch opts.Mode {
+		case model.CategoryDeleteCascade:
+			if _, err := tx.Exec("DELETE FROM tasks WHERE category_id = $1", id); err != nil {
+				return err
+			}
+		case model.CategoryDeleteReassign:
+			if err := reassignTasks(tx, tasks, id, opts.ReassignTo); err != nil {
+				return err
+			}
+		default:
+			return model.ErrCategoryNotEmpty
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockTasks reads the tasks matching where in ID order and locks them until
+// the transaction ends.
+func lockTasks(tx *sql.Tx, where string, args ...interface{}) ([]model.Task, error) {
+	return scanRows(tx, scanTask, "SELECT "+taskSelect+" FROM tasks WHERE "+where+" ORDER BY id FOR UPDATE", args...)
+}
+
+// reassignTasks moves tasks, the tasks of the category with id from, to the
+// category with id to.
+func reassignTasks(tx *sql.Tx, tasks []model.Task, from, to int) error {
+	for _, task := range tasks {
+		task.CategoryID = to
+		if err := checkTaskCategory(tx, task); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(
+		"UPDATE tasks SET category_id = $2, version = version + 1, updated_at = $3 WHERE category_id = $1",
+		from, to, db.Now(),
+	)
+	return err
+}
+
+func (data *Data) GetCategoryByID(id int) (*model.Category, error) {
+	category, err := scanCategory(data.DB.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (data *Data) GetCategories() ([]model.Category, error) {
+	categories, err := data.queryCategories("SELECT " + categoryColumns + " FROM categories ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching categories: %v", err)
+	}
+	return categories, nil
+}
+
+// GetCategoriesByUserID returns categories for specific user only
+func (data *Data) GetCategoriesByUserID(userID int) ([]model.Category, error) {
+	categories, err := data.queryCategories("SELECT "+categoryColumns+" FROM categories WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching categories for user %d: %v", userID, err)
+	}
+	return categories, nil
+}
+
+func (data *Data) queryCategories(query string, args ...interface{}) ([]model.Category, error) {
+	rows, err := data.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []model.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+const commentColumns = "id, task_id, author_id, body, created_at, updated_at, history"
+
+func scanComment(row interface{ Scan(...interface{}) error }) (model.Comment, error) {
+	var comment model.Comment
+	var history []byte
+	err := row.Scan(&comment.ID, &comment.TaskID, &comment.AuthorID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt, &history)
+	if err != nil {
+		return comment, err
+	}
+	comment.CreatedAt = comment.CreatedAt.UTC()
+	comment.UpdatedAt = comment.UpdatedAt.UTC()
+	if err := json.Unmarshal(history, &comment.History); err != nil {
+		return comment, err
+	}
+	if len(comment.History) == 0 {
+		comment.History = nil
+	}
+	for i := range comment.History {
+		comment.History[i].CreatedAt = comment.History[i].CreatedAt.UTC()
+	}
+	return comment, nil
+}
+
+func (data *Data) GetComments(taskID int) ([]model.Comment, error) {
+	rows, err := data.DB.Query("SELECT "+commentColumns+" FROM comments WHERE task_id = $1 ORDER BY id", taskID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching comments: %v", err)
+	}
+	defer rows.Close()
+
+	var comments []model.Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching comments: %v", err)
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+func (data *Data) GetCommentByID(id int) (*model.Comment, error) {
+	comment, err := scanComment(data.DB.QueryRow("SELECT "+commentColumns+" FROM comments WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// AddComment holds a share lock on the task, so it cannot be deleted before
+// the comment is written.
+func (data *Data) AddComment(comment model.Comment) (model.Comment, error) {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return model.Comment{}, err
+	}
+	defer tx.Rollback()
+
+	var taskID int
+	err = tx.QueryRow("SELECT id FROM tasks WHERE id = $1 FOR SHARE", comment.TaskID).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		return model.Comment{}, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return model.Comment{}, err
+	}
+
+	comment.CreatedAt = db.Now()
+	comment.UpdatedAt = comment.CreatedAt
+	comment.History = nil
+	err = tx.QueryRow(
+		`INSERT INTO comments (task_id, author_id, body, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4) RETURNING id`,
+		comment.TaskID, comment.AuthorID, comment.Body, comment.CreatedAt,
+	).Scan(&comment.ID)
+	if err != nil {
+		return model.Comment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Comment{}, err
+	}
+	return comment, nil
+}
+
+// UpdateComment locks the comment while revising it, so concurrent edits all
+// end up in the history.
+func (data *Data) UpdateComment(id int, body string) (model.Comment, error) {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return model.Comment{}, err
+	}
+	defer tx.Rollback()
+
+	comment, err := scanComment(tx.QueryRow("SELECT "+commentColumns+" FROM comments WHERE id = $1 FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		return model.Comment{}, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return model.Comment{}, err
+	}
+
+	comment.Revise(body, db.Now())
+	history, err := json.Marshal(comment.History)
+	if err != nil {
+		return model.Comment{}, err
+	}
+	_, err = tx.Exec(
+		"UPDATE comments SET body = $2, updated_at = $3, history = $4 WHERE id = $1",
+		id, comment.Body, comment.UpdatedAt, history,
+	)
+	if err != nil {
+		return model.Comment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Comment{}, err
+	}
+	return comment, nil
+}
+
+func (data *Data) DeleteComment(id int) error {
+	_, err := data.DB.Exec("DELETE FROM comments WHERE id = $1", id)
+	return err
+}
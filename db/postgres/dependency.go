@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/model"
+)
+
+// rowsQueryer is a *sql.DB or *sql.Tx.
+type rowsQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func getDependencies(q rowsQueryer, userID int) ([]model.Dependency, error) {
+	rows, err := q.Query(
+		"SELECT task_id, blocked_by_id, user_id FROM task_dependencies WHERE user_id = $1 ORDER BY task_id, blocked_by_id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deps []model.Dependency
+	for rows.Next() {
+		var dep model.Dependency
+		if err := rows.Scan(&dep.TaskID, &dep.BlockedByID, &dep.UserID); err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+	return deps, rows.Err()
+}
+
+func (data *Data) GetDependencies(userID int) ([]model.Dependency, error) {
+	return getDependencies(data.DB, userID)
+}
+
+// AddDependency records that dep.TaskID waits on dep.BlockedByID. Adding edges
+// for the same user is serialized with an advisory lock, so two inserts that
+// only form a cycle together cannot both pass the check.
+func (data *Data) AddDependency(dep model.Dependency) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", dep.UserID); err != nil {
+		return err
+	}
+
+	for _, id := range []int{dep.TaskID, dep.BlockedByID} {
+		var userID int
+		err := tx.QueryRow("SELECT user_id FROM tasks WHERE id = $1 FOR SHARE", id).Scan(&userID)
+		if err == sql.ErrNoRows {
+			return model.ErrRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if userID != dep.UserID {
+			return fmt.Errorf("%w: task %d belongs to a different user", model.ErrInvalidDependency, id)
+		}
+	}
+
+	deps, err := getDependencies(tx, dep.UserID)
+	if err != nil {
+		return err
+	}
+	for _, d := range deps {
+		if d == dep {
+			return nil
+		}
+	}
+	if err := model.CheckDependency(deps, dep); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO task_dependencies (task_id, blocked_by_id, user_id) VALUES ($1, $2, $3)",
+		dep.TaskID, dep.BlockedByID, dep.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (data *Data) RemoveDependency(dep model.Dependency) error {
+	_, err := data.DB.Exec(
+		"DELETE FROM task_dependencies WHERE task_id = $1 AND blocked_by_id = $2 AND user_id = $3",
+		dep.TaskID, dep.BlockedByID, dep.UserID,
+	)
+	return err
+}
@@ -0,0 +1,55 @@
+-- Initial schema, mirrors the Users, Categories, Tasks and Sessions buckets of
+-- the bbolt backend.
+
+CREATE TABLE users (
+	id         SERIAL PRIMARY KEY,
+	fullname   VARCHAR(255) NOT NULL DEFAULT '',
+	email      VARCHAR(255) NOT NULL UNIQUE,
+	password   VARCHAR(255) NOT NULL,
+	created_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+CREATE TABLE categories (
+	id      SERIAL PRIMARY KEY,
+	name    VARCHAR(255) NOT NULL,
+	user_id INTEGER      NOT NULL DEFAULT 0
+);
+
+CREATE INDEX categories_user_id_idx ON categories (user_id);
+
+CREATE TABLE tasks (
+	id          SERIAL PRIMARY KEY,
+	title       TEXT        NOT NULL,
+	deadline    TEXT        NOT NULL DEFAULT '',
+	priority    INTEGER     NOT NULL DEFAULT 0,
+	status      VARCHAR(64) NOT NULL DEFAULT '',
+	category_id INTEGER     NOT NULL DEFAULT 0,
+	user_id     INTEGER     NOT NULL DEFAULT 0
+);
+
+CREATE INDEX tasks_user_id_idx ON tasks (user_id);
+CREATE INDEX tasks_category_id_idx ON tasks (category_id);
+
+-- A session ID identifies a login and is shared by the rows rotated from it,
+-- so it comes from its own sequence rather than being the primary key.
+CREATE SEQUENCE session_ids;
+
+CREATE TABLE sessions (
+	token         TEXT PRIMARY KEY,
+	id            INTEGER      NOT NULL,
+	refresh_token TEXT         NOT NULL DEFAULT '',
+	family_id     TEXT         NOT NULL DEFAULT '',
+	rotated       BOOLEAN      NOT NULL DEFAULT FALSE,
+	email         VARCHAR(255) NOT NULL,
+	expiry        TIMESTAMPTZ  NOT NULL,
+	user_agent    TEXT         NOT NULL DEFAULT '',
+	ip            TEXT         NOT NULL DEFAULT '',
+	created_at    TIMESTAMPTZ  NOT NULL,
+	last_seen     TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX sessions_id_idx ON sessions (id);
+CREATE INDEX sessions_email_idx ON sessions (email);
+CREATE INDEX sessions_family_id_idx ON sessions (family_id);
+CREATE INDEX sessions_refresh_token_idx ON sessions (refresh_token) WHERE refresh_token <> '';
@@ -0,0 +1,2 @@
+-- RunServer used to delete this category on every start
+DELETE FROM categories WHERE name = 'acv';
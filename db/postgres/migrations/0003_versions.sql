@@ -0,0 +1,8 @@
+-- Optimistic concurrency: every write bumps version, ETags are built from it
+ALTER TABLE tasks
+	ADD COLUMN version    INTEGER     NOT NULL DEFAULT 1,
+	ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+ALTER TABLE categories
+	ADD COLUMN version    INTEGER     NOT NULL DEFAULT 1,
+	ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
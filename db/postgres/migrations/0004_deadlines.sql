@@ -0,0 +1,21 @@
+-- Typed deadlines: a timestamp plus whether only the date was given, and the
+-- timezone date-only deadlines of a user fall due in
+ALTER TABLE tasks
+	ADD COLUMN deadline_at        TIMESTAMPTZ,
+	ADD COLUMN deadline_date_only BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE tasks SET deadline_at = (deadline::date)::timestamp AT TIME ZONE 'UTC', deadline_date_only = TRUE
+WHERE deadline ~ '^\d{4}-\d{2}-\d{2}$';
+
+-- RFC 3339 with an offset, and date-times without one read as UTC
+UPDATE tasks SET deadline_at = deadline::timestamptz
+WHERE deadline ~ '^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})$';
+
+UPDATE tasks SET deadline_at = deadline::timestamp AT TIME ZONE 'UTC'
+WHERE deadline ~ '^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?$';
+
+-- Anything else could not be read and is dropped
+ALTER TABLE tasks DROP COLUMN deadline;
+ALTER TABLE tasks RENAME COLUMN deadline_at TO deadline;
+
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT '';
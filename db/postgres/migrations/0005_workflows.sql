@@ -0,0 +1,19 @@
+-- Per-user status workflows, users without a row use model.DefaultWorkflow
+CREATE TABLE workflows (
+	user_id     INTEGER PRIMARY KEY,
+	statuses    JSONB NOT NULL,
+	transitions JSONB NOT NULL
+);
+
+ALTER TABLE tasks ADD COLUMN completed_at TIMESTAMPTZ;
+
+-- Fold the spellings free-form statuses ended up with into the statuses of
+-- the default workflow
+UPDATE tasks SET status = 'Not Started'
+WHERE lower(trim(status)) IN ('not started', 'todo', 'to do', 'belum dimulai', 'belum');
+
+UPDATE tasks SET status = 'In Progress'
+WHERE lower(trim(status)) IN ('in progress', 'in_progress', 'in-progress', 'doing', 'progress', 'sedang dikerjakan');
+
+UPDATE tasks SET status = 'Completed', completed_at = updated_at
+WHERE lower(trim(status)) IN ('completed', 'complete', 'done', 'finished', 'selesai');
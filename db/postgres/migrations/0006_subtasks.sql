@@ -0,0 +1,5 @@
+-- Subtasks point at their parent and go with it when it is deleted
+ALTER TABLE tasks ADD COLUMN parent_id INTEGER REFERENCES tasks (id) ON DELETE CASCADE;
+ALTER TABLE tasks ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX tasks_parent_id_idx ON tasks (parent_id);
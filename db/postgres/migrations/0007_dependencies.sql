@@ -0,0 +1,9 @@
+-- Blocked-by edges between the tasks of a user, dropped with either task
+CREATE TABLE task_dependencies (
+	task_id       INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	blocked_by_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	user_id       INTEGER NOT NULL,
+	PRIMARY KEY (task_id, blocked_by_id)
+);
+
+CREATE INDEX task_dependencies_user_id_idx ON task_dependencies (user_id);
@@ -0,0 +1,5 @@
+-- Recurring tasks carry their rule, later occurrences the ID of the first one
+ALTER TABLE tasks ADD COLUMN recurrence JSONB;
+ALTER TABLE tasks ADD COLUMN series_id INTEGER;
+
+CREATE INDEX tasks_series_id_idx ON tasks (series_id);
@@ -0,0 +1,17 @@
+-- Per-user tags, names unique per user ignoring case, and the tags of a task
+CREATE TABLE tags (
+	id      SERIAL PRIMARY KEY,
+	name    TEXT NOT NULL,
+	color   TEXT NOT NULL,
+	user_id INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX tags_user_id_name_idx ON tags (user_id, lower(trim(name)));
+
+CREATE TABLE task_tags (
+	task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	tag_id  INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (task_id, tag_id)
+);
+
+CREATE INDEX task_tags_tag_id_idx ON task_tags (tag_id);
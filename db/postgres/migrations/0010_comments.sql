@@ -0,0 +1,12 @@
+-- Comments on a task, dropped with it. history holds the replaced bodies.
+CREATE TABLE comments (
+	id         SERIAL PRIMARY KEY,
+	task_id    INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	author_id  INTEGER NOT NULL,
+	body       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	history    JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX comments_task_id_idx ON comments (task_id);
@@ -0,0 +1,15 @@
+-- Attachments on a task, dropped with it. The content lives in the blob store
+-- under blob_key.
+CREATE TABLE attachments (
+	id           SERIAL PRIMARY KEY,
+	task_id      INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	user_id      INTEGER NOT NULL,
+	name         TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size         BIGINT NOT NULL,
+	blob_key     TEXT NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX attachments_task_id_idx ON attachments (task_id);
+CREATE INDEX attachments_user_id_idx ON attachments (user_id);
@@ -0,0 +1,14 @@
+-- Time spent on a task, dropped with it. end_at is NULL while the timer runs,
+-- and a user has at most one running timer.
+CREATE TABLE time_entries (
+	id       SERIAL PRIMARY KEY,
+	task_id  INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	user_id  INTEGER NOT NULL,
+	start_at TIMESTAMPTZ NOT NULL,
+	end_at   TIMESTAMPTZ,
+	note     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX time_entries_task_id_idx ON time_entries (task_id);
+CREATE INDEX time_entries_user_id_start_at_idx ON time_entries (user_id, start_at);
+CREATE UNIQUE INDEX time_entries_running_idx ON time_entries (user_id) WHERE end_at IS NULL;
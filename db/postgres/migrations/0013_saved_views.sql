@@ -0,0 +1,10 @@
+-- Named task list queries of a user, names unique per user ignoring case
+CREATE TABLE saved_views (
+	id      SERIAL PRIMARY KEY,
+	name    TEXT NOT NULL,
+	query   TEXT NOT NULL,
+	pinned  BOOLEAN NOT NULL DEFAULT FALSE,
+	user_id INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX saved_views_user_id_name_idx ON saved_views (user_id, lower(trim(name)));
@@ -0,0 +1,11 @@
+-- Deleted tasks and categories, each entry holding the JSON of what one
+-- delete removed until it is restored or purged
+CREATE TABLE trash (
+	id         SERIAL PRIMARY KEY,
+	user_id    INTEGER NOT NULL,
+	deleted_at TIMESTAMPTZ NOT NULL,
+	entry      JSONB NOT NULL
+);
+
+CREATE INDEX trash_user_id_idx ON trash (user_id);
+CREATE INDEX trash_deleted_at_idx ON trash (deleted_at);
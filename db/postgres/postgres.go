@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"a21hc3NpZ25tZW50/model"
+
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLockID serialises migrations when several instances start at once.
+const migrationLockID = 7426153
+
+type Data struct {
+	DB *sql.DB
+}
+
+// InitDB connects to the database described by cred, creates its schema if
+// needed and applies pending migrations.
+func InitDB(cred model.Credential) (*Data, error) {
+	db, err := sql.Open("postgres", dsn(cred))
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %v", err)
+	}
+
+	db.SetMaxOpenConns(20)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to database: %v", err)
+	}
+
+	if cred.Schema != "" && cred.Schema != "public" {
+		if _, err := db.Exec("CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(cred.Schema)); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("create schema: %v", err)
+		}
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Data{DB: db}, nil
+}
+
+// dsn builds a key/value connection string. search_path is passed as a run-time
+// parameter so every pooled connection uses the configured schema.
+func dsn(cred model.Credential) string {
+	params := []string{
+		"host=" + quote(cred.Host),
+		"port=" + strconv.Itoa(cred.Port),
+		"user=" + quote(cred.Username),
+		"dbname=" + quote(cred.DatabaseName),
+	}
+	if cred.Password != "" {
+		params = append(params, "password="+quote(cred.Password))
+	}
+	if cred.SSLMode != "" {
+		params = append(params, "sslmode="+quote(cred.SSLMode))
+	}
+	if cred.Schema != "" {
+		params = append(params, "search_path="+quote(cred.Schema))
+	}
+	return strings.Join(params, " ")
+}
+
+func quote(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// migrate applies every file in migrations/ that schema_migrations has no
+// record of, in version order, each in its own transaction. It refuses a
+// schema that already has migrations newer than the embedded ones.
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %v", err)
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	versions := make([]int, len(files))
+	for i, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		versions[i], err = strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("migration %s has no version prefix", name)
+		}
+	}
+
+	// A schema migrated by a newer binary may not be understood by this one
+	var current int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+	if latest := versions[len(versions)-1]; current > latest {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d), refusing to start", current, latest)
+	}
+
+	for i, file := range files {
+		body, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		if err := applyMigration(db, versions[i], string(body)); err != nil {
+			return fmt.Errorf("migration %s: %v", strings.TrimPrefix(file, "migrations/"), err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, version int, body string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return err
+	}
+
+	var applied bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.Exec(body); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// syncSequence moves the id sequence of table past rows stored with an
+// explicit ID, so the next generated ID does not collide with them.
+func syncSequence(q execer, table string) error {
+	_, err := q.Exec(fmt.Sprintf(
+		`SELECT setval(s.seq, m.max_id)
+		FROM (SELECT pg_get_serial_sequence('%s', 'id') AS seq) s, (SELECT MAX(id) AS max_id FROM %s) m
+		WHERE m.max_id > COALESCE(pg_sequence_last_value(s.seq::regclass), 0)`,
+		table, table,
+	))
+	return err
+}
+
+// isUniqueViolation reports whether err comes from a UNIQUE constraint.
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// Reset empties every table, used by tests.
+func (data *Data) Reset() error {
+	_, err := data.DB.Exec("TRUNCATE users, categories, tasks, task_dependencies, tags, task_tags, comments, attachments, time_entries, saved_views, trash, sessions, workflows RESTART IDENTITY")
+	if err != nil {
+		return err
+	}
+	_, err = data.DB.Exec("ALTER SEQUENCE session_ids RESTART")
+	return err
+}
+
+func (data *Data) CloseDB() error {
+	return data.DB.Close()
+}
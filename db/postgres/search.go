@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"fmt"
+
+	"a21hc3NpZ25tZW50/model"
+
+	"github.com/lib/pq"
+)
+
+// SearchTasks builds the documents of the user's tasks in one query and
+// matches them with the same tokeniser as the bbolt index, so every store
+// ranks alike.
+func (data *Data) SearchTasks(userID int, terms []string) ([]model.SearchMatch, error) {
+	rows, err := data.DB.Query(`SELECT t.id, t.title, COALESCE(c.name, ''),
+			COALESCE(array_agg(m.body ORDER BY m.id) FILTER (WHERE m.id IS NOT NULL), '{}')
+		FROM tasks t
+		LEFT JOIN categories c ON c.id = t.category_id
+		LEFT JOIN comments m ON m.task_id = t.id
+		WHERE t.user_id = $1
+		GROUP BY t.id, c.name
+		ORDER BY t.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error searching tasks: %v", err)
+	}
+	defer rows.Close()
+
+	var matches []model.SearchMatch
+	for rows.Next() {
+		var id int
+		var title, category string
+		var comments pq.StringArray
+		if err := rows.Scan(&id, &title, &category, &comments); err != nil {
+			return nil, fmt.Errorf("error searching tasks: %v", err)
+		}
+		doc := model.SearchDocument(title, category, comments)
+		matches = append(matches, model.MatchDocument(id, doc, terms)...)
+	}
+	return matches, rows.Err()
+}
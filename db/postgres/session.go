@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"a21hc3NpZ25tZW50/model"
+)
+
+const sessionColumns = "id, token, refresh_token, family_id, rotated, email, expiry, user_agent, ip, created_at, last_seen"
+
+func scanSession(row interface{ Scan(...interface{}) error }) (model.Session, error) {
+	var s model.Session
+	err := row.Scan(&s.ID, &s.Token, &s.RefreshToken, &s.FamilyID, &s.Rotated, &s.Email, &s.Expiry, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeen)
+	return s, err
+}
+
+// putSession inserts or replaces the row keyed by session.Token.
+func putSession(q execer, session model.Session) error {
+	_, err := q.Exec(
+		`INSERT INTO sessions (`+sessionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (token) DO UPDATE SET
+			id = EXCLUDED.id,
+			refresh_token = EXCLUDED.refresh_token,
+			family_id = EXCLUDED.family_id,
+			rotated = EXCLUDED.rotated,
+			email = EXCLUDED.email,
+			expiry = EXCLUDED.expiry,
+			user_agent = EXCLUDED.user_agent,
+			ip = EXCLUDED.ip,
+			created_at = EXCLUDED.created_at,
+			last_seen = EXCLUDED.last_seen`,
+		session.ID, session.Token, session.RefreshToken, session.FamilyID, session.Rotated, session.Email,
+		session.Expiry, session.UserAgent, session.IP, session.CreatedAt, session.LastSeen,
+	)
+	return err
+}
+
+func (data *Data) AddSession(session model.Session) error {
+	// Every login gets its own ID, rotated sessions keep the one they had
+	if session.ID <= 0 {
+		if err := data.DB.QueryRow("SELECT nextval('session_ids')").Scan(&session.ID); err != nil {
+			return err
+		}
+	}
+	return putSession(data.DB, session)
+}
+
+func (data *Data) UpdateSession(session model.Session) error {
+	return data.AddSession(session) // Reuse AddSession as it will overwrite the existing entry
+}
+
+func (data *Data) DeleteSession(token string) error {
+	_, err := data.DB.Exec("DELETE FROM sessions WHERE token = $1", token)
+	return err
+}
+
+// DeleteSessionsByID removes the session with the given ID together with the
+// records it was rotated from, which share the ID.
+func (data *Data) DeleteSessionsByID(id int) error {
+	_, err := data.DB.Exec("DELETE FROM sessions WHERE id = $1", id)
+	return err
+}
+
+// DeleteSessionsByEmail removes every session of a user, logging them out on all devices.
+func (data *Data) DeleteSessionsByEmail(email string) error {
+	_, err := data.DB.Exec("DELETE FROM sessions WHERE email = $1", email)
+	return err
+}
+
+// DeleteSessionFamily removes every session rotated from the same login.
+func (data *Data) DeleteSessionFamily(familyID string) error {
+	_, err := data.DB.Exec("DELETE FROM sessions WHERE family_id = $1", familyID)
+	return err
+}
+
+// GetSessionsByEmail returns every live session of a user, one per login.
+// Sessions whose refresh token was already rotated are left out.
+func (data *Data) GetSessionsByEmail(email string) ([]model.Session, error) {
+	rows, err := data.DB.Query("SELECT "+sessionColumns+" FROM sessions WHERE email = $1 AND NOT rotated ORDER BY token", email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []model.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records that the session behind token was just used.
+func (data *Data) TouchSession(token string, at time.Time) error {
+	res, err := data.DB.Exec("UPDATE sessions SET last_seen = $2 WHERE token = $1", token, at)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+func (data *Data) SessionAvailEmail(email string) (model.Session, error) {
+	s, err := scanSession(data.DB.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE email = $1 AND NOT rotated ORDER BY token LIMIT 1", email))
+	if err == sql.ErrNoRows {
+		return model.Session{}, fmt.Errorf("no session available for email: %s", email)
+	}
+	return s, err
+}
+
+func (data *Data) SessionAvailToken(token string) (model.Session, error) {
+	s, err := scanSession(data.DB.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE token = $1", token))
+	if err == sql.ErrNoRows {
+		return model.Session{}, fmt.Errorf("no session available for token: %s", token)
+	}
+	return s, err
+}
+
+// SessionByRefreshToken finds the session that was issued the given refresh
+// token, including sessions whose refresh token has already been rotated.
+func (data *Data) SessionByRefreshToken(refreshToken string) (model.Session, error) {
+	if refreshToken == "" {
+		return model.Session{}, fmt.Errorf("no session available for refresh token")
+	}
+
+	s, err := scanSession(data.DB.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE refresh_token = $1", refreshToken))
+	if err == sql.ErrNoRows {
+		return model.Session{}, fmt.Errorf("no session available for refresh token")
+	}
+	return s, err
+}
+
+// RotateSession marks old as rotated and stores next in the same transaction,
+// so a refresh token can never be exchanged twice.
+func (data *Data) RotateSession(old model.Session, next model.Session) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The row lock makes a concurrent exchange of the same token wait here
+	var rotated bool
+	err = tx.QueryRow("SELECT rotated FROM sessions WHERE token = $1 FOR UPDATE", old.Token).Scan(&rotated)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return err
+	}
+	if rotated {
+		return fmt.Errorf("session already rotated")
+	}
+
+	old.Rotated = true
+	if err := putSession(tx, old); err != nil {
+		return err
+	}
+	if err := putSession(tx, next); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
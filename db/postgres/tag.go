@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+const tagColumns = "id, name, color, user_id"
+
+func scanTag(row interface{ Scan(...interface{}) error }) (model.Tag, error) {
+	var tag model.Tag
+	err := row.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.UserID)
+	return tag, err
+}
+
+func (data *Data) GetTagsByUserID(userID int) ([]model.Tag, error) {
+	rows, err := data.DB.Query("SELECT "+tagColumns+" FROM tags WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tags: %v", err)
+	}
+	defer rows.Close()
+
+	var tags []model.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching tags: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (data *Data) GetTagByID(id int) (*model.Tag, error) {
+	tag, err := scanTag(data.DB.QueryRow("SELECT "+tagColumns+" FROM tags WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// CreateTag relies on tags_user_id_name_idx for unique names.
+func (data *Data) CreateTag(tag model.Tag) (model.Tag, error) {
+	err := data.DB.QueryRow(
+		"INSERT INTO tags (name, color, user_id) VALUES ($1, $2, $3) RETURNING id",
+		tag.Name, tag.Color, tag.UserID,
+	).Scan(&tag.ID)
+	if isUniqueViolation(err) {
+		return model.Tag{}, model.ErrTagExists
+	}
+	if err != nil {
+		return model.Tag{}, err
+	}
+	return tag, nil
+}
+
+// UpdateTag renames or recolors a tag, it stays with its user.
+func (data *Data) UpdateTag(tag model.Tag) error {
+	result, err := data.DB.Exec("UPDATE tags SET name = $2, color = $3 WHERE id = $1", tag.ID, tag.Name, tag.Color)
+	if isUniqueViolation(err) {
+		return model.ErrTagExists
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return model.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteTag bumps the version of the tasks carrying the tag, their rows in
+// task_tags go with it.
+func (data *Data) DeleteTag(id int) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE tasks SET version = version + 1, updated_at = $2 WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id = $1)",
+		id, db.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
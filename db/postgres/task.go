@@ -0,0 +1,474 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+
+	"github.com/lib/pq"
+)
+
+const taskColumns = "id, title, deadline, deadline_date_only, priority, status, completed_at, category_id, user_id, parent_id, position, recurrence, series_id, version, updated_at"
+
+// taskSelect reads taskColumns and the tags of a task from task_tags, in the
+// order scanTask expects them.
+const taskSelect = taskColumns + ", ARRAY(SELECT tag_id FROM task_tags WHERE task_id = tasks.id ORDER BY tag_id)"
+
+func scanTask(row interface{ Scan(...interface{}) error }) (model.Task, error) {
+	var task model.Task
+	var deadline, completedAt sql.NullTime
+	var parentID, seriesID sql.NullInt64
+	var recurrence []byte
+	var tagIDs []int64
+	err := row.Scan(&task.ID, &task.Title, &deadline, &task.Deadline.DateOnly, &task.Priority, &task.Status, &completedAt, &task.CategoryID, &task.UserID, &parentID, &task.Position, &recurrence, &seriesID, &task.Version, &task.UpdatedAt, pq.Array(&tagIDs))
+	if err != nil {
+		return task, err
+	}
+	for _, id := range tagIDs {
+		task.TagIDs = append(task.TagIDs, int(id))
+	}
+	task.ParentID = int(parentID.Int64)
+	task.SeriesID = int(seriesID.Int64)
+	if recurrence != nil {
+		task.Recurrence = &model.Recurrence{}
+		if err := json.Unmarshal(recurrence, task.Recurrence); err != nil {
+			return task, err
+		}
+	}
+	task.Deadline.At = deadlineTime(deadline)
+	if completedAt.Valid {
+		t := completedAt.Time.UTC()
+		task.CompletedAt = &t
+	}
+	task.UpdatedAt = task.UpdatedAt.UTC()
+	return task, nil
+}
+
+// deadlineArg is the value of the deadline column, NULL without a deadline.
+func deadlineArg(d model.Deadline) interface{} {
+	if d.IsZero() {
+		return nil
+	}
+	return d.At
+}
+
+func deadlineTime(t sql.NullTime) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return t.Time.UTC()
+}
+
+// parentArg is the value of the parent_id column, NULL for a top-level task.
+func parentArg(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// recurrenceArg is the value of the recurrence column, NULL for a task that
+// does not repeat.
+func recurrenceArg(r *model.Recurrence) interface{} {
+	if r == nil {
+		return nil
+	}
+	j, _ := json.Marshal(r)
+	return j
+}
+
+// seriesArg is the value of the series_id column, NULL outside a series.
+func seriesArg(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// checkTaskCategory enforces that task points at a category its user may use.
+// FOR SHARE keeps the category from being deleted before the task is written.
+func checkTaskCategory(q queryer, task model.Task) error {
+	var category model.Category
+	err := q.QueryRow("SELECT id, name, user_id FROM categories WHERE id = $1 FOR SHARE", task.CategoryID).
+		Scan(&category.ID, &category.Name, &category.UserID)
+	if err == sql.ErrNoRows {
+		return model.ErrCategoryNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !model.CategoryAllows(category, task.UserID) {
+		return model.ErrCategoryNotOwned
+	}
+	return nil
+}
+
+// checkTaskTags enforces that every tag of task exists and belongs to its
+// user. FOR SHARE keeps the tags from being deleted before the task is written.
+func checkTaskTags(q queryer, task model.Task) error {
+	for _, id := range task.TagIDs {
+		tag := model.Tag{ID: id}
+		err := q.QueryRow("SELECT user_id FROM tags WHERE id = $1 FOR SHARE", id).Scan(&tag.UserID)
+		if err == sql.ErrNoRows {
+			return model.ErrTagNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := model.CheckTaskTag(task, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTaskTags replaces the rows of task_tags for task id.
+func setTaskTags(tx *sql.Tx, id int, tagIDs []int) error {
+	if _, err := tx.Exec("DELETE FROM task_tags WHERE task_id = $1", id); err != nil {
+		return err
+	}
+	for _, tagID := range tagIDs {
+		if _, err := tx.Exec("INSERT INTO task_tags (task_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTaskParent enforces that a subtask points at a top-level task of its
+// user and has no subtasks of its own. A task without an ID yet has none.
+func checkTaskParent(q queryer, task model.Task) error {
+	if task.ParentID == 0 {
+		return nil
+	}
+	parent, err := scanTask(q.QueryRow("SELECT "+taskSelect+" FROM tasks WHERE id = $1 FOR SHARE", task.ParentID))
+	if err == sql.ErrNoRows {
+		return model.ErrParentNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	hasSubtasks := false
+	if task.ID > 0 {
+		err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE parent_id = $1)", task.ID).Scan(&hasSubtasks)
+		if err != nil {
+			return err
+		}
+	}
+	return model.CheckParent(task, parent, hasSubtasks)
+}
+
+// checkTaskRefs enforces the category, parent and tags of task.
+func checkTaskRefs(q queryer, task model.Task) error {
+	if err := checkTaskCategory(q, task); err != nil {
+		return err
+	}
+	if err := checkTaskParent(q, task); err != nil {
+		return err
+	}
+	return checkTaskTags(q, task)
+}
+
+// insertTask writes a checked task under a new ID and returns it as stored.
+func insertTask(tx *sql.Tx, task model.Task) (model.Task, error) {
+	task.Version, task.UpdatedAt = 1, db.Now()
+	err := tx.QueryRow(
+		`INSERT INTO tasks (title, deadline, deadline_date_only, priority, status, completed_at, category_id, user_id, parent_id, position, recurrence, series_id, version, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, 1, $13)
+		RETURNING id`,
+		task.Title, deadlineArg(task.Deadline), task.Deadline.DateOnly, task.Priority, task.Status, task.CompletedAt, task.CategoryID, task.UserID, parentArg(task.ParentID), task.Position,
+		recurrenceArg(task.Recurrence), seriesArg(task.SeriesID), task.UpdatedAt,
+	).Scan(&task.ID)
+	if err != nil {
+		return task, err
+	}
+	return task, setTaskTags(tx, task.ID, task.TagIDs)
+}
+
+func (data *Data) StoreTask(task model.Task) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTaskRefs(tx, task); err != nil {
+		return err
+	}
+
+	// Check if we need to generate an ID
+	if task.ID <= 0 {
+		if _, err := insertTask(tx, task); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	// If already has an ID, insert or replace like a bucket Put, without a version check
+	_, err = tx.Exec(
+		`INSERT INTO tasks (`+taskColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, 1, $14)
+		ON CONFLICT (id) DO UPDATE SET
+			title = EXCLUDED.title,
+			deadline = EXCLUDED.deadline,
+			deadline_date_only = EXCLUDED.deadline_date_only,
+			priority = EXCLUDED.priority,
+			status = EXCLUDED.status,
+			completed_at = EXCLUDED.completed_at,
+			category_id = EXCLUDED.category_id,
+			user_id = EXCLUDED.user_id,
+			parent_id = EXCLUDED.parent_id,
+			position = EXCLUDED.position,
+			recurrence = EXCLUDED.recurrence,
+			series_id = EXCLUDED.series_id,
+			version = tasks.version + 1,
+			updated_at = EXCLUDED.updated_at`,
+		task.ID, task.Title, deadlineArg(task.Deadline), task.Deadline.DateOnly, task.Priority, task.Status, task.CompletedAt, task.CategoryID, task.UserID, parentArg(task.ParentID), task.Position,
+		recurrenceArg(task.Recurrence), seriesArg(task.SeriesID), db.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	if err := syncSequence(tx, "tasks"); err != nil {
+		return err
+	}
+	if err := setTaskTags(tx, task.ID, task.TagIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateTask replaces the task stored under id. A non-zero task.Version must
+// match the stored one.
+func (data *Data) UpdateTask(id int, task model.Task) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	task.ID = id
+	version, err := checkTaskUpdate(tx, task)
+	if err != nil {
+		return err
+	}
+	if _, err := updateTask(tx, version, task); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockTask locks the row of task id until the transaction ends and returns
+// its version, checked against version unless that is 0.
+func lockTask(tx *sql.Tx, id, version int) (int, error) {
+	var current int
+	err := tx.QueryRow("SELECT version FROM tasks WHERE id = $1 FOR UPDATE", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return 0, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if version != 0 && version != current {
+		return current, model.ErrVersionConflict
+	}
+	return current, nil
+}
+
+// checkTaskUpdate locks and checks the stored task with the ID of task, which
+// replaces it, and returns its version.
+func checkTaskUpdate(tx *sql.Tx, task model.Task) (int, error) {
+	version, err := lockTask(tx, task.ID, task.Version)
+	if err != nil {
+		return version, err
+	}
+	return version, checkTaskRefs(tx, task)
+}
+
+// updateTask writes a checked task over the stored one at version and
+// returns it as stored.
+func updateTask(tx *sql.Tx, version int, task model.Task) (model.Task, error) {
+	task.Version, task.UpdatedAt = version+1, db.Now()
+	_, err := tx.Exec(
+		`UPDATE tasks SET title = $2, deadline = $3, deadline_date_only = $4, priority = $5, status = $6, completed_at = $7,
+			category_id = $8, user_id = $9, parent_id = $10, position = $11, recurrence = $12, series_id = $13, version = version + 1, updated_at = $14
+		WHERE id = $1`,
+		task.ID, task.Title, deadlineArg(task.Deadline), task.Deadline.DateOnly, task.Priority, task.Status, task.CompletedAt, task.CategoryID, task.UserID, parentArg(task.ParentID), task.Position,
+		recurrenceArg(task.Recurrence), seriesArg(task.SeriesID), task.UpdatedAt,
+	)
+	if err != nil {
+		return task, err
+	}
+	return task, setTaskTags(tx, task.ID, task.TagIDs)
+}
+
+// WriteTasks runs each write in a savepoint when not atomic, so a refused
+// write is rolled back alone.
+func (data *Data) WriteTasks(writes []model.TaskWrite, atomic bool) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range writes {
+		w := &writes[i]
+		if w.Err != nil {
+			continue
+		}
+		if !atomic {
+			if _, err := tx.Exec("SAVEPOINT task_write"); err != nil {
+				return err
+			}
+		}
+
+		var version int
+		switch w.Op {
+		case model.TaskOpCreate:
+			w.Err = checkTaskRefs(tx, w.Task)
+		case model.TaskOpDelete:
+			_, w.Err = lockTask(tx, w.Task.ID, w.Task.Version)
+		default:
+			version, w.Err = checkTaskUpdate(tx, w.Task)
+		}
+		if w.Err != nil {
+			if atomic {
+				return model.ErrBulkAborted
+			}
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT task_write"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var err error
+		switch w.Op {
+		case model.TaskOpCreate:
+			w.Task, err = insertTask(tx, w.Task)
+		case model.TaskOpDelete:
+			var task model.Task
+			if task, err = scanTask(tx.QueryRow("SELECT "+taskSelect+" FROM tasks WHERE id = $1", w.Task.ID)); err == nil {
+				var entry model.TrashEntry
+				entry, err = trashTask(tx, task)
+				w.Task, w.TrashID = task, entry.ID
+			}
+		default:
+			w.Task, err = updateTask(tx, version, w.Task)
+		}
+		if err != nil {
+			return err
+		}
+		if !atomic {
+			if _, err := tx.Exec("RELEASE SAVEPOINT task_write"); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteTask removes a task, parent_id cascades to its subtasks.
+func (data *Data) DeleteTask(id int) error {
+	_, err := data.DB.Exec("DELETE FROM tasks WHERE id = $1", id)
+	return err
+}
+
+func (data *Data) GetTaskByID(id int) (*model.Task, error) {
+	task, err := scanTask(data.DB.QueryRow("SELECT "+taskSelect+" FROM tasks WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (data *Data) GetTasksByUserID(userID int) ([]model.Task, error) {
+	rows, err := data.DB.Query("SELECT "+taskSelect+" FROM tasks WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tasks: %v", err)
+	}
+	defer rows.Close()
+
+	var tasks []model.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching tasks: %v", err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+func (data *Data) GetTaskListByCategory(categoryID int) ([]model.TaskCategory, error) {
+	if _, err := data.GetCategoryByID(categoryID); err != nil {
+		return nil, fmt.Errorf("error fetching category: %v", err)
+	}
+
+	taskCategories, err := data.queryTaskCategories(
+		`SELECT t.id, t.title, c.name
+		FROM tasks t
+		JOIN categories c ON c.id = t.category_id
+		WHERE t.category_id = $1
+		ORDER BY t.id`,
+		categoryID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tasks for category %d: %v", categoryID, err)
+	}
+	if len(taskCategories) == 0 {
+		return nil, fmt.Errorf("no tasks found for category ID: %d", categoryID)
+	}
+	return taskCategories, nil
+}
+
+// GetTaskListByCategoryAndUser returns tasks filtered by both category and user
+func (data *Data) GetTaskListByCategoryAndUser(categoryID, userID int) ([]model.TaskCategory, error) {
+	if _, err := data.GetCategoryByID(categoryID); err != nil {
+		return nil, fmt.Errorf("error fetching category: %v", err)
+	}
+
+	taskCategories, err := data.queryTaskCategories(
+		`SELECT t.id, t.title, c.name
+		FROM tasks t
+		JOIN categories c ON c.id = t.category_id
+		WHERE t.category_id = $1 AND t.user_id = $2
+		ORDER BY t.id`,
+		categoryID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tasks for category %d and user %d: %v", categoryID, userID, err)
+	}
+	return taskCategories, nil
+}
+
+func (data *Data) queryTaskCategories(query string, args ...interface{}) ([]model.TaskCategory, error) {
+	rows, err := data.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var taskCategories []model.TaskCategory
+	for rows.Next() {
+		var tc model.TaskCategory
+		if err := rows.Scan(&tc.ID, &tc.Title, &tc.Category); err != nil {
+			return nil, err
+		}
+		taskCategories = append(taskCategories, tc)
+	}
+	return taskCategories, rows.Err()
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"a21hc3NpZ25tZW50/model"
+
+	"github.com/lib/pq"
+)
+
+// taskSortExpr is the column a task order sorts on before the ID. Tasks
+// without a deadline sort as infinity, like model.NoDeadlineKey.
+var taskSortExpr = map[model.TaskSort]string{
+	model.SortCreated:  "id",
+	model.SortDeadline: "COALESCE(deadline, 'infinity'::timestamptz)",
+	model.SortPriority: "priority",
+}
+
+// taskFilter turns the filters of query into a WHERE clause over tasks.
+func taskFilter(userID int, query model.TaskQuery) (string, []interface{}) {
+	args := []interface{}{userID}
+	where := []string{"user_id = $1"}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, strings.ReplaceAll(cond, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if len(query.Statuses) > 0 {
+		add("status = ANY(?)", pq.Array(query.Statuses))
+	}
+	if query.MinPriority != 0 {
+		add("priority >= ?", query.MinPriority)
+	}
+	if query.MaxPriority != 0 {
+		add("priority <= ?", query.MaxPriority)
+	}
+	if query.CategoryID != 0 {
+		add("category_id = ?", query.CategoryID)
+	}
+	if !query.DeadlineFrom.IsZero() {
+		add("deadline >= ?", query.DeadlineFrom.At)
+	}
+	if to := query.DeadlineTo; !to.IsZero() {
+		if to.DateOnly {
+			add("deadline < ?", to.At.AddDate(0, 0, 1))
+		} else {
+			add("deadline <= ?", to.At)
+		}
+	}
+	if len(query.TagIDs) > 0 {
+		ids := make([]int64, len(query.TagIDs))
+		for i, id := range query.TagIDs {
+			ids[i] = int64(id)
+		}
+		if query.TagMatch == model.TagMatchAll {
+			add("(SELECT COUNT(*) FROM task_tags WHERE task_id = tasks.id AND tag_id = ANY(?)) = "+fmt.Sprint(len(ids)), pq.Array(ids))
+		} else {
+			add("EXISTS (SELECT 1 FROM task_tags WHERE task_id = tasks.id AND tag_id = ANY(?))", pq.Array(ids))
+		}
+	}
+	if len(query.ParentIDs) > 0 {
+		ids := make([]int64, len(query.ParentIDs))
+		for i, id := range query.ParentIDs {
+			ids[i] = int64(id)
+		}
+		add("COALESCE(parent_id, 0) = ANY(?)", pq.Array(ids))
+	}
+	// Terms are letters and digits only, see model.Tokenize, so they need no
+	// escaping in LIKE
+	for _, term := range query.Terms {
+		add("EXISTS (SELECT 1 FROM regexp_split_to_table(lower(title), '[^[:alnum:]]+') AS word WHERE word LIKE ? || '%')", term)
+	}
+	return strings.Join(where, " AND "), args
+}
+
+// GetTaskPage counts and reads the page in one read-only snapshot, so the
+// total matches the page. The page is read past the cursor by keyset, the
+// order being a column rather than an offset.
+func (data *Data) GetTaskPage(userID int, query model.TaskQuery) (model.TaskPage, error) {
+	page := model.TaskPage{Tasks: []model.Task{}}
+
+	tx, err := data.DB.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return page, err
+	}
+	defer tx.Rollback()
+
+	where, args := taskFilter(userID, query)
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE "+where, args...).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("error counting tasks: %v", err)
+	}
+
+	expr, dir, cmp := taskSortExpr[query.Sort], "ASC", ">"
+	if query.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if after := query.After; after != nil {
+		var key interface{} = after.Key
+		if query.Sort == model.SortDeadline {
+			key = time.Unix(0, after.Key).UTC()
+			if after.Key == model.NoDeadlineKey {
+				key = "infinity"
+			}
+		}
+		args = append(args, key, after.ID)
+		where += fmt.Sprintf(" AND (%s, id) %s ($%d, $%d)", expr, cmp, len(args)-1, len(args))
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM tasks WHERE %s ORDER BY %s %s, id %s", taskSelect, where, expr, dir, dir)
+	if query.Limit > 0 {
+		stmt += fmt.Sprintf(" LIMIT %d", query.Limit+1)
+	}
+
+	rows, err := tx.Query(stmt, args...)
+	if err != nil {
+		return page, fmt.Errorf("error fetching tasks: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return page, fmt.Errorf("error fetching tasks: %v", err)
+		}
+		if query.Limit > 0 && len(page.Tasks) == query.Limit {
+			page.Next = query.Cursor(page.Tasks[len(page.Tasks)-1])
+			break
+		}
+		page.Tasks = append(page.Tasks, task)
+	}
+	return page, rows.Err()
+}
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"a21hc3NpZ25tZW50/model"
+)
+
+const timeEntryColumns = "id, task_id, user_id, start_at, end_at, note"
+
+func scanTimeEntry(row interface{ Scan(...interface{}) error }) (model.TimeEntry, error) {
+	var entry model.TimeEntry
+	var end sql.NullTime
+	if err := row.Scan(&entry.ID, &entry.TaskID, &entry.UserID, &entry.Start, &end, &entry.Note); err != nil {
+		return entry, err
+	}
+	entry.Start = entry.Start.UTC()
+	if end.Valid {
+		at := end.Time.UTC()
+		entry.End = &at
+	}
+	return entry, nil
+}
+
+func (data *Data) queryTimeEntries(query string, args ...interface{}) ([]model.TimeEntry, error) {
+	rows, err := data.DB.Query("SELECT "+timeEntryColumns+" FROM time_entries WHERE "+query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching time entries: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []model.TimeEntry
+	for rows.Next() {
+		entry, err := scanTimeEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching time entries: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (data *Data) GetTimeEntries(userID int, from, to time.Time) ([]model.TimeEntry, error) {
+	return data.queryTimeEntries("user_id = $1 AND start_at < $3 AND (end_at IS NULL OR end_at > $2)", userID, from, to)
+}
+
+func (data *Data) GetTimeEntriesByTaskID(taskID int) ([]model.TimeEntry, error) {
+	return data.queryTimeEntries("task_id = $1", taskID)
+}
+
+func (data *Data) GetTimeEntryByID(id int) (*model.TimeEntry, error) {
+	entry, err := scanTimeEntry(data.DB.QueryRow("SELECT "+timeEntryColumns+" FROM time_entries WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (data *Data) GetRunningTimeEntry(userID int) (*model.TimeEntry, error) {
+	entry, err := scanTimeEntry(data.DB.QueryRow("SELECT "+timeEntryColumns+" FROM time_entries WHERE user_id = $1 AND end_at IS NULL", userID))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// AddTimeEntry relies on time_entries_running_idx for one running timer per
+// user.
+func (data *Data) AddTimeEntry(entry model.TimeEntry) (model.TimeEntry, error) {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	defer tx.Rollback()
+
+	var taskID int
+	err = tx.QueryRow("SELECT id FROM tasks WHERE id = $1 FOR SHARE", entry.TaskID).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		return model.TimeEntry{}, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO time_entries (task_id, user_id, start_at, end_at, note) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		entry.TaskID, entry.UserID, entry.Start, entry.End, entry.Note,
+	).Scan(&entry.ID)
+	if isUniqueViolation(err) {
+		return model.TimeEntry{}, model.ErrTimerRunning
+	}
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.TimeEntry{}, err
+	}
+	return entry, nil
+}
+
+func (data *Data) UpdateTimeEntry(entry model.TimeEntry) error {
+	result, err := data.DB.Exec(
+		"UPDATE time_entries SET start_at = $2, end_at = $3, note = $4 WHERE id = $1",
+		entry.ID, entry.Start, entry.End, entry.Note,
+	)
+	if isUniqueViolation(err) {
+		return model.ErrTimerRunning
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return model.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (data *Data) StopTimer(userID int, end time.Time) (model.TimeEntry, error) {
+	entry, err := scanTimeEntry(data.DB.QueryRow(
+		"UPDATE time_entries SET end_at = $2 WHERE user_id = $1 AND end_at IS NULL RETURNING "+timeEntryColumns,
+		userID, end,
+	))
+	if err == sql.ErrNoRows {
+		return model.TimeEntry{}, model.ErrNoTimerRunning
+	}
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	return entry, nil
+}
+
+func (data *Data) DeleteTimeEntry(id int) error {
+	_, err := data.DB.Exec("DELETE FROM time_entries WHERE id = $1", id)
+	return err
+}
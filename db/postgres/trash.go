@@ -0,0 +1,373 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+
+	"github.com/lib/pq"
+)
+
+// scanRows reads every row of a query with scan.
+func scanRows[T any](q rowsQueryer, scan func(interface{ Scan(...interface{}) error }) (T, error), query string, args ...interface{}) ([]T, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []T
+	for rows.Next() {
+		record, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func scanTrash(row interface{ Scan(...interface{}) error }) (model.TrashEntry, error) {
+	var id int
+	var entryJSON []byte
+	if err := row.Scan(&id, &entryJSON); err != nil {
+		return model.TrashEntry{}, err
+	}
+	entry, err := db.UnmarshalTrash(entryJSON)
+	entry.ID = id
+	return entry, err
+}
+
+// insertTrash stores entry under a new ID and returns it.
+func insertTrash(tx *sql.Tx, entry model.TrashEntry) (model.TrashEntry, error) {
+	entryJSON, err := db.MarshalTrash(entry)
+	if err != nil {
+		return entry, err
+	}
+	err = tx.QueryRow(
+		"INSERT INTO trash (user_id, deleted_at, entry) VALUES ($1, $2, $3) RETURNING id",
+		entry.UserID, entry.DeletedAt, entryJSON,
+	).Scan(&entry.ID)
+	return entry, err
+}
+
+// trashTask moves task and its subtasks to a new trash entry.
+func trashTask(tx *sql.Tx, task model.Task) (model.TrashEntry, error) {
+	entry := model.TrashEntry{UserID: task.UserID, DeletedAt: db.Now()}
+	if err := trashTasks(tx, &entry, []model.Task{task}); err != nil {
+		return entry, err
+	}
+	return insertTrash(tx, entry)
+}
+
+// trashTasks moves tasks, their subtasks and the records hanging off them
+// into entry. Deleting the tasks cascades to those records.
+func trashTasks(tx *sql.Tx, entry *model.TrashEntry, tasks []model.Task) error {
+	var ids []int64
+	add := func(task model.Task) error {
+		if entry.HasTask(task.ID) {
+			return nil
+		}
+		trashed := model.TrashedTask{Task: task}
+		var err error
+		trashed.Comments, err = scanRows(tx, scanComment, "SELECT "+commentColumns+" FROM comments WHERE task_id = $1 ORDER BY id", task.ID)
+		if err != nil {
+			return err
+		}
+		trashed.Attachments, err = scanRows(tx, scanAttachment, "SELECT "+attachmentColumns+" FROM attachments WHERE task_id = $1 ORDER BY id", task.ID)
+		if err != nil {
+			return err
+		}
+		entries, err := scanRows(tx, scanTimeEntry, "SELECT "+timeEntryColumns+" FROM time_entries WHERE task_id = $1 ORDER BY id", task.ID)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			trashed.TimeEntries = append(trashed.TimeEntries, model.TrashedTimeEntry(e, entry.DeletedAt))
+		}
+		entry.Tasks = append(entry.Tasks, trashed)
+		ids = append(ids, int64(task.ID))
+		return nil
+	}
+	for _, task := range tasks {
+		if err := add(task); err != nil {
+			return err
+		}
+		subtasks, err := lockTasks(tx, "parent_id = $1", task.ID)
+		if err != nil {
+			return err
+		}
+		for _, subtask := range subtasks {
+			if err := add(subtask); err != nil {
+				return err
+			}
+		}
+	}
+
+	var err error
+	entry.Dependencies, err = scanRows(tx, func(row interface{ Scan(...interface{}) error }) (model.Dependency, error) {
+		var dep model.Dependency
+		err := row.Scan(&dep.TaskID, &dep.BlockedByID, &dep.UserID)
+		return dep, err
+	}, `SELECT task_id, blocked_by_id, user_id FROM task_dependencies
+		WHERE task_id = ANY($1) OR blocked_by_id = ANY($1) ORDER BY task_id, blocked_by_id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM tasks WHERE id = ANY($1)", pq.Array(ids))
+	return err
+}
+
+func (data *Data) TrashTask(id int) (model.TrashEntry, error) {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	defer tx.Rollback()
+
+	tasks, err := lockTasks(tx, "id = $1", id)
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	if len(tasks) == 0 {
+		return model.TrashEntry{}, model.ErrRecordNotFound
+	}
+	entry, err := trashTask(tx, tasks[0])
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	return entry, tx.Commit()
+}
+
+// TrashCategory treats the tasks of the category like DeleteCategory, tasks
+// deleted with it going to its entry.
+func (data *Data) TrashCategory(id int, opts model.CategoryDelete) (model.TrashEntry, error) {
+	if err := opts.Validate(id); err != nil {
+		return model.TrashEntry{}, err
+	}
+
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	defer tx.Rollback()
+
+	category, err := scanCategory(tx.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = $1 FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		return model.TrashEntry{}, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	entry := model.TrashEntry{UserID: category.UserID, Category: &category, DeletedAt: db.Now()}
+
+	tasks, err := lockTasks(tx, "category_id = $1", id)
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	if len(tasks) > 0 {
+		switch opts.Mode {
+		case model.CategoryDeleteCascade:
+			err = trashTasks(tx, &entry, tasks)
+		case model.CategoryDeleteReassign:
+			err = reassignTasks(tx, tasks, id, opts.ReassignTo)
+		default:
+			err = model.ErrCategoryNotEmpty
+		}
+		if err != nil {
+			return model.TrashEntry{}, err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = $1", id); err != nil {
+		return model.TrashEntry{}, err
+	}
+	if entry, err = insertTrash(tx, entry); err != nil {
+		return model.TrashEntry{}, err
+	}
+	return entry, tx.Commit()
+}
+
+func (data *Data) GetTrash(userID int) ([]model.TrashEntry, error) {
+	entries, err := scanRows(data.DB, scanTrash, "SELECT id, entry FROM trash WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching trash: %v", err)
+	}
+	return entries, nil
+}
+
+func (data *Data) GetTrashEntry(id int) (*model.TrashEntry, error) {
+	entry, err := scanTrash(data.DB.QueryRow("SELECT id, entry FROM trash WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RestoreTrash inserts the records under their old IDs in the transaction
+// that drops the entry, which stays locked until then.
+func (data *Data) RestoreTrash(id int) (model.TrashEntry, error) {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	defer tx.Rollback()
+
+	entry, err := scanTrash(tx.QueryRow("SELECT id, entry FROM trash WHERE id = $1 FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		return model.TrashEntry{}, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+
+	now := db.Now()
+	if category := entry.Category; category != nil {
+		_, err := tx.Exec(
+			"INSERT INTO categories ("+categoryColumns+") VALUES ($1, $2, $3, $4, $5)",
+			category.ID, category.Name, category.UserID, category.Version+1, now,
+		)
+		if err != nil {
+			return model.TrashEntry{}, err
+		}
+	}
+
+	for _, t := range entry.RestoreOrder() {
+		if err := restoreTask(tx, t, now); err != nil {
+			return model.TrashEntry{}, err
+		}
+	}
+
+	for _, dep := range entry.Dependencies {
+		if err := restoreDependency(tx, dep); err != nil {
+			return model.TrashEntry{}, err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM trash WHERE id = $1", id); err != nil {
+		return model.TrashEntry{}, err
+	}
+	return entry, tx.Commit()
+}
+
+// restoreTask inserts a trashed task and its records, leaving out tags that
+// are gone.
+func restoreTask(tx *sql.Tx, t model.TrashedTask, now time.Time) error {
+	task := t.Task
+	var tagIDs []int
+	for _, tagID := range task.TagIDs {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tags WHERE id = $1)", tagID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			tagIDs = append(tagIDs, tagID)
+		}
+	}
+	task.TagIDs = tagIDs
+	if err := checkTaskRefs(tx, task); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(
+		`INSERT INTO tasks (`+taskColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		task.ID, task.Title, deadlineArg(task.Deadline), task.Deadline.DateOnly, task.Priority, task.Status, task.CompletedAt, task.CategoryID, task.UserID, parentArg(task.ParentID), task.Position,
+		recurrenceArg(task.Recurrence), seriesArg(task.SeriesID), task.Version+1, now,
+	)
+	if err != nil {
+		return err
+	}
+	if err := setTaskTags(tx, task.ID, task.TagIDs); err != nil {
+		return err
+	}
+
+	for _, c := range t.Comments {
+		history, err := json.Marshal(c.History)
+		if err != nil {
+			return err
+		}
+		if c.History == nil {
+			history = []byte("[]")
+		}
+		_, err = tx.Exec(
+			"INSERT INTO comments ("+commentColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			c.ID, c.TaskID, c.AuthorID, c.Body, c.CreatedAt, c.UpdatedAt, history,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, a := range t.Attachments {
+		_, err := tx.Exec(
+			"INSERT INTO attachments ("+attachmentColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			a.ID, a.TaskID, a.UserID, a.Name, a.ContentType, a.Size, a.Key, a.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range t.TimeEntries {
+		_, err := tx.Exec(
+			"INSERT INTO time_entries ("+timeEntryColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+			e.ID, e.TaskID, e.UserID, e.Start, e.End, e.Note,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreDependency adds dep back unless one of its tasks is gone, it is
+// there already or it would close a cycle. It takes the lock AddDependency
+// takes.
+func restoreDependency(tx *sql.Tx, dep model.Dependency) error {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", dep.UserID); err != nil {
+		return err
+	}
+	var found int
+	err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE id IN ($1, $2)", dep.TaskID, dep.BlockedByID).Scan(&found)
+	if err != nil || found < 2 {
+		return err
+	}
+
+	deps, err := getDependencies(tx, dep.UserID)
+	if err != nil {
+		return err
+	}
+	for _, d := range deps {
+		if d == dep {
+			return nil
+		}
+	}
+	if model.CheckDependency(deps, dep) != nil {
+		return nil
+	}
+	_, err = tx.Exec(
+		"INSERT INTO task_dependencies (task_id, blocked_by_id, user_id) VALUES ($1, $2, $3)",
+		dep.TaskID, dep.BlockedByID, dep.UserID,
+	)
+	return err
+}
+
+func (data *Data) PurgeTrash(id int) error {
+	_, err := data.DB.Exec("DELETE FROM trash WHERE id = $1", id)
+	return err
+}
+
+func (data *Data) PurgeTrashBefore(t time.Time) ([]model.TrashEntry, error) {
+	entries, err := scanRows(data.DB, scanTrash, "DELETE FROM trash WHERE deleted_at < $1 RETURNING id, entry", t)
+	if err != nil {
+		return nil, fmt.Errorf("error purging trash: %v", err)
+	}
+	return entries, nil
+}
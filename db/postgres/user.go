@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+func (data *Data) GetUserByEmail(email string) (model.User, error) {
+	var user model.User
+	err := data.DB.QueryRow(
+		"SELECT id, fullname, email, password, timezone, created_at, updated_at FROM users WHERE email = $1",
+		email,
+	).Scan(&user.ID, &user.Fullname, &user.Email, &user.Password, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return model.User{}, nil // Return an empty User struct and nil error if not found
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+func (data *Data) CreateUser(user model.User) (model.User, error) {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return model.User{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO users (fullname, email, password, timezone, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		user.Fullname, user.Email, user.Password, user.Timezone, user.CreatedAt, user.UpdatedAt,
+	).Scan(&user.ID)
+	if isUniqueViolation(err) {
+		return model.User{}, fmt.Errorf("email already exists")
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+
+	// Unlike bbolt the default categories are created with the user, a
+	// registration never leaves a user without them
+	if err := createDefaultCategories(tx, user.ID); err != nil {
+		return model.User{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+func (data *Data) UpdateUser(user model.User) error {
+	result, err := data.DB.Exec(
+		`UPDATE users SET fullname = $2, email = $3, password = $4, timezone = $5, updated_at = $6
+		WHERE id = $1`,
+		user.ID, user.Fullname, user.Email, user.Password, user.Timezone, db.Now(),
+	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("email already exists")
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return model.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetUsers retrieves all users from the database
+func (data *Data) GetUsers() ([]model.User, error) {
+	rows, err := data.DB.Query("SELECT id, fullname, email, password, timezone, created_at, updated_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.ID, &user.Fullname, &user.Email, &user.Password, &user.Timezone, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (data *Data) GetUserTaskCategory() ([]model.UserTaskCategory, error) {
+	rows, err := data.DB.Query(
+		`SELECT u.id, u.fullname, u.email, t.title, t.deadline, t.deadline_date_only, t.priority, t.status, COALESCE(c.name, 'Unknown')
+		FROM users u
+		JOIN tasks t ON t.user_id = u.id
+		LEFT JOIN categories c ON c.id = t.category_id
+		ORDER BY u.id, t.id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []model.UserTaskCategory
+	for rows.Next() {
+		var r model.UserTaskCategory
+		var deadline sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Fullname, &r.Email, &r.Task, &deadline, &r.Deadline.DateOnly, &r.Priority, &r.Status, &r.Category); err != nil {
+			return nil, err
+		}
+		r.Deadline.At = deadlineTime(deadline)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// CreateDefaultCategoriesForUser creates default categories for a user that has none
+func (data *Data) CreateDefaultCategoriesForUser(userID int) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createDefaultCategories(tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func createDefaultCategories(tx *sql.Tx, userID int) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE user_id = $1)", userID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking existing categories: %v", err)
+	}
+
+	// If user already has categories, don't create more
+	if exists {
+		return nil
+	}
+
+	for _, name := range []string{"Work", "Personal", "Study", "Health", "Home"} {
+		if _, err := tx.Exec("INSERT INTO categories (name, user_id) VALUES ($1, $2)", name, userID); err != nil {
+			return fmt.Errorf("error storing category: %v", err)
+		}
+	}
+	return nil
+}
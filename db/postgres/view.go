@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"a21hc3NpZ25tZW50/model"
+)
+
+const viewColumns = "id, name, query, pinned, user_id"
+
+func scanView(row interface{ Scan(...interface{}) error }) (model.SavedView, error) {
+	var view model.SavedView
+	err := row.Scan(&view.ID, &view.Name, &view.Query, &view.Pinned, &view.UserID)
+	return view, err
+}
+
+func (data *Data) GetViewsByUserID(userID int) ([]model.SavedView, error) {
+	rows, err := data.DB.Query("SELECT "+viewColumns+" FROM saved_views WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching views: %v", err)
+	}
+	defer rows.Close()
+
+	var views []model.SavedView
+	for rows.Next() {
+		view, err := scanView(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching views: %v", err)
+		}
+		views = append(views, view)
+	}
+	return views, rows.Err()
+}
+
+func (data *Data) GetViewByID(id int) (*model.SavedView, error) {
+	view, err := scanView(data.DB.QueryRow("SELECT "+viewColumns+" FROM saved_views WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// CreateView relies on saved_views_user_id_name_idx for unique names.
+func (data *Data) CreateView(view model.SavedView) (model.SavedView, error) {
+	err := data.DB.QueryRow(
+		"INSERT INTO saved_views (name, query, pinned, user_id) VALUES ($1, $2, $3, $4) RETURNING id",
+		view.Name, view.Query, view.Pinned, view.UserID,
+	).Scan(&view.ID)
+	if isUniqueViolation(err) {
+		return model.SavedView{}, model.ErrViewExists
+	}
+	if err != nil {
+		return model.SavedView{}, err
+	}
+	return view, nil
+}
+
+// UpdateView renames, re-queries or (un)pins a view, it stays with its user.
+func (data *Data) UpdateView(view model.SavedView) error {
+	result, err := data.DB.Exec(
+		"UPDATE saved_views SET name = $2, query = $3, pinned = $4 WHERE id = $1",
+		view.ID, view.Name, view.Query, view.Pinned,
+	)
+	if isUniqueViolation(err) {
+		return model.ErrViewExists
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return model.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (data *Data) DeleteView(id int) error {
+	_, err := data.DB.Exec("DELETE FROM saved_views WHERE id = $1", id)
+	return err
+}
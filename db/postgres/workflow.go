@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"a21hc3NpZ25tZW50/model"
+)
+
+func (data *Data) GetWorkflow(userID int) (*model.Workflow, error) {
+	var statuses, transitions []byte
+	err := data.DB.QueryRow("SELECT statuses, transitions FROM workflows WHERE user_id = $1", userID).
+		Scan(&statuses, &transitions)
+	if err == sql.ErrNoRows {
+		return nil, model.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	workflow := model.Workflow{UserID: userID}
+	if err := json.Unmarshal(statuses, &workflow.Statuses); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(transitions, &workflow.Transitions); err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+func (data *Data) SaveWorkflow(workflow model.Workflow) error {
+	statuses, err := json.Marshal(workflow.Statuses)
+	if err != nil {
+		return err
+	}
+	transitions, err := json.Marshal(workflow.Transitions)
+	if err != nil {
+		return err
+	}
+
+	_, err = data.DB.Exec(
+		`INSERT INTO workflows (user_id, statuses, transitions) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET statuses = EXCLUDED.statuses, transitions = EXCLUDED.transitions`,
+		workflow.UserID, statuses, transitions,
+	)
+	return err
+}
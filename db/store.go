@@ -0,0 +1,172 @@
+package db
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"time"
+)
+
+// Now is the UpdatedAt stores record on a write, in UTC and at the microsecond
+// precision every backend keeps.
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Store is the storage the repositories are built on. filebased (bbolt),
+// postgres and memory implement it with the same semantics, which the
+// storetest contract suite checks.
+type Store interface {
+	// Tasks, StoreTask and UpdateTask fail with model.ErrCategoryNotFound or
+	// model.ErrCategoryNotOwned unless the category is one the task's user may use.
+	// Every write bumps Version and sets UpdatedAt. UpdateTask fails with
+	// model.ErrRecordNotFound for a missing ID and, unless task.Version is 0,
+	// with model.ErrVersionConflict when it differs from the stored Version.
+	StoreTask(task model.Task) error
+	UpdateTask(id int, task model.Task) error
+	DeleteTask(id int) error
+	GetTaskByID(id int) (*model.Task, error)
+	GetTasksByUserID(userID int) ([]model.Task, error)
+	GetTaskListByCategory(categoryID int) ([]model.TaskCategory, error)
+	GetTaskListByCategoryAndUser(categoryID, userID int) ([]model.TaskCategory, error)
+	// GetTaskPage returns the tasks of a user matching query in its order,
+	// at most query.Limit of them past query.After, with how many match in
+	// all. query has been validated.
+	GetTaskPage(userID int, query model.TaskQuery) (model.TaskPage, error)
+	// WriteTasks makes writes in order in one transaction, skipping those
+	// whose Err is set. Each is checked like StoreTask, UpdateTask or
+	// DeleteTask would, updates and deletes against Task.Version unless it
+	// is 0, and a refused write gets its Err. When atomic, a refused write
+	// rolls back the others and WriteTasks returns model.ErrBulkAborted.
+	// Writes made set Task to the stored task, deletes move it to the trash
+	// like TrashTask and set TrashID.
+	WriteTasks(writes []model.TaskWrite, atomic bool) error
+
+	// Categories, versioned like tasks. StoreCategory and UpdateCategory fail
+	// with model.ErrCategoryExists when another category of the user has the
+	// name.
+	StoreCategory(category model.Category) error
+	UpdateCategory(id int, category model.Category) error
+	DeleteCategory(id int, opts model.CategoryDelete) error
+	GetCategoryByID(id int) (*model.Category, error)
+	GetCategories() ([]model.Category, error)
+	GetCategoriesByUserID(userID int) ([]model.Category, error)
+
+	// Users
+	GetUserByEmail(email string) (model.User, error)
+	CreateUser(user model.User) (model.User, error)
+	// UpdateUser replaces the user with user.ID, model.ErrRecordNotFound if
+	// there is none
+	UpdateUser(user model.User) error
+	CreateDefaultCategoriesForUser(userID int) error
+	GetUserTaskCategory() ([]model.UserTaskCategory, error)
+	GetUsers() ([]model.User, error)
+
+	// Workflows, GetWorkflow fails with model.ErrRecordNotFound for a user
+	// that kept the default one
+	GetWorkflow(userID int) (*model.Workflow, error)
+	SaveWorkflow(workflow model.Workflow) error
+
+	// Dependencies between the tasks of a user, listed by TaskID then
+	// BlockedByID. AddDependency fails with model.ErrRecordNotFound for a
+	// missing task, model.ErrInvalidDependency for a task of another user and
+	// model.ErrDependencyCycle for an edge that closes a cycle, adding an
+	// existing edge succeeds. Deleting a task drops its edges.
+	GetDependencies(userID int) ([]model.Dependency, error)
+	AddDependency(dep model.Dependency) error
+	RemoveDependency(dep model.Dependency) error
+
+	// Tags of a user in ID order. CreateTag and UpdateTag fail with
+	// model.ErrTagExists for a name the user already has, UpdateTag with
+	// model.ErrRecordNotFound for a missing tag. A task carrying a missing tag
+	// or one of another user is refused with model.ErrTagNotFound or
+	// model.ErrTagNotOwned. DeleteTag takes the tag off its tasks.
+	GetTagsByUserID(userID int) ([]model.Tag, error)
+	GetTagByID(id int) (*model.Tag, error)
+	CreateTag(tag model.Tag) (model.Tag, error)
+	UpdateTag(tag model.Tag) error
+	DeleteTag(id int) error
+
+	// Comments on a task in ID order. AddComment fails with
+	// model.ErrRecordNotFound for a missing task and sets the ID and both
+	// timestamps, UpdateComment keeps the replaced body in History. Deleting a
+	// task drops its comments.
+	GetComments(taskID int) ([]model.Comment, error)
+	GetCommentByID(id int) (*model.Comment, error)
+	AddComment(comment model.Comment) (model.Comment, error)
+	UpdateComment(id int, body string) (model.Comment, error)
+	DeleteComment(id int) error
+
+	// Attachments of a task in ID order. AddAttachment fails with
+	// model.ErrRecordNotFound for a missing task and with
+	// model.ErrQuotaExceeded when the user's attachments would then take more
+	// than quota bytes, zero meaning no quota; it sets the ID and CreatedAt.
+	// Deleting a task drops its attachment records, the blobs are left for the
+	// caller to sweep.
+	GetAttachments(taskID int) ([]model.Attachment, error)
+	GetAttachmentsByUserID(userID int) ([]model.Attachment, error)
+	GetAttachmentByID(id int) (*model.Attachment, error)
+	AddAttachment(attachment model.Attachment, quota int64) (model.Attachment, error)
+	DeleteAttachment(id int) error
+
+	// Time entries in ID order. GetTimeEntries returns those of a user that
+	// overlap from to to, a running timer counting as open-ended. A user has
+	// at most one running timer: adding or updating an entry without End fails
+	// with model.ErrTimerRunning while another one runs. AddTimeEntry fails
+	// with model.ErrRecordNotFound for a missing task, StopTimer with
+	// model.ErrNoTimerRunning. Deleting a task drops its entries.
+	GetTimeEntries(userID int, from, to time.Time) ([]model.TimeEntry, error)
+	GetTimeEntriesByTaskID(taskID int) ([]model.TimeEntry, error)
+	GetTimeEntryByID(id int) (*model.TimeEntry, error)
+	GetRunningTimeEntry(userID int) (*model.TimeEntry, error)
+	AddTimeEntry(entry model.TimeEntry) (model.TimeEntry, error)
+	UpdateTimeEntry(entry model.TimeEntry) error
+	StopTimer(userID int, end time.Time) (model.TimeEntry, error)
+	DeleteTimeEntry(id int) error
+
+	// Saved views of a user in ID order. CreateView and UpdateView fail with
+	// model.ErrViewExists for a name the user already has, UpdateView with
+	// model.ErrRecordNotFound for a missing view.
+	GetViewsByUserID(userID int) ([]model.SavedView, error)
+	GetViewByID(id int) (*model.SavedView, error)
+	CreateView(view model.SavedView) (model.SavedView, error)
+	UpdateView(view model.SavedView) error
+	DeleteView(id int) error
+
+	// Trash holds what deleting a task or a category removed, as entries of
+	// the user in ID order, see model.TrashEntry. TrashTask and TrashCategory
+	// remove records like DeleteTask and DeleteCategory do, keeping them in
+	// the entry they return; they fail with model.ErrRecordNotFound for a
+	// missing ID. RestoreTrash puts the records of an entry back under their
+	// IDs with their versions bumped and drops the entry, in one transaction.
+	// It fails with model.ErrCategoryNotFound, model.ErrCategoryNotOwned or
+	// model.ErrParentNotFound for a task whose category or parent is gone,
+	// and leaves out tags deleted since and edges that would close a cycle.
+	// PurgeTrashBefore drops the entries deleted before t and returns them.
+	TrashTask(id int) (model.TrashEntry, error)
+	TrashCategory(id int, opts model.CategoryDelete) (model.TrashEntry, error)
+	GetTrash(userID int) ([]model.TrashEntry, error)
+	GetTrashEntry(id int) (*model.TrashEntry, error)
+	RestoreTrash(id int) (model.TrashEntry, error)
+	PurgeTrash(id int) error
+	PurgeTrashBefore(t time.Time) ([]model.TrashEntry, error)
+
+	// SearchTasks returns the words of the user's tasks, as made by
+	// model.SearchDocument, that start with one of terms. Writes to a task,
+	// its comments or its category are searchable as soon as they return.
+	SearchTasks(userID int, terms []string) ([]model.SearchMatch, error)
+
+	// Sessions
+	AddSession(session model.Session) error
+	UpdateSession(session model.Session) error
+	DeleteSession(token string) error
+	SessionAvailEmail(email string) (model.Session, error)
+	SessionAvailToken(token string) (model.Session, error)
+	SessionByRefreshToken(refreshToken string) (model.Session, error)
+	RotateSession(old model.Session, next model.Session) error
+	TouchSession(token string, at time.Time) error
+	GetSessionsByEmail(email string) ([]model.Session, error)
+	DeleteSessionsByID(id int) error
+	DeleteSessionsByEmail(email string) error
+	DeleteSessionFamily(familyID string) error
+
+	CloseDB() error
+}
@@ -0,0 +1,1389 @@
+// Package storetest holds the contract every db.Store implementation must
+// satisfy. Backends run it from the Ginkgo suite with Contract.
+package storetest
+
+import (
+	"time"
+
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// Contract declares the store specs for one backend. open is called before
+// every spec and must return an empty store, the store is closed afterwards.
+func Contract(name string, open func() db.Store) bool {
+	return Describe("Store contract: "+name, func() {
+		var store db.Store
+
+		BeforeEach(func() {
+			store = open()
+		})
+
+		AfterEach(func() {
+			if store != nil {
+				Expect(store.CloseDB()).To(Succeed())
+			}
+		})
+
+		seed := func() {
+			for _, c := range []model.Category{
+				{ID: 1, Name: "Category 1"},
+				{ID: 2, Name: "Category 2"},
+				{ID: 3, Name: "Category 3"},
+			} {
+				Expect(store.StoreCategory(c)).To(Succeed())
+			}
+
+			for _, t := range []model.Task{
+				{ID: 1, Title: "Task 1", Deadline: model.DateDeadline(2023, 5, 30), Priority: 2, Status: "In Progress", CategoryID: 1, UserID: 2},
+				{ID: 2, Title: "Task 2", Deadline: model.DateDeadline(2023, 6, 1), Priority: 1, Status: "Completed", CategoryID: 2, UserID: 1},
+				{ID: 3, Title: "Task 3", Deadline: model.DateDeadline(2023, 6, 2), Priority: 4, Status: "Completed", CategoryID: 1, UserID: 1},
+				{ID: 4, Title: "Task 4", Deadline: model.DateDeadline(2023, 6, 7), Priority: 5, Status: "In Progress", CategoryID: 2, UserID: 1},
+			} {
+				Expect(store.StoreTask(t)).To(Succeed())
+			}
+		}
+
+		Describe("Tasks", func() {
+			BeforeEach(seed)
+
+			It("should generate IDs past the ones stored explicitly", func() {
+				Expect(store.StoreTask(model.Task{Title: "New", CategoryID: 1, UserID: 1})).To(Succeed())
+
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(4))
+				Expect(tasks[3].ID).To(BeNumerically(">", 4))
+				Expect(tasks[3].Title).To(Equal("New"))
+			})
+
+			It("should get, replace and delete a task by ID", func() {
+				task, err := store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Title).To(Equal("Task 2"))
+
+				task.Title = "Task 2 updated"
+				Expect(store.UpdateTask(task.ID, *task)).To(Succeed())
+
+				task, err = store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Title).To(Equal("Task 2 updated"))
+
+				Expect(store.DeleteTask(2)).To(Succeed())
+				task, err = store.GetTaskByID(2)
+				Expect(err).To(MatchError("record not found"))
+				Expect(task).To(BeNil())
+
+				Expect(store.DeleteTask(2)).To(Succeed())
+			})
+
+			It("should keep date-only and timed deadlines apart", func() {
+				timed, err := model.ParseDeadline("2023-06-01T17:30:00+07:00")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(store.UpdateTask(3, model.Task{Title: "Timed", Deadline: timed, CategoryID: 1, UserID: 1})).To(Succeed())
+				Expect(store.UpdateTask(4, model.Task{Title: "None", CategoryID: 2, UserID: 1})).To(Succeed())
+
+				task, err := store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Deadline).To(Equal(model.DateDeadline(2023, 6, 1)))
+
+				task, err = store.GetTaskByID(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Deadline).To(Equal(model.Deadline{At: time.Date(2023, 6, 1, 10, 30, 0, 0, time.UTC)}))
+
+				task, err = store.GetTaskByID(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Deadline.IsZero()).To(BeTrue())
+			})
+
+			It("should list the tasks of a user in ID order", func() {
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(3))
+				Expect([]int{tasks[0].ID, tasks[1].ID, tasks[2].ID}).To(Equal([]int{2, 3, 4}))
+
+				tasks, err = store.GetTasksByUserID(99)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(BeEmpty())
+			})
+
+			It("should list tasks by category with the category name", func() {
+				taskCategories, err := store.GetTaskListByCategory(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(taskCategories).To(Equal([]model.TaskCategory{
+					{ID: 1, Title: "Task 1", Category: "Category 1"},
+					{ID: 3, Title: "Task 3", Category: "Category 1"},
+				}))
+
+				_, err = store.GetTaskListByCategory(3)
+				Expect(err).To(MatchError("no tasks found for category ID: 3"))
+
+				_, err = store.GetTaskListByCategory(42)
+				Expect(err).To(MatchError("error fetching category: record not found"))
+			})
+
+			It("should list tasks by category and user", func() {
+				taskCategories, err := store.GetTaskListByCategoryAndUser(1, 1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(taskCategories).To(Equal([]model.TaskCategory{
+					{ID: 3, Title: "Task 3", Category: "Category 1"},
+				}))
+
+				taskCategories, err = store.GetTaskListByCategoryAndUser(3, 1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(taskCategories).To(BeEmpty())
+			})
+		})
+
+		Describe("Categories", func() {
+			BeforeEach(seed)
+
+			It("should get, replace and delete a category by ID", func() {
+				category, err := store.GetCategoryByID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(category.Name).To(Equal("Category 1"))
+
+				Expect(store.UpdateCategory(1, model.Category{ID: 1, Name: "Renamed", UserID: 5})).To(Succeed())
+				category, err = store.GetCategoryByID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(category.Name).To(Equal("Renamed"))
+				Expect(category.UserID).To(Equal(5))
+				Expect(category.Version).To(Equal(2))
+
+				Expect(store.DeleteCategory(3, model.CategoryDelete{})).To(Succeed())
+				category, err = store.GetCategoryByID(3)
+				Expect(err).To(MatchError("record not found"))
+				Expect(category).To(BeNil())
+
+				Expect(store.DeleteCategory(3, model.CategoryDelete{})).To(Succeed())
+			})
+
+			It("should list all categories and those of one user", func() {
+				Expect(store.StoreCategory(model.Category{Name: "Mine", UserID: 7})).To(Succeed())
+
+				categories, err := store.GetCategories()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(categories).To(HaveLen(4))
+
+				categories, err = store.GetCategoriesByUserID(7)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(categories).To(HaveLen(1))
+				Expect(categories[0].Name).To(Equal("Mine"))
+				Expect(categories[0].ID).To(BeNumerically(">", 3))
+			})
+
+			It("should keep category names unique per user", func() {
+				Expect(store.StoreCategory(model.Category{ID: 10, Name: "Work", UserID: 7})).To(Succeed())
+				Expect(store.StoreCategory(model.Category{ID: 11, Name: "Home", UserID: 7})).To(Succeed())
+				Expect(store.StoreCategory(model.Category{Name: "Work", UserID: 8})).To(Succeed())
+
+				Expect(store.StoreCategory(model.Category{Name: " work ", UserID: 7})).To(MatchError(model.ErrCategoryExists))
+				Expect(store.UpdateCategory(11, model.Category{Name: "WORK", UserID: 7})).To(MatchError(model.ErrCategoryExists))
+				category, err := store.GetCategoryByID(11)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(category.Name).To(Equal("Home"))
+				Expect(category.Version).To(Equal(1))
+
+				// A category keeps its own name in another case
+				Expect(store.UpdateCategory(10, model.Category{Name: "work", UserID: 7})).To(Succeed())
+				categories, err := store.GetCategoriesByUserID(7)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(categories).To(HaveLen(2))
+			})
+		})
+
+		Describe("Category integrity", func() {
+			BeforeEach(func() {
+				seed()
+				Expect(store.StoreCategory(model.Category{ID: 10, Name: "Mine", UserID: 1})).To(Succeed())
+				Expect(store.StoreCategory(model.Category{ID: 11, Name: "Theirs", UserID: 2})).To(Succeed())
+			})
+
+			It("should only store tasks in an existing category the user may use", func() {
+				Expect(store.StoreTask(model.Task{Title: "Mine", CategoryID: 10, UserID: 1})).To(Succeed())
+				Expect(store.StoreTask(model.Task{Title: "System", CategoryID: 3, UserID: 1})).To(Succeed())
+
+				Expect(store.StoreTask(model.Task{Title: "Missing", CategoryID: 42, UserID: 1})).To(MatchError(model.ErrCategoryNotFound))
+				Expect(store.StoreTask(model.Task{Title: "Theirs", CategoryID: 11, UserID: 1})).To(MatchError(model.ErrCategoryNotOwned))
+				Expect(store.UpdateTask(2, model.Task{ID: 2, Title: "Task 2", CategoryID: 11, UserID: 1})).To(MatchError(model.ErrCategoryNotOwned))
+
+				task, err := store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.CategoryID).To(Equal(2))
+			})
+
+			It("should reject deleting a category that still has tasks", func() {
+				Expect(store.DeleteCategory(2, model.CategoryDelete{})).To(MatchError(model.ErrCategoryNotEmpty))
+				Expect(store.DeleteCategory(2, model.CategoryDelete{Mode: model.CategoryDeleteReject})).To(MatchError(model.ErrCategoryNotEmpty))
+
+				_, err := store.GetCategoryByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(3))
+
+				Expect(store.DeleteCategory(2, model.CategoryDelete{Mode: "archive"})).To(HaveOccurred())
+			})
+
+			It("should delete the tasks of a category with cascade", func() {
+				Expect(store.DeleteCategory(2, model.CategoryDelete{Mode: model.CategoryDeleteCascade})).To(Succeed())
+
+				_, err := store.GetCategoryByID(2)
+				Expect(err).To(MatchError("record not found"))
+				_, err = store.GetTaskByID(2)
+				Expect(err).To(MatchError("record not found"))
+				_, err = store.GetTaskByID(4)
+				Expect(err).To(MatchError("record not found"))
+
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+				Expect(tasks[0].ID).To(Equal(3))
+			})
+
+			It("should move the tasks of a category with reassign", func() {
+				Expect(store.DeleteCategory(2, model.CategoryDelete{Mode: model.CategoryDeleteReassign, ReassignTo: 10})).To(Succeed())
+
+				_, err := store.GetCategoryByID(2)
+				Expect(err).To(MatchError("record not found"))
+				taskCategories, err := store.GetTaskListByCategoryAndUser(10, 1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(taskCategories).To(Equal([]model.TaskCategory{
+					{ID: 2, Title: "Task 2", Category: "Mine"},
+					{ID: 4, Title: "Task 4", Category: "Mine"},
+				}))
+			})
+
+			It("should leave everything in place when a task cannot be reassigned", func() {
+				// Category 1 holds tasks of users 1 and 2, user 1 may not use category 11
+				Expect(store.DeleteCategory(1, model.CategoryDelete{Mode: model.CategoryDeleteReassign, ReassignTo: 11})).To(MatchError(model.ErrCategoryNotOwned))
+				Expect(store.DeleteCategory(1, model.CategoryDelete{Mode: model.CategoryDeleteReassign, ReassignTo: 42})).To(MatchError(model.ErrCategoryNotFound))
+				Expect(store.DeleteCategory(1, model.CategoryDelete{Mode: model.CategoryDeleteReassign, ReassignTo: 1})).To(HaveOccurred())
+
+				_, err := store.GetCategoryByID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				for _, id := range []int{1, 3} {
+					task, err := store.GetTaskByID(id)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(task.CategoryID).To(Equal(1))
+				}
+			})
+		})
+
+		Describe("Versions", func() {
+			BeforeEach(seed)
+
+			It("should start at version 1 and bump the version on every write", func() {
+				task, err := store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Version).To(Equal(1))
+				Expect(task.UpdatedAt).ShouldNot(BeZero())
+				stored := task.UpdatedAt
+
+				// Version 0 skips the check
+				task.Title = "Task 2 updated"
+				task.Version = 0
+				Expect(store.UpdateTask(2, *task)).To(Succeed())
+				task, err = store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Version).To(Equal(2))
+				Expect(task.UpdatedAt).To(BeTemporally(">=", stored))
+
+				Expect(store.UpdateTask(2, *task)).To(Succeed())
+				task, err = store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Version).To(Equal(3))
+			})
+
+			It("should reject an update with a stale version", func() {
+				task, err := store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				stale := *task
+
+				task.Title = "first"
+				Expect(store.UpdateTask(2, *task)).To(Succeed())
+
+				stale.Title = "second"
+				Expect(store.UpdateTask(2, stale)).To(MatchError(model.ErrVersionConflict))
+				task, err = store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Title).To(Equal("first"))
+
+				category, err := store.GetCategoryByID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				category.Version = 5
+				Expect(store.UpdateCategory(1, *category)).To(MatchError(model.ErrVersionConflict))
+			})
+
+			It("should not create a record when updating a missing ID", func() {
+				Expect(store.UpdateTask(42, model.Task{ID: 42, Title: "ghost", CategoryID: 1, UserID: 1})).To(MatchError(model.ErrRecordNotFound))
+				_, err := store.GetTaskByID(42)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				Expect(store.UpdateCategory(42, model.Category{ID: 42, Name: "ghost"})).To(MatchError(model.ErrRecordNotFound))
+				_, err = store.GetCategoryByID(42)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+			})
+
+			It("should bump the version of tasks moved by a reassign", func() {
+				Expect(store.DeleteCategory(2, model.CategoryDelete{Mode: model.CategoryDeleteReassign, ReassignTo: 3})).To(Succeed())
+				task, err := store.GetTaskByID(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.CategoryID).To(Equal(3))
+				Expect(task.Version).To(Equal(2))
+			})
+		})
+
+		Describe("Users", func() {
+			It("should create a user with default categories and reject a duplicate email", func() {
+				user, err := store.CreateUser(model.User{Fullname: "test", Email: "test@mail.com", Password: "hash"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(user.ID).To(BeNumerically(">", 0))
+
+				categories, err := store.GetCategoriesByUserID(user.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				names := []string{}
+				for _, c := range categories {
+					names = append(names, c.Name)
+				}
+				Expect(names).To(Equal([]string{"Work", "Personal", "Study", "Health", "Home"}))
+
+				Expect(store.CreateDefaultCategoriesForUser(user.ID)).To(Succeed())
+				categories, err = store.GetCategoriesByUserID(user.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(categories).To(HaveLen(5))
+
+				_, err = store.CreateUser(model.User{Fullname: "again", Email: "test@mail.com", Password: "hash"})
+				Expect(err).To(MatchError("email already exists"))
+
+				users, err := store.GetUsers()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(users).To(HaveLen(1))
+			})
+
+			It("should find a user by email and return an empty user otherwise", func() {
+				created, err := store.CreateUser(model.User{Fullname: "test", Email: "test@mail.com", Password: "hash"})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				user, err := store.GetUserByEmail("test@mail.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(user.ID).To(Equal(created.ID))
+				Expect(user.Fullname).To(Equal("test"))
+
+				user, err = store.GetUserByEmail("nobody@mail.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(user).To(Equal(model.User{}))
+			})
+
+			It("should update a user and move its email", func() {
+				created, err := store.CreateUser(model.User{Fullname: "test", Email: "test@mail.com", Password: "hash"})
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.CreateUser(model.User{Fullname: "other", Email: "other@mail.com", Password: "hash"})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				created.Timezone = "Asia/Jakarta"
+				created.Email = "new@mail.com"
+				Expect(store.UpdateUser(created)).To(Succeed())
+
+				user, err := store.GetUserByEmail("new@mail.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(user.ID).To(Equal(created.ID))
+				Expect(user.Timezone).To(Equal("Asia/Jakarta"))
+				user, err = store.GetUserByEmail("test@mail.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(user).To(Equal(model.User{}))
+
+				created.Email = "other@mail.com"
+				Expect(store.UpdateUser(created)).To(MatchError("email already exists"))
+				Expect(store.UpdateUser(model.User{ID: 42, Email: "ghost@mail.com"})).To(MatchError(model.ErrRecordNotFound))
+			})
+
+			It("should join users with their tasks and category names", func() {
+				seed()
+				user, err := store.CreateUser(model.User{Fullname: "test", Email: "test@mail.com", Password: "hash"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(user.ID).To(Equal(1))
+
+				results, err := store.GetUserTaskCategory()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(Equal([]model.UserTaskCategory{
+					{ID: 1, Fullname: "test", Email: "test@mail.com", Task: "Task 2", Deadline: model.DateDeadline(2023, 6, 1), Priority: 1, Status: "Completed", Category: "Category 2"},
+					{ID: 1, Fullname: "test", Email: "test@mail.com", Task: "Task 3", Deadline: model.DateDeadline(2023, 6, 2), Priority: 4, Status: "Completed", Category: "Category 1"},
+					{ID: 1, Fullname: "test", Email: "test@mail.com", Task: "Task 4", Deadline: model.DateDeadline(2023, 6, 7), Priority: 5, Status: "In Progress", Category: "Category 2"},
+				}))
+			})
+		})
+
+		Describe("Subtasks", func() {
+			BeforeEach(seed)
+
+			It("should only store a subtask under a top-level task of the same user", func() {
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Step 1", CategoryID: 2, UserID: 1, ParentID: 2, Position: 1})).To(Succeed())
+				task, err := store.GetTaskByID(5)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.ParentID).To(Equal(2))
+				Expect(task.Position).To(Equal(1))
+
+				Expect(store.StoreTask(model.Task{Title: "Orphan", CategoryID: 2, UserID: 1, ParentID: 99})).To(MatchError(model.ErrParentNotFound))
+				Expect(store.StoreTask(model.Task{Title: "Theirs", CategoryID: 1, UserID: 1, ParentID: 1})).To(MatchError(model.ErrParentNotOwned))
+				Expect(store.StoreTask(model.Task{Title: "Step 1.1", CategoryID: 2, UserID: 1, ParentID: 5})).To(MatchError(model.ErrNestedSubtask))
+
+				// A parent cannot become a subtask, nor a task its own parent
+				task, err = store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				task.ParentID = 4
+				Expect(store.UpdateTask(2, *task)).To(MatchError(model.ErrNestedSubtask))
+				task.ParentID = 2
+				Expect(store.UpdateTask(2, *task)).To(MatchError(model.ErrNestedSubtask))
+
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(4))
+			})
+
+			It("should delete the subtasks of a deleted task", func() {
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Step 1", CategoryID: 2, UserID: 1, ParentID: 2})).To(Succeed())
+				Expect(store.StoreTask(model.Task{ID: 6, Title: "Step 2", CategoryID: 1, UserID: 1, ParentID: 2})).To(Succeed())
+				Expect(store.StoreTask(model.Task{ID: 7, Title: "Other step", CategoryID: 1, UserID: 1, ParentID: 3})).To(Succeed())
+
+				Expect(store.DeleteTask(2)).To(Succeed())
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect([]int{tasks[0].ID, tasks[1].ID, tasks[2].ID}).To(Equal([]int{3, 4, 7}))
+
+				// Also when the parent goes with its category
+				Expect(store.DeleteCategory(1, model.CategoryDelete{Mode: model.CategoryDeleteCascade})).To(Succeed())
+				tasks, err = store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+				Expect(tasks[0].ID).To(Equal(4))
+			})
+		})
+
+		Describe("Recurrence", func() {
+			BeforeEach(seed)
+
+			It("should keep the rule and series of an occurrence", func() {
+				r := &model.Recurrence{Freq: model.RecurWeekly, Interval: 2, ByDay: []string{"MO", "TH"}, Timezone: "Asia/Jakarta"}
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Report", Deadline: model.DateDeadline(2023, 6, 8), CategoryID: 2, UserID: 1, Recurrence: r, SeriesID: 4})).To(Succeed())
+
+				task, err := store.GetTaskByID(5)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Recurrence).To(Equal(r))
+				Expect(task.SeriesID).To(Equal(4))
+
+				task.Recurrence = nil
+				Expect(store.UpdateTask(5, *task)).To(Succeed())
+				task, err = store.GetTaskByID(5)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Recurrence).To(BeNil())
+				Expect(task.SeriesID).To(Equal(4))
+			})
+		})
+
+		Describe("Dependencies", func() {
+			BeforeEach(seed)
+
+			It("should add edges between tasks of one user and refuse cycles", func() {
+				Expect(store.AddDependency(model.Dependency{TaskID: 4, BlockedByID: 3, UserID: 1})).To(Succeed())
+				Expect(store.AddDependency(model.Dependency{TaskID: 3, BlockedByID: 2, UserID: 1})).To(Succeed())
+				Expect(store.AddDependency(model.Dependency{TaskID: 4, BlockedByID: 3, UserID: 1})).To(Succeed())
+
+				deps, err := store.GetDependencies(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deps).To(Equal([]model.Dependency{
+					{TaskID: 3, BlockedByID: 2, UserID: 1},
+					{TaskID: 4, BlockedByID: 3, UserID: 1},
+				}))
+
+				Expect(store.AddDependency(model.Dependency{TaskID: 2, BlockedByID: 4, UserID: 1})).To(MatchError(model.ErrDependencyCycle))
+				Expect(store.AddDependency(model.Dependency{TaskID: 2, BlockedByID: 2, UserID: 1})).To(MatchError(model.ErrInvalidDependency))
+				Expect(store.AddDependency(model.Dependency{TaskID: 2, BlockedByID: 1, UserID: 1})).To(MatchError(model.ErrInvalidDependency))
+				Expect(store.AddDependency(model.Dependency{TaskID: 2, BlockedByID: 99, UserID: 1})).To(MatchError(model.ErrRecordNotFound))
+
+				deps, err = store.GetDependencies(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deps).To(BeEmpty())
+
+				Expect(store.RemoveDependency(model.Dependency{TaskID: 3, BlockedByID: 2, UserID: 1})).To(Succeed())
+				Expect(store.AddDependency(model.Dependency{TaskID: 2, BlockedByID: 4, UserID: 1})).To(Succeed())
+			})
+
+			It("should drop the edges of a deleted task", func() {
+				Expect(store.AddDependency(model.Dependency{TaskID: 4, BlockedByID: 3, UserID: 1})).To(Succeed())
+				Expect(store.AddDependency(model.Dependency{TaskID: 3, BlockedByID: 2, UserID: 1})).To(Succeed())
+
+				Expect(store.DeleteTask(3)).To(Succeed())
+				deps, err := store.GetDependencies(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deps).To(BeEmpty())
+			})
+		})
+
+		Describe("Tags", func() {
+			BeforeEach(seed)
+
+			It("should create tags with names unique per user", func() {
+				work, err := store.CreateTag(model.Tag{Name: "Work", Color: "#ff0000", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(work.ID).To(BeNumerically(">", 0))
+				home, err := store.CreateTag(model.Tag{Name: "Home", Color: "#00ff00", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.CreateTag(model.Tag{Name: "work", Color: "#0000ff", UserID: 1})
+				Expect(err).To(MatchError(model.ErrTagExists))
+				_, err = store.CreateTag(model.Tag{Name: "Work", Color: "#0000ff", UserID: 2})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				tags, err := store.GetTagsByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tags).To(Equal([]model.Tag{work, home}))
+
+				home.Name = "WORK"
+				Expect(store.UpdateTag(home)).To(MatchError(model.ErrTagExists))
+				home.Name, home.Color, home.UserID = "Chores", "#123456", 2
+				Expect(store.UpdateTag(home)).To(Succeed())
+				tag, err := store.GetTagByID(home.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*tag).To(Equal(model.Tag{ID: home.ID, Name: "Chores", Color: "#123456", UserID: 1}))
+
+				Expect(store.UpdateTag(model.Tag{ID: 99, Name: "Missing", Color: "#123456"})).To(MatchError(model.ErrRecordNotFound))
+				_, err = store.GetTagByID(99)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+			})
+
+			It("should only tag tasks with existing tags of their user", func() {
+				work, err := store.CreateTag(model.Tag{Name: "Work", Color: "#ff0000", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				theirs, err := store.CreateTag(model.Tag{Name: "Work", Color: "#ff0000", UserID: 2})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Tagged", CategoryID: 2, UserID: 1, TagIDs: []int{work.ID}})).To(Succeed())
+				Expect(store.StoreTask(model.Task{Title: "Missing", CategoryID: 2, UserID: 1, TagIDs: []int{99}})).To(MatchError(model.ErrTagNotFound))
+				Expect(store.StoreTask(model.Task{Title: "Theirs", CategoryID: 2, UserID: 1, TagIDs: []int{theirs.ID}})).To(MatchError(model.ErrTagNotOwned))
+
+				task, err := store.GetTaskByID(5)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.TagIDs).To(Equal([]int{work.ID}))
+				task.TagIDs = []int{work.ID, theirs.ID}
+				Expect(store.UpdateTask(5, *task)).To(MatchError(model.ErrTagNotOwned))
+
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(4))
+			})
+
+			It("should list the tasks of a tag and follow retagging and deletes", func() {
+				work, err := store.CreateTag(model.Tag{Name: "Work", Color: "#ff0000", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				urgent, err := store.CreateTag(model.Tag{Name: "Urgent", Color: "#ff0000", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				for _, id := range []int{4, 3, 2} {
+					task, err := store.GetTaskByID(id)
+					Expect(err).ShouldNot(HaveOccurred())
+					task.TagIDs = []int{work.ID}
+					if id == 3 {
+						task.TagIDs = []int{work.ID, urgent.ID}
+					}
+					Expect(store.UpdateTask(id, *task)).To(Succeed())
+				}
+
+				ids := func(tagID int) []int {
+					page, err := store.GetTaskPage(1, model.TaskQuery{TagIDs: []int{tagID}, Sort: model.SortCreated})
+					Expect(err).ShouldNot(HaveOccurred())
+					var ids []int
+					for _, task := range page.Tasks {
+						ids = append(ids, task.ID)
+					}
+					return ids
+				}
+				Expect(ids(work.ID)).To(Equal([]int{2, 3, 4}))
+				Expect(ids(urgent.ID)).To(Equal([]int{3}))
+
+				task, err := store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				task.TagIDs = nil
+				Expect(store.UpdateTask(2, *task)).To(Succeed())
+				Expect(store.DeleteTask(4)).To(Succeed())
+				Expect(ids(work.ID)).To(Equal([]int{3}))
+
+				// Deleting a tag takes it off its tasks as a new version
+				task, err = store.GetTaskByID(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(store.DeleteTag(work.ID)).To(Succeed())
+				_, err = store.GetTagByID(work.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				Expect(ids(work.ID)).To(BeEmpty())
+
+				deleted, err := store.GetTaskByID(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deleted.TagIDs).To(Equal([]int{urgent.ID}))
+				Expect(deleted.Version).To(Equal(task.Version + 1))
+			})
+		})
+
+		Describe("Comments", func() {
+			BeforeEach(seed)
+
+			It("should add comments to a task and keep the bodies they replace", func() {
+				first, err := store.AddComment(model.Comment{TaskID: 4, AuthorID: 1, Body: "Started on **this**"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(first.ID).To(BeNumerically(">", 0))
+				Expect(first.CreatedAt).NotTo(BeZero())
+				Expect(first.UpdatedAt).To(Equal(first.CreatedAt))
+				second, err := store.AddComment(model.Comment{TaskID: 4, AuthorID: 1, Body: "Done"})
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.AddComment(model.Comment{TaskID: 99, AuthorID: 1, Body: "Lost"})
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				edited, err := store.UpdateComment(first.ID, "Started on this and that")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(edited.History).To(Equal([]model.CommentRevision{{Body: "Started on **this**", CreatedAt: first.CreatedAt}}))
+				Expect(edited.UpdatedAt).NotTo(BeTemporally("<", first.UpdatedAt))
+				_, err = store.UpdateComment(99, "Lost")
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				comments, err := store.GetComments(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(comments).To(Equal([]model.Comment{edited, second}))
+
+				Expect(store.DeleteComment(second.ID)).To(Succeed())
+				_, err = store.GetCommentByID(second.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				comment, err := store.GetCommentByID(first.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*comment).To(Equal(edited))
+			})
+
+			It("should drop the comments of a deleted task", func() {
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Step 1", CategoryID: 2, UserID: 1, ParentID: 4})).To(Succeed())
+				on4, err := store.AddComment(model.Comment{TaskID: 4, AuthorID: 1, Body: "Parent"})
+				Expect(err).ShouldNot(HaveOccurred())
+				on5, err := store.AddComment(model.Comment{TaskID: 5, AuthorID: 1, Body: "Subtask"})
+				Expect(err).ShouldNot(HaveOccurred())
+				on3, err := store.AddComment(model.Comment{TaskID: 3, AuthorID: 1, Body: "Other"})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(store.DeleteTask(4)).To(Succeed())
+				for _, id := range []int{on4.ID, on5.ID} {
+					_, err := store.GetCommentByID(id)
+					Expect(err).To(MatchError(model.ErrRecordNotFound))
+				}
+				comments, err := store.GetComments(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(comments).To(BeEmpty())
+				comments, err = store.GetComments(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(comments).To(Equal([]model.Comment{on3}))
+			})
+		})
+
+		Describe("Attachments", func() {
+			BeforeEach(seed)
+
+			attachment := func(taskID, userID int, size int64) model.Attachment {
+				return model.Attachment{TaskID: taskID, UserID: userID, Name: "shot.png", ContentType: "image/png", Size: size, Key: "blob"}
+			}
+
+			It("should add attachments within the user's quota", func() {
+				first, err := store.AddAttachment(attachment(4, 1, 600), 1000)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(first.ID).To(BeNumerically(">", 0))
+				Expect(first.CreatedAt).NotTo(BeZero())
+				_, err = store.AddAttachment(attachment(3, 1, 500), 1000)
+				Expect(err).To(MatchError(model.ErrQuotaExceeded))
+				second, err := store.AddAttachment(attachment(3, 1, 400), 1000)
+				Expect(err).ShouldNot(HaveOccurred())
+				// The quota is per user, zero means none
+				_, err = store.AddAttachment(attachment(1, 2, 900), 1000)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.AddAttachment(attachment(4, 1, 5000), 0)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.AddAttachment(attachment(99, 1, 1), 0)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				stored, err := store.GetAttachmentByID(first.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*stored).To(Equal(first))
+				attachments, err := store.GetAttachments(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(attachments).To(Equal([]model.Attachment{second}))
+				attachments, err = store.GetAttachmentsByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(attachments).To(HaveLen(3))
+				Expect(attachments[0]).To(Equal(first))
+
+				Expect(store.DeleteAttachment(first.ID)).To(Succeed())
+				_, err = store.GetAttachmentByID(first.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				Expect(store.DeleteAttachment(first.ID)).To(Succeed())
+			})
+
+			It("should drop the attachments of a deleted task", func() {
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Step 1", CategoryID: 2, UserID: 1, ParentID: 4})).To(Succeed())
+				_, err := store.AddAttachment(attachment(4, 1, 10), 0)
+				Expect(err).ShouldNot(HaveOccurred())
+				on5, err := store.AddAttachment(attachment(5, 1, 10), 0)
+				Expect(err).ShouldNot(HaveOccurred())
+				on3, err := store.AddAttachment(attachment(3, 1, 10), 0)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(store.DeleteTask(4)).To(Succeed())
+				_, err = store.GetAttachmentByID(on5.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				attachments, err := store.GetAttachmentsByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(attachments).To(Equal([]model.Attachment{on3}))
+			})
+		})
+
+		Describe("Time entries", func() {
+			BeforeEach(seed)
+
+			at := func(hour int) *time.Time {
+				t := time.Date(2026, 3, 1, hour, 0, 0, 0, time.UTC)
+				return &t
+			}
+
+			It("should keep one running timer per user", func() {
+				running, err := store.AddTimeEntry(model.TimeEntry{TaskID: 4, UserID: 1, Start: *at(9), Note: "Drafting"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(running.ID).To(BeNumerically(">", 0))
+				_, err = store.AddTimeEntry(model.TimeEntry{TaskID: 3, UserID: 1, Start: *at(10)})
+				Expect(err).To(MatchError(model.ErrTimerRunning))
+				// Other users and stopped entries are not affected
+				_, err = store.AddTimeEntry(model.TimeEntry{TaskID: 1, UserID: 2, Start: *at(9)})
+				Expect(err).ShouldNot(HaveOccurred())
+				manual, err := store.AddTimeEntry(model.TimeEntry{TaskID: 3, UserID: 1, Start: *at(7), End: at(8)})
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.AddTimeEntry(model.TimeEntry{TaskID: 99, UserID: 1, Start: *at(7), End: at(8)})
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				found, err := store.GetRunningTimeEntry(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*found).To(Equal(running))
+				manual.End = nil
+				Expect(store.UpdateTimeEntry(manual)).To(MatchError(model.ErrTimerRunning))
+
+				stopped, err := store.StopTimer(1, *at(11))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(stopped.ID).To(Equal(running.ID))
+				Expect(*stopped.End).To(Equal(*at(11)))
+				_, err = store.StopTimer(1, *at(12))
+				Expect(err).To(MatchError(model.ErrNoTimerRunning))
+				_, err = store.GetRunningTimeEntry(1)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				stored, err := store.GetTimeEntryByID(running.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*stored).To(Equal(stopped))
+				Expect(store.UpdateTimeEntry(model.TimeEntry{ID: 99, Start: *at(7), End: at(8)})).To(MatchError(model.ErrRecordNotFound))
+			})
+
+			It("should list the entries overlapping a range", func() {
+				early, err := store.AddTimeEntry(model.TimeEntry{TaskID: 4, UserID: 1, Start: *at(6), End: at(8)})
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.AddTimeEntry(model.TimeEntry{TaskID: 3, UserID: 1, Start: *at(2), End: at(4)})
+				Expect(err).ShouldNot(HaveOccurred())
+				running, err := store.AddTimeEntry(model.TimeEntry{TaskID: 3, UserID: 1, Start: *at(9)})
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.AddTimeEntry(model.TimeEntry{TaskID: 1, UserID: 2, Start: *at(7), End: at(8)})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				entries, err := store.GetTimeEntries(1, *at(7), *at(10))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(entries).To(Equal([]model.TimeEntry{early, running}))
+				entries, err = store.GetTimeEntries(1, *at(8), *at(9))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(entries).To(BeEmpty())
+
+				Expect(store.DeleteTask(4)).To(Succeed())
+				entries, err = store.GetTimeEntriesByTaskID(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(entries).To(BeEmpty())
+				entries, err = store.GetTimeEntriesByTaskID(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(entries).To(HaveLen(2))
+
+				Expect(store.DeleteTimeEntry(running.ID)).To(Succeed())
+				_, err = store.GetTimeEntryByID(running.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+			})
+		})
+
+		Describe("Search", func() {
+			BeforeEach(seed)
+
+			search := func(userID int, terms ...string) []model.SearchHit {
+				matches, err := store.SearchTasks(userID, terms)
+				Expect(err).ShouldNot(HaveOccurred())
+				return model.RankSearch(terms, matches)
+			}
+
+			It("should find the words of titles, comments and category names by prefix", func() {
+				task, err := store.GetTaskByID(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				task.Title = "Write the quarterly report"
+				Expect(store.UpdateTask(4, *task)).To(Succeed())
+				comment, err := store.AddComment(model.Comment{TaskID: 3, AuthorID: 1, Body: "Report draft attached"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Report of another user", CategoryID: 1, UserID: 2})).To(Succeed())
+
+				Expect(search(1, "report")).To(Equal([]model.SearchHit{
+					{TaskID: 4, Score: 3, Fields: model.SearchTitle},
+					{TaskID: 3, Score: 1, Fields: model.SearchComment},
+				}))
+				Expect(search(1, "rep", "quart")).To(Equal([]model.SearchHit{{TaskID: 4, Score: 3, Fields: model.SearchTitle}}))
+				Expect(search(1, "missing")).To(BeEmpty())
+
+				// Renaming a category and deleting a comment update the index
+				Expect(store.UpdateCategory(1, model.Category{ID: 1, Name: "Reporting"})).To(Succeed())
+				Expect(store.DeleteComment(comment.ID)).To(Succeed())
+				Expect(search(1, "report")).To(Equal([]model.SearchHit{
+					{TaskID: 4, Score: 3, Fields: model.SearchTitle},
+					{TaskID: 3, Score: 1, Fields: model.SearchCategory},
+				}))
+
+				Expect(store.DeleteTask(4)).To(Succeed())
+				Expect(search(1, "quarterly")).To(BeEmpty())
+				Expect(search(2, "report")).To(Equal([]model.SearchHit{
+					{TaskID: 5, Score: 3, Fields: model.SearchTitle | model.SearchCategory},
+					{TaskID: 1, Score: 1, Fields: model.SearchCategory},
+				}))
+			})
+		})
+
+		Describe("Bulk writes", func() {
+			BeforeEach(seed)
+
+			writes := func() []model.TaskWrite {
+				task2, err := store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				task2.Title = "Renamed"
+				return []model.TaskWrite{
+					{Op: model.TaskOpCreate, Task: model.Task{Title: "New", CategoryID: 1, UserID: 1}},
+					{Op: model.TaskOpUpdate, Task: *task2},
+					{Op: model.TaskOpMove, Task: model.Task{ID: 3, Title: "Task 3", CategoryID: 42, UserID: 1, Version: 1}},
+					{Op: model.TaskOpDelete, Task: model.Task{ID: 4, Version: 1}},
+				}
+			}
+
+			It("should roll back every write of an atomic batch one is refused in", func() {
+				_, err := store.AddComment(model.Comment{TaskID: 4, AuthorID: 1, Body: "kept"})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				batch := writes()
+				Expect(store.WriteTasks(batch, true)).To(MatchError(model.ErrBulkAborted))
+				Expect(batch[2].Err).To(MatchError(model.ErrCategoryNotFound))
+				Expect(batch[3].Err).ShouldNot(HaveOccurred())
+
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(3))
+				Expect(tasks[0].Title).To(Equal("Task 2"))
+				Expect(tasks[0].Version).To(Equal(1))
+				comments, err := store.GetComments(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(comments).To(HaveLen(1))
+			})
+
+			It("should make the other writes of a best-effort batch", func() {
+				batch := writes()
+				Expect(store.WriteTasks(batch, false)).To(Succeed())
+				Expect(batch[0].Err).ShouldNot(HaveOccurred())
+				Expect(batch[0].Task.ID).To(BeNumerically(">", 4))
+				Expect(batch[0].Task.Version).To(Equal(1))
+				Expect(batch[1].Task.Version).To(Equal(2))
+				Expect(batch[2].Err).To(MatchError(model.ErrCategoryNotFound))
+				Expect(batch[3].Task.Title).To(Equal("Task 4"))
+
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(3))
+				Expect(tasks[0].Title).To(Equal("Renamed"))
+				Expect(tasks[1].CategoryID).To(Equal(1))
+				Expect(tasks[2].Title).To(Equal("New"))
+				_, err = store.GetTaskByID(4)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+			})
+
+			It("should check versions and skip writes refused before", func() {
+				batch := []model.TaskWrite{
+					{Op: model.TaskOpUpdate, Task: model.Task{ID: 2, Title: "First", Status: "Completed", CategoryID: 2, UserID: 1, Version: 1}},
+					{Op: model.TaskOpUpdate, Task: model.Task{ID: 2, Title: "Stale", Status: "Completed", CategoryID: 2, UserID: 1, Version: 1}},
+					{Op: model.TaskOpDelete, Task: model.Task{ID: 3, Version: 5}},
+					{Op: model.TaskOpDelete, Task: model.Task{ID: 42}},
+					{Op: model.TaskOpDelete, Task: model.Task{ID: 4}, Err: model.ErrTaskNotOwned},
+				}
+				Expect(store.WriteTasks(batch, false)).To(Succeed())
+				Expect(batch[0].Err).ShouldNot(HaveOccurred())
+				Expect(batch[1].Err).To(MatchError(model.ErrVersionConflict))
+				Expect(batch[2].Err).To(MatchError(model.ErrVersionConflict))
+				Expect(batch[3].Err).To(MatchError(model.ErrRecordNotFound))
+				Expect(batch[4].Err).To(MatchError(model.ErrTaskNotOwned))
+
+				tasks, err := store.GetTasksByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(3))
+				Expect(tasks[0].Title).To(Equal("First"))
+			})
+		})
+
+		Describe("Trash", func() {
+			BeforeEach(seed)
+
+			It("should move a task with its subtasks and records to the trash and restore them", func() {
+				work, err := store.CreateTag(model.Tag{Name: "Work", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				task4, err := store.GetTaskByID(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				task4.TagIDs = []int{work.ID}
+				Expect(store.UpdateTask(4, *task4)).To(Succeed())
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Step 1", CategoryID: 2, UserID: 1, ParentID: 4})).To(Succeed())
+				Expect(store.AddDependency(model.Dependency{TaskID: 4, BlockedByID: 3, UserID: 1})).To(Succeed())
+				comment, err := store.AddComment(model.Comment{TaskID: 4, AuthorID: 1, Body: "Kept"})
+				Expect(err).ShouldNot(HaveOccurred())
+				attachment, err := store.AddAttachment(model.Attachment{TaskID: 5, UserID: 1, Name: "shot.png", ContentType: "image/png", Size: 10, Key: "1/blob"}, 0)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.AddTimeEntry(model.TimeEntry{TaskID: 4, UserID: 1, Start: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				entry, err := store.TrashTask(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(entry.ID).To(BeNumerically(">", 0))
+				Expect(entry.Kind()).To(Equal(model.TrashTask))
+				Expect(entry.Title()).To(Equal("Task 4"))
+				Expect(entry.Tasks).To(HaveLen(2))
+				Expect(entry.AttachmentKeys()).To(Equal([]string{"1/blob"}))
+				Expect(entry.Dependencies).To(Equal([]model.Dependency{{TaskID: 4, BlockedByID: 3, UserID: 1}}))
+				_, err = store.TrashTask(4)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				for _, id := range []int{4, 5} {
+					_, err = store.GetTaskByID(id)
+					Expect(err).To(MatchError(model.ErrRecordNotFound))
+				}
+				_, err = store.GetRunningTimeEntry(1)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				deps, err := store.GetDependencies(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deps).To(BeEmpty())
+
+				trash, err := store.GetTrash(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(trash).To(HaveLen(1))
+				Expect(trash[0].ID).To(Equal(entry.ID))
+				Expect(trash[0].AttachmentKeys()).To(Equal([]string{"1/blob"}))
+				Expect(store.GetTrash(2)).To(BeEmpty())
+				found, err := store.GetTrashEntry(entry.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(found.UserID).To(Equal(1))
+
+				restored, err := store.RestoreTrash(entry.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(restored.ID).To(Equal(entry.ID))
+				_, err = store.GetTrashEntry(entry.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				_, err = store.RestoreTrash(entry.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				task, err := store.GetTaskByID(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.Version).To(Equal(task4.Version + 2))
+				Expect(task.TagIDs).To(Equal([]int{work.ID}))
+				subtask, err := store.GetTaskByID(5)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(subtask.ParentID).To(Equal(4))
+				comments, err := store.GetComments(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(comments).To(Equal([]model.Comment{comment}))
+				back, err := store.GetAttachmentByID(attachment.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*back).To(Equal(attachment))
+				deps, err = store.GetDependencies(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deps).To(Equal([]model.Dependency{{TaskID: 4, BlockedByID: 3, UserID: 1}}))
+				// The timer was stopped when the task was deleted
+				entries, err := store.GetTimeEntriesByTaskID(4)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+				Expect(entries[0].End).NotTo(BeNil())
+			})
+
+			It("should trash a category with the tasks it cascades to", func() {
+				Expect(store.StoreCategory(model.Category{ID: 10, Name: "Mine", UserID: 1})).To(Succeed())
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Mine", CategoryID: 10, UserID: 1})).To(Succeed())
+
+				_, err := store.TrashCategory(10, model.CategoryDelete{})
+				Expect(err).To(MatchError(model.ErrCategoryNotEmpty))
+				Expect(store.GetTrash(1)).To(BeEmpty())
+				_, err = store.TrashCategory(42, model.CategoryDelete{})
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				entry, err := store.TrashCategory(10, model.CategoryDelete{Mode: model.CategoryDeleteCascade})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(entry.Kind()).To(Equal(model.TrashCategory))
+				Expect(entry.Title()).To(Equal("Mine"))
+				Expect(entry.Tasks).To(HaveLen(1))
+				_, err = store.GetCategoryByID(10)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				_, err = store.GetTaskByID(5)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				_, err = store.RestoreTrash(entry.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				category, err := store.GetCategoryByID(10)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(category.Name).To(Equal("Mine"))
+				task, err := store.GetTaskByID(5)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.CategoryID).To(Equal(10))
+			})
+
+			It("should leave the entry in the trash when its category is gone", func() {
+				Expect(store.StoreCategory(model.Category{ID: 10, Name: "Mine", UserID: 1})).To(Succeed())
+				Expect(store.StoreTask(model.Task{ID: 5, Title: "Mine", CategoryID: 10, UserID: 1})).To(Succeed())
+				tag, err := store.CreateTag(model.Tag{Name: "Gone", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(store.UpdateTask(3, model.Task{ID: 3, Title: "Task 3", Status: "Completed", CategoryID: 1, UserID: 1, TagIDs: []int{tag.ID}})).To(Succeed())
+
+				mine, err := store.TrashTask(5)
+				Expect(err).ShouldNot(HaveOccurred())
+				task3, err := store.TrashTask(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(store.DeleteCategory(10, model.CategoryDelete{})).To(Succeed())
+				Expect(store.DeleteTag(tag.ID)).To(Succeed())
+
+				_, err = store.RestoreTrash(mine.ID)
+				Expect(err).To(MatchError(model.ErrCategoryNotFound))
+				_, err = store.GetTrashEntry(mine.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = store.GetTaskByID(5)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				// A deleted tag is left out
+				_, err = store.RestoreTrash(task3.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				task, err := store.GetTaskByID(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.TagIDs).To(BeEmpty())
+			})
+
+			It("should move the tasks deleted by a bulk write to the trash", func() {
+				batch := []model.TaskWrite{{Op: model.TaskOpDelete, Task: model.Task{ID: 4}}}
+				Expect(store.WriteTasks(batch, true)).To(Succeed())
+				Expect(batch[0].TrashID).To(BeNumerically(">", 0))
+
+				entry, err := store.GetTrashEntry(batch[0].TrashID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(entry.Title()).To(Equal("Task 4"))
+			})
+
+			It("should purge entries for good", func() {
+				first, err := store.TrashTask(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				second, err := store.TrashTask(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				third, err := store.TrashTask(1)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(store.PurgeTrash(first.ID)).To(Succeed())
+				_, err = store.GetTrashEntry(first.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				_, err = store.RestoreTrash(first.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				Expect(store.PurgeTrash(first.ID)).To(Succeed())
+
+				Expect(store.PurgeTrashBefore(second.DeletedAt)).To(BeEmpty())
+				purged, err := store.PurgeTrashBefore(time.Now().Add(time.Minute))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(purged).To(HaveLen(2))
+				Expect([]int{purged[0].ID, purged[1].ID}).To(ConsistOf(second.ID, third.ID))
+				Expect(store.GetTrash(1)).To(BeEmpty())
+				Expect(store.GetTrash(2)).To(BeEmpty())
+			})
+		})
+
+		Describe("Task pages", func() {
+			BeforeEach(func() {
+				seed()
+				Expect(store.StoreTask(model.Task{ID: 6, Title: "Write the report", Priority: 4, Status: "In Progress", CategoryID: 1, UserID: 1})).To(Succeed())
+			})
+
+			ids := func(query model.TaskQuery) ([]int, model.TaskPage) {
+				Expect(query.Validate()).To(Succeed())
+				page, err := store.GetTaskPage(1, query)
+				Expect(err).ShouldNot(HaveOccurred())
+				ids := []int{}
+				for _, task := range page.Tasks {
+					Expect(task.UserID).To(Equal(1))
+					ids = append(ids, task.ID)
+				}
+				return ids, page
+			}
+
+			It("should walk the tasks of a user page by page in every order", func() {
+				got, page := ids(model.TaskQuery{Sort: model.SortPriority, Desc: true, Limit: 2})
+				Expect(got).To(Equal([]int{4, 6}))
+				Expect(page.Total).To(Equal(4))
+				Expect(page.Next).To(Equal(&model.TaskCursor{Sort: model.SortPriority, Desc: true, Key: 4, ID: 6}))
+
+				got, page = ids(model.TaskQuery{Sort: model.SortPriority, Desc: true, Limit: 2, After: page.Next})
+				Expect(got).To(Equal([]int{3, 2}))
+				Expect(page.Total).To(Equal(4))
+				Expect(page.Next).To(BeNil())
+
+				// Tasks without a deadline come last going up and first going down
+				got, _ = ids(model.TaskQuery{Sort: model.SortDeadline})
+				Expect(got).To(Equal([]int{2, 3, 4, 6}))
+				got, page = ids(model.TaskQuery{Sort: model.SortDeadline, Desc: true, Limit: 1})
+				Expect(got).To(Equal([]int{6}))
+				got, _ = ids(model.TaskQuery{Sort: model.SortDeadline, Desc: true, Limit: 2, After: page.Next})
+				Expect(got).To(Equal([]int{4, 3}))
+
+				got, page = ids(model.TaskQuery{Limit: 3})
+				Expect(got).To(Equal([]int{2, 3, 4}))
+				got, _ = ids(model.TaskQuery{Limit: 3, After: page.Next})
+				Expect(got).To(Equal([]int{6}))
+
+				// A task written between pages moves in the order
+				task, err := store.GetTaskByID(2)
+				Expect(err).ShouldNot(HaveOccurred())
+				task.Priority = 5
+				Expect(store.UpdateTask(2, *task)).To(Succeed())
+				got, _ = ids(model.TaskQuery{Sort: model.SortPriority, Desc: true})
+				Expect(got).To(Equal([]int{4, 2, 6, 3}))
+
+				// The cursor of a deleted task still starts past where it was
+				got, page = ids(model.TaskQuery{Sort: model.SortPriority, Desc: true, Limit: 2})
+				Expect(got).To(Equal([]int{4, 2}))
+				Expect(store.DeleteTask(2)).To(Succeed())
+				got, page = ids(model.TaskQuery{Sort: model.SortPriority, Desc: true, Limit: 2, After: page.Next})
+				Expect(got).To(Equal([]int{6, 3}))
+				Expect(page.Total).To(Equal(3))
+				got, _ = ids(model.TaskQuery{Sort: model.SortPriority, Limit: 2, After: &model.TaskCursor{Sort: model.SortPriority, Key: 5, ID: 2}})
+				Expect(got).To(Equal([]int{4}))
+			})
+
+			It("should filter before counting", func() {
+				tag, err := store.CreateTag(model.Tag{Name: "Work", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				task, err := store.GetTaskByID(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				task.TagIDs = []int{tag.ID}
+				Expect(store.UpdateTask(3, *task)).To(Succeed())
+
+				for _, c := range []struct {
+					query model.TaskQuery
+					want  []int
+				}{
+					{model.TaskQuery{Statuses: []string{"Completed"}}, []int{2, 3}},
+					{model.TaskQuery{MinPriority: 2, MaxPriority: 4}, []int{3, 6}},
+					{model.TaskQuery{CategoryID: 2}, []int{2, 4}},
+					{model.TaskQuery{DeadlineFrom: model.DateDeadline(2023, 6, 2), DeadlineTo: model.DateDeadline(2023, 6, 7)}, []int{3, 4}},
+					{model.TaskQuery{DeadlineTo: model.Deadline{At: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)}}, []int{2}},
+					{model.TaskQuery{Terms: []string{"rep", "wri"}}, []int{6}},
+					{model.TaskQuery{TagIDs: []int{tag.ID}}, []int{3}},
+					{model.TaskQuery{TagIDs: []int{0, tag.ID}, TagMatch: model.TagMatchAll}, []int{}},
+					{model.TaskQuery{Statuses: []string{"In Progress"}, Sort: model.SortDeadline, Limit: 1}, []int{4}},
+				} {
+					got, page := ids(c.query)
+					Expect(got).To(Equal(c.want))
+					if c.query.Limit == 0 {
+						Expect(page.Total).To(Equal(len(c.want)))
+					}
+				}
+
+				_, page := ids(model.TaskQuery{Statuses: []string{"In Progress"}, Sort: model.SortDeadline, Limit: 1})
+				Expect(page.Total).To(Equal(2))
+				Expect(page.Next).NotTo(BeNil())
+			})
+		})
+
+		Describe("Saved views", func() {
+			It("should keep views per user with unique names", func() {
+				urgent, err := store.CreateView(model.SavedView{Name: "Urgent", Query: "priority_min=4", Pinned: true, UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				week, err := store.CreateView(model.SavedView{Name: "This week", Query: "deadline_to=today%2B7", UserID: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(week.ID).NotTo(Equal(urgent.ID))
+				_, err = store.CreateView(model.SavedView{Name: " urgent", Query: "sort=priority", UserID: 1})
+				Expect(err).To(MatchError(model.ErrViewExists))
+				theirs, err := store.CreateView(model.SavedView{Name: "Urgent", Query: "sort=priority", UserID: 2})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				views, err := store.GetViewsByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(views).To(Equal([]model.SavedView{urgent, week}))
+
+				// An update keeps the user of the view
+				Expect(store.UpdateView(model.SavedView{ID: week.ID, Name: "URGENT", Query: "sort=deadline", UserID: 1})).To(MatchError(model.ErrViewExists))
+				Expect(store.UpdateView(model.SavedView{ID: theirs.ID, Name: "Mine", Query: "sort=deadline", Pinned: true, UserID: 1})).To(Succeed())
+				view, err := store.GetViewByID(theirs.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*view).To(Equal(model.SavedView{ID: theirs.ID, Name: "Mine", Query: "sort=deadline", Pinned: true, UserID: 2}))
+				Expect(store.UpdateView(model.SavedView{ID: 999, Name: "Missing"})).To(MatchError(model.ErrRecordNotFound))
+
+				Expect(store.DeleteView(urgent.ID)).To(Succeed())
+				_, err = store.GetViewByID(urgent.ID)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+				views, err = store.GetViewsByUserID(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(views).To(Equal([]model.SavedView{week}))
+			})
+		})
+
+		Describe("Workflows", func() {
+			It("should save a workflow per user and replace it", func() {
+				_, err := store.GetWorkflow(1)
+				Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+				workflow := model.DefaultWorkflow(1)
+				workflow.Statuses = append(workflow.Statuses, model.WorkflowStatus{Name: "Review", Category: model.StatusInProgress})
+				workflow.Transitions["In Progress"] = []string{"Review"}
+				workflow.Transitions["Review"] = []string{"Completed"}
+				Expect(store.SaveWorkflow(workflow)).To(Succeed())
+				Expect(store.SaveWorkflow(model.DefaultWorkflow(2))).To(Succeed())
+
+				stored, err := store.GetWorkflow(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*stored).To(Equal(workflow))
+
+				Expect(store.SaveWorkflow(model.DefaultWorkflow(1))).To(Succeed())
+				stored, err = store.GetWorkflow(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*stored).To(Equal(model.DefaultWorkflow(1)))
+			})
+
+			It("should keep the completion time of a task", func() {
+				seed()
+				completedAt := time.Date(2023, 6, 1, 9, 0, 0, 0, time.UTC)
+				task, err := store.GetTaskByID(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.CompletedAt).To(BeNil())
+
+				task.CompletedAt = &completedAt
+				Expect(store.UpdateTask(task.ID, *task)).To(Succeed())
+				task, err = store.GetTaskByID(3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(task.CompletedAt).NotTo(BeNil())
+				Expect(task.CompletedAt.Equal(completedAt)).To(BeTrue())
+			})
+		})
+
+		Describe("Sessions", func() {
+			newSession := func(token, refresh, family, email string) model.Session {
+				now := time.Now().UTC().Truncate(time.Second)
+				return model.Session{
+					Token:        token,
+					RefreshToken: refresh,
+					FamilyID:     family,
+					Email:        email,
+					Expiry:       now.Add(time.Hour),
+					CreatedAt:    now,
+					LastSeen:     now,
+				}
+			}
+
+			It("should give every new session its own ID and find it by token", func() {
+				Expect(store.AddSession(newSession("a", "ra", "fa", "test@mail.com"))).To(Succeed())
+				Expect(store.AddSession(newSession("b", "rb", "fb", "test@mail.com"))).To(Succeed())
+
+				a, err := store.SessionAvailToken("a")
+				Expect(err).ShouldNot(HaveOccurred())
+				b, err := store.SessionAvailToken("b")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(a.ID).To(BeNumerically(">", 0))
+				Expect(b.ID).NotTo(Equal(a.ID))
+				Expect(a.Expiry.Equal(newSession("a", "", "", "").Expiry)).To(BeTrue())
+
+				_, err = store.SessionAvailToken("missing")
+				Expect(err).To(MatchError("no session available for token: missing"))
+
+				session, err := store.SessionAvailEmail("test@mail.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(session.Token).To(Equal("a"))
+
+				_, err = store.SessionAvailEmail("nobody@mail.com")
+				Expect(err).To(MatchError("no session available for email: nobody@mail.com"))
+			})
+
+			It("should rotate a session only once and keep the rotated one findable by refresh token", func() {
+				Expect(store.AddSession(newSession("a", "ra", "fa", "test@mail.com"))).To(Succeed())
+				old, err := store.SessionByRefreshToken("ra")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				next := old
+				next.Token, next.RefreshToken = "a2", "ra2"
+				Expect(store.RotateSession(old, next)).To(Succeed())
+				Expect(store.RotateSession(old, next)).To(MatchError("session already rotated"))
+				Expect(store.RotateSession(newSession("x", "rx", "fx", "test@mail.com"), next)).To(MatchError("session not found"))
+
+				rotated, err := store.SessionByRefreshToken("ra")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(rotated.Rotated).To(BeTrue())
+
+				_, err = store.SessionByRefreshToken("unknown")
+				Expect(err).To(MatchError("no session available for refresh token"))
+
+				sessions, err := store.GetSessionsByEmail("test@mail.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(sessions).To(HaveLen(1))
+				Expect(sessions[0].Token).To(Equal("a2"))
+				Expect(sessions[0].ID).To(Equal(old.ID))
+
+				session, err := store.SessionAvailEmail("test@mail.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(session.Token).To(Equal("a2"))
+			})
+
+			It("should touch a session and fail for an unknown token", func() {
+				Expect(store.AddSession(newSession("a", "ra", "fa", "test@mail.com"))).To(Succeed())
+
+				at := time.Now().UTC().Add(time.Minute).Truncate(time.Second)
+				Expect(store.TouchSession("a", at)).To(Succeed())
+				session, err := store.SessionAvailToken("a")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(session.LastSeen.Equal(at)).To(BeTrue())
+
+				Expect(store.TouchSession("missing", at)).To(MatchError("session not found"))
+			})
+
+			It("should delete sessions by token, ID, family and email", func() {
+				Expect(store.AddSession(newSession("a", "ra", "fa", "test@mail.com"))).To(Succeed())
+				Expect(store.AddSession(newSession("b", "rb", "fb", "test@mail.com"))).To(Succeed())
+				Expect(store.AddSession(newSession("c", "rc", "fc", "other@mail.com"))).To(Succeed())
+				Expect(store.AddSession(newSession("d", "rd", "fd", "other@mail.com"))).To(Succeed())
+
+				// Rotating keeps the ID, deleting by ID removes both records
+				a, err := store.SessionAvailToken("a")
+				Expect(err).ShouldNot(HaveOccurred())
+				next := a
+				next.Token, next.RefreshToken = "a2", "ra2"
+				Expect(store.RotateSession(a, next)).To(Succeed())
+
+				Expect(store.DeleteSessionsByID(a.ID)).To(Succeed())
+				_, err = store.SessionAvailToken("a")
+				Expect(err).To(HaveOccurred())
+				_, err = store.SessionAvailToken("a2")
+				Expect(err).To(HaveOccurred())
+
+				Expect(store.DeleteSession("b")).To(Succeed())
+				_, err = store.SessionAvailToken("b")
+				Expect(err).To(HaveOccurred())
+
+				Expect(store.DeleteSessionFamily("fc")).To(Succeed())
+				_, err = store.SessionAvailToken("c")
+				Expect(err).To(HaveOccurred())
+
+				Expect(store.DeleteSessionsByEmail("other@mail.com")).To(Succeed())
+				sessions, err := store.GetSessionsByEmail("other@mail.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(sessions).To(BeEmpty())
+			})
+		})
+	})
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"encoding/json"
+
+	"a21hc3NpZ25tZW50/model"
+)
+
+// trashRecord is how a trash entry is stored, with the blob keys of its
+// attachments by ID that the JSON of model.Attachment leaves out.
+type trashRecord struct {
+	Entry model.TrashEntry `json:"entry"`
+	Keys  map[int]string   `json:"keys"`
+}
+
+// MarshalTrash encodes a trash entry for a store that keeps it as JSON.
+func MarshalTrash(entry model.TrashEntry) ([]byte, error) {
+	record := trashRecord{Entry: entry, Keys: map[int]string{}}
+	for _, t := range entry.Tasks {
+		for _, a := range t.Attachments {
+			record.Keys[a.ID] = a.Key
+		}
+	}
+	return json.Marshal(record)
+}
+
+// UnmarshalTrash decodes an entry written by MarshalTrash.
+func UnmarshalTrash(data []byte) (model.TrashEntry, error) {
+	var record trashRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return model.TrashEntry{}, err
+	}
+	entry := record.Entry
+	for i := range entry.Tasks {
+		for j := range entry.Tasks[i].Attachments {
+			a := &entry.Tasks[i].Attachments[j]
+			a.Key = record.Keys[a.ID]
+		}
+	}
+	return entry, nil
+}
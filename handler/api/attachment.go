@@ -0,0 +1,172 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/db/blob"
+	"a21hc3NpZ25tZW50/model"
+	"a21hc3NpZ25tZW50/service"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is what a multipart body may take on top of the file,
+// for boundaries and part headers.
+const multipartOverhead = 64 << 10
+
+type AttachmentAPI interface {
+	AddAttachment(c *gin.Context)
+	GetAttachments(c *gin.Context)
+	GetAttachment(c *gin.Context)
+	DeleteAttachment(c *gin.Context)
+}
+
+type attachmentAPI struct {
+	attachmentService service.AttachmentService
+	taskService       service.TaskService
+}
+
+func NewAttachmentAPI(attachmentService service.AttachmentService, taskService service.TaskService) *attachmentAPI {
+	return &attachmentAPI{attachmentService, taskService}
+}
+
+// AddAttachment takes the file from the "file" field of a multipart form.
+func (at *attachmentAPI) AddAttachment(c *gin.Context) {
+	task, userID, ok := ownTask(c, at.taskService)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, at.attachmentService.MaxSize()+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			attachmentWriteError(c, model.ErrAttachmentTooLarge)
+			return
+		}
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "file is required"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer file.Close()
+
+	attachment := model.Attachment{TaskID: task.ID, UserID: userID, Name: header.Filename, Size: header.Size}
+	if err := at.attachmentService.Store(&attachment, file); err != nil {
+		attachmentWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, attachment)
+}
+
+func (at *attachmentAPI) GetAttachments(c *gin.Context) {
+	task, _, ok := ownTask(c, at.taskService)
+	if !ok {
+		return
+	}
+
+	attachments, err := at.attachmentService.GetList(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if attachments == nil {
+		attachments = []model.Attachment{}
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// GetAttachment downloads the content of an attachment. It is always served
+// as a download of its sniffed type, never rendered inline.
+func (at *attachmentAPI) GetAttachment(c *gin.Context) {
+	attachment, ok := at.ownAttachment(c)
+	if !ok {
+		return
+	}
+
+	content, err := at.attachmentService.Open(*attachment)
+	if err == blob.ErrNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Attachment content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func (at *attachmentAPI) DeleteAttachment(c *gin.Context) {
+	attachment, ok := at.ownAttachment(c)
+	if !ok {
+		return
+	}
+
+	if err := at.attachmentService.Delete(*attachment); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "attachment delete success"})
+}
+
+// ownAttachment loads the :attachment_id attachment of a task of the user.
+func (at *attachmentAPI) ownAttachment(c *gin.Context) (*model.Attachment, bool) {
+	task, _, ok := ownTask(c, at.taskService)
+	if !ok {
+		return nil, false
+	}
+
+	attachmentID, err := strconv.Atoi(c.Param("attachment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid attachment ID"})
+		return nil, false
+	}
+
+	attachment, err := at.attachmentService.GetByID(task.ID, attachmentID)
+	if err == model.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Attachment not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+	return attachment, true
+}
+
+// attachmentWriteError answers a failed upload.
+func attachmentWriteError(c *gin.Context, err error) {
+	switch {
+	case err == model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Task not found"})
+	case err == model.ErrAttachmentTooLarge, err == model.ErrQuotaExceeded:
+		c.JSON(http.StatusRequestEntityTooLarge, model.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, model.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, model.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+	}
+}
+
+// sweepAttachments removes the blobs left behind by deleting tasks of the
+// user. The delete already succeeded, so a failure is only logged.
+func sweepAttachments(attachmentService service.AttachmentService, userID int) {
+	if _, err := attachmentService.Sweep(userID); err != nil {
+		fmt.Printf("Warning: sweeping attachments of user %d: %v\n", userID, err)
+	}
+}
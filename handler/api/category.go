@@ -5,7 +5,6 @@ import (
 	"a21hc3NpZ25tZW50/service"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +12,7 @@ import (
 type CategoryAPI interface {
 	AddCategory(c *gin.Context)
 	UpdateCategory(c *gin.Context)
+	PatchCategory(c *gin.Context)
 	DeleteCategory(c *gin.Context)
 	GetCategoryByID(c *gin.Context)
 	GetCategoryList(c *gin.Context)
@@ -49,23 +49,12 @@ func (ct *categoryAPI) AddCategory(c *gin.Context) {
 	// Set the user ID for the new category
 	newCategory.UserID = userIDInt
 
-	// Check if user already has a category with this name (including default categories)
-	existingCategories, err := ct.categoryService.GetListByUser(userIDInt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+	// The store refuses a name the user already has (case-insensitive)
+	err := ct.categoryService.Store(&newCategory)
+	if err == model.ErrCategoryExists {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Category with this name already exists"})
 		return
 	}
-
-	// Check for duplicate names (case-insensitive)
-	newCategoryNameLower := strings.ToLower(strings.TrimSpace(newCategory.Name))
-	for _, category := range existingCategories {
-		if strings.ToLower(strings.TrimSpace(category.Name)) == newCategoryNameLower {
-			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Category with this name already exists"})
-			return
-		}
-	}
-
-	err = ct.categoryService.Store(&newCategory)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
 		return
@@ -113,17 +102,98 @@ func (ct *categoryAPI) UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	// If-Match takes precedence over a version sent in the body
+	version, ok := ifMatchVersion(c, existingCategory.Version)
+	if !ok {
+		c.JSON(http.StatusPreconditionFailed, model.ErrorResponse{Error: model.ErrVersionConflict.Error()})
+		return
+	}
+	if version != 0 {
+		updatedCategory.Version = version
+	}
+
 	updatedCategory.ID = categoryID
 	updatedCategory.UserID = userIDInt // Ensure user ID remains the same
 	err = ct.categoryService.Update(categoryID, updatedCategory)
-	if err != nil {
+	switch err {
+	case nil:
+	case model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Category not found"})
+		return
+	case model.ErrVersionConflict:
+		c.JSON(http.StatusPreconditionFailed, model.ErrorResponse{Error: err.Error()})
+		return
+	case model.ErrCategoryExists:
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Category with this name already exists"})
+		return
+	default:
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	if category, err := ct.categoryService.GetByID(categoryID); err == nil {
+		c.Header("ETag", etag(category.Version))
+	}
 	c.JSON(http.StatusOK, model.SuccessResponse{Message: "category update success"})
 }
 
+// PatchCategory renames a category with a JSON merge patch or JSON Patch and
+// returns it. An If-Match header is checked like for UpdateCategory.
+func (ct *categoryAPI) PatchCategory(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid Category ID"})
+		return
+	}
+
+	userID, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	existingCategory, err := ct.categoryService.GetByID(categoryID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Category not found"})
+		return
+	}
+
+	if existingCategory.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: category belongs to different user or is a system category"})
+		return
+	}
+
+	patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(c, existingCategory.Version)
+	if !ok {
+		c.JSON(http.StatusPreconditionFailed, model.ErrorResponse{Error: model.ErrVersionConflict.Error()})
+		return
+	}
+
+	category, err := ct.categoryService.Patch(categoryID, patch, version)
+	switch {
+	case err == nil:
+	case patchError(c, err):
+		return
+	case err == model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Category not found"})
+		return
+	case err == model.ErrVersionConflict:
+		c.JSON(http.StatusPreconditionFailed, model.ErrorResponse{Error: err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Header("ETag", etag(category.Version))
+	c.JSON(http.StatusOK, category)
+}
+
 func (ct *categoryAPI) DeleteCategory(c *gin.Context) {
 	categoryID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -157,13 +227,33 @@ func (ct *categoryAPI) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	err = ct.categoryService.Delete(categoryID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+	// ?mode=reject (default), ?mode=cascade or ?mode=reassign&reassign_to=<category ID>
+	opts := model.CategoryDelete{Mode: model.CategoryDeleteMode(c.Query("mode"))}
+	if opts.Mode == model.CategoryDeleteReassign {
+		opts.ReassignTo, err = strconv.Atoi(c.Query("reassign_to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid reassign_to category ID"})
+			return
+		}
+	}
+	if err := opts.Validate(categoryID); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, model.SuccessResponse{Message: "category delete success"})
+	entry, err := ct.categoryService.Trash(categoryID, opts)
+	if err != nil {
+		switch err {
+		case model.ErrCategoryNotEmpty:
+			c.JSON(http.StatusConflict, model.ErrorResponse{Error: err.Error()})
+		case model.ErrCategoryNotFound, model.ErrCategoryNotOwned:
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "reassign_to: " + err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, model.TrashedResponse{Message: "category delete success", TrashID: entry.ID})
 }
 
 func (ct *categoryAPI) GetCategoryByID(c *gin.Context) {
@@ -196,6 +286,7 @@ func (ct *categoryAPI) GetCategoryByID(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", etag(category.Version))
 	c.JSON(http.StatusOK, category)
 }
 
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"a21hc3NpZ25tZW50/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommentAPI interface {
+	AddComment(c *gin.Context)
+	GetComments(c *gin.Context)
+	UpdateComment(c *gin.Context)
+	DeleteComment(c *gin.Context)
+}
+
+type commentAPI struct {
+	commentService service.CommentService
+	taskService    service.TaskService
+}
+
+func NewCommentAPI(commentService service.CommentService, taskService service.TaskService) *commentAPI {
+	return &commentAPI{commentService, taskService}
+}
+
+func (ct *commentAPI) AddComment(c *gin.Context) {
+	task, userID, ok := ownTask(c, ct.taskService)
+	if !ok {
+		return
+	}
+
+	var request model.CommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	comment := model.Comment{TaskID: task.ID, AuthorID: userID, Body: request.Body}
+	if err := ct.commentService.Store(&comment); err != nil {
+		commentWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, commentResponse(comment))
+}
+
+// GetComments returns the thread of a task, oldest comment first.
+func (ct *commentAPI) GetComments(c *gin.Context) {
+	task, _, ok := ownTask(c, ct.taskService)
+	if !ok {
+		return
+	}
+
+	comments, err := ct.commentService.GetList(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response := make([]model.Comment, 0, len(comments))
+	for _, comment := range comments {
+		response = append(response, commentResponse(comment))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateComment replaces the body of a comment, keeping the old body in its
+// history. Only the author may edit a comment.
+func (ct *commentAPI) UpdateComment(c *gin.Context) {
+	comment, ok := ct.ownComment(c)
+	if !ok {
+		return
+	}
+
+	var request model.CommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	updated, err := ct.commentService.Update(comment.ID, request.Body)
+	if err != nil {
+		commentWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, commentResponse(updated))
+}
+
+func (ct *commentAPI) DeleteComment(c *gin.Context) {
+	comment, ok := ct.ownComment(c)
+	if !ok {
+		return
+	}
+
+	if err := ct.commentService.Delete(comment.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "comment delete success"})
+}
+
+// ownTask loads the task of the :id parameter and checks it belongs to the
+// user like UpdateTask does, answering the request otherwise.
+func ownTask(c *gin.Context, taskService service.TaskService) (*model.Task, int, bool) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid task ID"})
+		return nil, 0, false
+	}
+
+	userID, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return nil, 0, false
+	}
+
+	userIDInt, ok := userID.(int)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Invalid user ID format"})
+		return nil, 0, false
+	}
+
+	existingTask, err := taskService.GetByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Task not found"})
+		return nil, 0, false
+	}
+
+	if existingTask.UserID != userIDInt {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: task belongs to different user"})
+		return nil, 0, false
+	}
+	return existingTask, userIDInt, true
+}
+
+// ownComment loads the :comment_id comment of a task of the user, written by
+// the user.
+func (ct *commentAPI) ownComment(c *gin.Context) (*model.Comment, bool) {
+	task, userID, ok := ownTask(c, ct.taskService)
+	if !ok {
+		return nil, false
+	}
+
+	commentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid comment ID"})
+		return nil, false
+	}
+
+	comment, err := ct.commentService.GetByID(task.ID, commentID)
+	if err == model.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Comment not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+
+	if comment.AuthorID != userID {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: comment belongs to different user"})
+		return nil, false
+	}
+	return comment, true
+}
+
+// commentResponse lists an empty history as [] rather than null.
+func commentResponse(comment model.Comment) model.Comment {
+	if comment.History == nil {
+		comment.History = []model.CommentRevision{}
+	}
+	return comment
+}
+
+// commentWriteError answers a failed comment write.
+func commentWriteError(c *gin.Context, err error) {
+	switch {
+	case err == model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Comment not found"})
+	case errors.Is(err, model.ErrInvalidComment):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+	}
+}
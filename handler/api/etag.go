@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag is the strong entity tag of a record at version.
+func etag(version int) string {
+	return fmt.Sprintf("%q", strconv.Itoa(version))
+}
+
+// ifMatchVersion checks the If-Match header of an update against current, the
+// version the handler just read. It returns the version to hand to the store,
+// which checks it again inside its write, or 0 when the header is missing or
+// "*" and any version may be replaced. ok is false when no tag in the header
+// matches current, weak tags never match.
+func ifMatchVersion(c *gin.Context, current int) (version int, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == etag(current) {
+			return current, true
+		}
+	}
+	return 0, false
+}
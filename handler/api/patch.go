@@ -0,0 +1,39 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readPatch reads the body of a PATCH request, answering for a media type
+// that is not a patch.
+func readPatch(c *gin.Context) (model.Patch, bool) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return model.Patch{}, false
+	}
+	patch, err := model.NewPatch(c.GetHeader("Content-Type"), body)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, model.ErrorResponse{Error: err.Error()})
+		return model.Patch{}, false
+	}
+	return patch, true
+}
+
+// patchError answers err if it comes from applying a patch and reports
+// whether it did.
+func patchError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, model.ErrPatchTestFailed):
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, model.ErrInvalidPatch):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+	default:
+		return false
+	}
+	return true
+}
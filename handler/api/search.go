@@ -0,0 +1,70 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"a21hc3NpZ25tZW50/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchAPI interface {
+	Search(c *gin.Context)
+}
+
+type searchAPI struct {
+	searchService service.SearchService
+	userService   service.UserService
+}
+
+func NewSearchAPI(searchService service.SearchService, userService service.UserService) *searchAPI {
+	return &searchAPI{searchService, userService}
+}
+
+// Search finds the user's tasks whose title, category or comments hold every
+// word of q, words matching by prefix. The best 20 come first, limit asks
+// for up to model.MaxSearchResults.
+func (s *searchAPI) Search(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > model.MaxSearchResults {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "limit must be between 1 and " + strconv.Itoa(model.MaxSearchResults)})
+			return
+		}
+	}
+
+	hits, err := s.searchService.Search(userID.(int), c.Query("q"), limit)
+	if err == model.ErrInvalidSearch {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	loc := time.UTC
+	if user, err := s.userService.GetUserByEmail(c.GetString("email")); err == nil {
+		loc = user.Location()
+	}
+	now := time.Now()
+	response := make([]model.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		response = append(response, model.SearchResult{
+			Task:    model.NewTaskResponse(hit.Task, now, loc),
+			Score:   hit.Score,
+			Matched: hit.Fields.Names(),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"a21hc3NpZ25tZW50/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagAPI interface {
+	AddTag(c *gin.Context)
+	UpdateTag(c *gin.Context)
+	DeleteTag(c *gin.Context)
+	GetTagByID(c *gin.Context)
+	GetTagList(c *gin.Context)
+}
+
+type tagAPI struct {
+	tagService service.TagService
+}
+
+func NewTagAPI(tagService service.TagService) *tagAPI {
+	return &tagAPI{tagService}
+}
+
+func (t *tagAPI) AddTag(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var tag model.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	tag.ID = 0
+	tag.UserID = userID.(int)
+
+	if err := t.tagService.Store(&tag); err != nil {
+		tagWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+func (t *tagAPI) UpdateTag(c *gin.Context) {
+	existing, ok := t.ownTag(c)
+	if !ok {
+		return
+	}
+
+	var tag model.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	tag.ID = existing.ID
+	tag.UserID = existing.UserID
+
+	if err := t.tagService.Update(tag); err != nil {
+		tagWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "tag update success"})
+}
+
+// DeleteTag removes a tag from all its tasks and deletes it.
+func (t *tagAPI) DeleteTag(c *gin.Context) {
+	tag, ok := t.ownTag(c)
+	if !ok {
+		return
+	}
+
+	if err := t.tagService.Delete(tag.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "tag delete success"})
+}
+
+func (t *tagAPI) GetTagByID(c *gin.Context) {
+	tag, ok := t.ownTag(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+func (t *tagAPI) GetTagList(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	tags, err := t.tagService.GetList(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if tags == nil {
+		tags = []model.Tag{}
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// ownTag loads the tag of the :id parameter, answering the request unless it
+// belongs to the user.
+func (t *tagAPI) ownTag(c *gin.Context) (*model.Tag, bool) {
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid tag ID"})
+		return nil, false
+	}
+
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return nil, false
+	}
+
+	tag, err := t.tagService.GetByID(tagID)
+	if err == model.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Tag not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+
+	if tag.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: tag belongs to different user"})
+		return nil, false
+	}
+	return tag, true
+}
+
+// tagWriteError answers a failed tag write.
+func tagWriteError(c *gin.Context, err error) {
+	switch {
+	case err == model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Tag not found"})
+	case err == model.ErrTagExists:
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, model.ErrInvalidTag):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+	}
+}
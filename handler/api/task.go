@@ -3,8 +3,10 @@ package api
 import (
 	"a21hc3NpZ25tZW50/model"
 	"a21hc3NpZ25tZW50/service"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,18 +14,58 @@ import (
 type TaskAPI interface {
 	AddTask(c *gin.Context)
 	UpdateTask(c *gin.Context)
+	UpdateFuture(c *gin.Context)
+	GenerateOccurrences(c *gin.Context)
+	TransitionTask(c *gin.Context)
+	PatchTask(c *gin.Context)
+	BulkTasks(c *gin.Context)
 	DeleteTask(c *gin.Context)
 	GetTaskByID(c *gin.Context)
 	GetTaskList(c *gin.Context)
 	GetTaskListByCategory(c *gin.Context)
+	AddDependency(c *gin.Context)
+	RemoveDependency(c *gin.Context)
 }
 
 type taskAPI struct {
-	taskService service.TaskService
+	taskService       service.TaskService
+	userService       service.UserService
+	attachmentService service.AttachmentService
 }
 
-func NewTaskAPI(taskRepo service.TaskService) *taskAPI {
-	return &taskAPI{taskRepo}
+func NewTaskAPI(taskRepo service.TaskService, userService service.UserService, attachmentService service.AttachmentService) *taskAPI {
+	return &taskAPI{taskRepo, userService, attachmentService}
+}
+
+// location is the timezone of the calling user, which overdue and due_in of
+// date-only deadlines depend on.
+func (t *taskAPI) location(c *gin.Context) *time.Location {
+	user, err := t.userService.GetUserByEmail(c.GetString("email"))
+	if err != nil {
+		return time.UTC
+	}
+	return user.Location()
+}
+
+// timezone is the timezone setting of the calling user, empty when it
+// cannot be read.
+func (t *taskAPI) timezone(c *gin.Context) string {
+	user, err := t.userService.GetUserByEmail(c.GetString("email"))
+	if err != nil {
+		return ""
+	}
+	return user.Timezone
+}
+
+// stampRecurrence makes the rule of task count days in the timezone of the
+// calling user.
+func (t *taskAPI) stampRecurrence(c *gin.Context, task *model.Task) {
+	if task.Recurrence == nil {
+		return
+	}
+	if timezone := t.timezone(c); timezone != "" {
+		task.Recurrence.Timezone = timezone
+	}
 }
 
 func (t *taskAPI) AddTask(c *gin.Context) {
@@ -39,7 +81,7 @@ func (t *taskAPI) AddTask(c *gin.Context) {
 		return
 	}
 
-	if newTask.Deadline == "" {
+	if newTask.Deadline.IsZero() {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Deadline cannot be empty"})
 		return
 	}
@@ -69,10 +111,12 @@ func (t *taskAPI) AddTask(c *gin.Context) {
 
 	// Set the user ID from the authenticated user
 	newTask.UserID = userIDInt
+	newTask.SeriesID = 0
+	t.stampRecurrence(c, &newTask)
 
 	err := t.taskService.Store(&newTask)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		taskWriteError(c, err)
 		return
 	}
 
@@ -80,6 +124,24 @@ func (t *taskAPI) AddTask(c *gin.Context) {
 }
 
 func (t *taskAPI) UpdateTask(c *gin.Context) {
+	t.updateTask(c, t.taskService.Update, "update task success")
+}
+
+// UpdateFuture updates a recurring task and its later occurrences, see
+// service.TaskService.UpdateFuture. Occurrences it replaces may have had
+// attachments, so their blobs are swept.
+func (t *taskAPI) UpdateFuture(c *gin.Context) {
+	updateFuture := func(id int, task *model.Task) error {
+		if err := t.taskService.UpdateFuture(id, task); err != nil {
+			return err
+		}
+		sweepAttachments(t.attachmentService, task.UserID)
+		return nil
+	}
+	t.updateTask(c, updateFuture, "update future occurrences success")
+}
+
+func (t *taskAPI) updateTask(c *gin.Context, update func(id int, task *model.Task) error, message string) {
 	taskID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid task ID"})
@@ -117,15 +179,251 @@ func (t *taskAPI) UpdateTask(c *gin.Context) {
 		return
 	}
 
+	// If-Match takes precedence over a version sent in the body
+	version, ok := ifMatchVersion(c, existingTask.Version)
+	if !ok {
+		c.JSON(http.StatusPreconditionFailed, model.ErrorResponse{Error: model.ErrVersionConflict.Error()})
+		return
+	}
+	if version != 0 {
+		updatedTask.Version = version
+	}
+
 	updatedTask.ID = taskID
 	updatedTask.UserID = userIDInt // Ensure user ID remains the same
-	err = t.taskService.Update(taskID, &updatedTask)
+	t.stampRecurrence(c, &updatedTask)
+	err = update(taskID, &updatedTask)
+	if err != nil {
+		taskWriteError(c, err)
+		return
+	}
+
+	if task, err := t.taskService.GetByID(taskID); err == nil {
+		c.Header("ETag", etag(task.Version))
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: message})
+}
+
+// TransitionTask moves a task to another status of the user's workflow and
+// returns it. An If-Match header is checked like for UpdateTask.
+func (t *taskAPI) TransitionTask(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid task ID"})
+		return
+	}
+
+	userID, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	existingTask, err := t.taskService.GetByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Task not found"})
+		return
+	}
+
+	if existingTask.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: task belongs to different user"})
+		return
+	}
+
+	var body model.TaskTransition
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	version, ok := ifMatchVersion(c, existingTask.Version)
+	if !ok {
+		c.JSON(http.StatusPreconditionFailed, model.ErrorResponse{Error: model.ErrVersionConflict.Error()})
+		return
+	}
+
+	// The task is only moved from the version If-Match names
+	task, err := t.taskService.Transition(taskID, body, version)
+	if err != nil {
+		taskWriteError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(task.Version))
+	c.JSON(http.StatusOK, model.NewTaskResponse(*task, time.Now(), t.location(c)))
+}
+
+// PatchTask changes the fields of a task a JSON merge patch or JSON Patch
+// names and returns it, see service.TaskService.Patch. An If-Match header is
+// checked like for UpdateTask.
+func (t *taskAPI) PatchTask(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid task ID"})
+		return
+	}
+
+	userID, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	existingTask, err := t.taskService.GetByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Task not found"})
+		return
+	}
+
+	if existingTask.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: task belongs to different user"})
+		return
+	}
+
+	patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(c, existingTask.Version)
+	if !ok {
+		c.JSON(http.StatusPreconditionFailed, model.ErrorResponse{Error: model.ErrVersionConflict.Error()})
+		return
+	}
+
+	task, err := t.taskService.Patch(taskID, patch, version, t.timezone(c))
+	if err != nil {
+		taskWriteError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(task.Version))
+	c.JSON(http.StatusOK, model.NewTaskResponse(*task, time.Now(), t.location(c)))
+}
+
+// BulkTasks runs the create, update, move and delete operations of a bulk
+// request in one transaction and answers how each went, see
+// service.TaskService.Bulk. A request that applied nothing because one of
+// its atomic operations failed is answered with the status of that one.
+func (t *taskAPI) BulkTasks(c *gin.Context) {
+	userID, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var request model.BulkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	writes, err := t.taskService.Bulk(userID.(int), request.Operations, request.Mode == model.BulkAtomic, t.timezone(c))
+	if err != nil && err != model.ErrBulkAborted {
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, model.SuccessResponse{Message: "update task success"})
+	response := model.BulkResponse{Committed: err == nil, Results: make([]model.TaskOpResult, len(writes))}
+	status := http.StatusOK
+	now, loc := time.Now(), t.location(c)
+	for i, write := range writes {
+		op := request.Operations[i]
+		result := model.TaskOpResult{Index: i, Op: op.Op, ID: op.ID, Status: http.StatusOK}
+		switch {
+		case write.Err != nil:
+			result.Status, result.Error = taskErrorStatus(write.Err)
+			if !response.Committed {
+				status = result.Status
+			}
+		case !response.Committed:
+			result.Status, result.Error = http.StatusFailedDependency, "not applied: another operation failed"
+		case op.Op == model.TaskOpDelete:
+			result.TrashID = write.TrashID
+		default:
+			task := model.NewTaskResponse(write.Task, now, loc)
+			result.ID, result.Task = write.Task.ID, &task
+		}
+		response.Results[i] = result
+	}
+	c.JSON(status, response)
+}
+
+// GenerateOccurrences creates the next occurrences of a recurring task ahead
+// of time and returns them.
+func (t *taskAPI) GenerateOccurrences(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid task ID"})
+		return
+	}
+
+	userID, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	existingTask, err := t.taskService.GetByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Task not found"})
+		return
+	}
+
+	if existingTask.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: task belongs to different user"})
+		return
+	}
+
+	var body model.OccurrenceRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tasks, err := t.taskService.Generate(taskID, body.Count)
+	if err != nil {
+		taskWriteError(c, err)
+		return
+	}
+
+	now, loc := time.Now(), t.location(c)
+	response := make([]model.TaskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		response = append(response, model.NewTaskResponse(task, now, loc))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// taskWriteError answers a failed task write.
+func taskWriteError(c *gin.Context, err error) {
+	status, message := taskErrorStatus(err)
+	c.JSON(status, model.ErrorResponse{Error: message})
+}
+
+// taskErrorStatus is the status and message a failed task write is answered
+// with.
+func taskErrorStatus(err error) (int, string) {
+	switch {
+	case err == model.ErrRecordNotFound:
+		return http.StatusNotFound, "Task not found"
+	case err == model.ErrTaskNotOwned:
+		return http.StatusForbidden, "Access denied: task belongs to different user"
+	case err == model.ErrVersionConflict:
+		return http.StatusPreconditionFailed, err.Error()
+	case errors.Is(err, model.ErrIllegalTransition), errors.Is(err, model.ErrTaskBlocked), errors.Is(err, model.ErrPatchTestFailed):
+		return http.StatusConflict, err.Error()
+	case err == model.ErrCategoryNotFound, err == model.ErrCategoryNotOwned, errors.Is(err, model.ErrUnknownStatus),
+		err == model.ErrParentNotFound, err == model.ErrParentNotOwned, err == model.ErrNestedSubtask,
+		errors.Is(err, model.ErrInvalidRecurrence), err == model.ErrTagNotFound, err == model.ErrTagNotOwned,
+		errors.Is(err, model.ErrInvalidTask), errors.Is(err, model.ErrInvalidPatch):
+		return http.StatusBadRequest, err.Error()
+	}
+	return http.StatusInternalServerError, err.Error()
 }
 
 func (t *taskAPI) DeleteTask(c *gin.Context) {
@@ -160,13 +458,13 @@ func (t *taskAPI) DeleteTask(c *gin.Context) {
 		return
 	}
 
-	err = t.taskService.Delete(taskID)
+	entry, err := t.taskService.Trash(taskID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, model.SuccessResponse{Message: "delete task success"})
+	c.JSON(http.StatusOK, model.TrashedResponse{Message: "delete task success", TrashID: entry.ID})
 }
 
 func (t *taskAPI) GetTaskByID(c *gin.Context) {
@@ -201,9 +499,20 @@ func (t *taskAPI) GetTaskByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, task)
+	// progress and blockers only need the subtasks and blockers of the task
+	relations, err := t.taskService.PageRelations(userIDInt, []model.Task{*task})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Header("ETag", etag(task.Version))
+	c.JSON(http.StatusOK, relations.Response(*task, time.Now(), t.location(c)))
 }
 
+// GetTaskList lists the tasks of the user, filtered and sorted by the query
+// parameters. Without limit or cursor every task comes back as an array,
+// with them a page of tasks and the cursor of the next one.
 func (t *taskAPI) GetTaskList(c *gin.Context) {
 	userID, exists := c.Get("id")
 	if !exists {
@@ -217,13 +526,49 @@ func (t *taskAPI) GetTaskList(c *gin.Context) {
 		return
 	}
 
-	tasks, err := t.taskService.GetList(userIDInt)
+	// Relative deadline bounds count days in the timezone of the user
+	now, loc := time.Now(), t.location(c)
+	query, err := model.ParseTaskQuery(c.Request.URL.Query(), now.In(loc))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	paged := c.Query("limit") != "" || query.After != nil
+	if paged && query.Limit == 0 {
+		query.Limit = model.DefaultTaskPage
+	}
+
+	page, err := t.taskService.GetPage(userIDInt, query)
+	if errors.Is(err, model.ErrInvalidTaskQuery) || errors.Is(err, model.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	relations, err := t.taskService.PageRelations(userIDInt, page.Tasks)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, tasks)
+	response := make([]model.TaskResponse, 0, len(page.Tasks))
+	for _, task := range page.Tasks {
+		response = append(response, relations.Response(task, now, loc))
+	}
+	if !paged {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	list := model.TaskListPage{Tasks: response, Total: page.Total}
+	if page.Next != nil {
+		next := page.Next.String()
+		list.NextCursor = &next
+	}
+	c.JSON(http.StatusOK, list)
 }
 
 func (t *taskAPI) GetTaskListByCategory(c *gin.Context) {
@@ -254,3 +599,98 @@ func (t *taskAPI) GetTaskListByCategory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, taskCategories)
 }
+
+// AddDependency makes the task wait on the task named by blocked_by_id, both
+// must belong to the calling user.
+func (t *taskAPI) AddDependency(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid task ID"})
+		return
+	}
+
+	userID, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	existingTask, err := t.taskService.GetByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Task not found"})
+		return
+	}
+
+	if existingTask.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: task belongs to different user"})
+		return
+	}
+
+	var dep model.Dependency
+	if err := c.ShouldBindJSON(&dep); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	dep.TaskID = taskID
+	dep.UserID = existingTask.UserID
+
+	if err := t.taskService.AddDependency(dep); err != nil {
+		dependencyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "add dependency success"})
+}
+
+func (t *taskAPI) RemoveDependency(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid task ID"})
+		return
+	}
+
+	blockedByID, err := strconv.Atoi(c.Param("blocked_by_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid task ID"})
+		return
+	}
+
+	userID, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	existingTask, err := t.taskService.GetByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Task not found"})
+		return
+	}
+
+	if existingTask.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: task belongs to different user"})
+		return
+	}
+
+	dep := model.Dependency{TaskID: taskID, BlockedByID: blockedByID, UserID: existingTask.UserID}
+	if err := t.taskService.RemoveDependency(dep); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "remove dependency success"})
+}
+
+// dependencyError answers a failed AddDependency.
+func dependencyError(c *gin.Context, err error) {
+	switch {
+	case err == model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Task not found"})
+	case err == model.ErrDependencyCycle:
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, model.ErrInvalidDependency):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+	}
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"a21hc3NpZ25tZW50/service"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimeEntryAPI interface {
+	StartTimer(c *gin.Context)
+	StopTimer(c *gin.Context)
+	GetTimer(c *gin.Context)
+	AddTimeEntry(c *gin.Context)
+	GetTimeEntries(c *gin.Context)
+	UpdateTimeEntry(c *gin.Context)
+	DeleteTimeEntry(c *gin.Context)
+	GetTimesheet(c *gin.Context)
+}
+
+type timeEntryAPI struct {
+	timeEntryService service.TimeEntryService
+	taskService      service.TaskService
+	userService      service.UserService
+}
+
+func NewTimeEntryAPI(timeEntryService service.TimeEntryService, taskService service.TaskService, userService service.UserService) *timeEntryAPI {
+	return &timeEntryAPI{timeEntryService, taskService, userService}
+}
+
+// StartTimer starts a timer on the task, the body with a note is optional.
+// A user runs one timer at a time.
+func (te *timeEntryAPI) StartTimer(c *gin.Context) {
+	task, _, ok := ownTask(c, te.taskService)
+	if !ok {
+		return
+	}
+
+	var request model.TimerRequest
+	if err := c.ShouldBindJSON(&request); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	entry, err := te.timeEntryService.Start(*task, request.Note)
+	if err != nil {
+		timeEntryWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (te *timeEntryAPI) StopTimer(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	entry, err := te.timeEntryService.Stop(userID.(int))
+	if err != nil {
+		timeEntryWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetTimer returns the running timer of the user.
+func (te *timeEntryAPI) GetTimer(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	entry, err := te.timeEntryService.GetRunning(userID.(int))
+	if err == model.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: model.ErrNoTimerRunning.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// AddTimeEntry records time spent on the task after the fact.
+func (te *timeEntryAPI) AddTimeEntry(c *gin.Context) {
+	task, _, ok := ownTask(c, te.taskService)
+	if !ok {
+		return
+	}
+
+	var request model.TimeEntryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	entry, err := te.timeEntryService.Store(*task, request)
+	if err != nil {
+		timeEntryWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (te *timeEntryAPI) GetTimeEntries(c *gin.Context) {
+	task, _, ok := ownTask(c, te.taskService)
+	if !ok {
+		return
+	}
+
+	entries, err := te.timeEntryService.GetList(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []model.TimeEntry{}
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func (te *timeEntryAPI) UpdateTimeEntry(c *gin.Context) {
+	entry, ok := te.ownTimeEntry(c)
+	if !ok {
+		return
+	}
+
+	var request model.TimeEntryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	updated, err := te.timeEntryService.Update(entry.ID, request)
+	if err != nil {
+		timeEntryWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (te *timeEntryAPI) DeleteTimeEntry(c *gin.Context) {
+	entry, ok := te.ownTimeEntry(c)
+	if !ok {
+		return
+	}
+
+	if err := te.timeEntryService.Delete(entry.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "time entry delete success"})
+}
+
+// GetTimesheet aggregates the time of the user for ?from=YYYY-MM-DD to
+// ?to=YYYY-MM-DD in the user's timezone, as JSON or with ?format=csv as a
+// CSV download.
+func (te *timeEntryAPI) GetTimesheet(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "format must be json or csv"})
+		return
+	}
+
+	loc := time.UTC
+	if user, err := te.userService.GetUserByEmail(c.GetString("email")); err == nil {
+		loc = user.Location()
+	}
+
+	sheet, err := te.timeEntryService.Timesheet(userID.(int), c.Query("from"), c.Query("to"), loc)
+	if err != nil {
+		timeEntryWriteError(c, err)
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, sheet)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := sheet.WriteCSV(&body); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	filename := fmt.Sprintf("timesheet-%s-%s.csv", sheet.From, sheet.To)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", body.Bytes())
+}
+
+// ownTimeEntry loads the :entry_id time entry of a task of the user.
+func (te *timeEntryAPI) ownTimeEntry(c *gin.Context) (*model.TimeEntry, bool) {
+	task, _, ok := ownTask(c, te.taskService)
+	if !ok {
+		return nil, false
+	}
+
+	entryID, err := strconv.Atoi(c.Param("entry_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid time entry ID"})
+		return nil, false
+	}
+
+	entry, err := te.timeEntryService.GetByID(task.ID, entryID)
+	if err == model.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Time entry not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+	return entry, true
+}
+
+// timeEntryWriteError answers a failed timer or time entry write.
+func timeEntryWriteError(c *gin.Context, err error) {
+	switch {
+	case err == model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Time entry not found"})
+	case err == model.ErrTimerRunning, err == model.ErrNoTimerRunning:
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, model.ErrInvalidTimeEntry), errors.Is(err, model.ErrInvalidTimeRange):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+	}
+}
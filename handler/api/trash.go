@@ -0,0 +1,117 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"a21hc3NpZ25tZW50/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashAPI interface {
+	GetTrashList(c *gin.Context)
+	RestoreTrash(c *gin.Context)
+	PurgeTrash(c *gin.Context)
+}
+
+type trashAPI struct {
+	trashService service.TrashService
+}
+
+func NewTrashAPI(trashService service.TrashService) *trashAPI {
+	return &trashAPI{trashService}
+}
+
+// GetTrashList lists what the user deleted, oldest first, with when each
+// entry will be purged.
+func (t *trashAPI) GetTrashList(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	entries, err := t.trashService.GetList(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	items := []model.TrashItem{}
+	for _, entry := range entries {
+		items = append(items, model.NewTrashItem(entry, t.trashService.Retention()))
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// RestoreTrash puts the records of an entry back under their IDs. An entry
+// whose category or parent task is gone answers 409 and stays in the trash.
+func (t *trashAPI) RestoreTrash(c *gin.Context) {
+	entry, ok := t.ownEntry(c)
+	if !ok {
+		return
+	}
+
+	_, err := t.trashService.Restore(entry.ID)
+	switch {
+	case err == model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Trash entry not found"})
+		return
+	case errors.Is(err, model.ErrRestoreConflict):
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "restore success"})
+}
+
+func (t *trashAPI) PurgeTrash(c *gin.Context) {
+	entry, ok := t.ownEntry(c)
+	if !ok {
+		return
+	}
+
+	if err := t.trashService.Purge(*entry); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "purge success"})
+}
+
+// ownEntry loads the trash entry of the :id parameter, answering the request
+// unless it belongs to the user.
+func (t *trashAPI) ownEntry(c *gin.Context) (*model.TrashEntry, bool) {
+	entryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid trash entry ID"})
+		return nil, false
+	}
+
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return nil, false
+	}
+
+	entry, err := t.trashService.GetByID(entryID)
+	if err == model.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Trash entry not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+
+	if entry.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: trash entry belongs to different user"})
+		return nil, false
+	}
+	return entry, true
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"a21hc3NpZ25tZW50/model"
 	"a21hc3NpZ25tZW50/service"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,6 +27,12 @@ func (u *userAPI) ListUsers(c *gin.Context) {
 type UserAPI interface {
 	Register(c *gin.Context)
 	Login(c *gin.Context)
+	Refresh(c *gin.Context)
+	Logout(c *gin.Context)
+	LogoutAll(c *gin.Context)
+	GetSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	SetTimezone(c *gin.Context)
 	GetUserTaskCategory(c *gin.Context)
 	ListUsers(c *gin.Context) // debug: lihat semua user
 }
@@ -54,6 +62,7 @@ func (u *userAPI) Register(c *gin.Context) {
 		Fullname: user.Fullname,
 		Email:    user.Email,
 		Password: user.Password,
+		Timezone: user.Timezone,
 	}
 
 	_, err := u.userService.Register(&recordUser)
@@ -62,6 +71,10 @@ func (u *userAPI) Register(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "email already exists"})
 			return
 		}
+		if errors.Is(err, model.ErrInvalidTimezone) {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "error internal server: " + err.Error()})
 		return
 	}
@@ -87,7 +100,12 @@ func (u *userAPI) Login(c *gin.Context) {
 		Password: user.Password,
 	}
 
-	token, err := u.userService.Login(&recordUser)
+	device := model.Device{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+
+	tokens, err := u.userService.Login(&recordUser, device)
 	if err != nil {
 		if err.Error() == "user not found" {
 			c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "user not found"})
@@ -101,13 +119,183 @@ func (u *userAPI) Login(c *gin.Context) {
 		return
 	}
 
-	c.SetCookie("session_token", *token, 3600, "/", "", false, true)
+	setTokenCookies(c, tokens)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "login success",
 	})
 }
 
+func (u *userAPI) Refresh(c *gin.Context) {
+	var req model.RefreshRequest
+
+	// Refresh token boleh dikirim lewat body JSON atau cookie refresh_token
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid decode json"})
+			return
+		}
+	}
+	if req.RefreshToken == "" {
+		if cookie, err := c.Cookie("refresh_token"); err == nil {
+			req.RefreshToken = cookie
+		}
+	}
+
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "refresh token is empty"})
+		return
+	}
+
+	tokens, err := u.userService.Refresh(req.RefreshToken)
+	if err != nil {
+		switch err.Error() {
+		case "invalid refresh token", "refresh token expired", "refresh token reuse detected", "user not found":
+			c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "error internal server: " + err.Error()})
+		}
+		return
+	}
+
+	setTokenCookies(c, tokens)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "refresh success",
+	})
+}
+
+func (u *userAPI) Logout(c *gin.Context) {
+	token, err := c.Cookie("session_token")
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	err = u.userService.Logout(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "error internal server: " + err.Error()})
+		return
+	}
+
+	clearTokenCookies(c)
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "logout success"})
+}
+
+func (u *userAPI) LogoutAll(c *gin.Context) {
+	email, exists := c.Get("email")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	emailStr, ok := email.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Invalid email format"})
+		return
+	}
+
+	err := u.userService.LogoutAll(emailStr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "error internal server: " + err.Error()})
+		return
+	}
+
+	clearTokenCookies(c)
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "logout all success"})
+}
+
+func (u *userAPI) GetSessions(c *gin.Context) {
+	email, exists := c.Get("email")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	emailStr, ok := email.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Invalid email format"})
+		return
+	}
+
+	token, _ := c.Cookie("session_token")
+
+	sessions, err := u.userService.GetSessions(emailStr, token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (u *userAPI) RevokeSession(c *gin.Context) {
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Invalid session ID"})
+		return
+	}
+
+	email, exists := c.Get("email")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	emailStr, ok := email.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Invalid email format"})
+		return
+	}
+
+	err = u.userService.RevokeSession(emailStr, sessionID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "session revoked"})
+}
+
+func (u *userAPI) SetTimezone(c *gin.Context) {
+	var body model.UserTimezone
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid decode json"})
+		return
+	}
+
+	err := u.userService.SetTimezone(c.GetString("email"), body.Timezone)
+	if errors.Is(err, model.ErrInvalidTimezone) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "timezone updated"})
+}
+
+// setTokenCookies hands a freshly issued pair to the caller. Both cookies
+// outlive the access token so an expired session_token can still be refreshed.
+func setTokenCookies(c *gin.Context, tokens *model.TokenPair) {
+	maxAge := int(model.RefreshTokenTTL.Seconds())
+	c.SetCookie("session_token", tokens.AccessToken, maxAge, "/", "", false, true)
+	c.SetCookie("refresh_token", tokens.RefreshToken, maxAge, "/api/v1/user/refresh", "", false, true)
+}
+
+func clearTokenCookies(c *gin.Context) {
+	c.SetCookie("session_token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, "/api/v1/user/refresh", "", false, true)
+}
+
 func (u *userAPI) GetUserTaskCategory(c *gin.Context) {
 	userTaskCategories, err := u.userService.GetUserTaskCategory()
 	if err != nil {
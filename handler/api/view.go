@@ -0,0 +1,167 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"a21hc3NpZ25tZW50/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ViewAPI interface {
+	AddView(c *gin.Context)
+	UpdateView(c *gin.Context)
+	DeleteView(c *gin.Context)
+	GetViewByID(c *gin.Context)
+	GetViewList(c *gin.Context)
+}
+
+type viewAPI struct {
+	viewService service.ViewService
+}
+
+func NewViewAPI(viewService service.ViewService) *viewAPI {
+	return &viewAPI{viewService}
+}
+
+func (v *viewAPI) AddView(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var view model.SavedView
+	if err := c.ShouldBindJSON(&view); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	view.ID = 0
+	view.UserID = userID.(int)
+
+	if err := v.viewService.Store(&view); err != nil {
+		viewWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.NewSavedViewResponse(view))
+}
+
+func (v *viewAPI) UpdateView(c *gin.Context) {
+	existing, ok := v.ownView(c)
+	if !ok {
+		return
+	}
+
+	var view model.SavedView
+	if err := c.ShouldBindJSON(&view); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	view.ID = existing.ID
+	view.UserID = existing.UserID
+
+	if err := v.viewService.Update(view); err != nil {
+		viewWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.NewSavedViewResponse(view))
+}
+
+func (v *viewAPI) DeleteView(c *gin.Context) {
+	view, ok := v.ownView(c)
+	if !ok {
+		return
+	}
+
+	if err := v.viewService.Delete(view.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "view delete success"})
+}
+
+func (v *viewAPI) GetViewByID(c *gin.Context) {
+	view, ok := v.ownView(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, model.NewSavedViewResponse(*view))
+}
+
+// GetViewList lists the views of the user, ?pinned=true only those pinned
+// to the navigation.
+func (v *viewAPI) GetViewList(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	views, err := v.viewService.GetList(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	pinnedOnly := c.Query("pinned") == "true"
+	response := []model.SavedViewResponse{}
+	for _, view := range views {
+		if !pinnedOnly || view.Pinned {
+			response = append(response, model.NewSavedViewResponse(view))
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ownView loads the view of the :id parameter, answering the request unless
+// it belongs to the user.
+func (v *viewAPI) ownView(c *gin.Context) (*model.SavedView, bool) {
+	viewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid view ID"})
+		return nil, false
+	}
+
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return nil, false
+	}
+
+	view, err := v.viewService.GetByID(viewID)
+	if err == model.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "View not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+
+	if view.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "Access denied: view belongs to different user"})
+		return nil, false
+	}
+	return view, true
+}
+
+// viewWriteError answers a failed view write.
+func viewWriteError(c *gin.Context, err error) {
+	switch {
+	case err == model.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "View not found"})
+	case err == model.ErrViewExists:
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, model.ErrInvalidView):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"a21hc3NpZ25tZW50/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WorkflowAPI interface {
+	GetWorkflow(c *gin.Context)
+	UpdateWorkflow(c *gin.Context)
+}
+
+type workflowAPI struct {
+	workflowService service.WorkflowService
+}
+
+func NewWorkflowAPI(workflowService service.WorkflowService) *workflowAPI {
+	return &workflowAPI{workflowService}
+}
+
+func (w *workflowAPI) GetWorkflow(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	workflow, err := w.workflowService.Get(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// UpdateWorkflow replaces the workflow of the user. Tasks keep their status
+// even when it is no longer part of the workflow, and may then move to any
+// status.
+func (w *workflowAPI) UpdateWorkflow(c *gin.Context) {
+	userID, ok := c.Get("id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var workflow model.Workflow
+	if err := c.ShouldBindJSON(&workflow); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	workflow.UserID = userID.(int)
+
+	err := w.workflowService.Save(workflow)
+	if errors.Is(err, model.ErrInvalidWorkflow) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{Message: "workflow update success"})
+}
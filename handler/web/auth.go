@@ -2,12 +2,14 @@ package web
 
 import (
 	"a21hc3NpZ25tZW50/client"
+	"a21hc3NpZ25tZW50/model"
 	"a21hc3NpZ25tZW50/service"
 	"embed"
 	"fmt"
+	"html/template"
 	"net/http"
 	"path"
-	"text/template"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,7 +19,9 @@ type AuthWeb interface {
 	LoginProcess(c *gin.Context)
 	Register(c *gin.Context)
 	RegisterProcess(c *gin.Context)
+	Refresh(c *gin.Context)
 	Logout(c *gin.Context)
+	LogoutAll(c *gin.Context)
 }
 
 type authWeb struct {
@@ -50,22 +54,21 @@ func (a *authWeb) LoginProcess(c *gin.Context) {
 	email := c.Request.FormValue("email")
 	password := c.Request.FormValue("password")
 
-	status, err := a.userClient.Login(email, password)
-	if err != nil {
-		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message=Login Error: "+err.Error())
-		return
+	device := model.Device{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
 	}
 
-	session, err := a.sessionService.GetSessionByEmail(email)
+	token, status, err := a.userClient.Login(email, password, device)
 	if err != nil {
-		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message=Session Error: "+err.Error())
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message=Login Error: "+err.Error())
 		return
 	}
 
 	if status == 200 {
 		http.SetCookie(c.Writer, &http.Cookie{
 			Name:   "session_token",
-			Value:  session.Token,
+			Value:  token,
 			Path:   "/",
 			MaxAge: 31536000,
 			Domain: "",
@@ -78,6 +81,16 @@ func (a *authWeb) LoginProcess(c *gin.Context) {
 	}
 }
 
+// currentSession returns the session of the session_token cookie the request
+// was authenticated with, so every device keeps using its own session.
+func currentSession(c *gin.Context, sessionService service.SessionService) (model.Session, error) {
+	token, err := c.Cookie("session_token")
+	if err != nil {
+		return model.Session{}, err
+	}
+	return sessionService.GetSessionByToken(token)
+}
+
 func (a *authWeb) Register(c *gin.Context) {
 	var header = path.Join("views", "general", "header.html")
 	var filepath = path.Join("views", "auth", "register.html")
@@ -113,7 +126,56 @@ func (a *authWeb) RegisterProcess(c *gin.Context) {
 	}
 }
 
+func (a *authWeb) Refresh(c *gin.Context) {
+	next := c.Query("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/client/dashboard"
+	}
+
+	token, err := c.Cookie("session_token")
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/login")
+		return
+	}
+
+	newToken, err := a.userClient.Refresh(token)
+	if err != nil {
+		c.SetCookie("session_token", "", -1, "/", "", false, false)
+		c.Redirect(http.StatusSeeOther, "/client/login")
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:   "session_token",
+		Value:  newToken,
+		Path:   "/",
+		MaxAge: 31536000,
+		Domain: "",
+	})
+
+	c.Redirect(http.StatusSeeOther, next)
+}
+
 func (a *authWeb) Logout(c *gin.Context) {
+	if token, err := c.Cookie("session_token"); err == nil {
+		if _, err := a.userClient.Logout(token); err != nil {
+			c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message=Logout Error: "+err.Error())
+			return
+		}
+	}
+
+	c.SetCookie("session_token", "", -1, "/", "", false, false)
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+func (a *authWeb) LogoutAll(c *gin.Context) {
+	if token, err := c.Cookie("session_token"); err == nil {
+		if _, err := a.userClient.LogoutAll(token); err != nil {
+			c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message=Logout Error: "+err.Error())
+			return
+		}
+	}
+
 	c.SetCookie("session_token", "", -1, "/", "", false, false)
 	c.Redirect(http.StatusSeeOther, "/")
 }
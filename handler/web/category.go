@@ -5,10 +5,10 @@ import (
 	"a21hc3NpZ25tZW50/model"
 	"a21hc3NpZ25tZW50/service"
 	"embed"
+	"html/template"
 	"net/http"
 	"path"
 	"strconv"
-	"text/template"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,12 +21,13 @@ type CategoryWeb interface {
 
 type categoryWeb struct {
 	categoryClient client.CategoryClient
+	viewClient     client.ViewClient
 	sessionService service.SessionService
 	embed          embed.FS
 }
 
-func NewCategoryWeb(categoryClient client.CategoryClient, sessionService service.SessionService, embed embed.FS) *categoryWeb {
-	return &categoryWeb{categoryClient, sessionService, embed}
+func NewCategoryWeb(categoryClient client.CategoryClient, viewClient client.ViewClient, sessionService service.SessionService, embed embed.FS) *categoryWeb {
+	return &categoryWeb{categoryClient, viewClient, sessionService, embed}
 }
 
 func (c *categoryWeb) Category(ctx *gin.Context) {
@@ -37,7 +38,7 @@ func (c *categoryWeb) Category(ctx *gin.Context) {
 		}
 	}
 
-	session, err := c.sessionService.GetSessionByEmail(email)
+	session, err := currentSession(ctx, c.sessionService)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
 		return
@@ -52,6 +53,7 @@ func (c *categoryWeb) Category(ctx *gin.Context) {
 	var dataTemplate = map[string]interface{}{
 		"email":      email,
 		"categories": categories,
+		"views":      pinnedViews(userViews(c.viewClient, session.Token)),
 	}
 
 	var funcMap = template.FuncMap{
@@ -76,14 +78,7 @@ func (c *categoryWeb) Category(ctx *gin.Context) {
 }
 
 func (c *categoryWeb) AddCategory(ctx *gin.Context) {
-	var email string
-	if temp, ok := ctx.Get("email"); ok {
-		if contextData, ok := temp.(string); ok {
-			email = contextData
-		}
-	}
-
-	session, err := c.sessionService.GetSessionByEmail(email)
+	session, err := currentSession(ctx, c.sessionService)
 	if err != nil {
 		ctx.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
 		return
@@ -109,14 +104,7 @@ func (c *categoryWeb) AddCategory(ctx *gin.Context) {
 }
 
 func (c *categoryWeb) DeleteCategory(ctx *gin.Context) {
-	var email string
-	if temp, ok := ctx.Get("email"); ok {
-		if contextData, ok := temp.(string); ok {
-			email = contextData
-		}
-	}
-
-	session, err := c.sessionService.GetSessionByEmail(email)
+	session, err := currentSession(ctx, c.sessionService)
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -135,7 +123,17 @@ func (c *categoryWeb) DeleteCategory(ctx *gin.Context) {
 		return
 	}
 
-	statusCode, err := c.categoryClient.DeleteCategory(session.Token, strconv.Itoa(categoryID))
+	// The page retries with ?mode=cascade once the user agrees to lose the tasks
+	opts := model.CategoryDelete{Mode: model.CategoryDeleteMode(ctx.Query("mode"))}
+	if opts.Mode == model.CategoryDeleteReassign {
+		opts.ReassignTo, _ = strconv.Atoi(ctx.Query("reassign_to"))
+	}
+
+	statusCode, err := c.categoryClient.DeleteCategory(session.Token, strconv.Itoa(categoryID), opts)
+	if err == model.ErrCategoryNotEmpty {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -5,9 +5,10 @@ import (
 	"a21hc3NpZ25tZW50/model"
 	"a21hc3NpZ25tZW50/service"
 	"embed"
+	"html/template"
 	"net/http"
+	"net/url"
 	"path"
-	"text/template"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,12 +20,23 @@ type DashboardWeb interface {
 type dashboardWeb struct {
 	sessionService service.SessionService
 	taskClient     client.TaskClient
+	viewClient     client.ViewClient
 	userService    service.UserService
 	embed          embed.FS
 }
 
-func NewDashboardWeb(sessionService service.SessionService, taskClient client.TaskClient, userService service.UserService, embed embed.FS) *dashboardWeb {
-	return &dashboardWeb{sessionService, taskClient, userService, embed}
+func NewDashboardWeb(sessionService service.SessionService, taskClient client.TaskClient, viewClient client.ViewClient, userService service.UserService, embed embed.FS) *dashboardWeb {
+	return &dashboardWeb{sessionService, taskClient, viewClient, userService, embed}
+}
+
+// dashboardTask is a row of the dashboard, with the deadline as the user
+// reads it and the computed fields the task API returns.
+type dashboardTask struct {
+	model.UserTaskCategory
+	DeadlineText string
+	Overdue      bool
+	DueIn        string
+	Tags         []*model.Tag
 }
 
 func (d *dashboardWeb) Dashboard(c *gin.Context) {
@@ -35,19 +47,37 @@ func (d *dashboardWeb) Dashboard(c *gin.Context) {
 		}
 	}
 
-	session, err := d.sessionService.GetSessionByEmail(email)
+	session, err := currentSession(c, d.sessionService)
 	if err != nil {
 		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
 		return
 	}
 
-	// Get tasks for the logged-in user (filtered by user ID)
-	tasks, err := d.taskClient.TaskList(session.Token)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"error": "Error getting user tasks: " + err.Error(),
-		})
-		return
+	// Get tasks for the logged-in user (filtered by user ID), those matching
+	// the search box, best first, or those of a saved view query
+	var tasks []*model.TaskResponse
+	query, view := c.Query("q"), c.Query("view")
+	if query != "" {
+		results, err := client.NewSearchClient().Search(session.Token, query)
+		if err != nil && err != model.ErrInvalidSearch {
+			c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+			return
+		}
+		for i := range results {
+			tasks = append(tasks, &results[i].Task)
+		}
+	} else {
+		tasks, err = d.taskClient.TaskList(session.Token, client.TaskListOptions{View: view})
+		if err != nil && view != "" {
+			c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+url.QueryEscape(err.Error()))
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"error": "Error getting user tasks: " + err.Error(),
+			})
+			return
+		}
 	}
 
 	user, err := d.userService.GetUserByEmail(email)
@@ -68,8 +98,20 @@ func (d *dashboardWeb) Dashboard(c *gin.Context) {
 		categoryByID[category.ID] = category.Name
 	}
 
+	tags, err := client.NewTagClient().TagList(session.Token)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+		return
+	}
+
+	tagByID := make(map[int]*model.Tag)
+	for _, tag := range tags {
+		tagByID[tag.ID] = tag
+	}
+
 	// Convert tasks to UserTaskCategory format for template compatibility
-	var userTaskCategories []model.UserTaskCategory
+	var userTaskCategories []dashboardTask
+	overdue := 0
 	if tasks != nil {
 		for _, task := range tasks {
 			categoryName := categoryByID[task.CategoryID]
@@ -77,15 +119,28 @@ func (d *dashboardWeb) Dashboard(c *gin.Context) {
 				categoryName = "Unknown"
 			}
 
-			userTaskCategory := model.UserTaskCategory{
-				ID:       task.ID,
-				Fullname: user.Fullname,
-				Email:    email,
-				Task:     task.Title,
-				Deadline: task.Deadline,
-				Priority: task.Priority,
-				Status:   task.Status,
-				Category: categoryName,
+			userTaskCategory := dashboardTask{
+				UserTaskCategory: model.UserTaskCategory{
+					ID:       task.ID,
+					Fullname: user.Fullname,
+					Email:    email,
+					Task:     task.Title,
+					Deadline: task.Deadline,
+					Priority: task.Priority,
+					Status:   task.Status,
+					Category: categoryName,
+				},
+				DeadlineText: formatDeadline(task.Deadline, user.Location()),
+				Overdue:      task.Overdue,
+				DueIn:        formatDueIn(task.DueIn),
+			}
+			for _, id := range task.TagIDs {
+				if tag, ok := tagByID[id]; ok {
+					userTaskCategory.Tags = append(userTaskCategory.Tags, tag)
+				}
+			}
+			if task.Overdue {
+				overdue++
 			}
 			userTaskCategories = append(userTaskCategories, userTaskCategory)
 		}
@@ -93,10 +148,17 @@ func (d *dashboardWeb) Dashboard(c *gin.Context) {
 
 	dataLength := len(userTaskCategories)
 
+	views := userViews(d.viewClient, session.Token)
+
 	var dataTemplate = map[string]interface{}{
 		"email":                email,
+		"query":                query,
+		"view":                 view,
+		"view_name":            viewName(views, view),
+		"views":                pinnedViews(views),
 		"user_task_categories": userTaskCategories,
 		"data_count":           dataLength,
+		"overdue_count":        overdue,
 		"has_sample_data":      false,
 	}
 
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	"fmt"
+	"time"
+)
+
+// formDeadline builds a deadline from the date and optional time inputs of the
+// task form. The time is read in the user's timezone, without one the deadline
+// stays date-only.
+func formDeadline(date, clock string, loc *time.Location) (model.Deadline, error) {
+	if clock == "" {
+		return model.ParseDeadline(date)
+	}
+
+	at, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, loc)
+	if err != nil {
+		return model.Deadline{}, fmt.Errorf("invalid deadline %q %q", date, clock)
+	}
+	return model.Deadline{At: at.UTC()}, nil
+}
+
+// formatDeadline shows a deadline the way its user reads it.
+func formatDeadline(d model.Deadline, loc *time.Location) string {
+	if d.IsZero() || d.DateOnly {
+		return d.String()
+	}
+	return d.At.In(loc).Format("2006-01-02 15:04 MST")
+}
+
+// formatDueIn turns due_in seconds into "due in 3 days" or "overdue by 2 hours".
+func formatDueIn(seconds *int64) string {
+	if seconds == nil {
+		return ""
+	}
+
+	d := time.Duration(*seconds) * time.Second
+	prefix := "due in "
+	if d <= 0 {
+		prefix, d = "overdue by ", -d
+	}
+
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%s%d days", prefix, d/(24*time.Hour))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%s%d hours", prefix, d/time.Hour)
+	case d >= 2*time.Minute:
+		return fmt.Sprintf("%s%d minutes", prefix, d/time.Minute)
+	}
+	return prefix + "a moment"
+}
@@ -2,9 +2,9 @@ package web
 
 import (
 	"embed"
+	"html/template"
 	"net/http"
 	"path"
-	"text/template"
 
 	"github.com/gin-gonic/gin"
 )
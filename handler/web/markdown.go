@@ -0,0 +1,78 @@
+package web
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Inline Markdown, matched on text that is already HTML-escaped. Links only
+// take http and https URLs.
+var (
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	mdBold   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdItalic = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	mdLink   = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+)
+
+// renderMarkdown turns the Markdown of a comment into HTML. It knows
+// paragraphs, "- " lists, code spans, bold, italic and links; everything else
+// is shown as text, and no HTML of the source gets through.
+func renderMarkdown(src string) string {
+	var out strings.Builder
+	for _, block := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		if lines[0] == "" {
+			continue
+		}
+
+		if isListItem(lines[0]) {
+			out.WriteString("<ul>")
+			for _, line := range lines {
+				if isListItem(line) {
+					line = strings.TrimSpace(line)[2:]
+				}
+				out.WriteString("<li>" + renderInline(line) + "</li>")
+			}
+			out.WriteString("</ul>")
+			continue
+		}
+
+		for i, line := range lines {
+			lines[i] = renderInline(line)
+		}
+		out.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>")
+	}
+	return out.String()
+}
+
+func isListItem(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ")
+}
+
+func renderInline(line string) string {
+	line = html.EscapeString(line)
+
+	// Code spans and links are set aside first, so their text and URLs keep
+	// any * and _ in them
+	var kept []string
+	keep := func(re *regexp.Regexp, render func(m []string) string) {
+		line = re.ReplaceAllStringFunc(line, func(s string) string {
+			kept = append(kept, render(re.FindStringSubmatch(s)))
+			return "\x00"
+		})
+	}
+	keep(mdCode, func(m []string) string { return "<code>" + m[1] + "</code>" })
+	keep(mdLink, func(m []string) string {
+		return `<a href="` + m[2] + `" rel="nofollow noopener" target="_blank">` + m[1] + "</a>"
+	})
+
+	line = mdBold.ReplaceAllString(line, "<strong>$1</strong>")
+	line = mdItalic.ReplaceAllString(line, "<em>$1$2</em>")
+
+	for _, s := range kept {
+		line = strings.Replace(line, "\x00", s, 1)
+	}
+	return line
+}
@@ -5,10 +5,11 @@ import (
 	"a21hc3NpZ25tZW50/model"
 	"a21hc3NpZ25tZW50/service"
 	"embed"
+	"html/template"
 	"net/http"
 	"path"
+	"sort"
 	"strconv"
-	"text/template"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,17 +18,54 @@ type TaskWeb interface {
 	TaskPage(c *gin.Context)
 	TaskAddProcess(c *gin.Context)
 	TaskDeleteProcess(c *gin.Context)
+	TaskCheckProcess(c *gin.Context)
+	TaskComments(c *gin.Context)
+	TaskCommentProcess(c *gin.Context)
 }
 
 type taskWeb struct {
 	taskClient     client.TaskClient
+	viewClient     client.ViewClient
 	sessionService service.SessionService
 	userService    service.UserService
 	embed          embed.FS
 }
 
-func NewTaskWeb(taskClient client.TaskClient, sessionService service.SessionService, userService service.UserService, embed embed.FS) *taskWeb {
-	return &taskWeb{taskClient, sessionService, userService, embed}
+func NewTaskWeb(taskClient client.TaskClient, viewClient client.ViewClient, sessionService service.SessionService, userService service.UserService, embed embed.FS) *taskWeb {
+	return &taskWeb{taskClient, viewClient, sessionService, userService, embed}
+}
+
+// taskRow is a top-level task of the task page with its subtasks in order.
+type taskRow struct {
+	*model.TaskResponse
+	Subtasks []*model.TaskResponse
+}
+
+// taskRows groups tasks under their parents, keeping the order of the list for
+// top-level tasks.
+func taskRows(tasks []*model.TaskResponse) []taskRow {
+	subtasks := map[int][]*model.TaskResponse{}
+	for _, task := range tasks {
+		if task.ParentID != 0 {
+			subtasks[task.ParentID] = append(subtasks[task.ParentID], task)
+		}
+	}
+
+	var rows []taskRow
+	for _, task := range tasks {
+		if task.ParentID != 0 {
+			continue
+		}
+		children := subtasks[task.ID]
+		sort.SliceStable(children, func(i, j int) bool {
+			if children[i].Position != children[j].Position {
+				return children[i].Position < children[j].Position
+			}
+			return children[i].ID < children[j].ID
+		})
+		rows = append(rows, taskRow{TaskResponse: task, Subtasks: children})
+	}
+	return rows
 }
 
 func (t *taskWeb) TaskPage(c *gin.Context) {
@@ -38,13 +76,13 @@ func (t *taskWeb) TaskPage(c *gin.Context) {
 		}
 	}
 
-	session, err := t.sessionService.GetSessionByEmail(email)
+	session, err := currentSession(c, t.sessionService)
 	if err != nil {
 		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
 		return
 	}
 
-	tasks, err := t.taskClient.TaskList(session.Token)
+	tasks, err := t.taskClient.TaskList(session.Token, client.TaskListOptions{})
 	if err != nil {
 		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
 		return
@@ -60,14 +98,29 @@ func (t *taskWeb) TaskPage(c *gin.Context) {
 
 	var dataTemplate = map[string]interface{}{
 		"email":      email,
-		"tasks":      tasks,
+		"tasks":      taskRows(tasks),
 		"categories": categories,
+		"views":      pinnedViews(userViews(t.viewClient, session.Token)),
+	}
+	// ?trashed=<entry ID> right after a delete shows the undo banner
+	if trashID, err := strconv.Atoi(c.Query("trashed")); err == nil && trashID > 0 {
+		dataTemplate["trashed"] = trashID
 	}
 
+	user, err := t.userService.GetUserByEmail(email)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+		return
+	}
+	loc := user.Location()
+
 	var funcMap = template.FuncMap{
 		"exampleFunc": func() int {
 			return 0
 		},
+		"deadline": func(d model.Deadline) string {
+			return formatDeadline(d, loc)
+		},
 	}
 
 	var header = path.Join("views", "general", "header.html")
@@ -93,7 +146,7 @@ func (t *taskWeb) TaskAddProcess(c *gin.Context) {
 		}
 	}
 
-	session, err := t.sessionService.GetSessionByEmail(email)
+	session, err := currentSession(c, t.sessionService)
 	if err != nil {
 		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
 		return
@@ -101,10 +154,13 @@ func (t *taskWeb) TaskAddProcess(c *gin.Context) {
 
 	// Tangkap semua data form
 	title := c.Request.FormValue("title")
-	deadline := c.Request.FormValue("deadline")
+	deadlineDate := c.Request.FormValue("deadline")
+	deadlineTime := c.Request.FormValue("deadline-time")
 	priorityStr := c.Request.FormValue("priority")
 	status := c.Request.FormValue("status")
 	categoryIDStr := c.Request.FormValue("category-id")
+	parentIDStr := c.Request.FormValue("parent-id")
+	repeat := c.Request.FormValue("repeat")
 
 	// Validasi data form
 	if title == "" {
@@ -112,7 +168,7 @@ func (t *taskWeb) TaskAddProcess(c *gin.Context) {
 		return
 	}
 
-	if deadline == "" {
+	if deadlineDate == "" {
 		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message=Deadline cannot be empty")
 		return
 	}
@@ -135,12 +191,28 @@ func (t *taskWeb) TaskAddProcess(c *gin.Context) {
 		return
 	}
 
+	// Parent task opsional, kosong berarti task biasa
+	parentID := 0
+	if parentIDStr != "" {
+		parentID, err = strconv.Atoi(parentIDStr)
+		if err != nil || parentID <= 0 {
+			c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message=Invalid parent task")
+			return
+		}
+	}
+
 	user, err := t.userService.GetUserByEmail(email)
 	if err != nil {
 		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
 		return
 	}
 
+	deadline, err := formDeadline(deadlineDate, deadlineTime, user.Location())
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+		return
+	}
+
 	task := model.Task{
 		Title:      title,
 		Deadline:   deadline,
@@ -148,6 +220,11 @@ func (t *taskWeb) TaskAddProcess(c *gin.Context) {
 		Status:     status,
 		CategoryID: categoryID,
 		UserID:     user.ID,
+		ParentID:   parentID,
+	}
+	// Pengulangan opsional, harian/mingguan/bulanan dihitung dari deadline
+	if repeat != "" {
+		task.Recurrence = &model.Recurrence{Freq: model.RecurrenceFreq(repeat)}
 	}
 
 	statusCode, err := t.taskClient.AddTask(session.Token, task)
@@ -164,14 +241,7 @@ func (t *taskWeb) TaskAddProcess(c *gin.Context) {
 }
 
 func (t *taskWeb) TaskDeleteProcess(c *gin.Context) {
-	var email string
-	if temp, ok := c.Get("email"); ok {
-		if contextData, ok := temp.(string); ok {
-			email = contextData
-		}
-	}
-
-	session, err := t.sessionService.GetSessionByEmail(email)
+	session, err := currentSession(c, t.sessionService)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -190,15 +260,141 @@ func (t *taskWeb) TaskDeleteProcess(c *gin.Context) {
 		return
 	}
 
-	statusCode, err := t.taskClient.DeleteTask(session.Token, taskID)
+	trashID, err := t.taskClient.DeleteTask(session.Token, taskID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if statusCode == 200 {
-		c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
-	} else {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
+	// The page offers to undo with the trash entry
+	c.JSON(http.StatusOK, gin.H{"message": "Task moved to trash", "trash_id": trashID})
+}
+
+// TaskCheckProcess ticks a subtask off or reopens it. Reopening prefers a todo
+// status and falls back to an in-progress one, since workflows like the
+// default one only allow a done task back into progress.
+func (t *taskWeb) TaskCheckProcess(c *gin.Context) {
+	session, err := currentSession(c, t.sessionService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var body struct {
+		Done bool `json:"done"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories := []model.StatusCategory{model.StatusTodo, model.StatusInProgress}
+	if body.Done {
+		categories = []model.StatusCategory{model.StatusDone}
 	}
+
+	var lastErr error
+	for _, category := range categories {
+		statusCode, err := t.taskClient.TransitionTask(session.Token, taskID, model.TaskTransition{Category: category})
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Task updated successfully"})
+			return
+		}
+		if statusCode != http.StatusConflict && statusCode != http.StatusBadRequest {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		lastErr = err
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": lastErr.Error()})
+}
+
+// taskComment is a comment as the task page shows it, with its body rendered
+// from Markdown.
+type taskComment struct {
+	ID     int    `json:"id"`
+	Author string `json:"author"`
+	Posted string `json:"posted"`
+	Edited bool   `json:"edited"`
+	HTML   string `json:"html"`
+}
+
+// TaskComments returns the comments of a task for the thread under it on the
+// task page.
+func (t *taskWeb) TaskComments(c *gin.Context) {
+	session, err := currentSession(c, t.sessionService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	comments, err := client.NewCommentClient().CommentList(session.Token, taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only the owner of a task can comment on it, so the author is the user
+	user, err := t.userService.GetUserByEmail(session.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	loc := user.Location()
+
+	thread := make([]taskComment, 0, len(comments))
+	for _, comment := range comments {
+		thread = append(thread, taskComment{
+			ID:     comment.ID,
+			Author: user.Fullname,
+			Posted: comment.CreatedAt.In(loc).Format("2 Jan 2006 15:04"),
+			Edited: comment.Edited(),
+			HTML:   renderMarkdown(comment.Body),
+		})
+	}
+	c.JSON(http.StatusOK, thread)
+}
+
+func (t *taskWeb) TaskCommentProcess(c *gin.Context) {
+	session, err := currentSession(c, t.sessionService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var request model.CommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statusCode, err := client.NewCommentClient().AddComment(session.Token, taskID, request.Body)
+	if err != nil {
+		if statusCode == http.StatusBadRequest {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment added successfully"})
 }
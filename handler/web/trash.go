@@ -0,0 +1,135 @@
+package web
+
+import (
+	"a21hc3NpZ25tZW50/client"
+	"a21hc3NpZ25tZW50/service"
+	"embed"
+	"html/template"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashWeb interface {
+	TrashPage(c *gin.Context)
+	TrashRestoreProcess(c *gin.Context)
+	TrashPurgeProcess(c *gin.Context)
+}
+
+type trashWeb struct {
+	trashClient    client.TrashClient
+	viewClient     client.ViewClient
+	sessionService service.SessionService
+	userService    service.UserService
+	embed          embed.FS
+}
+
+func NewTrashWeb(trashClient client.TrashClient, viewClient client.ViewClient, sessionService service.SessionService, userService service.UserService, embed embed.FS) *trashWeb {
+	return &trashWeb{trashClient, viewClient, sessionService, userService, embed}
+}
+
+func (t *trashWeb) TrashPage(c *gin.Context) {
+	var email string
+	if temp, ok := c.Get("email"); ok {
+		if contextData, ok := temp.(string); ok {
+			email = contextData
+		}
+	}
+
+	session, err := currentSession(c, t.sessionService)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+		return
+	}
+
+	items, err := t.trashClient.TrashList(session.Token)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+		return
+	}
+
+	user, err := t.userService.GetUserByEmail(email)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+		return
+	}
+	loc := user.Location()
+
+	// Newest first, the order the user is looking for them in
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+
+	var dataTemplate = map[string]interface{}{
+		"email": email,
+		"items": items,
+		"views": pinnedViews(userViews(t.viewClient, session.Token)),
+	}
+
+	var funcMap = template.FuncMap{
+		"when": func(at time.Time) string {
+			return at.In(loc).Format("Jan 2, 2006 15:04")
+		},
+	}
+
+	var header = path.Join("views", "general", "header.html")
+	var filepath = path.Join("views", "main", "trash.html")
+
+	temp, err := template.New("trash.html").Funcs(funcMap).ParseFS(t.embed, filepath, header)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+		return
+	}
+
+	err = temp.Execute(c.Writer, dataTemplate)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/client/modal?status=error&message="+err.Error())
+	}
+}
+
+// TrashRestoreProcess restores an entry, answering one that cannot be with
+// 409 and the reason.
+func (t *trashWeb) TrashRestoreProcess(c *gin.Context) {
+	session, err := currentSession(c, t.sessionService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	trashID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trash entry ID"})
+		return
+	}
+
+	if err := t.trashClient.RestoreTrash(session.Token, trashID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Restored successfully"})
+}
+
+func (t *trashWeb) TrashPurgeProcess(c *gin.Context) {
+	session, err := currentSession(c, t.sessionService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	trashID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trash entry ID"})
+		return
+	}
+
+	if err := t.trashClient.PurgeTrash(session.Token, trashID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted permanently"})
+}
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"a21hc3NpZ25tZW50/client"
+	"a21hc3NpZ25tZW50/model"
+)
+
+// userViews are the saved views of the user. A failure leaves them out of
+// the page rather than failing it.
+func userViews(viewClient client.ViewClient, token string) []model.SavedViewResponse {
+	views, err := viewClient.ViewList(token)
+	if err != nil {
+		return nil
+	}
+	return views
+}
+
+// pinnedViews are the views linked from the navigation.
+func pinnedViews(views []model.SavedViewResponse) []model.SavedViewResponse {
+	var pinned []model.SavedViewResponse
+	for _, view := range views {
+		if view.Pinned {
+			pinned = append(pinned, view)
+		}
+	}
+	return pinned
+}
+
+// viewName is the name of the view among views with query, if any.
+func viewName(views []model.SavedViewResponse, query string) string {
+	current := model.SavedView{Name: "current", Query: query}
+	if current.Validate() != nil {
+		return ""
+	}
+	for _, view := range views {
+		if view.Query == current.Query {
+			return view.Name
+		}
+	}
+	return ""
+}
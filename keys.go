@@ -0,0 +1,84 @@
+package main
+
+import (
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/model"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runKeysCommand handles `keys rotate` and `keys list`, the admin commands
+// for the JWT key file. It returns the process exit code.
+func runKeysCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keys rotate [-grace 1h] | keys list")
+		return 2
+	}
+
+	path := config.KeysFilePath()
+
+	switch args[0] {
+	case "rotate":
+		fs := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+		grace := fs.Duration("grace", time.Hour, "how long the previous key keeps verifying tokens")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		if *grace < 0 {
+			fmt.Fprintln(os.Stderr, "grace must not be negative")
+			return 2
+		}
+
+		// Without a key file there is nothing to retire, rotation creates one
+		var set config.KeySet
+		if _, err := os.Stat(path); err == nil {
+			set, err = config.LoadKeySet(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+
+		rotated, key, err := config.RotateKeySet(set, *grace)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if err := config.SaveKeySet(path, rotated); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		fmt.Printf("New signing key %s written to %s\n", key.ID, path)
+		// Access tokens signed just before the rotation should outlive the old key
+		if *grace < model.AccessTokenTTL {
+			fmt.Printf("Warning: grace %s is shorter than the access token lifetime, some users will have to log in again\n", *grace)
+		}
+		return 0
+
+	case "list":
+		set, err := config.LoadKeySet(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		for _, key := range set.Keys {
+			state := "verify until " + key.RetiresAt.Format(time.RFC3339)
+			if key.ID == set.Current {
+				state = "current"
+			} else if !key.RetiresAt.After(time.Now()) {
+				state = "retired"
+			}
+			fmt.Printf("%s\t%s\tcreated %s\n", key.ID, state, key.CreatedAt.Format(time.RFC3339))
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown keys command %q\n", args[0])
+	return 2
+}
@@ -2,7 +2,12 @@ package main
 
 import (
 	"a21hc3NpZ25tZW50/client"
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/db/blob"
 	"a21hc3NpZ25tZW50/db/filebased"
+	"a21hc3NpZ25tZW50/db/memory"
+	"a21hc3NpZ25tZW50/db/postgres"
 	"a21hc3NpZ25tZW50/handler/api"
 	"a21hc3NpZ25tZW50/handler/web"
 	"a21hc3NpZ25tZW50/middleware"
@@ -11,16 +16,26 @@ import (
 	"embed"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
+	_ "time/tzdata" // IANA zones for user timezones on hosts without zoneinfo
 
 	"github.com/gin-gonic/gin"
 )
 
 type APIHandler struct {
-	UserAPIHandler     api.UserAPI
-	CategoryAPIHandler api.CategoryAPI
-	TaskAPIHandler     api.TaskAPI
+	UserAPIHandler       api.UserAPI
+	CategoryAPIHandler   api.CategoryAPI
+	TaskAPIHandler       api.TaskAPI
+	WorkflowAPIHandler   api.WorkflowAPI
+	TagAPIHandler        api.TagAPI
+	CommentAPIHandler    api.CommentAPI
+	AttachmentAPIHandler api.AttachmentAPI
+	TimeEntryAPIHandler  api.TimeEntryAPI
+	SearchAPIHandler     api.SearchAPI
+	ViewAPIHandler       api.ViewAPI
+	TrashAPIHandler      api.TrashAPI
 }
 
 type ClientHandler struct {
@@ -30,12 +45,24 @@ type ClientHandler struct {
 	TaskWeb      web.TaskWeb
 	CategoryWeb  web.CategoryWeb
 	ModalWeb     web.ModalWeb
+	TrashWeb     web.TrashWeb
 }
 
 //go:embed views/*
 var Resources embed.FS
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		os.Exit(runKeysCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(os.Args[2:]))
+	}
+
+	if err := config.JwtKeys.Load(); err != nil {
+		panic(err)
+	}
+
 	gin.SetMode(gin.ReleaseMode) //release
 
 	wg := sync.WaitGroup{}
@@ -55,49 +82,164 @@ func main() {
 		}))
 		router.Use(gin.Recovery())
 
-		filebasedDb, err := filebased.InitDB()
-
-		if err != nil {
-			panic(err)
+		// The bbolt file is only opened when it is the configured backend
+		var filebasedDb *filebased.Data
+		if config.DBDriver() == "bbolt" {
+			var err error
+			filebasedDb, err = filebased.InitDB()
+			if err != nil {
+				panic(err)
+			}
 		}
 
 		router = RunServer(router, filebasedDb)
 		router = RunClient(router, Resources, filebasedDb)
+		go RunTrashPurge(filebasedDb)
 
 		fmt.Println("Server is running on port 8080")
-		err = router.Run(":8080")
+		router.Run(":8080")
 
 	}()
 
 	wg.Wait()
 }
 
-func RunServer(gin *gin.Engine, filebasedDb *filebased.Data) *gin.Engine {
-	userRepo := repo.NewUserRepo(filebasedDb)
-	sessionRepo := repo.NewSessionsRepo(filebasedDb)
-	categoryRepo := repo.NewCategoryRepo(filebasedDb)
-	taskRepo := repo.NewTaskRepo(filebasedDb)
+var (
+	sharedStoreOnce sync.Once
+	sharedStore     db.Store
+)
 
-	userService := service.NewUserService(userRepo, sessionRepo)
-	categoryService := service.NewCategoryService(categoryRepo)
-	taskService := service.NewTaskService(taskRepo)
+// openStore returns the storage backend named by APP_DB_DRIVER. The Postgres
+// pool and the memory store are opened once and shared by RunServer and
+// RunClient.
+func openStore(filebasedDb *filebased.Data) db.Store {
+	switch config.DBDriver() {
+	case "bbolt":
+		return filebasedDb
+	case "postgres":
+		sharedStoreOnce.Do(func() {
+			postgresDb, err := postgres.InitDB(config.PostgresCredential())
+			if err != nil {
+				panic(err)
+			}
+			sharedStore = postgresDb
+		})
+		return sharedStore
+	case "memory":
+		sharedStoreOnce.Do(func() {
+			sharedStore = memory.InitDB()
+		})
+		return sharedStore
+	}
+
+	panic(fmt.Sprintf("unknown APP_DB_DRIVER %q, use bbolt, postgres or memory", config.DBDriver()))
+}
 
-	// Delete "acv" category if it exists
-	err := categoryService.DeleteByName("acv")
+// RunTrashPurge empties the trash of entries older than the retention every
+// purge interval, until the process exits.
+func RunTrashPurge(filebasedDb *filebased.Data) {
+	store := openStore(filebasedDb)
+	attachmentService := service.NewAttachmentService(repo.NewAttachmentRepo(store), openBlobs(), config.AttachmentMaxSize(), config.AttachmentQuota())
+	trashService := service.NewTrashService(repo.NewTrashRepo(store), attachmentService, config.TrashRetention())
+
+	ticker := time.NewTicker(config.TrashPurgeInterval())
+	defer ticker.Stop()
+	for {
+		purged, err := trashService.PurgeExpired(time.Now())
+		if err != nil {
+			fmt.Printf("Warning: purging trash: %v\n", err)
+		} else if purged > 0 {
+			fmt.Printf("Purged %d trash entries\n", purged)
+		}
+		<-ticker.C
+	}
+}
+
+var (
+	sharedBlobsOnce sync.Once
+	sharedBlobs     blob.Store
+)
+
+// openBlobs returns the blob store named by APP_BLOB_STORE. A bbolt file can
+// only be opened once, so that store is shared like the Postgres pool.
+func openBlobs() blob.Store {
+	if config.BlobStore() == "bbolt" {
+		sharedBlobsOnce.Do(func() {
+			blobs, err := blob.New()
+			if err != nil {
+				panic(err)
+			}
+			sharedBlobs = blobs
+		})
+		return sharedBlobs
+	}
+
+	blobs, err := blob.New()
 	if err != nil {
-		fmt.Printf("Warning: Could not delete 'acv' category: %v\n", err)
-	} else {
-		fmt.Println("Successfully removed 'acv' category")
+		panic(err)
+	}
+	return blobs
+}
+
+func RunServer(gin *gin.Engine, filebasedDb *filebased.Data) *gin.Engine {
+	// Sessions record the client IP, so a forwarded one is only taken from
+	// the web front end
+	if err := gin.SetTrustedProxies(config.TrustedProxies()); err != nil {
+		panic(err)
 	}
 
+	store := openStore(filebasedDb)
+
+	userRepo := repo.NewUserRepo(store)
+	sessionRepo := repo.NewSessionsRepo(store)
+	categoryRepo := repo.NewCategoryRepo(store)
+	taskRepo := repo.NewTaskRepo(store)
+	workflowRepo := repo.NewWorkflowRepo(store)
+	dependencyRepo := repo.NewDependencyRepo(store)
+	tagRepo := repo.NewTagRepo(store)
+	commentRepo := repo.NewCommentRepo(store)
+	attachmentRepo := repo.NewAttachmentRepo(store)
+	timeEntryRepo := repo.NewTimeEntryRepo(store)
+	searchRepo := repo.NewSearchRepo(store)
+	viewRepo := repo.NewViewRepo(store)
+	trashRepo := repo.NewTrashRepo(store)
+
+	userService := service.NewUserService(userRepo, sessionRepo)
+	categoryService := service.NewCategoryService(categoryRepo)
+	taskService := service.NewTaskService(taskRepo, workflowRepo, dependencyRepo, tagRepo)
+	workflowService := service.NewWorkflowService(workflowRepo)
+	tagService := service.NewTagService(tagRepo)
+	viewService := service.NewViewService(viewRepo)
+	commentService := service.NewCommentService(commentRepo)
+	timeEntryService := service.NewTimeEntryService(timeEntryRepo, taskRepo, categoryRepo)
+	searchService := service.NewSearchService(searchRepo, taskRepo)
+	attachmentService := service.NewAttachmentService(attachmentRepo, openBlobs(), config.AttachmentMaxSize(), config.AttachmentQuota())
+	trashService := service.NewTrashService(trashRepo, attachmentService, config.TrashRetention())
+
 	userAPIHandler := api.NewUserAPI(userService)
 	categoryAPIHandler := api.NewCategoryAPI(categoryService)
-	taskAPIHandler := api.NewTaskAPI(taskService)
+	taskAPIHandler := api.NewTaskAPI(taskService, userService, attachmentService)
+	workflowAPIHandler := api.NewWorkflowAPI(workflowService)
+	tagAPIHandler := api.NewTagAPI(tagService)
+	viewAPIHandler := api.NewViewAPI(viewService)
+	commentAPIHandler := api.NewCommentAPI(commentService, taskService)
+	attachmentAPIHandler := api.NewAttachmentAPI(attachmentService, taskService)
+	timeEntryAPIHandler := api.NewTimeEntryAPI(timeEntryService, taskService, userService)
+	searchAPIHandler := api.NewSearchAPI(searchService, userService)
+	trashAPIHandler := api.NewTrashAPI(trashService)
 
 	apiHandler := APIHandler{
-		UserAPIHandler:     userAPIHandler,
-		CategoryAPIHandler: categoryAPIHandler,
-		TaskAPIHandler:     taskAPIHandler,
+		UserAPIHandler:       userAPIHandler,
+		CategoryAPIHandler:   categoryAPIHandler,
+		TaskAPIHandler:       taskAPIHandler,
+		WorkflowAPIHandler:   workflowAPIHandler,
+		TagAPIHandler:        tagAPIHandler,
+		CommentAPIHandler:    commentAPIHandler,
+		AttachmentAPIHandler: attachmentAPIHandler,
+		TimeEntryAPIHandler:  timeEntryAPIHandler,
+		SearchAPIHandler:     searchAPIHandler,
+		ViewAPIHandler:       viewAPIHandler,
+		TrashAPIHandler:      trashAPIHandler,
 	}
 
 	version := gin.Group("/api/v1")
@@ -106,19 +248,45 @@ func RunServer(gin *gin.Engine, filebasedDb *filebased.Data) *gin.Engine {
 		{
 			user.POST("/login", apiHandler.UserAPIHandler.Login)
 			user.POST("/register", apiHandler.UserAPIHandler.Register)
+			user.POST("/refresh", apiHandler.UserAPIHandler.Refresh)
 			user.GET("/list", apiHandler.UserAPIHandler.ListUsers) // endpoint debug, tanpa auth
 
-			user.Use(middleware.Auth())
+			user.Use(middleware.Auth(sessionRepo))
+			user.POST("/logout", apiHandler.UserAPIHandler.Logout)
+			user.POST("/logout/all", apiHandler.UserAPIHandler.LogoutAll)
+			user.GET("/sessions", apiHandler.UserAPIHandler.GetSessions)
+			user.DELETE("/sessions/:id", apiHandler.UserAPIHandler.RevokeSession)
+			user.PUT("/timezone", apiHandler.UserAPIHandler.SetTimezone)
 			// user.GET("/profile/:email", apiHandler.UserAPIHandler.GetUserProfile) // Nonaktifkan untuk sementara
 			user.GET("/tasks", apiHandler.UserAPIHandler.GetUserTaskCategory)
 		}
 
 		task := version.Group("/task")
 		{
-			task.Use(middleware.Auth())
+			task.Use(middleware.Auth(sessionRepo))
 			task.POST("/add", apiHandler.TaskAPIHandler.AddTask)
 			task.GET("/get/:id", apiHandler.TaskAPIHandler.GetTaskByID)
 			task.PUT("/update/:id", apiHandler.TaskAPIHandler.UpdateTask)
+			task.PATCH("/:id", apiHandler.TaskAPIHandler.PatchTask)
+			task.POST("/bulk", apiHandler.TaskAPIHandler.BulkTasks)
+			task.PUT("/:id/future", apiHandler.TaskAPIHandler.UpdateFuture)
+			task.POST("/:id/occurrences", apiHandler.TaskAPIHandler.GenerateOccurrences)
+			task.POST("/:id/transition", apiHandler.TaskAPIHandler.TransitionTask)
+			task.POST("/:id/dependencies", apiHandler.TaskAPIHandler.AddDependency)
+			task.DELETE("/:id/dependencies/:blocked_by_id", apiHandler.TaskAPIHandler.RemoveDependency)
+			task.POST("/:id/comments", apiHandler.CommentAPIHandler.AddComment)
+			task.GET("/:id/comments", apiHandler.CommentAPIHandler.GetComments)
+			task.PUT("/:id/comments/:comment_id", apiHandler.CommentAPIHandler.UpdateComment)
+			task.DELETE("/:id/comments/:comment_id", apiHandler.CommentAPIHandler.DeleteComment)
+			task.POST("/:id/attachments", apiHandler.AttachmentAPIHandler.AddAttachment)
+			task.GET("/:id/attachments", apiHandler.AttachmentAPIHandler.GetAttachments)
+			task.GET("/:id/attachments/:attachment_id", apiHandler.AttachmentAPIHandler.GetAttachment)
+			task.DELETE("/:id/attachments/:attachment_id", apiHandler.AttachmentAPIHandler.DeleteAttachment)
+			task.POST("/:id/timer/start", apiHandler.TimeEntryAPIHandler.StartTimer)
+			task.POST("/:id/time", apiHandler.TimeEntryAPIHandler.AddTimeEntry)
+			task.GET("/:id/time", apiHandler.TimeEntryAPIHandler.GetTimeEntries)
+			task.PUT("/:id/time/:entry_id", apiHandler.TimeEntryAPIHandler.UpdateTimeEntry)
+			task.DELETE("/:id/time/:entry_id", apiHandler.TimeEntryAPIHandler.DeleteTimeEntry)
 			task.DELETE("/delete/:id", apiHandler.TaskAPIHandler.DeleteTask)
 			task.GET("/list", apiHandler.TaskAPIHandler.GetTaskList)
 			task.GET("/category/:id", apiHandler.TaskAPIHandler.GetTaskListByCategory)
@@ -126,38 +294,98 @@ func RunServer(gin *gin.Engine, filebasedDb *filebased.Data) *gin.Engine {
 
 		category := version.Group("/category")
 		{
-			category.Use(middleware.Auth())
+			category.Use(middleware.Auth(sessionRepo))
 			category.POST("/add", apiHandler.CategoryAPIHandler.AddCategory)
 			category.GET("/get/:id", apiHandler.CategoryAPIHandler.GetCategoryByID)
 			category.PUT("/update/:id", apiHandler.CategoryAPIHandler.UpdateCategory)
+			category.PATCH("/:id", apiHandler.CategoryAPIHandler.PatchCategory)
 			category.DELETE("/delete/:id", apiHandler.CategoryAPIHandler.DeleteCategory)
 			category.GET("/list", apiHandler.CategoryAPIHandler.GetCategoryList)
 		}
+
+		timer := version.Group("/timer")
+		{
+			timer.Use(middleware.Auth(sessionRepo))
+			timer.GET("", apiHandler.TimeEntryAPIHandler.GetTimer)
+			timer.POST("/stop", apiHandler.TimeEntryAPIHandler.StopTimer)
+		}
+
+		timesheet := version.Group("/timesheet")
+		{
+			timesheet.Use(middleware.Auth(sessionRepo))
+			timesheet.GET("", apiHandler.TimeEntryAPIHandler.GetTimesheet)
+		}
+
+		search := version.Group("/search")
+		{
+			search.Use(middleware.Auth(sessionRepo))
+			search.GET("", apiHandler.SearchAPIHandler.Search)
+		}
+
+		workflow := version.Group("/workflow")
+		{
+			workflow.Use(middleware.Auth(sessionRepo))
+			workflow.GET("", apiHandler.WorkflowAPIHandler.GetWorkflow)
+			workflow.PUT("", apiHandler.WorkflowAPIHandler.UpdateWorkflow)
+		}
+
+		tag := version.Group("/tag")
+		{
+			tag.Use(middleware.Auth(sessionRepo))
+			tag.POST("/add", apiHandler.TagAPIHandler.AddTag)
+			tag.GET("/get/:id", apiHandler.TagAPIHandler.GetTagByID)
+			tag.PUT("/update/:id", apiHandler.TagAPIHandler.UpdateTag)
+			tag.DELETE("/delete/:id", apiHandler.TagAPIHandler.DeleteTag)
+			tag.GET("/list", apiHandler.TagAPIHandler.GetTagList)
+		}
+
+		view := version.Group("/view")
+		{
+			view.Use(middleware.Auth(sessionRepo))
+			view.POST("/add", apiHandler.ViewAPIHandler.AddView)
+			view.GET("/get/:id", apiHandler.ViewAPIHandler.GetViewByID)
+			view.PUT("/update/:id", apiHandler.ViewAPIHandler.UpdateView)
+			view.DELETE("/delete/:id", apiHandler.ViewAPIHandler.DeleteView)
+			view.GET("/list", apiHandler.ViewAPIHandler.GetViewList)
+		}
+
+		trash := version.Group("/trash")
+		{
+			trash.Use(middleware.Auth(sessionRepo))
+			trash.GET("/list", apiHandler.TrashAPIHandler.GetTrashList)
+			trash.POST("/restore/:id", apiHandler.TrashAPIHandler.RestoreTrash)
+			trash.DELETE("/purge/:id", apiHandler.TrashAPIHandler.PurgeTrash)
+		}
 	}
 
 	return gin
 }
 
 func RunClient(gin *gin.Engine, embed embed.FS, filebasedDb *filebased.Data) *gin.Engine {
-	sessionRepo := repo.NewSessionsRepo(filebasedDb)
+	store := openStore(filebasedDb)
+
+	sessionRepo := repo.NewSessionsRepo(store)
 	sessionService := service.NewSessionService(sessionRepo)
 
-	userRepo := repo.NewUserRepo(filebasedDb)
+	userRepo := repo.NewUserRepo(store)
 	userService := service.NewUserService(userRepo, sessionRepo)
 
 	userClient := client.NewUserClient()
 	taskClient := client.NewTaskClient()
 	categoryClient := client.NewCategoryClient()
+	trashClient := client.NewTrashClient()
+	viewClient := client.NewViewClient()
 
 	authWeb := web.NewAuthWeb(userClient, sessionService, embed)
 	modalWeb := web.NewModalWeb(embed)
 	homeWeb := web.NewHomeWeb(embed)
-	dashboardWeb := web.NewDashboardWeb(sessionService, taskClient, userService, embed)
-	taskWeb := web.NewTaskWeb(taskClient, sessionService, userService, embed)
-	categoryWeb := web.NewCategoryWeb(categoryClient, sessionService, embed)
+	dashboardWeb := web.NewDashboardWeb(sessionService, taskClient, viewClient, userService, embed)
+	taskWeb := web.NewTaskWeb(taskClient, viewClient, sessionService, userService, embed)
+	categoryWeb := web.NewCategoryWeb(categoryClient, viewClient, sessionService, embed)
+	trashWeb := web.NewTrashWeb(trashClient, viewClient, sessionService, userService, embed)
 
 	client := ClientHandler{
-		authWeb, homeWeb, dashboardWeb, taskWeb, categoryWeb, modalWeb,
+		authWeb, homeWeb, dashboardWeb, taskWeb, categoryWeb, modalWeb, trashWeb,
 	}
 
 	gin.StaticFS("/static", http.Dir("frontend/public"))
@@ -171,21 +399,29 @@ func RunClient(gin *gin.Engine, embed embed.FS, filebasedDb *filebased.Data) *gi
 		user.POST("/login/process", client.AuthWeb.LoginProcess)
 		user.GET("/register", client.AuthWeb.Register)
 		user.POST("/register/process", client.AuthWeb.RegisterProcess)
+		user.GET("/refresh", client.AuthWeb.Refresh)
 
-		user.Use(middleware.Auth())
+		user.Use(middleware.Auth(sessionRepo))
 		user.GET("/logout", client.AuthWeb.Logout)
+		user.GET("/logout/all", client.AuthWeb.LogoutAll)
 	}
 
 	main := gin.Group("/client")
 	{
-		main.Use(middleware.Auth())
+		main.Use(middleware.Auth(sessionRepo))
 		main.GET("/dashboard", client.DashboardWeb.Dashboard)
 		main.GET("/task", client.TaskWeb.TaskPage)
 		main.POST("/task/add/process", client.TaskWeb.TaskAddProcess)
 		main.POST("/task/delete/:id", client.TaskWeb.TaskDeleteProcess)
+		main.POST("/task/check/:id", client.TaskWeb.TaskCheckProcess)
+		main.GET("/task/comments/:id", client.TaskWeb.TaskComments)
+		main.POST("/task/comment/:id", client.TaskWeb.TaskCommentProcess)
 		main.GET("/category", client.CategoryWeb.Category)
 		main.POST("/category/add/process", client.CategoryWeb.AddCategory)
 		main.POST("/category/delete/:id", client.CategoryWeb.DeleteCategory)
+		main.GET("/trash", client.TrashWeb.TrashPage)
+		main.POST("/trash/restore/:id", client.TrashWeb.TrashRestoreProcess)
+		main.POST("/trash/purge/:id", client.TrashWeb.TrashPurgeProcess)
 	}
 
 	modal := gin.Group("/client")
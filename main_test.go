@@ -2,19 +2,29 @@ package main_test
 
 import (
 	main "a21hc3NpZ25tZW50"
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/db/blob"
 	"a21hc3NpZ25tZW50/db/filebased"
+	"a21hc3NpZ25tZW50/db/memory"
+	"a21hc3NpZ25tZW50/db/postgres"
+	"a21hc3NpZ25tZW50/db/storetest"
 	"a21hc3NpZ25tZW50/middleware"
 	"a21hc3NpZ25tZW50/model"
 	repo "a21hc3NpZ25tZW50/repository"
 	"a21hc3NpZ25tZW50/service"
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -23,6 +33,7 @@ import (
 	"github.com/golang-jwt/jwt"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"go.etcd.io/bbolt"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -69,6 +80,22 @@ func SetCookie(mux *gin.Engine) *http.Cookie {
 	return cookie
 }
 
+// unversioned drops the fields the store sets on every write, so results can be
+// compared with the fixtures they were stored from.
+func unversioned[T model.Task | model.Category](records []T) []T {
+	out := make([]T, len(records))
+	for i, r := range records {
+		switch v := any(&r).(type) {
+		case *model.Task:
+			v.Version, v.UpdatedAt = 0, time.Time{}
+		case *model.Category:
+			v.Version, v.UpdatedAt = 0, time.Time{}
+		}
+		out[i] = r
+	}
+	return out
+}
+
 var _ = Describe("Task Tracker Plus", Ordered, func() {
 	var apiServer *gin.Engine
 
@@ -93,6 +120,8 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 	BeforeEach(func() {
 		gin.SetMode(gin.ReleaseMode) //release
 		Expect(os.Setenv("APP_DB_PATH", testDBPath)).To(Succeed())
+		Expect(os.Setenv("JWT_SECRET", "test-secret-key-at-least-32-bytes")).To(Succeed())
+		Expect(os.Setenv("APP_BLOB_DIR", GinkgoT().TempDir())).To(Succeed())
 
 		os.Remove(testDBPath)
 
@@ -106,7 +135,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 		userService = service.NewUserService(userRepo, sessionRepo)
 		sessionService = service.NewSessionService(sessionRepo)
 		categoryService = service.NewCategoryService(categoryRepo)
-		taskService = service.NewTaskService(taskRepo)
+		taskService = service.NewTaskService(taskRepo, repo.NewWorkflowRepo(filebasedDb), repo.NewDependencyRepo(filebasedDb), repo.NewTagRepo(filebasedDb))
 
 		Expect(err).ShouldNot(HaveOccurred())
 
@@ -119,7 +148,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 				Fullname: "test",
 				Email:    "test@mail.com",
 				Task:     "Task 2",
-				Deadline: "2023-06-01",
+				Deadline: model.DateDeadline(2023, 6, 1),
 				Priority: 1,
 				Status:   "Completed",
 				Category: "Category 2",
@@ -129,7 +158,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 				Fullname: "test",
 				Email:    "test@mail.com",
 				Task:     "Task 5",
-				Deadline: "2023-06-07",
+				Deadline: model.DateDeadline(2023, 6, 7),
 				Priority: 5,
 				Status:   "In Progress",
 				Category: "Category 3",
@@ -154,7 +183,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 			{
 				ID:         1,
 				Title:      "Task 1",
-				Deadline:   "2023-05-30",
+				Deadline:   model.DateDeadline(2023, 5, 30),
 				Priority:   2,
 				Status:     "In Progress",
 				CategoryID: 1,
@@ -163,7 +192,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 			{
 				ID:         2,
 				Title:      "Task 2",
-				Deadline:   "2023-06-01",
+				Deadline:   model.DateDeadline(2023, 6, 1),
 				Priority:   1,
 				Status:     "Completed",
 				CategoryID: 2,
@@ -172,7 +201,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 			{
 				ID:         3,
 				Title:      "Task 3",
-				Deadline:   "2023-06-02",
+				Deadline:   model.DateDeadline(2023, 6, 2),
 				Priority:   4,
 				Status:     "Completed",
 				CategoryID: 1,
@@ -181,7 +210,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 			{
 				ID:         4,
 				Title:      "Task 4",
-				Deadline:   "2023-06-02",
+				Deadline:   model.DateDeadline(2023, 6, 2),
 				Priority:   3,
 				Status:     "Completed",
 				CategoryID: 1,
@@ -190,7 +219,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 			{
 				ID:         5,
 				Title:      "Task 5",
-				Deadline:   "2023-06-07",
+				Deadline:   model.DateDeadline(2023, 6, 7),
 				Priority:   5,
 				Status:     "In Progress",
 				CategoryID: 3,
@@ -223,6 +252,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 		filebasedDb.DB.Close()
 		os.Remove(testDBPath)
 		Expect(os.Unsetenv("APP_DB_PATH")).To(Succeed())
+		Expect(os.Unsetenv("APP_BLOB_DIR")).To(Succeed())
 	})
 
 	Describe("Auth Middleware", func() {
@@ -239,12 +269,16 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 		When("valid token is provided", func() {
 			It("should set user Email in context and call next middleware", func() {
 				claims := &model.Claims{Email: "aditira@gmail.com"}
-				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-				signedToken, _ := token.SignedString(model.JwtKey)
+				signedToken, _ := config.JwtKeys.Sign(claims)
+				Expect(sessionRepo.AddSessions(model.Session{
+					Token:  signedToken,
+					Email:  "aditira@gmail.com",
+					Expiry: time.Now().Add(time.Hour),
+				})).To(Succeed())
 				req, _ := http.NewRequest(http.MethodGet, "/", nil)
 				req.AddCookie(&http.Cookie{Name: "session_token", Value: signedToken})
 
-				router.Use(middleware.Auth())
+				router.Use(middleware.Auth(sessionRepo))
 				router.GET("/", func(ctx *gin.Context) {
 					Email := ctx.MustGet("email").(string)
 					Expect(Email).To(Equal("aditira@gmail.com"))
@@ -255,11 +289,143 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 			})
 		})
 
+		When("expired token is provided", func() {
+			It("should return token expired error response", func() {
+				claims := &model.Claims{
+					Email:          "aditira@gmail.com",
+					StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(-time.Minute).Unix()},
+				}
+				signedToken, _ := config.JwtKeys.Sign(claims)
+				req, _ := http.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Content-Type", "application/json")
+				req.AddCookie(&http.Cookie{Name: "session_token", Value: signedToken})
+
+				router.Use(middleware.Auth(sessionRepo))
+				router.GET("/", func(ctx *gin.Context) {
+					Fail("handler should not be reached with an expired token")
+				})
+
+				router.ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusUnauthorized))
+
+				var errResp model.ErrorResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &errResp)).Should(Succeed())
+				Expect(errResp.Error).To(Equal(model.ErrTokenExpired.Error()))
+			})
+		})
+
+		When("token has no session behind it", func() {
+			It("should return session revoked error response", func() {
+				claims := &model.Claims{Email: "aditira@gmail.com"}
+				signedToken, _ := config.JwtKeys.Sign(claims)
+				req, _ := http.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Content-Type", "application/json")
+				req.AddCookie(&http.Cookie{Name: "session_token", Value: signedToken})
+
+				router.Use(middleware.Auth(sessionRepo))
+				router.GET("/", func(ctx *gin.Context) {
+					Fail("handler should not be reached without a session")
+				})
+
+				router.ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusUnauthorized))
+
+				var errResp model.ErrorResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &errResp)).Should(Succeed())
+				Expect(errResp.Error).To(Equal(model.ErrSessionRevoked.Error()))
+			})
+		})
+
+		When("token is signed with the old hard-coded key", func() {
+			It("should reject the forged token", func() {
+				claims := &model.Claims{Email: "aditira@gmail.com"}
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+				signedToken, _ := token.SignedString([]byte("secret-key"))
+				Expect(sessionRepo.AddSessions(model.Session{
+					Token:  signedToken,
+					Email:  "aditira@gmail.com",
+					Expiry: time.Now().Add(time.Hour),
+				})).To(Succeed())
+				req, _ := http.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Content-Type", "application/json")
+				req.AddCookie(&http.Cookie{Name: "session_token", Value: signedToken})
+
+				router.Use(middleware.Auth(sessionRepo))
+				router.GET("/", func(ctx *gin.Context) {
+					Fail("handler should not be reached with a forged token")
+				})
+
+				router.ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		When("signing keys are rotated", func() {
+			var keysPath string
+
+			BeforeEach(func() {
+				keysPath = fmt.Sprintf("%s/jwt-keys.json", GinkgoT().TempDir())
+				set, err := config.NewKeySet()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(config.SaveKeySet(keysPath, set)).To(Succeed())
+			})
+
+			It("should keep verifying the previous key during the grace period", func() {
+				claims := &model.Claims{Email: "aditira@gmail.com"}
+				oldToken, err := config.NewKeyRing(keysPath).Sign(claims)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				set, err := config.LoadKeySet(keysPath)
+				Expect(err).ShouldNot(HaveOccurred())
+				rotated, key, err := config.RotateKeySet(set, time.Hour)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(config.SaveKeySet(keysPath, rotated)).To(Succeed())
+
+				ring := config.NewKeyRing(keysPath)
+				newToken, err := ring.Sign(claims)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				parsed, err := jwt.ParseWithClaims(newToken, &model.Claims{}, ring.Keyfunc)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(parsed.Header["kid"]).To(Equal(key.ID))
+
+				_, err = jwt.ParseWithClaims(oldToken, &model.Claims{}, ring.Keyfunc)
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should reject tokens signed with a retired key", func() {
+				claims := &model.Claims{Email: "aditira@gmail.com"}
+				oldToken, err := config.NewKeyRing(keysPath).Sign(claims)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				set, err := config.LoadKeySet(keysPath)
+				Expect(err).ShouldNot(HaveOccurred())
+				rotated, _, err := config.RotateKeySet(set, 0)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(config.SaveKeySet(keysPath, rotated)).To(Succeed())
+
+				_, err = jwt.ParseWithClaims(oldToken, &model.Claims{}, config.NewKeyRing(keysPath).Keyfunc)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("JWT_SECRET is shorter than 32 bytes", func() {
+			It("should fail to load the keys", func() {
+				secret := os.Getenv("JWT_SECRET")
+				DeferCleanup(os.Setenv, "JWT_SECRET", secret)
+
+				Expect(os.Setenv("JWT_SECRET", "short-secret")).To(Succeed())
+				Expect((&config.KeyRing{}).Load()).To(MatchError("JWT_SECRET is shorter than 32 bytes"))
+				Expect(os.Setenv("JWT_SECRET", secret)).To(Succeed())
+				Expect((&config.KeyRing{}).Load()).To(Succeed())
+			})
+		})
+
 		When("session token is missing", func() {
 			It("should return unauthorized error response", func() {
 				req, _ := http.NewRequest(http.MethodGet, "/", nil)
 
-				router.Use(middleware.Auth())
+				router.Use(middleware.Auth(sessionRepo))
 
 				router.ServeHTTP(w, req)
 				Expect(w.Code).To(Equal(http.StatusSeeOther))
@@ -271,7 +437,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 				req, _ := http.NewRequest(http.MethodGet, "/", nil)
 				req.AddCookie(&http.Cookie{Name: "session_token", Value: "invalid_token"})
 
-				router.Use(middleware.Auth())
+				router.Use(middleware.Auth(sessionRepo))
 
 				router.ServeHTTP(w, req)
 				Expect(w.Code).To(Equal(http.StatusBadRequest))
@@ -447,7 +613,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 
 			When("deleting a category with a valid category ID", func() {
 				It("should delete the category from the database without returning an error", func() {
-					err = categoryRepo.Delete(2)
+					err = categoryRepo.Delete(2, model.CategoryDelete{Mode: model.CategoryDeleteCascade})
 					Expect(err).ShouldNot(HaveOccurred())
 
 					result, err := categoryRepo.GetByID(2)
@@ -471,7 +637,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 						model.Category{ID: 10, Name: "Home", UserID: 1},
 					)
 
-					Expect(results).To(ConsistOf(expectedCategories))
+					Expect(unversioned(results)).To(ConsistOf(expectedCategories))
 				})
 			})
 
@@ -483,7 +649,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 					newTask := model.Task{
 						ID:         1,
 						Title:      "Updated with Repository Task 1",
-						Deadline:   "2023-05-30",
+						Deadline:   model.DateDeadline(2023, 5, 30),
 						Priority:   2,
 						CategoryID: 1,
 						Status:     "In Progress",
@@ -518,7 +684,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 					Expect(err).ShouldNot(HaveOccurred())
 					Expect(results).To(HaveLen(2))
 
-					Expect(results).To(Equal([]model.Task{insertTasks[1], insertTasks[4]}))
+					Expect(unversioned(results)).To(Equal([]model.Task{insertTasks[1], insertTasks[4]}))
 				})
 			})
 
@@ -590,7 +756,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 			Describe("Delete", func() {
 				When("deleting a category from the database", func() {
 					It("should delete the category without any errors", func() {
-						err := categoryService.Delete(3)
+						err := categoryService.Delete(3, model.CategoryDelete{Mode: model.CategoryDeleteCascade})
 						Expect(err).ShouldNot(HaveOccurred())
 					})
 				})
@@ -612,7 +778,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 							model.Category{ID: 10, Name: "Home", UserID: 1},
 						)
 
-						Expect(categories).To(ConsistOf(expectedCategories))
+						Expect(unversioned(categories)).To(ConsistOf(expectedCategories))
 					})
 				})
 			})
@@ -625,7 +791,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 						task := &model.Task{
 							ID:         1,
 							Title:      "Updated with Service Task 1",
-							Deadline:   "2023-05-30",
+							Deadline:   model.DateDeadline(2023, 5, 30),
 							Priority:   5,
 							CategoryID: 1,
 							Status:     "In Progress",
@@ -651,7 +817,58 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 					It("should return the list of tasks without any errors", func() {
 						tasks, err := taskService.GetList(1)
 						Expect(err).ShouldNot(HaveOccurred())
-						Expect(tasks).To(Equal([]model.Task{insertTasks[1], insertTasks[4]}))
+						Expect(unversioned(tasks)).To(Equal([]model.Task{insertTasks[1], insertTasks[4]}))
+					})
+				})
+			})
+
+			Describe("Generate", func() {
+				deadlines := func(tasks []model.Task) []string {
+					var out []string
+					for _, t := range tasks {
+						out = append(out, t.Deadline.String())
+					}
+					return out
+				}
+
+				store := func(deadline model.Deadline, r model.Recurrence) int {
+					task := model.Task{Title: "Report", Deadline: deadline, Priority: 1, Status: "Not Started", CategoryID: 3, UserID: 1, Recurrence: &r}
+					Expect(taskService.Store(&task)).To(Succeed())
+					tasks, err := taskService.GetList(1)
+					Expect(err).ShouldNot(HaveOccurred())
+					return tasks[len(tasks)-1].ID
+				}
+
+				When("the rule repeats on weekdays", func() {
+					It("should follow the weekdays and skip weeks by the interval", func() {
+						id := store(model.DateDeadline(2023, 6, 5), model.Recurrence{Freq: model.RecurWeekly, ByDay: []string{"MO", "TH"}, Interval: 2})
+						tasks, err := taskService.Generate(id, 3)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(deadlines(tasks)).To(Equal([]string{"2023-06-08", "2023-06-19", "2023-06-22"}))
+						Expect(tasks[0].SeriesID).To(Equal(id))
+						Expect(tasks[0].Status).To(Equal("Not Started"))
+					})
+				})
+
+				When("the rule repeats monthly from the 31st", func() {
+					It("should use the last day of shorter months", func() {
+						id := store(model.DateDeadline(2023, 1, 31), model.Recurrence{Freq: model.RecurMonthly})
+						tasks, err := taskService.Generate(id, 3)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(deadlines(tasks)).To(Equal([]string{"2023-02-28", "2023-03-31", "2023-04-30"}))
+
+						// a second call continues after the last occurrence
+						tasks, err = taskService.Generate(id, 1)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(deadlines(tasks)).To(Equal([]string{"2023-05-31"}))
+					})
+				})
+
+				When("the rule counts from completion", func() {
+					It("should refuse to create occurrences ahead", func() {
+						id := store(model.DateDeadline(2023, 6, 5), model.Recurrence{Freq: model.RecurDaily, Interval: 3, AfterCompletion: true})
+						_, err := taskService.Generate(id, 1)
+						Expect(err).To(MatchError(model.ErrInvalidRecurrence))
 					})
 				})
 			})
@@ -716,6 +933,323 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 				})
 			})
 
+			Describe("Refresh", func() {
+				login := func() (access, refresh string) {
+					body, _ := json.Marshal(model.UserLogin{Email: "test@mail.com", Password: "testing123"})
+					w := httptest.NewRecorder()
+					r := httptest.NewRequest("POST", "/api/v1/user/login", bytes.NewReader(body))
+					r.Header.Set("Content-Type", "application/json")
+					apiServer.ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusOK))
+
+					for _, c := range w.Result().Cookies() {
+						switch c.Name {
+						case "session_token":
+							access = c.Value
+						case "refresh_token":
+							refresh = c.Value
+						}
+					}
+					return access, refresh
+				}
+
+				refresh := func(token string) *httptest.ResponseRecorder {
+					body, _ := json.Marshal(model.RefreshRequest{RefreshToken: token})
+					w := httptest.NewRecorder()
+					r := httptest.NewRequest("POST", "/api/v1/user/refresh", bytes.NewReader(body))
+					r.Header.Set("Content-Type", "application/json")
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				When("login succeeds", func() {
+					It("should issue an expiring access token and a refresh token", func() {
+						access, refreshToken := login()
+						Expect(refreshToken).NotTo(BeEmpty())
+
+						claims := &model.Claims{}
+						_, err := jwt.ParseWithClaims(access, claims, config.JwtKeys.Keyfunc)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(claims.ExpiresAt).To(BeNumerically(">", time.Now().Unix()))
+						Expect(claims.ExpiresAt).To(BeNumerically("<=", time.Now().Add(model.AccessTokenTTL).Unix()))
+					})
+				})
+
+				When("exchanging a valid refresh token", func() {
+					It("should rotate both tokens", func() {
+						access, refreshToken := login()
+
+						w := refresh(refreshToken)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						var newAccess, newRefresh string
+						for _, c := range w.Result().Cookies() {
+							switch c.Name {
+							case "session_token":
+								newAccess = c.Value
+							case "refresh_token":
+								newRefresh = c.Value
+							}
+						}
+						Expect(newAccess).NotTo(BeEmpty())
+						Expect(newAccess).NotTo(Equal(access))
+						Expect(newRefresh).NotTo(BeEmpty())
+						Expect(newRefresh).NotTo(Equal(refreshToken))
+					})
+				})
+
+				When("reusing a refresh token that was already rotated", func() {
+					It("should revoke the whole token family", func() {
+						_, refreshToken := login()
+
+						w := refresh(refreshToken)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						var newRefresh string
+						for _, c := range w.Result().Cookies() {
+							if c.Name == "refresh_token" {
+								newRefresh = c.Value
+							}
+						}
+
+						w = refresh(refreshToken)
+						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+
+						var errResp model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &errResp)).Should(Succeed())
+						Expect(errResp.Error).To(Equal("refresh token reuse detected"))
+
+						w = refresh(newRefresh)
+						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+					})
+				})
+
+				When("logging out after a refresh", func() {
+					It("should delete the sessions it was rotated from too", func() {
+						_, refreshToken := login()
+
+						w := refresh(refreshToken)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var access string
+						for _, c := range w.Result().Cookies() {
+							if c.Name == "session_token" {
+								access = c.Value
+							}
+						}
+
+						r := httptest.NewRequest("POST", "/api/v1/user/logout", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(&http.Cookie{Name: "session_token", Value: access})
+						w = httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						// The rotated token is gone rather than seen as reused
+						w = refresh(refreshToken)
+						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+						var errResp model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &errResp)).Should(Succeed())
+						Expect(errResp.Error).To(Equal("invalid refresh token"))
+					})
+				})
+			})
+
+			Describe("Logout", func() {
+				When("logging out with a valid session", func() {
+					It("should revoke the token server-side", func() {
+						cookie := SetCookie(apiServer)
+
+						r, _ := http.NewRequest("POST", "/api/v1/user/logout", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(cookie)
+						w := httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						r, _ = http.NewRequest("GET", "/api/v1/task/list", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(cookie)
+						w = httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+					})
+				})
+
+				When("logging out everywhere", func() {
+					It("should revoke every session of the user", func() {
+						first := SetCookie(apiServer)
+						second := SetCookie(apiServer)
+
+						r, _ := http.NewRequest("POST", "/api/v1/user/logout/all", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(second)
+						w := httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						for _, cookie := range []*http.Cookie{first, second} {
+							r, _ = http.NewRequest("GET", "/api/v1/task/list", nil)
+							r.Header.Set("Content-Type", "application/json")
+							r.AddCookie(cookie)
+							w = httptest.NewRecorder()
+							apiServer.ServeHTTP(w, r)
+							Expect(w.Code).To(Equal(http.StatusUnauthorized))
+						}
+					})
+				})
+			})
+
+			Describe("Sessions", func() {
+				When("the user is logged in on two devices", func() {
+					It("should keep both sessions and list them", func() {
+						laptop := SetCookie(apiServer)
+						phone := SetCookie(apiServer)
+
+						r, _ := http.NewRequest("GET", "/api/v1/user/sessions", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(phone)
+						w := httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						var sessions []model.SessionInfo
+						Expect(json.Unmarshal(w.Body.Bytes(), &sessions)).Should(Succeed())
+						Expect(sessions).To(HaveLen(2))
+						Expect(w.Body.String()).NotTo(ContainSubstring(laptop.Value))
+
+						current := 0
+						for _, session := range sessions {
+							if session.Current {
+								current++
+							}
+						}
+						Expect(current).To(Equal(1))
+					})
+				})
+
+				When("revoking another device", func() {
+					It("should log that device out only", func() {
+						laptop := SetCookie(apiServer)
+						phone := SetCookie(apiServer)
+
+						r, _ := http.NewRequest("GET", "/api/v1/user/sessions", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(phone)
+						w := httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+
+						var sessions []model.SessionInfo
+						Expect(json.Unmarshal(w.Body.Bytes(), &sessions)).Should(Succeed())
+
+						var laptopID int
+						for _, session := range sessions {
+							if !session.Current {
+								laptopID = session.ID
+							}
+						}
+						Expect(laptopID).NotTo(BeZero())
+
+						r, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/v1/user/sessions/%d", laptopID), nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(phone)
+						w = httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						r, _ = http.NewRequest("GET", "/api/v1/task/list", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(laptop)
+						w = httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+
+						r, _ = http.NewRequest("GET", "/api/v1/task/list", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(phone)
+						w = httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+					})
+				})
+
+				When("a login forwards the IP of the client", func() {
+					It("should only record it from a trusted proxy", func() {
+						ip := func(remoteAddr string) string {
+							body, _ := json.Marshal(model.UserLogin{Email: "test@mail.com", Password: "testing123"})
+							r := httptest.NewRequest("POST", "/api/v1/user/login", bytes.NewReader(body))
+							r.Header.Set("Content-Type", "application/json")
+							r.Header.Set("X-Forwarded-For", "203.0.113.7")
+							r.RemoteAddr = remoteAddr
+							w := httptest.NewRecorder()
+							apiServer.ServeHTTP(w, r)
+							Expect(w.Code).To(Equal(http.StatusOK))
+
+							r, _ = http.NewRequest("GET", "/api/v1/user/sessions", nil)
+							r.Header.Set("Content-Type", "application/json")
+							for _, c := range w.Result().Cookies() {
+								r.AddCookie(c)
+							}
+							w = httptest.NewRecorder()
+							apiServer.ServeHTTP(w, r)
+							var sessions []model.SessionInfo
+							Expect(json.Unmarshal(w.Body.Bytes(), &sessions)).Should(Succeed())
+							for _, session := range sessions {
+								if session.Current {
+									return session.IP
+								}
+							}
+							return ""
+						}
+
+						Expect(ip("127.0.0.1:40000")).To(Equal("203.0.113.7"))
+						Expect(ip("198.51.100.9:40000")).To(Equal("198.51.100.9"))
+					})
+				})
+
+				When("revoking a session that does not exist", func() {
+					It("should return status code 404", func() {
+						r, _ := http.NewRequest("DELETE", "/api/v1/user/sessions/999", nil)
+						r.Header.Set("Content-Type", "application/json")
+						r.AddCookie(SetCookie(apiServer))
+						w := httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusNotFound))
+					})
+				})
+			})
+
+			Describe("SetTimezone", func() {
+				When("sending an IANA timezone", func() {
+					It("should store it on the user", func() {
+						reqBody, _ := json.Marshal(model.UserTimezone{Timezone: "Asia/Jakarta"})
+						r, _ := http.NewRequest("PUT", "/api/v1/user/timezone", bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						user, err := userRepo.GetUserByEmail("test@mail.com")
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(user.Timezone).To(Equal("Asia/Jakarta"))
+					})
+				})
+
+				When("sending an unknown timezone", func() {
+					It("should return status code 400", func() {
+						reqBody, _ := json.Marshal(model.UserTimezone{Timezone: "Mars/Olympus"})
+						r, _ := http.NewRequest("PUT", "/api/v1/user/timezone", bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(`invalid timezone "Mars/Olympus"`))
+					})
+				})
+			})
+
 			Describe("GetUserTaskCategory", func() {
 				When("sending without cookie", func() {
 					It("should return status code 401", func() {
@@ -784,6 +1318,45 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 					})
 				})
 
+				When("sending a stale If-Match header", func() {
+					It("should return status code 412 and keep the category", func() {
+						r, _ := http.NewRequest("GET", "/api/v1/category/get/6", nil)
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						stale := w.Header().Get("ETag")
+						Expect(stale).To(Equal(`"1"`))
+
+						requestBody, _ := json.Marshal(model.Category{Name: "First"})
+						r, _ = http.NewRequest("PUT", "/api/v1/category/update/6", bytes.NewReader(requestBody))
+						r.Header.Set("Content-Type", "application/json")
+						r.Header.Set("If-Match", stale)
+						w = httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						Expect(w.Header().Get("ETag")).To(Equal(`"2"`))
+
+						requestBody, _ = json.Marshal(model.Category{Name: "Lost update"})
+						r, _ = http.NewRequest("PUT", "/api/v1/category/update/6", bytes.NewReader(requestBody))
+						r.Header.Set("Content-Type", "application/json")
+						r.Header.Set("If-Match", stale)
+						w = httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(model.ErrVersionConflict.Error()))
+
+						category, err := categoryRepo.GetByID(6)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(category.Name).To(Equal("First"))
+					})
+				})
+
 				When("updating a non-existing category", func() {
 					It("should return status code 400", func() {
 						updatedCategory := model.Category{
@@ -850,21 +1423,73 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 						Expect(response.Message).To(Equal("category delete success"))
 					})
 				})
-			})
 
-			Describe("GetCategoryList", func() {
-				When("sending without cookie", func() {
-					It("should return status code 401", func() {
-						r, _ := http.NewRequest("GET", "/api/v1/category/list", nil)
-						w := httptest.NewRecorder()
-						r.Header.Set("Content-Type", "application/json")
-						apiServer.ServeHTTP(w, r)
-						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+				When("deleting a category that still has tasks", func() {
+					BeforeEach(func() {
+						Expect(taskRepo.Store(&model.Task{ID: 6, Title: "Task 6", Deadline: model.DateDeadline(2023, 6, 8), Priority: 1, Status: "In Progress", CategoryID: 6, UserID: 1})).To(Succeed())
 					})
-				})
 
-				When("retrieving the list of categories", func() {
-					It("should return the list of categories and status code 200", func() {
+					It("should return status code 409 without a mode", func() {
+						r, _ := http.NewRequest("DELETE", "/api/v1/category/delete/6", nil)
+						w := httptest.NewRecorder()
+
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusConflict))
+
+						_, err := categoryRepo.GetByID(6)
+						Expect(err).ShouldNot(HaveOccurred())
+					})
+
+					It("should delete its tasks with mode cascade", func() {
+						r, _ := http.NewRequest("DELETE", "/api/v1/category/delete/6?mode=cascade", nil)
+						w := httptest.NewRecorder()
+
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						_, err := taskRepo.GetByID(6)
+						Expect(err).To(MatchError("record not found"))
+					})
+
+					It("should move its tasks with mode reassign", func() {
+						r, _ := http.NewRequest("DELETE", "/api/v1/category/delete/6?mode=reassign&reassign_to=7", nil)
+						w := httptest.NewRecorder()
+
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						task, err := taskRepo.GetByID(6)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(task.CategoryID).To(Equal(7))
+					})
+
+					It("should return status code 400 for an unknown mode", func() {
+						r, _ := http.NewRequest("DELETE", "/api/v1/category/delete/6?mode=archive", nil)
+						w := httptest.NewRecorder()
+
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+					})
+				})
+			})
+
+			Describe("GetCategoryList", func() {
+				When("sending without cookie", func() {
+					It("should return status code 401", func() {
+						r, _ := http.NewRequest("GET", "/api/v1/category/list", nil)
+						w := httptest.NewRecorder()
+						r.Header.Set("Content-Type", "application/json")
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+					})
+				})
+
+				When("retrieving the list of categories", func() {
+					It("should return the list of categories and status code 200", func() {
 						r, _ := http.NewRequest("GET", "/api/v1/category/list", nil)
 						w := httptest.NewRecorder()
 
@@ -874,7 +1499,7 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 
 						var response []model.Category
 						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
-						Expect(response).To(ConsistOf([]model.Category{
+						Expect(unversioned(response)).To(ConsistOf([]model.Category{
 							{ID: 6, Name: "Work", UserID: 1},
 							{ID: 7, Name: "Personal", UserID: 1},
 							{ID: 8, Name: "Study", UserID: 1},
@@ -887,67 +1512,1578 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 		})
 
 		Describe("Task API", func() {
+			Describe("AddTask", func() {
+				When("sending an RFC 3339 deadline", func() {
+					It("should store the instant in UTC", func() {
+						reqBody := []byte(`{"title": "Call", "deadline": "2023-06-01T17:30:00+07:00", "priority": 2, "status": "Not Started", "category_id": 6}`)
+						r, _ := http.NewRequest("POST", "/api/v1/task/add", bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						tasks, err := taskRepo.GetList(1)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(tasks[len(tasks)-1].Deadline).To(Equal(model.Deadline{At: time.Date(2023, 6, 1, 10, 30, 0, 0, time.UTC)}))
+					})
+				})
+
+				When("sending a deadline in another format", func() {
+					It("should return status code 400", func() {
+						reqBody := []byte(`{"title": "Call", "deadline": "01/06/2023", "priority": 2, "status": "Not Started", "category_id": 6}`)
+						r, _ := http.NewRequest("POST", "/api/v1/task/add", bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(`invalid deadline "01/06/2023": use YYYY-MM-DD or RFC 3339`))
+					})
+				})
+			})
+
 			Describe("UpdateTask", func() {
 				When("sending without cookie", func() {
 					It("should return status code 401", func() {
 						updatedTask := model.Task{
 							ID:         1,
 							Title:      "Updated with API Task 1",
-							Deadline:   "2023-05-30",
+							Deadline:   model.DateDeadline(2023, 5, 30),
 							Priority:   5,
 							CategoryID: 1,
 							Status:     "In Progress",
 						}
 						reqBody, _ := json.Marshal(updatedTask)
 
-						r, _ := http.NewRequest("PUT", fmt.Sprintf("/api/v1/task/update/%d", 1), bytes.NewReader(reqBody))
-						w := httptest.NewRecorder()
-						r.Header.Set("Content-Type", "application/json")
-						apiServer.ServeHTTP(w, r)
-						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+						r, _ := http.NewRequest("PUT", fmt.Sprintf("/api/v1/task/update/%d", 1), bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+						r.Header.Set("Content-Type", "application/json")
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+					})
+				})
+
+				When("updating existing task", func() {
+					It("should return status code 200", func() {
+						updatedTask := model.Task{
+							ID:         2,
+							Title:      "Updated with API Task 2",
+							Deadline:   model.DateDeadline(2023, 5, 30),
+							Priority:   5,
+							CategoryID: 2,
+							Status:     "In Progress",
+						}
+						reqBody, _ := json.Marshal(updatedTask)
+
+						r, _ := http.NewRequest("PUT", fmt.Sprintf("/api/v1/task/update/%d", 2), bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						var response model.SuccessResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Message).To(Equal("update task success"))
+					})
+				})
+
+				When("sending an If-Match header", func() {
+					It("should return the ETag and only accept the current one", func() {
+						r, _ := http.NewRequest("GET", "/api/v1/task/get/2", nil)
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						var task model.Task
+						Expect(json.Unmarshal(w.Body.Bytes(), &task)).Should(Succeed())
+						current := w.Header().Get("ETag")
+						Expect(current).To(Equal(fmt.Sprintf(`"%d"`, task.Version)))
+
+						task.Title = "Updated with If-Match"
+						reqBody, _ := json.Marshal(task)
+
+						r, _ = http.NewRequest("PUT", "/api/v1/task/update/2", bytes.NewReader(reqBody))
+						r.Header.Set("If-Match", fmt.Sprintf(`"%d"`, task.Version-1))
+						w = httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+
+						r, _ = http.NewRequest("PUT", "/api/v1/task/update/2", bytes.NewReader(reqBody))
+						r.Header.Set("If-Match", current)
+						w = httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						Expect(w.Header().Get("ETag")).To(Equal(fmt.Sprintf(`"%d"`, task.Version+1)))
+
+						// The body still carries the old version
+						r, _ = http.NewRequest("PUT", "/api/v1/task/update/2", bytes.NewReader(reqBody))
+						w = httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+
+						stored, err := taskRepo.GetByID(2)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(stored.Title).To(Equal("Updated with If-Match"))
+						Expect(stored.Version).To(Equal(task.Version + 1))
+					})
+				})
+
+				When("updating a task that does not exist", func() {
+					It("should return status code 404 without creating it", func() {
+						reqBody, _ := json.Marshal(model.Task{Title: "ghost", CategoryID: 2})
+
+						r, _ := http.NewRequest("PUT", "/api/v1/task/update/999", bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusNotFound))
+
+						_, err := taskRepo.GetByID(999)
+						Expect(err).To(MatchError(model.ErrRecordNotFound))
+					})
+				})
+
+				When("moving the task to a category of another user", func() {
+					It("should return status code 400 and keep the task", func() {
+						Expect(categoryRepo.Store(&model.Category{ID: 11, Name: "Theirs", UserID: 2})).To(Succeed())
+
+						updatedTask := model.Task{Title: "Task 2", Deadline: model.DateDeadline(2023, 6, 1), Priority: 1, CategoryID: 11, Status: "Completed"}
+						reqBody, _ := json.Marshal(updatedTask)
+
+						r, _ := http.NewRequest("PUT", "/api/v1/task/update/2", bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(model.ErrCategoryNotOwned.Error()))
+
+						task, err := taskRepo.GetByID(2)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(task.CategoryID).To(Equal(2))
+					})
+				})
+
+				When("sending invalid request", func() {
+					It("should return status code 400", func() {
+						reqBody := []byte("invalid request body")
+						r, _ := http.NewRequest("PUT", "/api/v1/task/update/2", bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).NotTo(BeNil())
+					})
+				})
+			})
+
+			Describe("TransitionTask", func() {
+				transition := func(id int, status string) *httptest.ResponseRecorder {
+					reqBody, _ := json.Marshal(model.TaskTransition{Status: status})
+					r, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/task/%d/transition", id), bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				When("sending without cookie", func() {
+					It("should return status code 401", func() {
+						r, _ := http.NewRequest("POST", "/api/v1/task/5/transition", strings.NewReader(`{"status":"Completed"}`))
+						w := httptest.NewRecorder()
+						r.Header.Set("Content-Type", "application/json")
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusUnauthorized))
+					})
+				})
+
+				When("moving a task along the workflow", func() {
+					It("should stamp completed_at when it is done and clear it again", func() {
+						w := transition(5, "Completed")
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						var task model.TaskResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &task)).Should(Succeed())
+						Expect(task.Status).To(Equal("Completed"))
+						Expect(task.CompletedAt).NotTo(BeNil())
+						Expect(w.Header().Get("ETag")).To(Equal(fmt.Sprintf(`"%d"`, task.Version)))
+
+						w = transition(5, "In Progress")
+						Expect(w.Code).To(Equal(http.StatusOK))
+						stored, err := taskRepo.GetByID(5)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(stored.Status).To(Equal("In Progress"))
+						Expect(stored.CompletedAt).To(BeNil())
+					})
+				})
+
+				When("the workflow does not allow the move", func() {
+					It("should return status code 409 and keep the task", func() {
+						w := transition(2, "Not Started")
+						Expect(w.Code).To(Equal(http.StatusConflict))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(`cannot move task from "Completed" to "Not Started", allowed from "Completed": "In Progress"`))
+
+						stored, err := taskRepo.GetByID(2)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(stored.Status).To(Equal("Completed"))
+					})
+				})
+
+				When("the status is not part of the workflow", func() {
+					It("should return status code 400", func() {
+						w := transition(5, "Blocked")
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(ContainSubstring(`unknown status "Blocked"`))
+					})
+				})
+
+				When("the task belongs to another user", func() {
+					It("should return status code 403", func() {
+						Expect(transition(1, "Completed").Code).To(Equal(http.StatusForbidden))
+					})
+				})
+
+				When("the task changes after the client read it", func() {
+					It("should refuse the move", func() {
+						read, err := taskRepo.GetByID(5)
+						Expect(err).ShouldNot(HaveOccurred())
+
+						// Written after the If-Match was checked against read
+						changed := *read
+						changed.Title = "Task 5 changed"
+						Expect(taskRepo.Update(5, &changed)).To(Succeed())
+
+						_, err = taskService.Transition(5, model.TaskTransition{Status: "Completed"}, read.Version)
+						Expect(err).To(MatchError(model.ErrVersionConflict))
+
+						r, _ := http.NewRequest("POST", "/api/v1/task/5/transition", strings.NewReader(`{"status":"Completed"}`))
+						r.Header.Set("If-Match", fmt.Sprintf(`"%d"`, read.Version))
+						r.AddCookie(SetCookie(apiServer))
+						w := httptest.NewRecorder()
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+
+						stored, err := taskRepo.GetByID(5)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(stored.Status).To(Equal(read.Status))
+						Expect(stored.Title).To(Equal("Task 5 changed"))
+					})
+				})
+			})
+
+			Describe("Subtasks", func() {
+				addTask := func(task model.Task) *httptest.ResponseRecorder {
+					reqBody, _ := json.Marshal(task)
+					r, _ := http.NewRequest("POST", "/api/v1/task/add", bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				taskList := func() map[int]model.TaskResponse {
+					r, _ := http.NewRequest("GET", "/api/v1/task/list", nil)
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusOK))
+
+					var list []model.TaskResponse
+					Expect(json.Unmarshal(w.Body.Bytes(), &list)).Should(Succeed())
+					byID := map[int]model.TaskResponse{}
+					for _, task := range list {
+						byID[task.ID] = task
+					}
+					return byID
+				}
+
+				BeforeEach(func() {
+					for _, title := range []string{"Step 1", "Step 2", "Step 3"} {
+						w := addTask(model.Task{Title: title, Deadline: model.DateDeadline(2023, 6, 7), Priority: 1, Status: "Not Started", CategoryID: 3, ParentID: 5})
+						Expect(w.Code).To(Equal(http.StatusOK))
+					}
+				})
+
+				When("listing tasks", func() {
+					It("should order the subtasks and report the progress of their parent", func() {
+						tasks := taskList()
+						Expect(tasks[6].ParentID).To(Equal(5))
+						Expect([]int{tasks[6].Position, tasks[7].Position, tasks[8].Position}).To(Equal([]int{1, 2, 3}))
+						Expect(*tasks[5].Progress).To(Equal(0))
+						Expect(tasks[2].Progress).To(BeNil())
+						Expect(tasks[6].Progress).To(BeNil())
+
+						reqBody, _ := json.Marshal(model.TaskTransition{Category: model.StatusDone})
+						r, _ := http.NewRequest("POST", "/api/v1/task/7/transition", bytes.NewReader(reqBody))
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						tasks = taskList()
+						Expect(tasks[7].Status).To(Equal("Completed"))
+						Expect(*tasks[5].Progress).To(Equal(33))
+
+						r, _ = http.NewRequest("GET", "/api/v1/task/get/5", nil)
+						w = httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						var task model.TaskResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &task)).Should(Succeed())
+						Expect(*task.Progress).To(Equal(33))
+					})
+				})
+
+				When("adding a subtask to a subtask", func() {
+					It("should return status code 400", func() {
+						w := addTask(model.Task{Title: "Step 1.1", Deadline: model.DateDeadline(2023, 6, 7), Priority: 1, Status: "Not Started", CategoryID: 3, ParentID: 6})
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(model.ErrNestedSubtask.Error()))
+					})
+				})
+
+				When("deleting the parent", func() {
+					It("should delete its subtasks", func() {
+						r, _ := http.NewRequest("DELETE", "/api/v1/task/delete/5", nil)
+						w := httptest.NewRecorder()
+						r.AddCookie(SetCookie(apiServer))
+						apiServer.ServeHTTP(w, r)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						tasks := taskList()
+						Expect(tasks).To(HaveLen(1))
+						Expect(tasks).To(HaveKey(2))
+					})
+				})
+			})
+
+			Describe("Dependencies", func() {
+				do := func(method, url string, body any) *httptest.ResponseRecorder {
+					reqBody, _ := json.Marshal(body)
+					r, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				getTask := func(id int) model.TaskResponse {
+					w := do("GET", fmt.Sprintf("/api/v1/task/get/%d", id), nil)
+					Expect(w.Code).To(Equal(http.StatusOK))
+					var task model.TaskResponse
+					Expect(json.Unmarshal(w.Body.Bytes(), &task)).Should(Succeed())
+					return task
+				}
+
+				BeforeEach(func() {
+					w := do("POST", "/api/v1/task/add", model.Task{Title: "Follow-up", Deadline: model.DateDeadline(2023, 6, 9), Priority: 1, Status: "Not Started", CategoryID: 3})
+					Expect(w.Code).To(Equal(http.StatusOK))
+					w = do("POST", "/api/v1/task/6/dependencies", model.Dependency{BlockedByID: 5})
+					Expect(w.Code).To(Equal(http.StatusOK))
+				})
+
+				When("listing tasks", func() {
+					It("should flag the task waiting on an open task", func() {
+						w := do("GET", "/api/v1/task/list", nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						var list []model.TaskResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &list)).Should(Succeed())
+						Expect(list).To(HaveLen(3))
+						for _, task := range list {
+							Expect(task.Blocked).To(Equal(task.ID == 6))
+						}
+						Expect(list[2].BlockedBy).To(Equal([]int{5}))
+						Expect(list[0].BlockedBy).To(BeEmpty())
+					})
+				})
+
+				When("starting a blocked task", func() {
+					It("should return status code 409 until the predecessor is done", func() {
+						w := do("POST", "/api/v1/task/6/transition", model.TaskTransition{Status: "In Progress"})
+						Expect(w.Code).To(Equal(http.StatusConflict))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(`task is blocked by open tasks: 5 "Task 5"`))
+
+						Expect(do("POST", "/api/v1/task/5/transition", model.TaskTransition{Status: "Completed"}).Code).To(Equal(http.StatusOK))
+						Expect(getTask(6).Blocked).To(BeFalse())
+						Expect(do("POST", "/api/v1/task/6/transition", model.TaskTransition{Status: "In Progress"}).Code).To(Equal(http.StatusOK))
+					})
+				})
+
+				When("the edge would close a cycle", func() {
+					It("should return status code 409", func() {
+						w := do("POST", "/api/v1/task/5/dependencies", model.Dependency{BlockedByID: 6})
+						Expect(w.Code).To(Equal(http.StatusConflict))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(model.ErrDependencyCycle.Error()))
+					})
+				})
+
+				When("the predecessor belongs to another user", func() {
+					It("should return status code 400", func() {
+						Expect(do("POST", "/api/v1/task/6/dependencies", model.Dependency{BlockedByID: 1}).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("POST", "/api/v1/task/6/dependencies", model.Dependency{BlockedByID: 99}).Code).To(Equal(http.StatusNotFound))
+					})
+				})
+
+				When("removing the edge", func() {
+					It("should unblock the task", func() {
+						Expect(do("DELETE", "/api/v1/task/6/dependencies/5", nil).Code).To(Equal(http.StatusOK))
+
+						task := getTask(6)
+						Expect(task.Blocked).To(BeFalse())
+						Expect(task.BlockedBy).To(BeEmpty())
+					})
+				})
+			})
+
+			Describe("Recurring tasks", func() {
+				do := func(method, url string, body any) *httptest.ResponseRecorder {
+					reqBody, _ := json.Marshal(body)
+					r, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				series := func() []model.Task {
+					tasks, err := taskRepo.GetList(1)
+					Expect(err).ShouldNot(HaveOccurred())
+					var out []model.Task
+					for _, t := range tasks {
+						if t.Series() == 6 {
+							out = append(out, t)
+						}
+					}
+					return out
+				}
+
+				BeforeEach(func() {
+					weekly := &model.Recurrence{Freq: model.RecurWeekly, ByDay: []string{"MO", "TH"}}
+					w := do("POST", "/api/v1/task/add", model.Task{Title: "Weekly report", Deadline: model.DateDeadline(2023, 6, 5), Priority: 2, Status: "Not Started", CategoryID: 3, Recurrence: weekly})
+					Expect(w.Code).To(Equal(http.StatusOK))
+				})
+
+				When("completing an occurrence", func() {
+					It("should create the next one once", func() {
+						Expect(do("POST", "/api/v1/task/6/transition", model.TaskTransition{Status: "Completed"}).Code).To(Equal(http.StatusOK))
+
+						tasks := series()
+						Expect(tasks).To(HaveLen(2))
+						Expect(tasks[1].Title).To(Equal("Weekly report"))
+						Expect(tasks[1].Deadline).To(Equal(model.DateDeadline(2023, 6, 8)))
+						Expect(tasks[1].Status).To(Equal("Not Started"))
+						Expect(tasks[1].SeriesID).To(Equal(6))
+						Expect(tasks[1].Recurrence.ByDay).To(Equal([]string{"MO", "TH"}))
+
+						// reopening and completing again does not add another one
+						Expect(do("POST", "/api/v1/task/6/transition", model.TaskTransition{Status: "In Progress"}).Code).To(Equal(http.StatusOK))
+						Expect(do("POST", "/api/v1/task/6/transition", model.TaskTransition{Status: "Completed"}).Code).To(Equal(http.StatusOK))
+						Expect(series()).To(HaveLen(2))
+					})
+				})
+
+				When("the rule counts from completion", func() {
+					It("should move the deadline from the day of completion", func() {
+						everyThreeDays := &model.Recurrence{Freq: model.RecurDaily, Interval: 3, AfterCompletion: true}
+						w := do("PUT", "/api/v1/task/update/6", model.Task{Title: "Water plants", Deadline: model.DateDeadline(2023, 6, 5), Priority: 2, Status: "Completed", CategoryID: 3, Recurrence: everyThreeDays})
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						tasks := series()
+						Expect(tasks).To(HaveLen(2))
+						y, m, d := time.Now().UTC().Date()
+						Expect(tasks[1].Deadline).To(Equal(model.DateDeadline(y, m, d+3)))
+					})
+				})
+
+				When("creating occurrences ahead", func() {
+					It("should return them and refuse tasks that do not repeat", func() {
+						w := do("POST", "/api/v1/task/6/occurrences", model.OccurrenceRequest{Count: 2})
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						var created []model.TaskResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &created)).Should(Succeed())
+						Expect(created).To(HaveLen(2))
+						Expect(created[0].ID).To(Equal(7))
+						Expect(created[1].Deadline).To(Equal(model.DateDeadline(2023, 6, 12)))
+
+						Expect(do("POST", "/api/v1/task/5/occurrences", model.OccurrenceRequest{Count: 2}).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("POST", "/api/v1/task/6/occurrences", model.OccurrenceRequest{Count: 100}).Code).To(Equal(http.StatusBadRequest))
+					})
+				})
+
+				When("editing all future occurrences", func() {
+					BeforeEach(func() {
+						Expect(do("POST", "/api/v1/task/6/occurrences", model.OccurrenceRequest{Count: 2}).Code).To(Equal(http.StatusOK))
+					})
+
+					It("should carry a new title over to the later occurrences", func() {
+						weekly := &model.Recurrence{Freq: model.RecurWeekly, ByDay: []string{"MO", "TH"}}
+						w := do("PUT", "/api/v1/task/7/future", model.Task{Title: "Team report", Deadline: model.DateDeadline(2023, 6, 8), Priority: 3, Status: "Not Started", CategoryID: 3, Recurrence: weekly})
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						tasks := series()
+						Expect(tasks).To(HaveLen(3))
+						Expect([]string{tasks[0].Title, tasks[1].Title, tasks[2].Title}).To(Equal([]string{"Weekly report", "Team report", "Team report"}))
+						Expect(tasks[2].Priority).To(Equal(3))
+					})
+
+					It("should create the later occurrences again for a new rule", func() {
+						w := do("PUT", "/api/v1/task/6/future", model.Task{Title: "Weekly report", Deadline: model.DateDeadline(2023, 6, 5), Priority: 2, Status: "Not Started", CategoryID: 3, Recurrence: &model.Recurrence{Freq: model.RecurDaily}})
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						tasks := series()
+						Expect(tasks).To(HaveLen(3))
+						Expect(tasks[1].Deadline).To(Equal(model.DateDeadline(2023, 6, 6)))
+						Expect(tasks[2].Deadline).To(Equal(model.DateDeadline(2023, 6, 7)))
+					})
+
+					It("should only change the occurrence itself through update", func() {
+						weekly := &model.Recurrence{Freq: model.RecurWeekly, ByDay: []string{"MO", "TH"}}
+						w := do("PUT", "/api/v1/task/update/7", model.Task{Title: "Skipped", Deadline: model.DateDeadline(2023, 6, 8), Priority: 2, Status: "Not Started", CategoryID: 3, Recurrence: weekly})
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						tasks := series()
+						Expect([]string{tasks[0].Title, tasks[1].Title, tasks[2].Title}).To(Equal([]string{"Weekly report", "Skipped", "Weekly report"}))
+					})
+				})
+
+				When("sending an invalid rule", func() {
+					It("should return status code 400", func() {
+						w := do("POST", "/api/v1/task/add", model.Task{Title: "Yearly", Deadline: model.DateDeadline(2023, 6, 5), Priority: 1, Status: "Not Started", CategoryID: 3, Recurrence: &model.Recurrence{Freq: "yearly"}})
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(HavePrefix(`invalid recurrence: unknown freq "yearly"`))
+					})
+				})
+			})
+
+			Describe("Tags", func() {
+				do := func(method, url string, body any) *httptest.ResponseRecorder {
+					reqBody, _ := json.Marshal(body)
+					r, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				addTag := func(name, color string) model.Tag {
+					w := do("POST", "/api/v1/tag/add", model.Tag{Name: name, Color: color})
+					Expect(w.Code).To(Equal(http.StatusOK))
+					var tag model.Tag
+					Expect(json.Unmarshal(w.Body.Bytes(), &tag)).Should(Succeed())
+					return tag
+				}
+
+				listIDs := func(query string) []int {
+					w := do("GET", "/api/v1/task/list"+query, nil)
+					Expect(w.Code).To(Equal(http.StatusOK))
+					var list []model.TaskResponse
+					Expect(json.Unmarshal(w.Body.Bytes(), &list)).Should(Succeed())
+					ids := []int{}
+					for _, task := range list {
+						ids = append(ids, task.ID)
+					}
+					return ids
+				}
+
+				When("managing tags", func() {
+					It("should create, rename and delete tags of the user", func() {
+						work := addTag("Work", "")
+						Expect(work.Color).To(Equal(model.DefaultTagColor))
+						Expect(work.UserID).To(Equal(1))
+
+						Expect(do("POST", "/api/v1/tag/add", model.Tag{Name: " work "}).Code).To(Equal(http.StatusConflict))
+						Expect(do("POST", "/api/v1/tag/add", model.Tag{Name: "Red", Color: "red"}).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("POST", "/api/v1/tag/add", model.Tag{Name: "a,b"}).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("POST", "/api/v1/tag/add", model.Tag{Name: "<b>urgent</b>"}).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("PUT", fmt.Sprintf("/api/v1/tag/update/%d", work.ID), model.Tag{Name: `"><script>alert(1)</script>`}).Code).To(Equal(http.StatusBadRequest))
+						Expect(model.Tag{Name: "Études v2.0_final-draft", Color: model.DefaultTagColor}.Validate()).To(Succeed())
+
+						w := do("PUT", fmt.Sprintf("/api/v1/tag/update/%d", work.ID), model.Tag{Name: "Office", Color: "#0ea5e9"})
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						w = do("GET", "/api/v1/tag/list", nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var tags []model.Tag
+						Expect(json.Unmarshal(w.Body.Bytes(), &tags)).Should(Succeed())
+						Expect(tags).To(Equal([]model.Tag{{ID: work.ID, Name: "Office", Color: "#0ea5e9", UserID: 1}}))
+
+						Expect(do("DELETE", fmt.Sprintf("/api/v1/tag/delete/%d", work.ID), nil).Code).To(Equal(http.StatusOK))
+						Expect(do("GET", fmt.Sprintf("/api/v1/tag/get/%d", work.ID), nil).Code).To(Equal(http.StatusNotFound))
+					})
+
+					It("should return status code 403 for a tag of another user", func() {
+						theirs, err := filebasedDb.CreateTag(model.Tag{Name: "Theirs", Color: model.DefaultTagColor, UserID: 2})
+						Expect(err).ShouldNot(HaveOccurred())
+
+						Expect(do("GET", fmt.Sprintf("/api/v1/tag/get/%d", theirs.ID), nil).Code).To(Equal(http.StatusForbidden))
+						Expect(do("DELETE", fmt.Sprintf("/api/v1/tag/delete/%d", theirs.ID), nil).Code).To(Equal(http.StatusForbidden))
+
+						w := do("POST", "/api/v1/task/add", model.Task{Title: "Tagged", Deadline: model.DateDeadline(2023, 6, 9), Priority: 1, Status: "Not Started", CategoryID: 3, TagIDs: []int{theirs.ID}})
+						Expect(w.Code).To(Equal(http.StatusBadRequest))
+						var response model.ErrorResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+						Expect(response.Error).To(Equal(model.ErrTagNotOwned.Error()))
+					})
+				})
+
+				When("filtering the task list by tag", func() {
+					BeforeEach(func() {
+						work, urgent := addTag("Work", "#ff0000"), addTag("Urgent", "#f59e0b")
+						for _, tagIDs := range [][]int{{work.ID}, {urgent.ID, work.ID, urgent.ID}} {
+							w := do("POST", "/api/v1/task/add", model.Task{Title: "Tagged", Deadline: model.DateDeadline(2023, 6, 9), Priority: 1, Status: "Not Started", CategoryID: 3, TagIDs: tagIDs})
+							Expect(w.Code).To(Equal(http.StatusOK))
+						}
+					})
+
+					It("should return the tasks carrying any or all of the tags", func() {
+						Expect(listIDs("")).To(Equal([]int{2, 5, 6, 7}))
+						Expect(listIDs("?tag=work")).To(Equal([]int{6, 7}))
+						Expect(listIDs("?tag=Urgent&tag=nope")).To(Equal([]int{7}))
+						Expect(listIDs("?tag=Work,Urgent&match=all")).To(Equal([]int{7}))
+						Expect(listIDs("?tag=Work&tag=nope&match=all")).To(BeEmpty())
+
+						w := do("GET", "/api/v1/task/get/7", nil)
+						var task model.TaskResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &task)).Should(Succeed())
+						Expect(task.TagIDs).To(Equal([]int{1, 2}))
+					})
+
+					It("should return status code 400 for an unknown match", func() {
+						Expect(do("GET", "/api/v1/task/list?tag=Work&match=some", nil).Code).To(Equal(http.StatusBadRequest))
+					})
+
+					It("should drop a deleted tag from the filter", func() {
+						Expect(do("DELETE", "/api/v1/tag/delete/1", nil).Code).To(Equal(http.StatusOK))
+						Expect(listIDs("?tag=Work")).To(BeEmpty())
+						Expect(listIDs("?tag=Urgent")).To(Equal([]int{7}))
+					})
+				})
+			})
+
+			Describe("Task list queries", func() {
+				do := func(url string) *httptest.ResponseRecorder {
+					r, _ := http.NewRequest("GET", url, nil)
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				page := func(url string) ([]int, model.TaskListPage) {
+					w := do(url)
+					Expect(w.Code).To(Equal(http.StatusOK))
+					var list model.TaskListPage
+					Expect(json.Unmarshal(w.Body.Bytes(), &list)).Should(Succeed())
+					ids := []int{}
+					for _, task := range list.Tasks {
+						ids = append(ids, task.ID)
+					}
+					return ids, list
+				}
+
+				BeforeEach(func() {
+					for _, task := range []model.Task{
+						{ID: 6, Title: "Write the report", Deadline: model.DateDeadline(2023, 6, 3), Priority: 3, Status: "Not Started", CategoryID: 3, UserID: 1},
+						{ID: 7, Title: "Review report", Deadline: model.DateDeadline(2023, 6, 10), Priority: 3, Status: "In Progress", CategoryID: 3, UserID: 1},
+					} {
+						Expect(filebasedDb.StoreTask(task)).To(Succeed())
+					}
+				})
+
+				When("asking for a page", func() {
+					It("should return the tasks in order with the cursor of the next page", func() {
+						ids, list := page("/api/v1/task/list?sort=priority&order=desc&limit=2")
+						Expect(ids).To(Equal([]int{5, 7}))
+						Expect(list.Total).To(Equal(4))
+						Expect(list.NextCursor).NotTo(BeNil())
+
+						ids, list = page("/api/v1/task/list?sort=priority&order=desc&limit=2&cursor=" + *list.NextCursor)
+						Expect(ids).To(Equal([]int{6, 2}))
+						Expect(list.Total).To(Equal(4))
+						Expect(list.NextCursor).To(BeNil())
+
+						ids, _ = page("/api/v1/task/list?sort=deadline&limit=3")
+						Expect(ids).To(Equal([]int{2, 6, 5}))
+					})
+
+					It("should keep the relations of the tasks on the page", func() {
+						Expect(filebasedDb.StoreTask(model.Task{ID: 8, Title: "Outline", Status: "Completed", CategoryID: 3, UserID: 1, ParentID: 5})).To(Succeed())
+
+						_, list := page("/api/v1/task/list?sort=priority&order=desc&limit=1")
+						Expect(list.Tasks[0].ID).To(Equal(5))
+						Expect(list.Tasks[0].Progress).NotTo(BeNil())
+						Expect(*list.Tasks[0].Progress).To(Equal(100))
+					})
+				})
+
+				When("filtering without a page", func() {
+					It("should return the matching tasks as an array", func() {
+						listIDs := func(query string) []int {
+							w := do("/api/v1/task/list" + query)
+							Expect(w.Code).To(Equal(http.StatusOK))
+							var list []model.TaskResponse
+							Expect(json.Unmarshal(w.Body.Bytes(), &list)).Should(Succeed())
+							ids := []int{}
+							for _, task := range list {
+								ids = append(ids, task.ID)
+							}
+							return ids
+						}
+
+						Expect(listIDs("")).To(Equal([]int{2, 5, 6, 7}))
+						Expect(listIDs("?status=In%20Progress,Not%20Started&priority_min=3&category_id=3&deadline_from=2023-06-05&q=report")).To(Equal([]int{7}))
+						Expect(listIDs("?deadline_to=2023-06-03&order=desc")).To(Equal([]int{6, 2}))
+						Expect(listIDs("?status=Completed&status=Not%20Started&priority_max=3")).To(Equal([]int{2, 6}))
+					})
+				})
+
+				When("the query is invalid", func() {
+					It("should return status code 400", func() {
+						_, list := page("/api/v1/task/list?sort=priority&limit=1")
+						for _, query := range []string{
+							"sort=title",
+							"order=up",
+							"limit=0",
+							"limit=101",
+							"priority_min=high",
+							"priority_min=4&priority_max=2",
+							"deadline_from=tomorrow",
+							"deadline_from=2023-06-05&deadline_to=2023-06-01",
+							"q=!!",
+							"cursor=garbage",
+							"sort=deadline&cursor=" + *list.NextCursor,
+						} {
+							Expect(do("/api/v1/task/list?"+query).Code).To(Equal(http.StatusBadRequest), query)
+						}
+					})
+				})
+			})
+
+			Describe("Saved views", func() {
+				do := func(method, url string, body any) *httptest.ResponseRecorder {
+					reqBody, _ := json.Marshal(body)
+					r, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				addView := func(view model.SavedView) model.SavedViewResponse {
+					w := do("POST", "/api/v1/view/add", view)
+					Expect(w.Code).To(Equal(http.StatusOK))
+					var created model.SavedViewResponse
+					Expect(json.Unmarshal(w.Body.Bytes(), &created)).Should(Succeed())
+					return created
+				}
+
+				When("managing views", func() {
+					It("should create, list, update and delete views of the user", func() {
+						urgent := addView(model.SavedView{Name: "Urgent work", Query: "priority_min=4&status=In Progress,Not Started&sort=deadline", Pinned: true})
+						Expect(urgent.UserID).To(Equal(1))
+						Expect(urgent.Query).To(Equal("priority_min=4&sort=deadline&status=In+Progress%2CNot+Started"))
+						Expect(urgent.URL).To(Equal("/client/dashboard?view=priority_min%3D4%26sort%3Ddeadline%26status%3DIn%2BProgress%252CNot%2BStarted"))
+						addView(model.SavedView{Name: "This week", Query: "deadline_from=today&deadline_to=today+7"})
+
+						Expect(do("POST", "/api/v1/view/add", model.SavedView{Name: " urgent WORK ", Query: "sort=priority"}).Code).To(Equal(http.StatusConflict))
+
+						w := do("GET", "/api/v1/view/list?pinned=true", nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var views []model.SavedViewResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &views)).Should(Succeed())
+						Expect(views).To(HaveLen(1))
+						Expect(views[0].Name).To(Equal("Urgent work"))
+
+						w = do("PUT", fmt.Sprintf("/api/v1/view/update/%d", urgent.ID), model.SavedView{Name: "Urgent", Query: "priority_min=5"})
+						Expect(w.Code).To(Equal(http.StatusOK))
+						w = do("GET", fmt.Sprintf("/api/v1/view/get/%d", urgent.ID), nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var view model.SavedViewResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &view)).Should(Succeed())
+						Expect(view.SavedView).To(Equal(model.SavedView{ID: urgent.ID, Name: "Urgent", Query: "priority_min=5", UserID: 1}))
+
+						// The query of a view is a task list query
+						w = do("GET", "/api/v1/task/list?"+view.Query, nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var tasks []model.TaskResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &tasks)).Should(Succeed())
+						Expect(tasks).To(HaveLen(1))
+						Expect(tasks[0].ID).To(Equal(5))
+
+						Expect(do("DELETE", fmt.Sprintf("/api/v1/view/delete/%d", urgent.ID), nil).Code).To(Equal(http.StatusOK))
+						Expect(do("GET", fmt.Sprintf("/api/v1/view/get/%d", urgent.ID), nil).Code).To(Equal(http.StatusNotFound))
+					})
+
+					It("should return status code 400 for a query outside the task list grammar", func() {
+						for _, query := range []string{
+							"sort=title",
+							"priority_min=high",
+							"deadline_to=tomorrow",
+							"limit=10",
+							"cursor=abc",
+							"colour=red",
+							"q=%zz",
+						} {
+							Expect(do("POST", "/api/v1/view/add", model.SavedView{Name: "Bad", Query: query}).Code).To(Equal(http.StatusBadRequest), query)
+						}
+						Expect(do("POST", "/api/v1/view/add", model.SavedView{Name: " ", Query: "sort=priority"}).Code).To(Equal(http.StatusBadRequest))
+					})
+
+					It("should return status code 403 for a view of another user", func() {
+						theirs, err := filebasedDb.CreateView(model.SavedView{Name: "Theirs", Query: "sort=priority", UserID: 2})
+						Expect(err).ShouldNot(HaveOccurred())
+
+						Expect(do("GET", fmt.Sprintf("/api/v1/view/get/%d", theirs.ID), nil).Code).To(Equal(http.StatusForbidden))
+						Expect(do("PUT", fmt.Sprintf("/api/v1/view/update/%d", theirs.ID), model.SavedView{Name: "Mine", Query: ""}).Code).To(Equal(http.StatusForbidden))
+						Expect(do("DELETE", fmt.Sprintf("/api/v1/view/delete/%d", theirs.ID), nil).Code).To(Equal(http.StatusForbidden))
+					})
+				})
+
+				When("the query has relative deadlines", func() {
+					It("should count days from today in the timezone of the user", func() {
+						today := time.Now().UTC()
+						for id, days := range map[int]int{6: -1, 7: 3, 8: 10} {
+							d := today.AddDate(0, 0, days)
+							Expect(filebasedDb.StoreTask(model.Task{ID: id, Title: "Due", Deadline: model.DateDeadline(d.Year(), d.Month(), d.Day()), Priority: 2, Status: "Not Started", CategoryID: 3, UserID: 1})).To(Succeed())
+						}
+
+						w := do("GET", "/api/v1/task/list?deadline_from=today&deadline_to=today%2B7", nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var tasks []model.TaskResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &tasks)).Should(Succeed())
+						Expect(tasks).To(HaveLen(1))
+						Expect(tasks[0].ID).To(Equal(7))
+
+						// An unescaped + works too
+						w = do("GET", "/api/v1/task/list?deadline_to=today+3&deadline_from=today-1", nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						Expect(json.Unmarshal(w.Body.Bytes(), &tasks)).Should(Succeed())
+						Expect(tasks).To(HaveLen(2))
+
+						Expect(do("GET", "/api/v1/task/list?deadline_to=today*3", nil).Code).To(Equal(http.StatusBadRequest))
+					})
+				})
+			})
+
+			Describe("Patch", func() {
+				patch := func(url, contentType, body string, header ...string) *httptest.ResponseRecorder {
+					r, _ := http.NewRequest("PATCH", url, strings.NewReader(body))
+					r.Header.Set("Content-Type", contentType)
+					if len(header) > 0 {
+						r.Header.Set("If-Match", header[0])
+					}
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				When("sending a merge patch", func() {
+					It("should change the named fields and keep the others", func() {
+						w := patch("/api/v1/task/5", model.MergePatchType, `{"priority": 2, "title": "Task 5 renamed"}`)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						Expect(w.Header().Get("ETag")).To(Equal(`"2"`))
+						var task model.TaskResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &task)).Should(Succeed())
+						Expect(task.Title).To(Equal("Task 5 renamed"))
+						Expect(task.Priority).To(Equal(2))
+
+						stored, err := taskRepo.GetByID(5)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(stored.Title).To(Equal("Task 5 renamed"))
+						Expect(stored.Deadline.String()).To(Equal("2023-06-07"))
+						Expect(stored.Status).To(Equal("In Progress"))
+						Expect(stored.CategoryID).To(Equal(3))
+						Expect(stored.UserID).To(Equal(1))
+
+						// Plain JSON is a merge patch too
+						Expect(patch("/api/v1/task/5", "application/json; charset=utf-8", `{"priority": 3}`).Code).To(Equal(http.StatusOK))
+						stored, _ = taskRepo.GetByID(5)
+						Expect(stored.Priority).To(Equal(3))
+						Expect(stored.Title).To(Equal("Task 5 renamed"))
+					})
+				})
+
+				When("sending a JSON Patch", func() {
+					It("should apply every operation or none", func() {
+						w := patch("/api/v1/task/5", model.JSONPatchType, `[
+							{"op": "test", "path": "/title", "value": "Task 5"},
+							{"op": "replace", "path": "/title", "value": "Task 5 patched"},
+							{"op": "copy", "from": "/priority", "path": "/position"}
+						]`)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						stored, _ := taskRepo.GetByID(5)
+						Expect(stored.Title).To(Equal("Task 5 patched"))
+						Expect(stored.Position).To(Equal(5))
+
+						w = patch("/api/v1/task/5", model.JSONPatchType, `[
+							{"op": "replace", "path": "/priority", "value": 1},
+							{"op": "test", "path": "/title", "value": "Task 5"}
+						]`)
+						Expect(w.Code).To(Equal(http.StatusConflict))
+						stored, _ = taskRepo.GetByID(5)
+						Expect(stored.Priority).To(Equal(5))
+
+						Expect(patch("/api/v1/task/5", model.JSONPatchType, `{"title": "x"}`).Code).To(Equal(http.StatusBadRequest))
+						Expect(patch("/api/v1/task/5", model.JSONPatchType, `[{"op": "remove", "path": "/nothing"}]`).Code).To(Equal(http.StatusBadRequest))
+					})
+				})
+
+				When("the result is not a valid task", func() {
+					It("should return status code 400 and keep the task", func() {
+						for _, body := range []string{
+							`{"title": null}`,
+							`{"title": "  "}`,
+							`{"deadline": null}`,
+							`{"category_id": 0}`,
+							`{"priority": "high"}`,
+							`{"overdue": true}`,
+							`{"category_id": 42}`,
+							`{"status": "Unknown"}`,
+							`not json`,
+						} {
+							Expect(patch("/api/v1/task/5", model.MergePatchType, body).Code).To(Equal(http.StatusBadRequest), body)
+						}
+						stored, _ := taskRepo.GetByID(5)
+						Expect(stored.Title).To(Equal("Task 5"))
+						Expect(stored.Version).To(Equal(1))
+
+						Expect(patch("/api/v1/task/5", "text/plain", `{"priority": 1}`).Code).To(Equal(http.StatusUnsupportedMediaType))
+						Expect(patch("/api/v1/task/1", model.MergePatchType, `{"priority": 1}`).Code).To(Equal(http.StatusForbidden))
+						Expect(patch("/api/v1/task/42", model.MergePatchType, `{"priority": 1}`).Code).To(Equal(http.StatusNotFound))
+					})
+				})
+
+				When("sending a stale If-Match header", func() {
+					It("should return status code 412", func() {
+						Expect(patch("/api/v1/task/5", model.MergePatchType, `{"priority": 4}`, `"1"`).Code).To(Equal(http.StatusOK))
+						Expect(patch("/api/v1/task/5", model.MergePatchType, `{"priority": 3}`, `"1"`).Code).To(Equal(http.StatusPreconditionFailed))
+						stored, _ := taskRepo.GetByID(5)
+						Expect(stored.Priority).To(Equal(4))
+					})
+				})
+
+				When("patching a category", func() {
+					It("should rename it and check the new name", func() {
+						w := patch("/api/v1/category/6", model.MergePatchType, `{"name": "Renamed"}`)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						Expect(w.Header().Get("ETag")).To(Equal(`"2"`))
+						var category model.Category
+						Expect(json.Unmarshal(w.Body.Bytes(), &category)).Should(Succeed())
+						Expect(category.Name).To(Equal("Renamed"))
+						Expect(category.UserID).To(Equal(1))
+
+						Expect(patch("/api/v1/category/6", model.JSONPatchType, `[{"op": "remove", "path": "/name"}]`).Code).To(Equal(http.StatusBadRequest))
+						Expect(patch("/api/v1/category/6", model.MergePatchType, `{"name": " personal "}`).Code).To(Equal(http.StatusBadRequest))
+						Expect(patch("/api/v1/category/6", model.MergePatchType, `{"name": "Renamed"}`, `"1"`).Code).To(Equal(http.StatusPreconditionFailed))
+						Expect(patch("/api/v1/category/6", model.MergePatchType, `{"user_id": 2}`).Code).To(Equal(http.StatusOK))
+						Expect(patch("/api/v1/category/1", model.MergePatchType, `{"name": "Mine"}`).Code).To(Equal(http.StatusForbidden))
+						stored, err := categoryRepo.GetByID(6)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(stored.Name).To(Equal("Renamed"))
+						Expect(stored.UserID).To(Equal(1))
+					})
+				})
+			})
+
+			Describe("Bulk operations", func() {
+				bulk := func(body string) (int, model.BulkResponse) {
+					r, _ := http.NewRequest("POST", "/api/v1/task/bulk", strings.NewReader(body))
+					r.Header.Set("Content-Type", "application/json")
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					var response model.BulkResponse
+					if w.Code != http.StatusBadRequest {
+						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+					}
+					return w.Code, response
+				}
+
+				When("every operation succeeds", func() {
+					It("should apply them in order and return each task", func() {
+						code, response := bulk(`{"operations": [
+							{"op": "create", "task": {"title": "Sprint review", "deadline": "2023-06-09", "priority": 2, "status": "Not Started", "category_id": 6}},
+							{"op": "update", "id": 5, "fields": {"priority": 1, "title": "Task 5 closed"}},
+							{"op": "move", "id": 5, "category_id": 6},
+							{"op": "delete", "id": 2, "version": 1}
+						]}`)
+						Expect(code).To(Equal(http.StatusOK))
+						Expect(response.Committed).To(BeTrue())
+						Expect(response.Results).To(HaveLen(4))
+						for i, result := range response.Results {
+							Expect(result.Index).To(Equal(i))
+							Expect(result.Status).To(Equal(http.StatusOK))
+						}
+						Expect(response.Results[0].Task.Title).To(Equal("Sprint review"))
+						Expect(response.Results[0].Task.UserID).To(Equal(1))
+						Expect(response.Results[0].ID).To(Equal(response.Results[0].Task.ID))
+						Expect(response.Results[2].Task.CategoryID).To(Equal(6))
+						Expect(response.Results[2].Task.Version).To(Equal(3))
+						Expect(response.Results[3].Task).To(BeNil())
+						Expect(response.Results[3].TrashID).To(BeNumerically(">", 0))
+
+						stored, err := taskRepo.GetByID(5)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(stored.Title).To(Equal("Task 5 closed"))
+						Expect(stored.Priority).To(Equal(1))
+						Expect(stored.CategoryID).To(Equal(6))
+						Expect(stored.Deadline.String()).To(Equal("2023-06-07"))
+						_, err = taskRepo.GetByID(2)
+						Expect(err).To(MatchError(model.ErrRecordNotFound))
+					})
+				})
+
+				When("an atomic operation fails", func() {
+					It("should apply none and answer with its status", func() {
+						code, response := bulk(`{"operations": [
+							{"op": "update", "id": 5, "fields": {"priority": 1}},
+							{"op": "delete", "id": 1},
+							{"op": "delete", "id": 2}
+						]}`)
+						Expect(code).To(Equal(http.StatusForbidden))
+						Expect(response.Committed).To(BeFalse())
+						Expect(response.Results[0].Status).To(Equal(http.StatusFailedDependency))
+						Expect(response.Results[1].Status).To(Equal(http.StatusForbidden))
+						Expect(response.Results[2].Status).To(Equal(http.StatusFailedDependency))
+
+						stored, _ := taskRepo.GetByID(5)
+						Expect(stored.Priority).To(Equal(5))
+						_, err := taskRepo.GetByID(2)
+						Expect(err).ShouldNot(HaveOccurred())
+
+						code, response = bulk(`{"mode": "atomic", "operations": [
+							{"op": "update", "id": 5, "fields": {"priority": 1}},
+							{"op": "move", "id": 5, "category_id": 42}
+						]}`)
+						Expect(code).To(Equal(http.StatusBadRequest))
+						stored, _ = taskRepo.GetByID(5)
+						Expect(stored.Priority).To(Equal(5))
+						Expect(stored.Version).To(Equal(1))
+					})
+				})
+
+				When("a best-effort operation fails", func() {
+					It("should apply the others", func() {
+						code, response := bulk(`{"mode": "best_effort", "operations": [
+							{"op": "update", "id": 5, "fields": {"priority": 1}},
+							{"op": "delete", "id": 1},
+							{"op": "update", "id": 42, "fields": {"priority": 1}},
+							{"op": "update", "id": 2, "fields": {"title": null}},
+							{"op": "update", "id": 2, "version": 7, "fields": {"priority": 3}},
+							{"op": "move", "id": 2, "category_id": 6}
+						]}`)
+						Expect(code).To(Equal(http.StatusOK))
+						Expect(response.Committed).To(BeTrue())
+						statuses := []int{}
+						for _, result := range response.Results {
+							statuses = append(statuses, result.Status)
+						}
+						Expect(statuses).To(Equal([]int{200, 403, 404, 400, 412, 200}))
+						Expect(response.Results[1].Error).To(Equal("Access denied: task belongs to different user"))
+
+						stored, _ := taskRepo.GetByID(5)
+						Expect(stored.Priority).To(Equal(1))
+						stored, _ = taskRepo.GetByID(2)
+						Expect(stored.Title).To(Equal("Task 2"))
+						Expect(stored.CategoryID).To(Equal(6))
+						stored, _ = taskRepo.GetByID(1)
+						Expect(stored).NotTo(BeNil())
+					})
+				})
+
+				When("sending an invalid request", func() {
+					It("should return status code 400", func() {
+						for _, body := range []string{
+							`{"operations": []}`,
+							`{"mode": "some", "operations": [{"op": "delete", "id": 5}]}`,
+							`{"operations": [{"op": "rename", "id": 5}]}`,
+							`{"operations": [{"op": "update", "id": 5}]}`,
+							`{"operations": [{"op": "move", "id": 5}]}`,
+							`{"operations": [{"op": "create"}]}`,
+						} {
+							code, _ := bulk(body)
+							Expect(code).To(Equal(http.StatusBadRequest), body)
+						}
+					})
+				})
+			})
+
+			Describe("Comments", func() {
+				do := func(method, url string, body any) *httptest.ResponseRecorder {
+					reqBody, _ := json.Marshal(body)
+					r, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				thread := func(taskID int) []model.Comment {
+					w := do("GET", fmt.Sprintf("/api/v1/task/%d/comments", taskID), nil)
+					Expect(w.Code).To(Equal(http.StatusOK))
+					var comments []model.Comment
+					Expect(json.Unmarshal(w.Body.Bytes(), &comments)).Should(Succeed())
+					return comments
+				}
+
+				var comment model.Comment
+
+				BeforeEach(func() {
+					w := do("POST", "/api/v1/task/5/comments", model.CommentRequest{Body: "Waiting for **review**"})
+					Expect(w.Code).To(Equal(http.StatusOK))
+					Expect(json.Unmarshal(w.Body.Bytes(), &comment)).Should(Succeed())
+				})
+
+				When("adding a comment", func() {
+					It("should return it with the user as author and an empty history", func() {
+						Expect(comment.TaskID).To(Equal(5))
+						Expect(comment.AuthorID).To(Equal(1))
+						Expect(comment.Body).To(Equal("Waiting for **review**"))
+						Expect(comment.History).To(BeEmpty())
+						Expect(strings.Contains(do("GET", "/api/v1/task/5/comments", nil).Body.String(), `"history":[]`)).To(BeTrue())
+
+						Expect(do("POST", "/api/v1/task/5/comments", model.CommentRequest{Body: "Reviewed"}).Code).To(Equal(http.StatusOK))
+						comments := thread(5)
+						Expect(comments).To(HaveLen(2))
+						Expect(comments[1].Body).To(Equal("Reviewed"))
+						Expect(thread(2)).To(BeEmpty())
+					})
+
+					It("should return status code 400 for an empty body", func() {
+						Expect(do("POST", "/api/v1/task/5/comments", model.CommentRequest{Body: "   "}).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("POST", "/api/v1/task/5/comments", map[string]string{}).Code).To(Equal(http.StatusBadRequest))
+					})
+				})
+
+				When("editing a comment", func() {
+					It("should keep the old body in its history", func() {
+						w := do("PUT", fmt.Sprintf("/api/v1/task/5/comments/%d", comment.ID), model.CommentRequest{Body: "Reviewed, see [notes](https://example.com/notes)"})
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						// Saving the same body again is not a revision
+						Expect(do("PUT", fmt.Sprintf("/api/v1/task/5/comments/%d", comment.ID), model.CommentRequest{Body: "Reviewed, see [notes](https://example.com/notes)"}).Code).To(Equal(http.StatusOK))
+
+						comments := thread(5)
+						Expect(comments).To(HaveLen(1))
+						Expect(comments[0].Body).To(Equal("Reviewed, see [notes](https://example.com/notes)"))
+						Expect(comments[0].CreatedAt).To(Equal(comment.CreatedAt))
+						Expect(comments[0].History).To(Equal([]model.CommentRevision{{Body: "Waiting for **review**", CreatedAt: comment.CreatedAt}}))
+					})
+
+					It("should return status code 404 for a comment of another task", func() {
+						Expect(do("PUT", fmt.Sprintf("/api/v1/task/2/comments/%d", comment.ID), model.CommentRequest{Body: "Moved"}).Code).To(Equal(http.StatusNotFound))
+						Expect(do("DELETE", "/api/v1/task/5/comments/99", nil).Code).To(Equal(http.StatusNotFound))
+					})
+				})
+
+				When("the task belongs to another user", func() {
+					It("should return status code 403", func() {
+						Expect(do("GET", "/api/v1/task/1/comments", nil).Code).To(Equal(http.StatusForbidden))
+						Expect(do("POST", "/api/v1/task/1/comments", model.CommentRequest{Body: "Hi"}).Code).To(Equal(http.StatusForbidden))
+						Expect(do("GET", "/api/v1/task/99/comments", nil).Code).To(Equal(http.StatusNotFound))
+					})
+				})
+
+				When("deleting", func() {
+					It("should remove the comment, and all comments with their task", func() {
+						Expect(do("DELETE", fmt.Sprintf("/api/v1/task/5/comments/%d", comment.ID), nil).Code).To(Equal(http.StatusOK))
+						Expect(thread(5)).To(BeEmpty())
+
+						Expect(do("POST", "/api/v1/task/5/comments", model.CommentRequest{Body: "Again"}).Code).To(Equal(http.StatusOK))
+						Expect(do("DELETE", "/api/v1/task/delete/5", nil).Code).To(Equal(http.StatusOK))
+						comments, err := filebasedDb.GetComments(5)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(comments).To(BeEmpty())
+					})
+				})
+			})
+
+			Describe("Attachments", func() {
+				png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 600)...)
+
+				upload := func(taskID int, name string, content []byte) *httptest.ResponseRecorder {
+					var body bytes.Buffer
+					form := multipart.NewWriter(&body)
+					part, _ := form.CreateFormFile("file", name)
+					part.Write(content)
+					form.Close()
+
+					r, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/task/%d/attachments", taskID), &body)
+					r.Header.Set("Content-Type", form.FormDataContentType())
+					r.AddCookie(SetCookie(apiServer))
+					w := httptest.NewRecorder()
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				do := func(method, url string) *httptest.ResponseRecorder {
+					r, _ := http.NewRequest(method, url, nil)
+					r.AddCookie(SetCookie(apiServer))
+					w := httptest.NewRecorder()
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				blobs := func() []string {
+					keys, err := filepath.Glob(filepath.Join(os.Getenv("APP_BLOB_DIR"), "1", "*"))
+					Expect(err).ShouldNot(HaveOccurred())
+					return keys
+				}
+
+				var attachment model.Attachment
+
+				BeforeEach(func() {
+					Expect(os.Setenv("APP_ATTACHMENT_MAX_SIZE", "1000")).To(Succeed())
+					Expect(os.Setenv("APP_ATTACHMENT_QUOTA", "1500")).To(Succeed())
+					DeferCleanup(os.Unsetenv, "APP_ATTACHMENT_MAX_SIZE")
+					DeferCleanup(os.Unsetenv, "APP_ATTACHMENT_QUOTA")
+					apiServer = main.RunServer(gin.New(), filebasedDb)
+
+					w := upload(5, "../screens/shot.png", png)
+					Expect(w.Code).To(Equal(http.StatusOK))
+					Expect(json.Unmarshal(w.Body.Bytes(), &attachment)).Should(Succeed())
+				})
+
+				When("uploading a file", func() {
+					It("should store it with the sniffed type and a clean name", func() {
+						Expect(attachment.TaskID).To(Equal(5))
+						Expect(attachment.UserID).To(Equal(1))
+						Expect(attachment.Name).To(Equal("shot.png"))
+						Expect(attachment.ContentType).To(Equal("image/png"))
+						Expect(attachment.Size).To(Equal(int64(len(png))))
+						Expect(blobs()).To(HaveLen(1))
+
+						w := do("GET", "/api/v1/task/5/attachments")
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var attachments []model.Attachment
+						Expect(json.Unmarshal(w.Body.Bytes(), &attachments)).Should(Succeed())
+						Expect(attachments).To(Equal([]model.Attachment{attachment}))
+						Expect(do("GET", "/api/v1/task/2/attachments").Body.String()).To(Equal("[]"))
+					})
+
+					It("should download it as an attachment", func() {
+						w := do("GET", fmt.Sprintf("/api/v1/task/5/attachments/%d", attachment.ID))
+						Expect(w.Code).To(Equal(http.StatusOK))
+						Expect(w.Body.Bytes()).To(Equal(png))
+						Expect(w.Header().Get("Content-Type")).To(Equal("image/png"))
+						Expect(w.Header().Get("Content-Disposition")).To(Equal(`attachment; filename=shot.png`))
+						Expect(w.Header().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+
+						Expect(do("GET", fmt.Sprintf("/api/v1/task/2/attachments/%d", attachment.ID)).Code).To(Equal(http.StatusNotFound))
+					})
+
+					It("should never serve a name with markup as HTML", func() {
+						w := upload(5, "<img src=x onerror=alert(1)>.txt", []byte("plain notes"))
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var notes model.Attachment
+						Expect(json.Unmarshal(w.Body.Bytes(), &notes)).Should(Succeed())
+						Expect(notes.Name).To(Equal("<img src=x onerror=alert(1)>.txt"))
+
+						w = do("GET", "/api/v1/task/5/attachments")
+						Expect(w.Header().Get("Content-Type")).To(HavePrefix("application/json"))
+						Expect(w.Body.String()).NotTo(ContainSubstring("<img"))
+
+						w = do("GET", fmt.Sprintf("/api/v1/task/5/attachments/%d", notes.ID))
+						Expect(w.Code).To(Equal(http.StatusOK))
+						Expect(w.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+						Expect(w.Header().Get("Content-Disposition")).To(Equal(`attachment; filename="<img src=x onerror=alert(1)>.txt"`))
+						Expect(w.Header().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+					})
+
+					It("should return status code 415 for a type that is not allowed", func() {
+						Expect(upload(5, "notes.txt", []byte("plain notes")).Code).To(Equal(http.StatusOK))
+						Expect(upload(5, "archive.png", []byte("PK\x03\x04 not really a picture")).Code).To(Equal(http.StatusUnsupportedMediaType))
+						Expect(upload(5, "page.txt", []byte("<html><body>hi</body></html>")).Code).To(Equal(http.StatusUnsupportedMediaType))
+					})
+
+					It("should return status code 413 over the size limit or the quota", func() {
+						Expect(upload(5, "big.txt", bytes.Repeat([]byte("a"), 1001)).Code).To(Equal(http.StatusRequestEntityTooLarge))
+						Expect(upload(5, "more.txt", bytes.Repeat([]byte("a"), 900)).Code).To(Equal(http.StatusRequestEntityTooLarge))
+						Expect(upload(5, "fits.txt", bytes.Repeat([]byte("a"), 800)).Code).To(Equal(http.StatusOK))
+						Expect(blobs()).To(HaveLen(2))
+					})
+
+					It("should return status code 400 without a file", func() {
+						Expect(do("POST", "/api/v1/task/5/attachments").Code).To(Equal(http.StatusBadRequest))
+					})
+				})
+
+				When("the task belongs to another user", func() {
+					It("should return status code 403", func() {
+						Expect(upload(1, "shot.png", png).Code).To(Equal(http.StatusForbidden))
+						Expect(do("GET", "/api/v1/task/1/attachments").Code).To(Equal(http.StatusForbidden))
+					})
+				})
+
+				When("deleting", func() {
+					It("should remove the attachment and its content", func() {
+						Expect(do("DELETE", fmt.Sprintf("/api/v1/task/5/attachments/%d", attachment.ID)).Code).To(Equal(http.StatusOK))
+						Expect(do("GET", fmt.Sprintf("/api/v1/task/5/attachments/%d", attachment.ID)).Code).To(Equal(http.StatusNotFound))
+						Expect(blobs()).To(BeEmpty())
+					})
+
+					It("should keep the content of a deleted task until it is purged", func() {
+						Expect(upload(2, "notes.txt", []byte("kept")).Code).To(Equal(http.StatusOK))
+						w := do("DELETE", "/api/v1/task/delete/5")
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var trashed model.TrashedResponse
+						Expect(json.Unmarshal(w.Body.Bytes(), &trashed)).Should(Succeed())
+
+						attachments, err := filebasedDb.GetAttachmentsByUserID(1)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(attachments).To(HaveLen(1))
+						Expect(attachments[0].Name).To(Equal("notes.txt"))
+						Expect(blobs()).To(HaveLen(2))
+
+						Expect(do("DELETE", fmt.Sprintf("/api/v1/trash/purge/%d", trashed.TrashID)).Code).To(Equal(http.StatusOK))
+						Expect(blobs()).To(HaveLen(1))
+					})
+				})
+			})
+
+			Describe("Time tracking", func() {
+				do := func(method, url string, body any) *httptest.ResponseRecorder {
+					var reqBody []byte
+					if body != nil {
+						reqBody, _ = json.Marshal(body)
+					}
+					r, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					return w
+				}
+
+				at := func(value string) *time.Time {
+					t, err := time.Parse(time.RFC3339, value)
+					Expect(err).ShouldNot(HaveOccurred())
+					return &t
+				}
+
+				When("starting and stopping a timer", func() {
+					It("should allow one running timer per user", func() {
+						w := do("POST", "/api/v1/task/5/timer/start", model.TimerRequest{Note: "Drafting"})
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var started model.TimeEntry
+						Expect(json.Unmarshal(w.Body.Bytes(), &started)).Should(Succeed())
+						Expect(started.TaskID).To(Equal(5))
+						Expect(started.End).To(BeNil())
+
+						Expect(do("POST", "/api/v1/task/2/timer/start", nil).Code).To(Equal(http.StatusConflict))
+						Expect(do("GET", "/api/v1/timer", nil).Body.String()).To(ContainSubstring(`"note":"Drafting"`))
+
+						w = do("POST", "/api/v1/timer/stop", nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var stopped model.TimeEntry
+						Expect(json.Unmarshal(w.Body.Bytes(), &stopped)).Should(Succeed())
+						Expect(stopped.ID).To(Equal(started.ID))
+						Expect(stopped.End).NotTo(BeNil())
+						Expect(stopped.End.After(stopped.Start)).To(BeTrue())
+
+						Expect(do("POST", "/api/v1/timer/stop", nil).Code).To(Equal(http.StatusConflict))
+						Expect(do("GET", "/api/v1/timer", nil).Code).To(Equal(http.StatusNotFound))
+						Expect(do("POST", "/api/v1/task/2/timer/start", nil).Code).To(Equal(http.StatusOK))
+					})
+
+					It("should return status code 403 for a task of another user", func() {
+						Expect(do("POST", "/api/v1/task/1/timer/start", nil).Code).To(Equal(http.StatusForbidden))
+						Expect(do("GET", "/api/v1/task/1/time", nil).Code).To(Equal(http.StatusForbidden))
+					})
+				})
+
+				When("adding and editing entries", func() {
+					It("should keep manual entries and their edits", func() {
+						w := do("POST", "/api/v1/task/5/time", model.TimeEntryRequest{Start: *at("2026-03-01T09:00:00Z"), End: at("2026-03-01T10:00:00Z"), Note: "Call"})
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var entry model.TimeEntry
+						Expect(json.Unmarshal(w.Body.Bytes(), &entry)).Should(Succeed())
+
+						w = do("PUT", fmt.Sprintf("/api/v1/task/5/time/%d", entry.ID), model.TimeEntryRequest{Start: *at("2026-03-01T09:00:00Z"), End: at("2026-03-01T11:30:00Z"), Note: "Long call"})
+						Expect(w.Code).To(Equal(http.StatusOK))
+
+						w = do("GET", "/api/v1/task/5/time", nil)
+						var entries []model.TimeEntry
+						Expect(json.Unmarshal(w.Body.Bytes(), &entries)).Should(Succeed())
+						Expect(entries).To(HaveLen(1))
+						Expect(entries[0].Note).To(Equal("Long call"))
+						Expect(entries[0].Duration(time.Now())).To(Equal(150 * time.Minute))
+
+						Expect(do("PUT", fmt.Sprintf("/api/v1/task/2/time/%d", entry.ID), model.TimeEntryRequest{Start: *at("2026-03-01T09:00:00Z"), End: at("2026-03-01T10:00:00Z")}).Code).To(Equal(http.StatusNotFound))
+						Expect(do("DELETE", fmt.Sprintf("/api/v1/task/5/time/%d", entry.ID), nil).Code).To(Equal(http.StatusOK))
+						Expect(do("GET", "/api/v1/task/5/time", nil).Body.String()).To(Equal("[]"))
+					})
+
+					It("should return status code 400 for an entry that ends before it starts or never", func() {
+						Expect(do("POST", "/api/v1/task/5/time", model.TimeEntryRequest{Start: *at("2026-03-01T10:00:00Z"), End: at("2026-03-01T09:00:00Z")}).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("POST", "/api/v1/task/5/time", model.TimeEntryRequest{Start: *at("2026-03-01T10:00:00Z")}).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("POST", "/api/v1/task/5/time", map[string]string{}).Code).To(Equal(http.StatusBadRequest))
+					})
+				})
+
+				When("requesting a timesheet", func() {
+					BeforeEach(func() {
+						user, err := userRepo.GetUserByEmail("test@mail.com")
+						Expect(err).ShouldNot(HaveOccurred())
+						user.Timezone = "Asia/Jakarta"
+						Expect(userRepo.UpdateUser(user)).To(Succeed())
+
+						// 23:00 to 01:00 in Jakarta, an hour on each day
+						Expect(do("POST", "/api/v1/task/5/time", model.TimeEntryRequest{Start: *at("2026-03-01T16:00:00Z"), End: at("2026-03-01T18:00:00Z")}).Code).To(Equal(http.StatusOK))
+						Expect(do("POST", "/api/v1/task/2/time", model.TimeEntryRequest{Start: *at("2026-03-02T02:00:00Z"), End: at("2026-03-02T03:30:00Z")}).Code).To(Equal(http.StatusOK))
+					})
+
+					It("should add up hours by day, category and task in the user's timezone", func() {
+						w := do("GET", "/api/v1/timesheet?from=2026-03-01&to=2026-03-02", nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						var sheet model.Timesheet
+						Expect(json.Unmarshal(w.Body.Bytes(), &sheet)).Should(Succeed())
+
+						Expect(sheet.Timezone).To(Equal("Asia/Jakarta"))
+						Expect(sheet.Rows).To(Equal([]model.TimesheetRow{
+							{Date: "2026-03-01", CategoryID: 3, Category: "Category 3", TaskID: 5, Task: "Task 5", Seconds: 3600, Hours: 1},
+							{Date: "2026-03-02", CategoryID: 2, Category: "Category 2", TaskID: 2, Task: "Task 2", Seconds: 5400, Hours: 1.5},
+							{Date: "2026-03-02", CategoryID: 3, Category: "Category 3", TaskID: 5, Task: "Task 5", Seconds: 3600, Hours: 1},
+						}))
+						Expect(sheet.Days).To(Equal([]model.TimesheetDay{
+							{Date: "2026-03-01", Seconds: 3600, Hours: 1},
+							{Date: "2026-03-02", Seconds: 9000, Hours: 2.5},
+						}))
+						Expect(sheet.Categories).To(Equal([]model.TimesheetCategory{
+							{CategoryID: 2, Category: "Category 2", Seconds: 5400, Hours: 1.5},
+							{CategoryID: 3, Category: "Category 3", Seconds: 7200, Hours: 2},
+						}))
+						Expect(sheet.TotalHours).To(Equal(3.5))
+
+						w = do("GET", "/api/v1/timesheet?from=2026-03-02&to=2026-03-02", nil)
+						Expect(json.Unmarshal(w.Body.Bytes(), &sheet)).Should(Succeed())
+						Expect(sheet.TotalHours).To(Equal(2.5))
+					})
+
+					It("should export the rows as CSV", func() {
+						w := do("GET", "/api/v1/timesheet?from=2026-03-01&to=2026-03-02&format=csv", nil)
+						Expect(w.Code).To(Equal(http.StatusOK))
+						Expect(w.Header().Get("Content-Type")).To(Equal("text/csv; charset=utf-8"))
+						Expect(w.Header().Get("Content-Disposition")).To(Equal("attachment; filename=timesheet-2026-03-01-2026-03-02.csv"))
+						Expect(w.Body.String()).To(Equal("date,category,task,hours\n" +
+							"2026-03-01,Category 3,Task 5,1.00\n" +
+							"2026-03-02,Category 2,Task 2,1.50\n" +
+							"2026-03-02,Category 3,Task 5,1.00\n"))
+					})
+
+					It("should return status code 400 for a bad range or format", func() {
+						Expect(do("GET", "/api/v1/timesheet?from=2026-03-02&to=2026-03-01", nil).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("GET", "/api/v1/timesheet?from=March&to=2026-03-01", nil).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("GET", "/api/v1/timesheet?from=2025-01-01&to=2026-03-01", nil).Code).To(Equal(http.StatusBadRequest))
+						Expect(do("GET", "/api/v1/timesheet?from=2026-03-01&to=2026-03-01&format=xml", nil).Code).To(Equal(http.StatusBadRequest))
 					})
 				})
 
-				When("updating existing task", func() {
-					It("should return status code 200", func() {
-						updatedTask := model.Task{
-							ID:         2,
-							Title:      "Updated with API Task 2",
-							Deadline:   "2023-05-30",
-							Priority:   5,
-							CategoryID: 2,
-							Status:     "In Progress",
-						}
-						reqBody, _ := json.Marshal(updatedTask)
+				When("deleting the task", func() {
+					It("should drop its time entries", func() {
+						Expect(do("POST", "/api/v1/task/5/timer/start", nil).Code).To(Equal(http.StatusOK))
+						Expect(do("DELETE", "/api/v1/task/delete/5", nil).Code).To(Equal(http.StatusOK))
+						Expect(do("GET", "/api/v1/timer", nil).Code).To(Equal(http.StatusNotFound))
+					})
+				})
+			})
 
-						r, _ := http.NewRequest("PUT", fmt.Sprintf("/api/v1/task/update/%d", 2), bytes.NewReader(reqBody))
-						w := httptest.NewRecorder()
+			Describe("Search", func() {
+				search := func(query string) (int, []model.SearchResult) {
+					r, _ := http.NewRequest("GET", "/api/v1/search?"+query, nil)
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
 
-						r.AddCookie(SetCookie(apiServer))
-						apiServer.ServeHTTP(w, r)
-						Expect(w.Code).To(Equal(http.StatusOK))
+					var results []model.SearchResult
+					if w.Code == http.StatusOK {
+						Expect(json.Unmarshal(w.Body.Bytes(), &results)).Should(Succeed())
+					}
+					return w.Code, results
+				}
 
-						var response model.SuccessResponse
-						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
-						Expect(response.Message).To(Equal("update task success"))
-					})
+				It("should rank the user's tasks matching every word", func() {
+					code, results := search("q=Task")
+					Expect(code).To(Equal(http.StatusOK))
+					Expect(results).To(HaveLen(2))
+					Expect([]int{results[0].Task.ID, results[1].Task.ID}).To(Equal([]int{5, 2}))
+					Expect(results[0].Matched).To(Equal([]string{"title"}))
+
+					// One-letter words are not indexed, "3" is left out
+					_, results = search("q=categ+3")
+					Expect(results).To(HaveLen(2))
+					Expect(results[0].Matched).To(Equal([]string{"category"}))
+					_, results = search("q=task+categ")
+					Expect(results[0].Matched).To(Equal([]string{"title", "category"}))
+
+					_, results = search("q=task&limit=1")
+					Expect(results).To(HaveLen(1))
 				})
 
-				When("sending invalid request", func() {
-					It("should return status code 400", func() {
-						reqBody := []byte("invalid request body")
-						r, _ := http.NewRequest("PUT", "/api/v1/task/update/2", bytes.NewReader(reqBody))
-						w := httptest.NewRecorder()
+				It("should find a task by its comments until it is deleted", func() {
+					r, _ := http.NewRequest("POST", "/api/v1/task/5/comments", strings.NewReader(`{"body":"Waiting for the budget review"}`))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusOK))
 
-						r.AddCookie(SetCookie(apiServer))
-						apiServer.ServeHTTP(w, r)
+					code, results := search("q=budg")
+					Expect(code).To(Equal(http.StatusOK))
+					Expect(results).To(HaveLen(1))
+					Expect(results[0].Task.ID).To(Equal(5))
+					Expect(results[0].Matched).To(Equal([]string{"comment"}))
 
-						Expect(w.Code).To(Equal(http.StatusBadRequest))
+					r, _ = http.NewRequest("DELETE", "/api/v1/task/delete/5", nil)
+					w = httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusOK))
 
-						var response model.ErrorResponse
-						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
-						Expect(response.Error).NotTo(BeNil())
-					})
+					code, results = search("q=budget")
+					Expect(code).To(Equal(http.StatusOK))
+					Expect(results).To(BeEmpty())
+				})
+
+				It("should return status code 400 for a query without words or a bad limit", func() {
+					for _, query := range []string{"", "q=", "q=a+%21", "q=task&limit=0", "q=task&limit=x"} {
+						code, _ := search(query)
+						Expect(code).To(Equal(http.StatusBadRequest), query)
+					}
 				})
 			})
 
@@ -1016,7 +3152,48 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 
 						var response []model.Task
 						Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
-						Expect(response).To(Equal([]model.Task{insertTasks[1], insertTasks[4]}))
+						Expect(unversioned(response)).To(Equal([]model.Task{insertTasks[1], insertTasks[4]}))
+					})
+				})
+
+				When("tasks have deadlines", func() {
+					It("should compute overdue and due_in in the user's timezone", func() {
+						// Today west of every zone is still tomorrow's deadline in the
+						// far west and already past in the far east
+						west, _ := time.LoadLocation("Etc/GMT+12")
+						today := time.Now().In(west)
+						Expect(taskRepo.Store(&model.Task{ID: 6, Title: "Today", Deadline: model.DateDeadline(today.Date()), Status: "In Progress", CategoryID: 6, UserID: 1})).To(Succeed())
+
+						list := func(timezone string) map[int]model.TaskResponse {
+							user, err := userRepo.GetUserByEmail("test@mail.com")
+							Expect(err).ShouldNot(HaveOccurred())
+							user.Timezone = timezone
+							Expect(userRepo.UpdateUser(user)).To(Succeed())
+
+							r, _ := http.NewRequest("GET", "/api/v1/task/list", nil)
+							w := httptest.NewRecorder()
+							r.AddCookie(SetCookie(apiServer))
+							apiServer.ServeHTTP(w, r)
+							Expect(w.Code).To(Equal(http.StatusOK))
+
+							var response []model.TaskResponse
+							Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+							byID := map[int]model.TaskResponse{}
+							for _, task := range response {
+								byID[task.ID] = task
+							}
+							return byID
+						}
+
+						tasks := list("Etc/GMT+12")
+						Expect(tasks[2].Overdue).To(BeTrue())
+						Expect(*tasks[2].DueIn).To(BeNumerically("<", 0))
+						Expect(tasks[6].Overdue).To(BeFalse())
+						Expect(*tasks[6].DueIn).To(BeNumerically(">", 0))
+
+						tasks = list("Pacific/Kiritimati")
+						Expect(tasks[6].Overdue).To(BeTrue())
+						Expect(*tasks[6].DueIn).To(BeNumerically("<", 0))
 					})
 				})
 			})
@@ -1051,6 +3228,210 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 			})
 		})
 
+		Describe("Trash API", func() {
+			do := func(method, url string) *httptest.ResponseRecorder {
+				r, _ := http.NewRequest(method, url, nil)
+				r.AddCookie(SetCookie(apiServer))
+				w := httptest.NewRecorder()
+				apiServer.ServeHTTP(w, r)
+				return w
+			}
+
+			trash := func(url string) int {
+				w := do("DELETE", url)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				var response model.TrashedResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+				Expect(response.TrashID).To(BeNumerically(">", 0))
+				return response.TrashID
+			}
+
+			list := func() []model.TrashItem {
+				w := do("GET", "/api/v1/trash/list")
+				Expect(w.Code).To(Equal(http.StatusOK))
+				var items []model.TrashItem
+				Expect(json.Unmarshal(w.Body.Bytes(), &items)).Should(Succeed())
+				return items
+			}
+
+			When("a task is deleted", func() {
+				It("should list it in the trash and restore it", func() {
+					id := trash("/api/v1/task/delete/5")
+					_, err := taskRepo.GetByID(5)
+					Expect(err).To(MatchError(model.ErrRecordNotFound))
+
+					items := list()
+					Expect(items).To(HaveLen(1))
+					Expect(items[0].ID).To(Equal(id))
+					Expect(items[0].Kind).To(Equal(model.TrashTask))
+					Expect(items[0].Title).To(Equal("Task 5"))
+					Expect(items[0].Tasks).To(Equal(1))
+					Expect(*items[0].PurgeAt).To(Equal(items[0].DeletedAt.Add(30 * 24 * time.Hour)))
+
+					Expect(do("POST", fmt.Sprintf("/api/v1/trash/restore/%d", id)).Code).To(Equal(http.StatusOK))
+					Expect(do("GET", "/api/v1/task/get/5").Code).To(Equal(http.StatusOK))
+					Expect(list()).To(BeEmpty())
+					Expect(do("POST", fmt.Sprintf("/api/v1/trash/restore/%d", id)).Code).To(Equal(http.StatusNotFound))
+				})
+
+				It("should purge it for good", func() {
+					id := trash("/api/v1/task/delete/5")
+					Expect(do("DELETE", fmt.Sprintf("/api/v1/trash/purge/%d", id)).Code).To(Equal(http.StatusOK))
+					Expect(list()).To(BeEmpty())
+					Expect(do("POST", fmt.Sprintf("/api/v1/trash/restore/%d", id)).Code).To(Equal(http.StatusNotFound))
+				})
+			})
+
+			When("a category is deleted with its tasks", func() {
+				BeforeEach(func() {
+					Expect(taskRepo.Store(&model.Task{ID: 6, Title: "Task 6", Deadline: model.DateDeadline(2023, 6, 8), Priority: 1, Status: "In Progress", CategoryID: 6, UserID: 1})).To(Succeed())
+				})
+
+				It("should restore both", func() {
+					id := trash("/api/v1/category/delete/6?mode=cascade")
+					items := list()
+					Expect(items).To(HaveLen(1))
+					Expect(items[0].Kind).To(Equal(model.TrashCategory))
+					Expect(items[0].Tasks).To(Equal(1))
+
+					Expect(do("POST", fmt.Sprintf("/api/v1/trash/restore/%d", id)).Code).To(Equal(http.StatusOK))
+					Expect(do("GET", "/api/v1/category/get/6").Code).To(Equal(http.StatusOK))
+					task, err := taskRepo.GetByID(6)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(task.CategoryID).To(Equal(6))
+				})
+
+				It("should return status code 409 for a task whose category is gone", func() {
+					taskID := trash("/api/v1/task/delete/6")
+					categoryID := trash("/api/v1/category/delete/6")
+
+					w := do("POST", fmt.Sprintf("/api/v1/trash/restore/%d", taskID))
+					Expect(w.Code).To(Equal(http.StatusConflict))
+					var response model.ErrorResponse
+					Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+					Expect(response.Error).To(Equal("cannot restore: category not found"))
+					Expect(list()).To(HaveLen(2))
+
+					Expect(do("POST", fmt.Sprintf("/api/v1/trash/restore/%d", categoryID)).Code).To(Equal(http.StatusOK))
+					Expect(do("POST", fmt.Sprintf("/api/v1/trash/restore/%d", taskID)).Code).To(Equal(http.StatusOK))
+				})
+			})
+
+			When("the entry belongs to another user", func() {
+				It("should return status code 403", func() {
+					entry, err := filebasedDb.TrashTask(1)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(list()).To(BeEmpty())
+					Expect(do("POST", fmt.Sprintf("/api/v1/trash/restore/%d", entry.ID)).Code).To(Equal(http.StatusForbidden))
+					Expect(do("DELETE", fmt.Sprintf("/api/v1/trash/purge/%d", entry.ID)).Code).To(Equal(http.StatusForbidden))
+					Expect(do("DELETE", "/api/v1/trash/purge/99").Code).To(Equal(http.StatusNotFound))
+				})
+			})
+
+			When("sending without cookie", func() {
+				It("should return status code 401", func() {
+					r, _ := http.NewRequest("GET", "/api/v1/trash/list", nil)
+					w := httptest.NewRecorder()
+					r.Header.Set("Content-Type", "application/json")
+					apiServer.ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusUnauthorized))
+				})
+			})
+
+			When("the retention passes", func() {
+				It("should purge the expired entries", func() {
+					trash("/api/v1/task/delete/5")
+					blobs, err := blob.New()
+					Expect(err).ShouldNot(HaveOccurred())
+					attachmentService := service.NewAttachmentService(repo.NewAttachmentRepo(filebasedDb), blobs, 1000, 0)
+					trashService := service.NewTrashService(repo.NewTrashRepo(filebasedDb), attachmentService, time.Hour)
+
+					Expect(trashService.PurgeExpired(time.Now())).To(Equal(0))
+					Expect(trashService.PurgeExpired(time.Now().Add(2 * time.Hour))).To(Equal(1))
+					Expect(list()).To(BeEmpty())
+				})
+
+				It("should keep entries forever with a retention of zero", func() {
+					Expect(os.Setenv("APP_TRASH_RETENTION", "0")).To(Succeed())
+					DeferCleanup(os.Unsetenv, "APP_TRASH_RETENTION")
+					apiServer = main.RunServer(gin.New(), filebasedDb)
+
+					trash("/api/v1/task/delete/5")
+					Expect(list()[0].PurgeAt).To(BeNil())
+				})
+			})
+		})
+
+		Describe("Workflow API", func() {
+			When("the user has not configured a workflow", func() {
+				It("should return the default one", func() {
+					r, _ := http.NewRequest("GET", "/api/v1/workflow", nil)
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusOK))
+
+					var workflow model.Workflow
+					Expect(json.Unmarshal(w.Body.Bytes(), &workflow)).Should(Succeed())
+					Expect(workflow).To(Equal(model.DefaultWorkflow(1)))
+				})
+			})
+
+			When("saving a custom workflow", func() {
+				It("should enforce its transitions", func() {
+					workflow := model.Workflow{
+						Statuses: []model.WorkflowStatus{
+							{Name: "Backlog", Category: model.StatusTodo},
+							{Name: "Review", Category: model.StatusInProgress},
+							{Name: "Shipped", Category: model.StatusDone},
+						},
+						Transitions: map[string][]string{
+							"Backlog": {"Review"},
+							"Review":  {"Backlog", "Shipped"},
+						},
+					}
+					reqBody, _ := json.Marshal(workflow)
+					r, _ := http.NewRequest("PUT", "/api/v1/workflow", bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusOK))
+
+					// Task 5 is "In Progress", which the new workflow does not know
+					Expect(taskService.Transition(5, model.TaskTransition{Status: "Review"}, 0)).Error().ShouldNot(HaveOccurred())
+					_, err := taskService.Transition(5, model.TaskTransition{Status: "Shipped"}, 0)
+					Expect(err).ShouldNot(HaveOccurred())
+					_, err = taskService.Transition(5, model.TaskTransition{Status: "Backlog"}, 0)
+					Expect(err).To(MatchError(model.ErrIllegalTransition))
+					Expect(err).To(MatchError(`cannot move task from "Shipped" to "Backlog": "Shipped" is a final status`))
+
+					task, err := taskRepo.GetByID(5)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(task.Status).To(Equal("Shipped"))
+					Expect(task.CompletedAt).NotTo(BeNil())
+				})
+			})
+
+			When("saving an invalid workflow", func() {
+				It("should return status code 400", func() {
+					workflow := model.Workflow{
+						Statuses:    []model.WorkflowStatus{{Name: "Open", Category: model.StatusTodo}},
+						Transitions: map[string][]string{"Open": {"Closed"}},
+					}
+					reqBody, _ := json.Marshal(workflow)
+					r, _ := http.NewRequest("PUT", "/api/v1/workflow", bytes.NewReader(reqBody))
+					w := httptest.NewRecorder()
+					r.AddCookie(SetCookie(apiServer))
+					apiServer.ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+					var response model.ErrorResponse
+					Expect(json.Unmarshal(w.Body.Bytes(), &response)).Should(Succeed())
+					Expect(response.Error).To(Equal(`invalid workflow: no status in category "done"`))
+				})
+			})
+		})
+
 		Describe("HTML", func() {
 			Describe("views/main/index.html", func() {
 				var (
@@ -1277,6 +3658,433 @@ var _ = Describe("Task Tracker Plus", Ordered, func() {
 					})
 				})
 			})
+
+			Describe("Web pages", func() {
+				var webServer *httptest.Server
+				var cookie *http.Cookie
+				var parent model.Task
+
+				// markup is what a user could type into a field to run a script
+				// in the browser of whoever views it
+				markup := func(field string) string {
+					return fmt.Sprintf("<img src=x onerror=alert('%s')>", field)
+				}
+
+				get := func(target string) string {
+					r, _ := http.NewRequest("GET", webServer.URL+target, nil)
+					r.AddCookie(cookie)
+					// Not followed, a redirect is the error modal of the page
+					resp, err := http.DefaultTransport.RoundTrip(r)
+					Expect(err).ShouldNot(HaveOccurred())
+					defer resp.Body.Close()
+					body, err := ioutil.ReadAll(resp.Body)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(http.StatusOK), resp.Header.Get("Location"))
+					return string(body)
+				}
+
+				expectEscaped := func(page string, fields ...string) {
+					// A template that fails halfway still answers 200
+					Expect(page).To(ContainSubstring("</body>"))
+					for _, field := range fields {
+						Expect(page).NotTo(ContainSubstring(markup(field)))
+						Expect(page).To(ContainSubstring(template.HTMLEscapeString(markup(field))))
+					}
+				}
+
+				BeforeEach(func() {
+					// The web pages read the API over HTTP, so both run on one
+					// test server
+					router := main.RunClient(main.RunServer(gin.New(), filebasedDb), main.Resources, filebasedDb)
+					webServer = httptest.NewServer(router)
+					DeferCleanup(webServer.Close)
+					baseURL := config.BaseURL
+					config.BaseURL = webServer.URL
+					DeferCleanup(func() { config.BaseURL = baseURL })
+					cookie = SetCookie(router)
+
+					category := model.Category{ID: 6, Name: markup("category"), UserID: 1}
+					Expect(categoryRepo.Store(&category)).To(Succeed())
+					// The API refuses such a tag name, a tag from before it did
+					// is stored directly
+					tag := model.Tag{Name: markup("tag"), Color: "#ff0000", UserID: 1}
+					Expect(repo.NewTagRepo(filebasedDb).Store(&tag)).To(Succeed())
+
+					parent = model.Task{ID: 20, Title: markup("parent"), Deadline: model.DateDeadline(2023, 6, 9), Priority: 3, Status: "In Progress", CategoryID: 6, UserID: 1, TagIDs: []int{tag.ID}}
+					Expect(taskRepo.Store(&parent)).To(Succeed())
+					subtask := model.Task{ID: 21, Title: markup("subtask"), Deadline: model.DateDeadline(2023, 6, 8), Priority: 2, Status: "Not Started", CategoryID: 6, UserID: 1, ParentID: parent.ID}
+					Expect(taskRepo.Store(&subtask)).To(Succeed())
+				})
+
+				It("should escape the titles of tasks and their parents on the task page", func() {
+					expectEscaped(get("/client/task"), "parent", "subtask", "category")
+				})
+
+				It("should escape task titles, categories and tags on the dashboard", func() {
+					page := get("/client/dashboard")
+					expectEscaped(page, "parent", "subtask", "category", "tag")
+					Expect(page).To(ContainSubstring(`style="background-color: #ff0000">#` + template.HTMLEscapeString(markup("tag"))))
+				})
+
+				It("should escape category names on the category page", func() {
+					expectEscaped(get("/client/category"), "category")
+				})
+
+				It("should escape the titles of trashed tasks", func() {
+					r, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/task/delete/%d", webServer.URL, parent.ID), nil)
+					r.AddCookie(cookie)
+					resp, err := http.DefaultClient.Do(r)
+					Expect(err).ShouldNot(HaveOccurred())
+					resp.Body.Close()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+					expectEscaped(get("/client/trash"), "parent")
+				})
+
+				It("should escape the message of a modal", func() {
+					expectEscaped(get("/client/modal?status=error&message="+url.QueryEscape(markup("message"))), "message")
+				})
+
+				It("should escape the names of saved views", func() {
+					reqBody, _ := json.Marshal(model.SavedView{Name: markup("view"), Query: "sort=priority", Pinned: true})
+					r, _ := http.NewRequest("POST", webServer.URL+"/api/v1/view/add", bytes.NewReader(reqBody))
+					r.AddCookie(cookie)
+					resp, err := http.DefaultClient.Do(r)
+					Expect(err).ShouldNot(HaveOccurred())
+					resp.Body.Close()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+					expectEscaped(get("/client/dashboard?view=sort%3Dpriority"), "view")
+					expectEscaped(get("/client/task"), "view")
+				})
+
+				It("should escape the search query", func() {
+					expectEscaped(get("/client/dashboard?q="+url.QueryEscape(markup("query"))), "query")
+				})
+
+				It("should escape comment bodies", func() {
+					reqBody, _ := json.Marshal(model.CommentRequest{Body: markup("comment") + " **bold**"})
+					r, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/task/%d/comments", webServer.URL, parent.ID), bytes.NewReader(reqBody))
+					r.AddCookie(cookie)
+					resp, err := http.DefaultClient.Do(r)
+					Expect(err).ShouldNot(HaveOccurred())
+					resp.Body.Close()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+					var thread []struct {
+						HTML string `json:"html"`
+					}
+					Expect(json.Unmarshal([]byte(get(fmt.Sprintf("/client/task/comments/%d", parent.ID))), &thread)).Should(Succeed())
+					Expect(thread).To(HaveLen(1))
+					Expect(thread[0].HTML).To(Equal("<p>" + template.HTMLEscapeString(markup("comment")) + " <strong>bold</strong></p>"))
+				})
+			})
+		})
+	})
+})
+
+var _ = Describe("Blob stores", func() {
+	for _, backend := range []struct {
+		name string
+		open func(dir string) (blob.Store, error)
+	}{
+		{"fs", func(dir string) (blob.Store, error) { return blob.NewFS(dir) }},
+		{"bbolt", func(dir string) (blob.Store, error) { return blob.NewBolt(filepath.Join(dir, "blobs.db")) }},
+	} {
+		backend := backend
+
+		It("should put, list, open and delete blobs in "+backend.name, func() {
+			store, err := backend.open(GinkgoT().TempDir())
+			Expect(err).ShouldNot(HaveOccurred())
+			DeferCleanup(store.Close)
+
+			Expect(store.Put("1/a", strings.NewReader("first"))).To(Succeed())
+			Expect(store.Put("1/b", strings.NewReader("second"))).To(Succeed())
+			Expect(store.Put("12/c", strings.NewReader("other user"))).To(Succeed())
+			Expect(store.Put("1/a", strings.NewReader("replaced"))).To(Succeed())
+
+			keys, err := store.List("1/")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keys).To(Equal([]string{"1/a", "1/b"}))
+
+			content, err := store.Open("1/a")
+			Expect(err).ShouldNot(HaveOccurred())
+			b, err := ioutil.ReadAll(content)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(content.Close()).To(Succeed())
+			Expect(string(b)).To(Equal("replaced"))
+
+			Expect(store.Delete("1/a")).To(Succeed())
+			Expect(store.Delete("1/a")).To(Succeed())
+			_, err = store.Open("1/a")
+			Expect(err).To(MatchError(blob.ErrNotFound))
+
+			for _, key := range []string{"", "../escape", "1/../../escape", "/abs"} {
+				Expect(store.Put(key, strings.NewReader("x"))).To(MatchError(blob.ErrInvalidKey))
+			}
+		})
+	}
+
+	It("should refuse blobs over the bbolt size limit", func() {
+		store, err := blob.NewBolt(filepath.Join(GinkgoT().TempDir(), "blobs.db"))
+		Expect(err).ShouldNot(HaveOccurred())
+		DeferCleanup(store.Close)
+
+		Expect(store.Put("1/big", bytes.NewReader(make([]byte, blob.BoltMaxSize+1)))).To(MatchError(blob.ErrTooLarge))
+		Expect(store.Put("1/fits", bytes.NewReader(make([]byte, blob.BoltMaxSize)))).To(Succeed())
+	})
+})
+
+var _ = storetest.Contract("bbolt", func() db.Store {
+	Expect(os.Setenv("APP_DB_PATH", filepath.Join(GinkgoT().TempDir(), "file.db"))).To(Succeed())
+	DeferCleanup(os.Unsetenv, "APP_DB_PATH")
+
+	filebasedDb, err := filebased.InitDB()
+	Expect(err).ShouldNot(HaveOccurred())
+	return filebasedDb
+})
+
+var _ = storetest.Contract("memory", func() db.Store {
+	return memory.InitDB()
+})
+
+var _ = storetest.Contract("postgres", func() db.Store {
+	if os.Getenv("APP_TEST_POSTGRES") == "" {
+		Skip("set APP_TEST_POSTGRES and the APP_DB_* connection variables to run against Postgres")
+	}
+
+	cred := config.PostgresCredential()
+	cred.Schema = "task_tracker_test"
+
+	postgresDb, err := postgres.InitDB(cred)
+	Expect(err).ShouldNot(HaveOccurred())
+	Expect(postgresDb.Reset()).To(Succeed())
+	return postgresDb
+})
+
+var _ = Describe("bbolt migrations", func() {
+	var path string
+
+	// writeLegacy writes the layout older versions used: decimal string keys,
+	// no indexes and no Meta bucket
+	writeLegacy := func() {
+		legacy, err := bbolt.Open(path, 0600, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		err = legacy.Update(func(tx *bbolt.Tx) error {
+			put := func(bucket, key string, v interface{}) {
+				b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+				Expect(err).ShouldNot(HaveOccurred())
+				j, err := json.Marshal(v)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(b.Put([]byte(key), j)).To(Succeed())
+			}
+			put("Users", "1", model.User{ID: 1, Fullname: "Legacy", Email: "legacy@mail.com"})
+			put("Categories", "3", model.Category{ID: 3, Name: "Work", UserID: 1})
+			put("Categories", "4", model.Category{ID: 4, Name: "acv", UserID: 1})
+			// Deadlines were free-form strings
+			put("Tasks", "9", map[string]interface{}{"id": 9, "title": "Old task", "deadline": "30/05/2023", "category_id": 3, "user_id": 1})
+			put("Tasks", "10", map[string]interface{}{"id": 10, "title": "Older task", "deadline": "next week", "category_id": 3, "user_id": 1})
+			_, err := tx.CreateBucketIfNotExists([]byte("Sessions"))
+			return err
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(legacy.Close()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "legacy.db")
+		Expect(os.Setenv("APP_DB_PATH", path)).To(Succeed())
+		DeferCleanup(os.Unsetenv, "APP_DB_PATH")
+	})
+
+	It("should stamp a new file with the latest schema version", func() {
+		filebasedDb, err := filebased.InitDB()
+		Expect(err).ShouldNot(HaveOccurred())
+		DeferCleanup(filebasedDb.CloseDB)
+
+		version, err := filebased.SchemaVersion(filebasedDb.DB)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(version).To(Equal(filebased.LatestSchemaVersion()))
+	})
+
+	It("should rekey records, build the indexes and drop the acv category on open", func() {
+		writeLegacy()
+
+		filebasedDb, err := filebased.InitDB()
+		Expect(err).ShouldNot(HaveOccurred())
+		DeferCleanup(filebasedDb.CloseDB)
+
+		user, err := filebasedDb.GetUserByEmail("legacy@mail.com")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(user.ID).To(Equal(1))
+
+		tasks, err := filebasedDb.GetTasksByUserID(1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(tasks).To(HaveLen(2))
+		Expect(tasks[0].ID).To(Equal(9))
+		Expect(tasks[1].ID).To(Equal(10))
+		Expect(tasks[0].Version).To(Equal(1))
+		Expect(tasks[0].Deadline).To(Equal(model.DateDeadline(2023, 5, 30)))
+		Expect(tasks[1].Deadline.IsZero()).To(BeTrue())
+
+		list, err := filebasedDb.GetTaskListByCategory(3)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(list).To(HaveLen(2))
+
+		categories, err := filebasedDb.GetCategoriesByUserID(1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(categories).To(HaveLen(1))
+		Expect(categories[0].Name).To(Equal("Work"))
+
+		// New IDs continue after the highest legacy one
+		Expect(filebasedDb.StoreTask(model.Task{Title: "New task", CategoryID: 3, UserID: 1})).To(Succeed())
+		task, err := filebasedDb.GetTaskByID(11)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(task.Title).To(Equal("New task"))
+	})
+
+	It("should convert the string deadlines of a version 4 file", func() {
+		filebasedDb, err := filebased.InitDB()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filebasedDb.CreateDefaultCategoriesForUser(1)).To(Succeed())
+
+		// What the previous binary wrote: any string, and schema version 4
+		err = filebasedDb.DB.Update(func(tx *bbolt.Tx) error {
+			for id, deadline := range map[int]string{1: "2023-05-30", 2: "2023-05-30T17:00:00+07:00", 3: "tomorrow"} {
+				key := make([]byte, 8)
+				binary.BigEndian.PutUint64(key, uint64(id))
+				j, err := json.Marshal(map[string]interface{}{"id": id, "title": "Task", "deadline": deadline, "category_id": 1, "user_id": 1, "version": 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tx.Bucket([]byte("Tasks")).Put(key, j)).To(Succeed())
+			}
+			version := make([]byte, 8)
+			binary.BigEndian.PutUint64(version, 4)
+			return tx.Bucket([]byte("Meta")).Put([]byte("schema_version"), version)
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		results, err := filebased.Migrate(filebasedDb.DB, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(HaveLen(11))
+		Expect(results[0].Summary).To(Equal("converted 1 deadlines, cleared 1 unreadable ones"))
+
+		task, err := filebasedDb.GetTaskByID(1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(task.Deadline).To(Equal(model.DateDeadline(2023, 5, 30)))
+		task, err = filebasedDb.GetTaskByID(2)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(task.Deadline).To(Equal(model.Deadline{At: time.Date(2023, 5, 30, 10, 0, 0, 0, time.UTC)}))
+		task, err = filebasedDb.GetTaskByID(3)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(task.Deadline.IsZero()).To(BeTrue())
+
+		// The search index is built for the records written without it
+		Expect(results[7].Summary).To(Equal("indexed 3 tasks"))
+		matches, err := filebasedDb.SearchTasks(1, []string{"task"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(matches).To(HaveLen(3))
+
+		// So are the sort indexes, tasks without a deadline last
+		Expect(results[8].Summary).To(Equal("indexed 3 tasks"))
+		page, err := filebasedDb.GetTaskPage(1, model.TaskQuery{Sort: model.SortDeadline})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect([]int{page.Tasks[0].ID, page.Tasks[1].ID, page.Tasks[2].ID}).To(Equal([]int{1, 2, 3}))
+		Expect(filebasedDb.CloseDB()).To(Succeed())
+	})
+
+	It("should fold the statuses of a version 5 file into the default workflow", func() {
+		filebasedDb, err := filebased.InitDB()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filebasedDb.CreateDefaultCategoriesForUser(1)).To(Succeed())
+
+		updatedAt := time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC)
+		err = filebasedDb.DB.Update(func(tx *bbolt.Tx) error {
+			for id, status := range map[int]string{1: "selesai", 2: " in progress", 3: "Not Started", 4: "Blocked"} {
+				key := make([]byte, 8)
+				binary.BigEndian.PutUint64(key, uint64(id))
+				j, err := json.Marshal(model.Task{ID: id, Title: "Task", Status: status, CategoryID: 1, UserID: 1, Version: 1, UpdatedAt: updatedAt})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tx.Bucket([]byte("Tasks")).Put(key, j)).To(Succeed())
+			}
+			Expect(tx.DeleteBucket([]byte("Workflows"))).To(Succeed())
+			version := make([]byte, 8)
+			binary.BigEndian.PutUint64(version, 5)
+			return tx.Bucket([]byte("Meta")).Put([]byte("schema_version"), version)
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		results, err := filebased.Migrate(filebasedDb.DB, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(HaveLen(10))
+		Expect(results[0].Summary).To(Equal("updated 2 tasks, left 1 with an unknown status"))
+
+		task, err := filebasedDb.GetTaskByID(1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(task.Status).To(Equal("Completed"))
+		Expect(task.CompletedAt).NotTo(BeNil())
+		Expect(task.CompletedAt.Equal(updatedAt)).To(BeTrue())
+		task, err = filebasedDb.GetTaskByID(2)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(task.Status).To(Equal("In Progress"))
+		Expect(task.CompletedAt).To(BeNil())
+		task, err = filebasedDb.GetTaskByID(4)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(task.Status).To(Equal("Blocked"))
+
+		_, err = filebasedDb.GetWorkflow(1)
+		Expect(err).To(MatchError(model.ErrRecordNotFound))
+		Expect(filebasedDb.CloseDB()).To(Succeed())
+	})
+
+	It("should report pending migrations without writing on dry run", func() {
+		writeLegacy()
+
+		boltDb, err := filebased.OpenDB()
+		Expect(err).ShouldNot(HaveOccurred())
+
+		results, err := filebased.Migrate(boltDb, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(HaveLen(filebased.LatestSchemaVersion()))
+		Expect(results[2].Summary).To(Equal("deleted 1 categories"))
+		Expect(results[3].Summary).To(Equal("versioned 2 tasks and 1 categories"))
+
+		version, err := filebased.SchemaVersion(boltDb)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(version).To(Equal(0))
+
+		err = boltDb.View(func(tx *bbolt.Tx) error {
+			Expect(tx.Bucket([]byte("TasksByUser"))).To(BeNil())
+			Expect(tx.Bucket([]byte("Tasks")).Get([]byte("9"))).NotTo(BeNil())
+			return nil
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Nothing is left pending by a real run
+		results, err = filebased.Migrate(boltDb, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(HaveLen(filebased.LatestSchemaVersion()))
+		results, err = filebased.Migrate(boltDb, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(BeEmpty())
+		Expect(boltDb.Close()).To(Succeed())
+	})
+
+	It("should refuse a file written by a newer binary", func() {
+		boltDb, err := bbolt.Open(path, 0600, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		err = boltDb.Update(func(tx *bbolt.Tx) error {
+			b, err := tx.CreateBucket([]byte("Meta"))
+			if err != nil {
+				return err
+			}
+			version := make([]byte, 8)
+			binary.BigEndian.PutUint64(version, uint64(filebased.LatestSchemaVersion()+1))
+			return b.Put([]byte("schema_version"), version)
 		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(boltDb.Close()).To(Succeed())
+
+		_, err = filebased.InitDB()
+		Expect(err).To(MatchError(ContainSubstring("newer than this binary supports")))
 	})
 })
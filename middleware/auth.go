@@ -1,48 +1,94 @@
 package middleware
 
 import (
+	"a21hc3NpZ25tZW50/config"
 	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 )
 
-func Auth() gin.HandlerFunc {
+func Auth(sessionRepo repo.SessionRepository) gin.HandlerFunc {
 	return gin.HandlerFunc(func(ctx *gin.Context) {
-		// TODO: answer here
 		cookie, err := ctx.Request.Cookie("session_token")
 		if err != nil {
 			if ctx.GetHeader("Content-Type") == "application/json" {
-				ctx.JSON(http.StatusUnauthorized, model.NewErrorResponse("error unauthorized user id"))
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.NewErrorResponse("error unauthorized user id"))
 			} else {
-				ctx.Redirect(http.StatusSeeOther, "/page/login")
+				ctx.Redirect(http.StatusSeeOther, "/client/login")
+				ctx.Abort()
 			}
 			return
 		}
 
 		claims := &model.Claims{}
 
-		txn, err := jwt.ParseWithClaims(cookie.Value, claims, func(t *jwt.Token) (interface{}, error) {
-			return model.JwtKey, nil
-		})
+		txn, err := jwt.ParseWithClaims(cookie.Value, claims, config.JwtKeys.Keyfunc)
 
 		if err != nil {
-			if err == jwt.ErrSignatureInvalid {
-				ctx.JSON(http.StatusUnauthorized, model.NewErrorResponse(err.Error()))
-				return
+			if ve, ok := err.(*jwt.ValidationError); ok {
+				if ve.Errors&jwt.ValidationErrorExpired != 0 {
+					tokenExpired(ctx)
+					return
+				}
+				// Unknown or retired kid, or a signature that doesn't match
+				if ve.Errors&(jwt.ValidationErrorSignatureInvalid|jwt.ValidationErrorUnverifiable) != 0 {
+					ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.NewErrorResponse(err.Error()))
+					return
+				}
 			}
-			ctx.JSON(http.StatusBadRequest, model.NewErrorResponse(err.Error()))
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, model.NewErrorResponse(err.Error()))
 			return
 		}
 
 		if !txn.Valid {
-			ctx.JSON(http.StatusUnauthorized, model.NewErrorResponse(err.Error()))
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.NewErrorResponse("invalid token"))
 			return
 		}
 
+		// A signed token is only honoured while its session exists, so logout
+		// and revocation take effect immediately
+		session, err := sessionRepo.TokenValidity(cookie.Value)
+		if err != nil {
+			if ctx.GetHeader("Content-Type") == "application/json" {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.NewErrorResponse(model.ErrSessionRevoked.Error()))
+			} else {
+				ctx.SetCookie("session_token", "", -1, "/", "", false, false)
+				ctx.Redirect(http.StatusSeeOther, "/client/login")
+				ctx.Abort()
+			}
+			return
+		}
+
+		// The refresh token of this session was already exchanged, the
+		// caller holds an outdated token and should pick up the newer one
+		if session.Rotated {
+			tokenExpired(ctx)
+			return
+		}
+
+		// Last-seen only needs minute precision, skip the write otherwise
+		if now := time.Now(); now.Sub(session.LastSeen) > time.Minute {
+			_ = sessionRepo.TouchSession(cookie.Value, now)
+		}
+
 		ctx.Set("id", claims.ID)
 		ctx.Set("email", claims.Email)
 		ctx.Next()
 	})
 }
+
+// tokenExpired tells API callers to refresh and sends browsers through the
+// web refresh page, which returns them to the page they asked for.
+func tokenExpired(ctx *gin.Context) {
+	if ctx.GetHeader("Content-Type") == "application/json" {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.NewErrorResponse(model.ErrTokenExpired.Error()))
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/client/refresh?next="+url.QueryEscape(ctx.Request.URL.RequestURI()))
+	ctx.Abort()
+}
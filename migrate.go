@@ -0,0 +1,58 @@
+package main
+
+import (
+	"a21hc3NpZ25tZW50/config"
+	"a21hc3NpZ25tZW50/db/filebased"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runMigrateCommand handles `migrate [-dry-run]`, which brings the bbolt file
+// up to the schema of this binary or, with -dry-run, reports what that would
+// change. It returns the process exit code.
+func runMigrateCommand(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "run pending migrations in a transaction that is rolled back")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Postgres applies its SQL migrations on startup, the memory store has none
+	if config.DBDriver() != "bbolt" {
+		fmt.Fprintf(os.Stderr, "migrate only applies to the bbolt file, APP_DB_DRIVER is %q\n", config.DBDriver())
+		return 2
+	}
+
+	db, err := filebased.OpenDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	current, err := filebased.SchemaVersion(db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Schema version %d, this binary writes %d\n", current, filebased.LatestSchemaVersion())
+
+	results, err := filebased.Migrate(db, *dryRun)
+	for _, m := range results {
+		verb := "Applied"
+		if *dryRun {
+			verb = "Would apply"
+		}
+		fmt.Printf("%s migration %d (%s): %s\n", verb, m.Version, m.Name, m.Summary)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if len(results) == 0 {
+		fmt.Println("Nothing to migrate")
+	}
+	return 0
+}
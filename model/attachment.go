@@ -0,0 +1,66 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrQuotaExceeded      = errors.New("attachment quota exceeded")
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	ErrUnsupportedType    = errors.New("unsupported attachment type")
+)
+
+// AttachmentTypes are the content types a file may have, as sniffed from its
+// first bytes by http.DetectContentType: screenshots, PDFs and plain text.
+var AttachmentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain; charset=utf-8"}
+
+// Attachment describes a file on a task. The content lives in a blob store
+// under Key; records are dropped with their task, and the blobs they leave
+// behind are swept per user.
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	UserID      int       `json:"user_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Key         string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SniffAttachmentType returns the content type of a file starting with head,
+// or ErrUnsupportedType if it is not one of AttachmentTypes. The type the
+// client sent is never trusted.
+func SniffAttachmentType(head []byte) (string, error) {
+	contentType := http.DetectContentType(head)
+	for _, allowed := range AttachmentTypes {
+		if contentType == allowed {
+			return contentType, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+}
+
+// AttachmentName cleans the file name sent by a client for storing and for
+// Content-Disposition: no directories, quotes or control characters.
+func AttachmentName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidBulk = errors.New("invalid bulk request")
+	// ErrBulkAborted is returned when an operation of an atomic bulk request
+	// fails, none of them is then applied.
+	ErrBulkAborted  = errors.New("bulk request aborted: an operation failed")
+	ErrTaskNotOwned = errors.New("task belongs to a different user")
+)
+
+// MaxBulkOps is the most operations one bulk request may hold.
+const MaxBulkOps = 100
+
+// BulkMode says what happens to the other operations of a bulk request when
+// one fails.
+type BulkMode string
+
+const (
+	BulkAtomic     BulkMode = "atomic"      // none is applied
+	BulkBestEffort BulkMode = "best_effort" // the others are still applied
+)
+
+type TaskOpKind string
+
+const (
+	TaskOpCreate TaskOpKind = "create"
+	TaskOpUpdate TaskOpKind = "update"
+	TaskOpMove   TaskOpKind = "move"
+	TaskOpDelete TaskOpKind = "delete"
+)
+
+// TaskOp is one operation of a bulk request.
+type TaskOp struct {
+	Op         TaskOpKind      `json:"op"`
+	ID         int             `json:"id,omitempty"`          // update, move, delete
+	Task       *Task           `json:"task,omitempty"`        // create
+	Fields     json.RawMessage `json:"fields,omitempty"`      // update: a merge patch of the task
+	CategoryID int             `json:"category_id,omitempty"` // move
+	Version    int             `json:"version,omitempty"`     // fails unless the task is at this version, 0 for any
+}
+
+type BulkRequest struct {
+	Mode       BulkMode `json:"mode"` // atomic when empty
+	Operations []TaskOp `json:"operations"`
+}
+
+// Validate checks the mode of r and that each operation has what its kind
+// needs.
+func (r *BulkRequest) Validate() error {
+	if r.Mode == "" {
+		r.Mode = BulkAtomic
+	}
+	if r.Mode != BulkAtomic && r.Mode != BulkBestEffort {
+		return fmt.Errorf(`%w: mode must be "atomic" or "best_effort"`, ErrInvalidBulk)
+	}
+	if len(r.Operations) == 0 || len(r.Operations) > MaxBulkOps {
+		return fmt.Errorf("%w: send between 1 and %d operations", ErrInvalidBulk, MaxBulkOps)
+	}
+
+	for i, op := range r.Operations {
+		var problem string
+		switch {
+		case op.Op == TaskOpCreate:
+			if op.Task == nil {
+				problem = "create needs a task"
+			}
+		case op.Op != TaskOpUpdate && op.Op != TaskOpMove && op.Op != TaskOpDelete:
+			problem = "op must be create, update, move or delete"
+		case op.ID <= 0:
+			problem = fmt.Sprintf("%s needs a task id", op.Op)
+		case op.Op == TaskOpUpdate && len(op.Fields) == 0:
+			problem = "update needs fields"
+		case op.Op == TaskOpMove && op.CategoryID <= 0:
+			problem = "move needs a category_id"
+		}
+		if problem != "" {
+			return fmt.Errorf("%w: operation %d: %s", ErrInvalidBulk, i, problem)
+		}
+	}
+	return nil
+}
+
+// TaskWrite is an operation of a bulk request made ready for the store.
+type TaskWrite struct {
+	Op      TaskOpKind
+	Task    Task  // the task to create, the replacement of Task.ID, or the task to delete; its Version is checked
+	TrashID int   // the trash entry a delete moved the task to
+	Err     error // why the write was refused
+}
+
+// TaskOpResult is how an operation of a bulk request went, with the status
+// it would have got as a request of its own.
+type TaskOpResult struct {
+	Index   int           `json:"index"`
+	Op      TaskOpKind    `json:"op"`
+	ID      int           `json:"id,omitempty"`
+	Status  int           `json:"status"`
+	Error   string        `json:"error,omitempty"`
+	Task    *TaskResponse `json:"task,omitempty"`     // the task as written, not for delete
+	TrashID int           `json:"trash_id,omitempty"` // the trash entry of a delete
+}
+
+type BulkResponse struct {
+	Committed bool           `json:"committed"` // false when an atomic request applied nothing
+	Results   []TaskOpResult `json:"results"`
+}
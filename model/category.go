@@ -0,0 +1,62 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A task may only point at a category that exists and is either owned by the
+// task's user or a system category (UserID 0). Every store enforces this on
+// StoreTask and UpdateTask.
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryNotOwned = errors.New("category belongs to a different user")
+	// ErrCategoryNotEmpty is returned when deleting a category that still has
+	// tasks with CategoryDeleteReject.
+	ErrCategoryNotEmpty = errors.New("category still has tasks")
+	// ErrCategoryExists is returned when updating a category to the name of
+	// another category of its user, see SameCategoryName.
+	ErrCategoryExists = errors.New("category already exists")
+)
+
+// SameCategoryName reports whether two category names are the same, ignoring
+// case and surrounding spaces.
+func SameCategoryName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// CategoryDeleteMode decides what happens to the tasks of a deleted category.
+type CategoryDeleteMode string
+
+const (
+	CategoryDeleteReject   CategoryDeleteMode = "reject"   // refuse while the category has tasks
+	CategoryDeleteCascade  CategoryDeleteMode = "cascade"  // delete the tasks with it
+	CategoryDeleteReassign CategoryDeleteMode = "reassign" // move the tasks to ReassignTo
+)
+
+// CategoryDelete selects how DeleteCategory treats the tasks of the category.
+// The zero value rejects deleting a category that still has tasks.
+type CategoryDelete struct {
+	Mode       CategoryDeleteMode
+	ReassignTo int
+}
+
+// Validate checks opts before category id is deleted with it.
+func (opts CategoryDelete) Validate(id int) error {
+	switch opts.Mode {
+	case "", CategoryDeleteReject, CategoryDeleteCascade:
+		return nil
+	case CategoryDeleteReassign:
+		if opts.ReassignTo == id {
+			return fmt.Errorf("cannot reassign tasks to the category being deleted")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown category delete mode %q", opts.Mode)
+}
+
+// CategoryAllows reports whether a task of userID may use category.
+func CategoryAllows(category Category, userID int) bool {
+	return category.UserID == 0 || category.UserID == userID
+}
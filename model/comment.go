@@ -0,0 +1,62 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidComment is returned for a comment body that cannot be stored.
+var ErrInvalidComment = errors.New("invalid comment")
+
+// MaxCommentLength is the longest comment body in characters.
+const MaxCommentLength = 10000
+
+// Comment is a note on a task, its Body is Markdown. Every edit keeps the body
+// it replaces in History, oldest first, so a thread shows what was said when.
+type Comment struct {
+	ID        int               `json:"id"`
+	TaskID    int               `json:"task_id"`
+	AuthorID  int               `json:"author_id"`
+	Body      string            `json:"body"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	History   []CommentRevision `json:"history"`
+}
+
+// CommentRevision is an earlier body of a comment, written at CreatedAt.
+type CommentRevision struct {
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Edited reports whether the body of c changed after it was posted.
+func (c Comment) Edited() bool {
+	return len(c.History) > 0
+}
+
+// Revise replaces the body of c with body at now, keeping the old one.
+func (c *Comment) Revise(body string, now time.Time) {
+	c.History = append(c.History, CommentRevision{Body: c.Body, CreatedAt: c.UpdatedAt})
+	c.Body = body
+	c.UpdatedAt = now
+}
+
+// CommentRequest is the body of POST /api/v1/task/:id/comments and of PUT on
+// a comment.
+type CommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// Validate checks the body of a comment.
+func (r CommentRequest) Validate() error {
+	if strings.TrimSpace(r.Body) == "" {
+		return fmt.Errorf("%w: body cannot be empty", ErrInvalidComment)
+	}
+	if utf8.RuneCountInString(r.Body) > MaxCommentLength {
+		return fmt.Errorf("%w: body is longer than %d characters", ErrInvalidComment, MaxCommentLength)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// ErrInvalidTimezone is returned for a timezone that is not an IANA name.
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// Deadline is when a task is due. A date-only deadline is a calendar date, due
+// at the end of that day in the timezone of the task's user; At is then
+// midnight UTC of the date. Otherwise At is the instant itself, in UTC. The
+// zero value means no deadline.
+//
+// In JSON a deadline is "YYYY-MM-DD", an RFC 3339 timestamp, or null.
+type Deadline struct {
+	At       time.Time
+	DateOnly bool
+}
+
+// ParseDeadline accepts a date-only value or an RFC 3339 timestamp. An empty
+// string is no deadline.
+func ParseDeadline(s string) (Deadline, error) {
+	if s == "" {
+		return Deadline{}, nil
+	}
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return Deadline{At: t, DateOnly: true}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return Deadline{At: t.UTC()}, nil
+	}
+	return Deadline{}, fmt.Errorf("invalid deadline %q: use YYYY-MM-DD or RFC 3339", s)
+}
+
+// DateDeadline is the date-only deadline for year, month and day.
+func DateDeadline(year int, month time.Month, day int) Deadline {
+	return Deadline{At: time.Date(year, month, day, 0, 0, 0, 0, time.UTC), DateOnly: true}
+}
+
+func (d Deadline) IsZero() bool {
+	return d.At.IsZero()
+}
+
+// String formats d the way ParseDeadline reads it.
+func (d Deadline) String() string {
+	switch {
+	case d.IsZero():
+		return ""
+	case d.DateOnly:
+		return d.At.Format(dateLayout)
+	}
+	return d.At.Format(time.RFC3339)
+}
+
+// Due is the instant d passes for a user in loc.
+func (d Deadline) Due(loc *time.Location) time.Time {
+	if !d.DateOnly {
+		return d.At
+	}
+	y, m, day := d.At.Date()
+	return time.Date(y, m, day+1, 0, 0, 0, 0, loc)
+}
+
+// Overdue reports whether d has passed at now for a user in loc.
+func (d Deadline) Overdue(now time.Time, loc *time.Location) bool {
+	return !d.IsZero() && !now.Before(d.Due(loc))
+}
+
+func (d Deadline) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+func (d *Deadline) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Deadline{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid deadline: %v", err)
+	}
+	parsed, err := ParseDeadline(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// LoadTimezone resolves a user's timezone, empty means UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("%w %q", ErrInvalidTimezone, name)
+	}
+	return loc, nil
+}
+
+// Location is the timezone date-only deadlines of u fall due in. A timezone
+// that no longer loads falls back to UTC.
+func (u User) Location() *time.Location {
+	loc, err := LoadTimezone(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// TaskResponse is a task as the API returns it, with the fields computed for
+// its user at the time of the request.
+type TaskResponse struct {
+	Task
+	Overdue bool   `json:"overdue"`
+	DueIn   *int64 `json:"due_in"` // seconds until the deadline, negative once overdue, null without one
+	// Progress is the percentage of done subtasks, null for a task without
+	// subtasks. Progress, BlockedBy and Blocked are set from TaskRelations.
+	Progress  *int  `json:"progress"`
+	BlockedBy []int `json:"blocked_by"` // IDs of the tasks this one waits on
+	Blocked   bool  `json:"blocked"`    // some task in BlockedBy is not done yet
+}
+
+func NewTaskResponse(task Task, now time.Time, loc *time.Location) TaskResponse {
+	resp := TaskResponse{Task: task}
+	if !task.Deadline.IsZero() {
+		dueIn := int64(task.Deadline.Due(loc).Sub(now) / time.Second)
+		resp.DueIn = &dueIn
+		resp.Overdue = task.Deadline.Overdue(now, loc)
+	}
+	return resp
+}
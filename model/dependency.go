@@ -0,0 +1,142 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidDependency is returned for an edge between a task and itself
+	// or between tasks of different users.
+	ErrInvalidDependency = errors.New("invalid dependency")
+	// ErrDependencyCycle is returned when an edge would make a task wait on
+	// itself, directly or through other tasks.
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrTaskBlocked is matched by BlockedError.
+	ErrTaskBlocked = errors.New("task is blocked")
+)
+
+// Dependency says TaskID cannot start until BlockedByID is done. Both tasks
+// belong to UserID.
+type Dependency struct {
+	TaskID      int `json:"task_id"`
+	BlockedByID int `json:"blocked_by_id" binding:"required"`
+	UserID      int `json:"user_id"`
+}
+
+// SortDependencies orders edges by TaskID, then BlockedByID, the order stores
+// list them in.
+func SortDependencies(deps []Dependency) {
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].TaskID != deps[j].TaskID {
+			return deps[i].TaskID < deps[j].TaskID
+		}
+		return deps[i].BlockedByID < deps[j].BlockedByID
+	})
+}
+
+// CheckDependency reports whether dep may be added to deps, the existing edges
+// of the same user. Every store calls it inside the write that adds dep.
+func CheckDependency(deps []Dependency, dep Dependency) error {
+	if dep.TaskID == dep.BlockedByID {
+		return fmt.Errorf("%w: a task cannot wait on itself", ErrInvalidDependency)
+	}
+
+	// dep closes a cycle if TaskID already comes before BlockedByID, i.e. if
+	// following blocked-by edges from BlockedByID reaches TaskID
+	blockedBy := map[int][]int{}
+	for _, d := range deps {
+		blockedBy[d.TaskID] = append(blockedBy[d.TaskID], d.BlockedByID)
+	}
+	seen := map[int]bool{}
+	stack := []int{dep.BlockedByID}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == dep.TaskID {
+			return ErrDependencyCycle
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		stack = append(stack, blockedBy[id]...)
+	}
+	return nil
+}
+
+// BlockedError is returned when a task is started while tasks it depends on
+// are still open.
+type BlockedError struct {
+	Open []Task
+}
+
+func (e *BlockedError) Error() string {
+	open := make([]string, len(e.Open))
+	for i, t := range e.Open {
+		open[i] = fmt.Sprintf("%d %q", t.ID, t.Title)
+	}
+	return "task is blocked by open tasks: " + strings.Join(open, ", ")
+}
+
+func (e *BlockedError) Unwrap() error {
+	return ErrTaskBlocked
+}
+
+// Blockers maps every task in tasks to the tasks it waits on that are not in
+// a done status of w yet. tasks must hold every task deps refer to.
+func (w Workflow) Blockers(tasks []Task, deps []Dependency) map[int][]Task {
+	byID := make(map[int]Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+
+	blockers := map[int][]Task{}
+	for _, d := range deps {
+		predecessor, ok := byID[d.BlockedByID]
+		if !ok {
+			continue
+		}
+		if status, ok := w.Status(predecessor.Status); ok && status.Category == StatusDone {
+			continue
+		}
+		blockers[d.TaskID] = append(blockers[d.TaskID], predecessor)
+	}
+	return blockers
+}
+
+// TaskRelations holds what a task response says about the other tasks of its
+// user, computed once for all of them.
+type TaskRelations struct {
+	Progress  map[int]int    // see Workflow.Progress
+	BlockedBy map[int][]int  // every task a task waits on
+	Blockers  map[int][]Task // see Workflow.Blockers
+}
+
+// NewTaskRelations computes the relations between tasks, every task of one
+// user, under their workflow w.
+func NewTaskRelations(tasks []Task, deps []Dependency, w Workflow) TaskRelations {
+	blockedBy := map[int][]int{}
+	for _, d := range deps {
+		blockedBy[d.TaskID] = append(blockedBy[d.TaskID], d.BlockedByID)
+	}
+	return TaskRelations{
+		Progress:  w.Progress(tasks),
+		BlockedBy: blockedBy,
+		Blockers:  w.Blockers(tasks, deps),
+	}
+}
+
+// Response is the API response for task with its relations filled in.
+func (r TaskRelations) Response(task Task, now time.Time, loc *time.Location) TaskResponse {
+	resp := NewTaskResponse(task, now, loc)
+	if p, ok := r.Progress[task.ID]; ok {
+		resp.Progress = &p
+	}
+	resp.BlockedBy = append([]int{}, r.BlockedBy[task.ID]...)
+	resp.Blocked = len(r.Blockers[task.ID]) > 0
+	return resp
+}
@@ -1,11 +1,39 @@
 package model
 
-import "github.com/golang-jwt/jwt"
+import (
+	"errors"
+	"time"
 
-var JwtKey = []byte("secret-key")
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// AccessTokenTTL is how long a signed session_token stays valid.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a refresh token can be exchanged for a new pair.
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// ErrTokenExpired is reported by the Auth middleware when the access token is
+// well-formed but past its expiry, so callers know a refresh may succeed.
+var ErrTokenExpired = errors.New("token expired")
+
+// ErrSessionRevoked is reported when a validly signed token no longer has a
+// session behind it, after logout or revocation.
+var ErrSessionRevoked = errors.New("session revoked")
 
 type Claims struct {
 	ID    int    `json:"id"`
 	Email string `json:"email"`
 	jwt.StandardClaims
 }
+
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -1,11 +1,26 @@
 package model
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrRecordNotFound is returned for an ID that has no record.
+	ErrRecordNotFound = errors.New("record not found")
+	// ErrVersionConflict is returned when an update carries a Version other
+	// than the stored one, i.e. the record changed since it was read.
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrInvalidTask is returned for a task missing a field every task needs.
+	ErrInvalidTask = errors.New("invalid task")
+)
 
 type Category struct {
-	ID     int    `gorm:"primaryKey" json:"id"`
-	Name   string `json:"name"`
-	UserID int    `json:"user_id"` // User ID who owns this category
+	ID        int       `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name"`
+	UserID    int       `json:"user_id"`    // User ID who owns this category
+	Version   int       `json:"version"`    // bumped by the store on every write
+	UpdatedAt time.Time `json:"updated_at"` // set by the store on every write
 }
 
 type User struct {
@@ -13,6 +28,7 @@ type User struct {
 	Fullname  string    `json:"fullname" gorm:"type:varchar(255);"`
 	Email     string    `json:"email" gorm:"type:varchar(255);not null"`
 	Password  string    `json:"password" gorm:"type:varchar(255);not null"`
+	Timezone  string    `json:"timezone"` // IANA name, date-only deadlines fall due at midnight here; empty is UTC
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -26,23 +42,61 @@ type UserRegister struct {
 	Fullname string `json:"fullname" binding:"required"`
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Timezone string `json:"timezone"`
+}
+
+// UserTimezone is the body of PUT /api/v1/user/timezone.
+type UserTimezone struct {
+	Timezone string `json:"timezone"`
 }
 
 type Task struct {
-	ID         int    `gorm:"primaryKey" json:"id"`
-	Title      string `json:"title"`
-	Deadline   string `json:"deadline"`
-	Priority   int    `json:"priority"`
-	Status     string `json:"status"`
-	CategoryID int    `json:"category_id"`
-	UserID     int    `json:"user_id"`
+	ID          int         `gorm:"primaryKey" json:"id"`
+	Title       string      `json:"title"`
+	Deadline    Deadline    `json:"deadline"`
+	Priority    int         `json:"priority"`
+	Status      string      `json:"status"`
+	CompletedAt *time.Time  `json:"completed_at"` // set while Status is a done status of the user's workflow
+	CategoryID  int         `json:"category_id"`
+	UserID      int         `json:"user_id"`
+	ParentID    int         `json:"parent_id"`  // 0 for a top-level task
+	Position    int         `json:"position"`   // order among the subtasks of ParentID
+	Recurrence  *Recurrence `json:"recurrence"` // nil for a task that does not repeat
+	SeriesID    int         `json:"series_id"`  // ID of the first occurrence, 0 on that one
+	TagIDs      []int       `json:"tag_ids"`    // sorted, see Tag
+	Version     int         `json:"version"`    // bumped by the store on every write
+	UpdatedAt   time.Time   `json:"updated_at"` // set by the store on every write
 }
 
 type Session struct {
-	ID     int       `gorm:"primaryKey" json:"id"`
-	Token  string    `json:"token"`
-	Email  string    `json:"email"`
-	Expiry time.Time `json:"expiry"`
+	ID           int       `gorm:"primaryKey" json:"id"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	FamilyID     string    `json:"family_id,omitempty"` // shared by every session rotated from the same login
+	Rotated      bool      `json:"rotated,omitempty"`   // refresh token already exchanged, reuse revokes the family
+	Email        string    `json:"email"`
+	Expiry       time.Time `json:"expiry"` // expiry of the refresh token, the access token carries its own
+	UserAgent    string    `json:"user_agent,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// Device describes where a login came from.
+type Device struct {
+	UserAgent string `json:"user_agent"`
+	IP        string `json:"ip"`
+}
+
+// SessionInfo is the public view of a session, without any token.
+type SessionInfo struct {
+	ID        int       `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	Expiry    time.Time `json:"expiry"`
+	Current   bool      `json:"current"`
 }
 
 type TaskCategory struct {
@@ -52,14 +106,14 @@ type TaskCategory struct {
 }
 
 type UserTaskCategory struct {
-	ID       int    `json:"id"`
-	Fullname string `json:"fullname"`
-	Email    string `json:"email"`
-	Task     string `json:"task"`
-	Deadline string `json:"deadline"`
-	Priority int    `json:"priority"`
-	Status   string `json:"status"`
-	Category string `json:"category"`
+	ID       int      `json:"id"`
+	Fullname string   `json:"fullname"`
+	Email    string   `json:"email"`
+	Task     string   `json:"task"`
+	Deadline Deadline `json:"deadline"`
+	Priority int      `json:"priority"`
+	Status   string   `json:"status"`
+	Category string   `json:"category"`
 }
 
 type Credential struct {
@@ -69,4 +123,5 @@ type Credential struct {
 	DatabaseName string
 	Port         int
 	Schema       string
+	SSLMode      string
 }
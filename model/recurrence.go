@@ -0,0 +1,184 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RecurrenceFreq is the period a recurrence rule repeats in, like FREQ in an
+// RRULE.
+type RecurrenceFreq string
+
+const (
+	RecurDaily   RecurrenceFreq = "daily"
+	RecurWeekly  RecurrenceFreq = "weekly"
+	RecurMonthly RecurrenceFreq = "monthly"
+)
+
+// ErrInvalidRecurrence is returned for a rule that cannot be used, or for an
+// occurrence operation on a task that does not recur.
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+
+// weekdays maps the BYDAY codes of an RRULE to weekdays.
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Recurrence is a simplified RRULE. Every occurrence of a recurring task is a
+// task of its own, the next one is created when one is completed or ahead of
+// time, see Next.
+type Recurrence struct {
+	Freq            RecurrenceFreq `json:"freq"`
+	Interval        int            `json:"interval,omitempty"`         // every Interval days, weeks or months, 0 is 1
+	ByDay           []string       `json:"by_day,omitempty"`           // weekly only: MO, TU, ...; empty repeats on the weekday of the deadline
+	ByMonthDay      int            `json:"by_month_day,omitempty"`     // monthly only: 1-31, short months use their last day
+	AfterCompletion bool           `json:"after_completion,omitempty"` // count from the day an occurrence is completed instead of its deadline
+	Timezone        string         `json:"timezone,omitempty"`         // set from the user on every write, days are counted here
+}
+
+// Validate checks that r is a rule Next can follow.
+func (r Recurrence) Validate() error {
+	switch r.Freq {
+	case RecurDaily, RecurWeekly, RecurMonthly:
+	default:
+		return fmt.Errorf("%w: unknown freq %q, use one of %s", ErrInvalidRecurrence, r.Freq, quoteAll([]string{string(RecurDaily), string(RecurWeekly), string(RecurMonthly)}))
+	}
+	if r.Interval < 0 {
+		return fmt.Errorf("%w: interval cannot be negative", ErrInvalidRecurrence)
+	}
+	if len(r.ByDay) > 0 && r.Freq != RecurWeekly {
+		return fmt.Errorf("%w: by_day needs freq %q", ErrInvalidRecurrence, RecurWeekly)
+	}
+	for _, day := range r.ByDay {
+		if _, ok := weekdays[day]; !ok {
+			return fmt.Errorf("%w: unknown weekday %q, use MO, TU, WE, TH, FR, SA or SU", ErrInvalidRecurrence, day)
+		}
+	}
+	if r.ByMonthDay != 0 && r.Freq != RecurMonthly {
+		return fmt.Errorf("%w: by_month_day needs freq %q", ErrInvalidRecurrence, RecurMonthly)
+	}
+	if r.ByMonthDay < 0 || r.ByMonthDay > 31 {
+		return fmt.Errorf("%w: by_month_day must be between 1 and 31", ErrInvalidRecurrence)
+	}
+	if _, err := LoadTimezone(r.Timezone); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	return nil
+}
+
+// Next is the deadline of the occurrence after the one due at prev. With
+// AfterCompletion it counts from the day completedAt falls on instead, keeping
+// the time of day of prev. Days are counted in r.Timezone for deadlines with a
+// time; date-only deadlines are calendar dates already.
+func (r Recurrence) Next(prev Deadline, completedAt *time.Time) Deadline {
+	loc, err := LoadTimezone(r.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	at := prev.At
+	if !prev.DateOnly {
+		at = at.In(loc)
+	}
+	if r.AfterCompletion && completedAt != nil {
+		done := completedAt.In(loc)
+		at = time.Date(done.Year(), done.Month(), done.Day(), at.Hour(), at.Minute(), at.Second(), 0, at.Location())
+	}
+
+	next := r.step(at)
+	if prev.DateOnly {
+		return Deadline{At: next, DateOnly: true}
+	}
+	return Deadline{At: next.UTC()}
+}
+
+// step moves at forward by one period of r, keeping its time of day.
+func (r Recurrence) step(at time.Time) time.Time {
+	interval := r.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	y, m, d := at.Date()
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, at.Hour(), at.Minute(), at.Second(), 0, at.Location())
+	}
+
+	switch r.Freq {
+	case RecurWeekly:
+		if len(r.ByDay) == 0 {
+			return date(y, m, d+7*interval)
+		}
+		on := map[time.Weekday]bool{}
+		for _, day := range r.ByDay {
+			on[weekdays[day]] = true
+		}
+		// weeks start on Monday, i counts days from there
+		monday := d - (int(at.Weekday())+6)%7
+		for i := d - monday + 1; i < 7; i++ {
+			if on[time.Weekday((i+1)%7)] {
+				return date(y, m, monday+i)
+			}
+		}
+		for i := 0; i < 7; i++ {
+			if on[time.Weekday((i+1)%7)] {
+				return date(y, m, monday+7*interval+i)
+			}
+		}
+	case RecurMonthly:
+		day := r.ByMonthDay
+		if day == 0 {
+			day = d
+		}
+		if this := clampDay(y, m, day); this > d {
+			return date(y, m, this)
+		}
+		first := time.Date(y, m+time.Month(interval), 1, 0, 0, 0, 0, time.UTC)
+		return date(first.Year(), first.Month(), clampDay(first.Year(), first.Month(), day))
+	}
+	return date(y, m, d+interval)
+}
+
+// clampDay is day, or the last day of the month if it is shorter.
+func clampDay(y int, m time.Month, day int) int {
+	if last := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+		return last
+	}
+	return day
+}
+
+// Series is the ID shared by all occurrences of a recurring task, the ID of
+// the first one.
+func (t Task) Series() int {
+	if t.SeriesID != 0 {
+		return t.SeriesID
+	}
+	return t.ID
+}
+
+// Occurrence is the task that follows t in its series, due at deadline and in
+// status.
+func (t Task) Occurrence(deadline Deadline, status string) Task {
+	return Task{
+		Title:      t.Title,
+		Deadline:   deadline,
+		Priority:   t.Priority,
+		Status:     status,
+		CategoryID: t.CategoryID,
+		UserID:     t.UserID,
+		Recurrence: t.Recurrence,
+		SeriesID:   t.Series(),
+		TagIDs:     t.TagIDs,
+	}
+}
+
+// OccurrenceRequest is the body of POST /api/v1/task/:id/occurrences.
+type OccurrenceRequest struct {
+	Count int `json:"count" binding:"required,min=1,max=52"`
+}
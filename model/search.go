@@ -0,0 +1,209 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidSearch = errors.New("search query has no words")
+
+const (
+	MinSearchToken   = 2  // shorter words are not indexed
+	MaxSearchToken   = 32 // longer words are cut to this many runes
+	MaxSearchTerms   = 8
+	MaxSearchResults = 50
+)
+
+// SearchField is a set of the task fields a word was found in.
+type SearchField uint8
+
+const (
+	SearchTitle SearchField = 1 << iota
+	SearchComment
+	SearchCategory
+)
+
+var searchFieldNames = []struct {
+	field  SearchField
+	name   string
+	weight float64
+}{
+	{SearchTitle, "title", 3},
+	{SearchCategory, "category", 2},
+	{SearchComment, "comment", 1},
+}
+
+// Names lists the fields in f, title first.
+func (f SearchField) Names() []string {
+	names := []string{}
+	for _, n := range searchFieldNames {
+		if f&n.field != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// weight is what a word found in f adds to the score of its task. A word in
+// several fields counts for each of them.
+func (f SearchField) weight() float64 {
+	var w float64
+	for _, n := range searchFieldNames {
+		if f&n.field != 0 {
+			w += n.weight
+		}
+	}
+	return w
+}
+
+// Tokenize splits text into lower case words of letters and digits, in
+// order. Words shorter than MinSearchToken are dropped.
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) < MinSearchToken {
+			continue
+		}
+		if runes := []rune(word); len(runes) > MaxSearchToken {
+			word = string(runes[:MaxSearchToken])
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// SearchDocument maps every word of a task to the fields it appears in: the
+// title, the name of its category and the bodies of its comments.
+func SearchDocument(title, category string, comments []string) map[string]SearchField {
+	doc := map[string]SearchField{}
+	add := func(text string, field SearchField) {
+		for _, token := range Tokenize(text) {
+			doc[token] |= field
+		}
+	}
+	add(title, SearchTitle)
+	add(category, SearchCategory)
+	for _, body := range comments {
+		add(body, SearchComment)
+	}
+	return doc
+}
+
+// ParseSearchQuery returns the distinct words of q, at most MaxSearchTerms,
+// or ErrInvalidSearch if it has none.
+func ParseSearchQuery(q string) ([]string, error) {
+	var terms []string
+	seen := map[string]bool{}
+	for _, token := range Tokenize(q) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		terms = append(terms, token)
+		if len(terms) == MaxSearchTerms {
+			break
+		}
+	}
+	if len(terms) == 0 {
+		return nil, ErrInvalidSearch
+	}
+	return terms, nil
+}
+
+// SearchMatch is an indexed word of a task that starts with one of the
+// words searched for.
+type SearchMatch struct {
+	TaskID int
+	Token  string
+	Fields SearchField
+}
+
+// MatchDocument returns the words of doc that start with one of terms, for
+// stores that build documents on the fly instead of keeping an index.
+func MatchDocument(taskID int, doc map[string]SearchField, terms []string) []SearchMatch {
+	var matches []SearchMatch
+	for token, fields := range doc {
+		for _, term := range terms {
+			if strings.HasPrefix(token, term) {
+				matches = append(matches, SearchMatch{TaskID: taskID, Token: token, Fields: fields})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// SearchHit is a task found by a search.
+type SearchHit struct {
+	TaskID int
+	Score  float64
+	Fields SearchField // the fields any of the words were found in
+	Task   Task
+}
+
+// RankSearch scores the tasks of matches against terms. A task is a hit only
+// if every term matches one of its words. Each term adds the weight of the
+// fields its best word is in, halved when the word only starts with the term.
+// Hits come best first, newer tasks first on a tie.
+func RankSearch(terms []string, matches []SearchMatch) []SearchHit {
+	type termScore struct {
+		score  float64
+		fields SearchField
+	}
+	byTask := map[int][]termScore{}
+	for _, m := range matches {
+		scores := byTask[m.TaskID]
+		if scores == nil {
+			scores = make([]termScore, len(terms))
+			byTask[m.TaskID] = scores
+		}
+		for i, term := range terms {
+			if !strings.HasPrefix(m.Token, term) {
+				continue
+			}
+			score := m.Fields.weight()
+			if m.Token != term {
+				score /= 2
+			}
+			if score > scores[i].score {
+				scores[i].score = score
+			}
+			scores[i].fields |= m.Fields
+		}
+	}
+
+	hits := []SearchHit{}
+	for taskID, scores := range byTask {
+		hit := SearchHit{TaskID: taskID}
+		for _, s := range scores {
+			if s.score == 0 {
+				hit.Score = 0
+				break
+			}
+			hit.Score += s.score
+			hit.Fields |= s.fields
+		}
+		if hit.Score > 0 {
+			hits = append(hits, hit)
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].TaskID > hits[j].TaskID
+	})
+	return hits
+}
+
+// SearchResult is a hit as the search API returns it.
+type SearchResult struct {
+	Task    TaskResponse `json:"task"`
+	Score   float64      `json:"score"`
+	Matched []string     `json:"matched"` // fields the words were found in
+}
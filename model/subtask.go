@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A subtask is a task with ParentID set. Subtasks are one level deep: a parent
+// is always a top-level task of the same user. Every store enforces this on
+// StoreTask and UpdateTask, and deletes the subtasks of a deleted task with it.
+var (
+	ErrParentNotFound = errors.New("parent task not found")
+	ErrParentNotOwned = errors.New("parent task belongs to a different user")
+	ErrNestedSubtask  = errors.New("subtasks cannot have subtasks")
+)
+
+// CheckParent reports whether task may be a subtask of parent. hasSubtasks
+// tells whether task itself already has subtasks.
+func CheckParent(task, parent Task, hasSubtasks bool) error {
+	if parent.UserID != task.UserID {
+		return ErrParentNotOwned
+	}
+	if parent.ID == task.ID || parent.ParentID != 0 || hasSubtasks {
+		return ErrNestedSubtask
+	}
+	return nil
+}
+
+// SortSubtasks orders tasks by Position, then ID, the order subtasks are shown
+// in under their parent.
+func SortSubtasks(tasks []Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Position != tasks[j].Position {
+			return tasks[i].Position < tasks[j].Position
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+// Progress computes, for every task in tasks that has subtasks among them, the
+// percentage of its subtasks in a done status of w, rounded down.
+func (w Workflow) Progress(tasks []Task) map[int]int {
+	total := map[int]int{}
+	done := map[int]int{}
+	for _, task := range tasks {
+		if task.ParentID == 0 {
+			continue
+		}
+		total[task.ParentID]++
+		if status, ok := w.Status(task.Status); ok && status.Category == StatusDone {
+			done[task.ParentID]++
+		}
+	}
+
+	progress := make(map[int]int, len(total))
+	for parent, n := range total {
+		progress[parent] = done[parent] * 100 / n
+	}
+	return progress
+}
+
+// Target picks the status a task in from moves to when it should end up in
+// category, for clients that tick a subtask off rather than name a status.
+// It prefers a status allowed from from, and takes any status of the category
+// when from is not part of w.
+func (w Workflow) Target(from string, category StatusCategory) (string, error) {
+	if status, ok := w.Status(from); ok {
+		if status.Category == category {
+			return from, nil
+		}
+		for _, to := range w.Transitions[from] {
+			if s, ok := w.Status(to); ok && s.Category == category {
+				return to, nil
+			}
+		}
+		return "", &TransitionError{From: from, To: string(category), Allowed: w.Transitions[from]}
+	}
+
+	for _, s := range w.Statuses {
+		if s.Category == category {
+			return s.Name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no status in category %q", ErrUnknownStatus, category)
+}
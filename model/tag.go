@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tags are labels a user puts on any number of tasks, next to the one
+// category of a task. A task lists its tags in TagIDs; every store checks on
+// StoreTask and UpdateTask that they exist and belong to the task's user, and
+// drops a deleted tag from its tasks.
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagNotOwned = errors.New("tag belongs to a different user")
+	ErrTagExists   = errors.New("tag already exists")
+	ErrInvalidTag  = errors.New("invalid tag")
+)
+
+// DefaultTagColor is used for tags created without a color.
+const DefaultTagColor = "#6b7280"
+
+var tagColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// tagName is what a tag name is made of: letters, digits, spaces, "-", "_"
+// and ".".
+var tagName = regexp.MustCompile(`^[\p{L}\p{N} _.-]+$`)
+
+type Tag struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Color  string `json:"color"` // #rrggbb
+	UserID int    `json:"user_id"`
+}
+
+// Validate checks the name and color of t. Names keep to a plain set of
+// characters, so they cannot hold markup or the commas that separate tags in
+// GET /api/v1/task/list?tag=a,b.
+func (t Tag) Validate() error {
+	name := strings.TrimSpace(t.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidTag)
+	}
+	if len(name) > 50 {
+		return fmt.Errorf("%w: name is longer than 50 characters", ErrInvalidTag)
+	}
+	if !tagName.MatchString(name) {
+		return fmt.Errorf("%w: name can only have letters, digits, spaces, \"-\", \"_\" and \".\"", ErrInvalidTag)
+	}
+	if !tagColor.MatchString(t.Color) {
+		return fmt.Errorf("%w: color %q is not of the form #rrggbb", ErrInvalidTag, t.Color)
+	}
+	return nil
+}
+
+// SameTagName reports whether two tag names clash, names are unique per user
+// ignoring case and surrounding space.
+func SameTagName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// CheckTaskTag reports whether task may carry tag.
+func CheckTaskTag(task Task, tag Tag) error {
+	if tag.UserID != task.UserID {
+		return ErrTagNotOwned
+	}
+	return nil
+}
+
+// NormalizeTagIDs sorts ids and drops duplicates.
+func NormalizeTagIDs(ids []int) []int {
+	if len(ids) == 0 {
+		return nil
+	}
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	out := sorted[:1]
+	for _, id := range sorted[1:] {
+		if id != out[len(out)-1] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// TagMatch says whether a task filtered by tags needs any or all of them.
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "any"
+	TagMatchAll TagMatch = "all"
+)
+
+// HasTags reports whether task carries any, or with TagMatchAll every, tag of
+// ids.
+func (t Task) HasTags(ids []int, match TagMatch) bool {
+	has := map[int]bool{}
+	for _, id := range t.TagIDs {
+		has[id] = true
+	}
+	for _, id := range ids {
+		if has[id] && match != TagMatchAll {
+			return true
+		}
+		if !has[id] && match == TagMatchAll {
+			return false
+		}
+	}
+	return match == TagMatchAll
+}
@@ -0,0 +1,360 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidTaskQuery = errors.New("invalid task query")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+)
+
+const (
+	DefaultTaskPage = 50
+	MaxTaskPage     = 100
+)
+
+// TaskSort is the order of a task list. Every order ends on the task ID, so
+// it is total and a cursor can point between two tasks.
+type TaskSort string
+
+const (
+	SortCreated  TaskSort = "created" // task IDs grow with creation
+	SortDeadline TaskSort = "deadline"
+	SortPriority TaskSort = "priority"
+)
+
+// NoDeadlineKey is the sort key of a task without a deadline: last going
+// up, first going down.
+const NoDeadlineKey = math.MaxInt64
+
+// TaskQuery selects and orders the tasks of a user. Zero fields do not
+// filter.
+type TaskQuery struct {
+	Statuses     []string
+	MinPriority  int
+	MaxPriority  int
+	CategoryID   int
+	DeadlineFrom Deadline // tasks due at or after, with a deadline
+	DeadlineTo   Deadline // tasks due at or before, a date-only bound takes the whole day
+	Terms        []string // words every title must have one starting with, see Tokenize
+	TagNames     []string // resolved into TagIDs by the task service
+	TagIDs       []int    // 0 stands for a tag name the user has none of
+	TagMatch     TagMatch
+	ParentIDs    []int
+
+	Sort  TaskSort
+	Desc  bool
+	Limit int         // 0 returns every task
+	After *TaskCursor // start past this task
+}
+
+// TaskCursor points at the last task of a page. It is only valid for the
+// order it was made in.
+type TaskCursor struct {
+	Sort TaskSort `json:"s"`
+	Desc bool     `json:"d,omitempty"`
+	Key  int64    `json:"k"`
+	ID   int      `json:"i"`
+}
+
+// TaskPage is a page of tasks and how many tasks match the query in all.
+type TaskPage struct {
+	Tasks []Task
+	Total int
+	Next  *TaskCursor // nil on the last page
+}
+
+// Validate checks the ranges of q and that its cursor was made in its order.
+func (q *TaskQuery) Validate() error {
+	if q.Sort == "" {
+		q.Sort = SortCreated
+	}
+	var problem string
+	switch {
+	case q.Sort != SortCreated && q.Sort != SortDeadline && q.Sort != SortPriority:
+		problem = "sort must be created, deadline or priority"
+	case q.MinPriority < 0 || q.MaxPriority < 0:
+		problem = "priority must not be negative"
+	case q.MaxPriority != 0 && q.MinPriority > q.MaxPriority:
+		problem = "priority_min must not be above priority_max"
+	case !q.DeadlineFrom.IsZero() && !q.DeadlineTo.IsZero() && q.DeadlineTo.endsBefore(q.DeadlineFrom.At):
+		problem = "deadline_to must not be before deadline_from"
+	case q.Limit < 0 || q.Limit > MaxTaskPage:
+		problem = fmt.Sprintf("limit must be between 1 and %d", MaxTaskPage)
+	case q.After != nil && (q.After.Sort != q.Sort || q.After.Desc != q.Desc):
+		return ErrInvalidCursor
+	}
+	if problem != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidTaskQuery, problem)
+	}
+	if q.TagMatch == "" {
+		q.TagMatch = TagMatchAny
+	}
+	return nil
+}
+
+// endsBefore reports whether the instants d covers end before t, a date-only
+// deadline covering its whole day.
+func (d Deadline) endsBefore(t time.Time) bool {
+	if d.DateOnly {
+		return !d.At.AddDate(0, 0, 1).After(t)
+	}
+	return d.At.Before(t)
+}
+
+// Match reports whether task passes the filters of q. The order and cursor
+// are not looked at.
+func (q TaskQuery) Match(task Task) bool {
+	if len(q.Statuses) > 0 && !containsString(q.Statuses, task.Status) {
+		return false
+	}
+	if q.MinPriority != 0 && task.Priority < q.MinPriority {
+		return false
+	}
+	if q.MaxPriority != 0 && task.Priority > q.MaxPriority {
+		return false
+	}
+	if q.CategoryID != 0 && task.CategoryID != q.CategoryID {
+		return false
+	}
+	if !q.DeadlineFrom.IsZero() && (task.Deadline.IsZero() || task.Deadline.At.Before(q.DeadlineFrom.At)) {
+		return false
+	}
+	if !q.DeadlineTo.IsZero() && (task.Deadline.IsZero() || q.DeadlineTo.endsBefore(task.Deadline.At)) {
+		return false
+	}
+	if len(q.TagIDs) > 0 && !task.HasTags(q.TagIDs, q.TagMatch) {
+		return false
+	}
+	if len(q.ParentIDs) > 0 && !containsInt(q.ParentIDs, task.ParentID) {
+		return false
+	}
+	if len(q.Terms) > 0 {
+		words := Tokenize(task.Title)
+		for _, term := range q.Terms {
+			found := false
+			for _, word := range words {
+				if strings.HasPrefix(word, term) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SortKey is what task is ordered by under q, before its ID.
+func (q TaskQuery) SortKey(task Task) int64 {
+	switch q.Sort {
+	case SortDeadline:
+		if task.Deadline.IsZero() {
+			return NoDeadlineKey
+		}
+		return task.Deadline.At.UnixNano()
+	case SortPriority:
+		return int64(task.Priority)
+	}
+	return int64(task.ID)
+}
+
+// Less reports whether a comes before b in the order of q.
+func (q TaskQuery) Less(a, b Task) bool {
+	ka, kb := q.SortKey(a), q.SortKey(b)
+	if ka == kb {
+		return (a.ID < b.ID) != q.Desc
+	}
+	return (ka < kb) != q.Desc
+}
+
+// Cursor points at task in the order of q.
+func (q TaskQuery) Cursor(task Task) *TaskCursor {
+	return &TaskCursor{Sort: q.Sort, Desc: q.Desc, Key: q.SortKey(task), ID: task.ID}
+}
+
+// Past reports whether task comes after the cursor of q, every task does
+// without one.
+func (q TaskQuery) Past(task Task) bool {
+	if q.After == nil {
+		return true
+	}
+	k := q.SortKey(task)
+	if k == q.After.Key {
+		return (task.ID > q.After.ID) != q.Desc && task.ID != q.After.ID
+	}
+	return (k > q.After.Key) != q.Desc
+}
+
+// Paginate cuts the page of q out of tasks, which match it and are in its
+// order.
+func (q TaskQuery) Paginate(tasks []Task) TaskPage {
+	page := TaskPage{Total: len(tasks), Tasks: []Task{}}
+	for _, task := range tasks {
+		if !q.Past(task) {
+			continue
+		}
+		if q.Limit > 0 && len(page.Tasks) == q.Limit {
+			page.Next = q.Cursor(page.Tasks[len(page.Tasks)-1])
+			break
+		}
+		page.Tasks = append(page.Tasks, task)
+	}
+	return page
+}
+
+// String encodes c for clients, who pass it back as is.
+func (c TaskCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseTaskCursor reads a cursor made by TaskCursor.String.
+func ParseTaskCursor(s string) (*TaskCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c TaskCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskQueryParams are the query parameters of GET /api/v1/task/list.
+var TaskQueryParams = []string{
+	"status", "priority_min", "priority_max", "category_id", "deadline_from", "deadline_to",
+	"q", "tag", "match", "sort", "order", "limit", "cursor",
+}
+
+// ParseTaskQuery reads a task list query string. Deadline bounds take a
+// date, an RFC 3339 time or a day relative to today: today, today+7,
+// today-1. Unknown parameters are ignored.
+func ParseTaskQuery(values url.Values, today time.Time) (TaskQuery, error) {
+	var q TaskQuery
+	var err error
+	q.Statuses = queryList(values, "status")
+	q.TagNames = queryList(values, "tag")
+
+	for key, field := range map[string]*int{
+		"priority_min": &q.MinPriority,
+		"priority_max": &q.MaxPriority,
+		"category_id":  &q.CategoryID,
+		"limit":        &q.Limit,
+	} {
+		if value := values.Get(key); value != "" {
+			if *field, err = strconv.Atoi(value); err != nil {
+				return q, fmt.Errorf("%w: %s must be a number", ErrInvalidTaskQuery, key)
+			}
+		}
+	}
+	if values.Get("limit") != "" && q.Limit == 0 {
+		return q, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidTaskQuery, MaxTaskPage)
+	}
+	if q.DeadlineFrom, err = parseDeadlineBound(values.Get("deadline_from"), today); err != nil {
+		return q, err
+	}
+	if q.DeadlineTo, err = parseDeadlineBound(values.Get("deadline_to"), today); err != nil {
+		return q, err
+	}
+	if text := values.Get("q"); text != "" {
+		if q.Terms, err = ParseSearchQuery(text); err != nil {
+			return q, fmt.Errorf("%w: q has no words", ErrInvalidTaskQuery)
+		}
+	}
+
+	q.Sort = TaskSort(values.Get("sort"))
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf(`%w: order must be "asc" or "desc"`, ErrInvalidTaskQuery)
+	}
+	q.TagMatch = TagMatch(values.Get("match"))
+	if q.TagMatch != "" && q.TagMatch != TagMatchAny && q.TagMatch != TagMatchAll {
+		return q, fmt.Errorf(`%w: match must be "any" or "all"`, ErrInvalidTaskQuery)
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		if q.After, err = ParseTaskCursor(cursor); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// parseDeadlineBound reads a deadline filter, today+N counting N days from
+// the date of today. An unescaped + reaches it as a space, which is read as
+// a + too.
+func parseDeadlineBound(s string, today time.Time) (Deadline, error) {
+	if rest, ok := strings.CutPrefix(s, "today"); ok {
+		days := 0
+		if strings.HasPrefix(rest, " ") {
+			rest = "+" + rest[1:]
+		}
+		if rest != "" {
+			n, err := strconv.Atoi(rest)
+			if err != nil || (rest[0] != '+' && rest[0] != '-') {
+				return Deadline{}, fmt.Errorf("%w: invalid deadline %q: use today, today+N or today-N", ErrInvalidTaskQuery, s)
+			}
+			days = n
+		}
+		return DateDeadline(today.Year(), today.Month(), today.Day()+days), nil
+	}
+	d, err := ParseDeadline(s)
+	if err != nil {
+		return d, fmt.Errorf("%w: %v", ErrInvalidTaskQuery, err)
+	}
+	return d, nil
+}
+
+// queryList splits the repeated or comma separated values of key.
+func queryList(values url.Values, key string) []string {
+	var list []string
+	for _, value := range values[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// TaskListPage is a page of the task list as the API returns it.
+type TaskListPage struct {
+	Tasks      []TaskResponse `json:"tasks"`
+	NextCursor *string        `json:"next_cursor"` // null on the last page
+	Total      int            `json:"total"`       // tasks matching the filters on every page
+}
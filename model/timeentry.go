@@ -0,0 +1,77 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrTimerRunning     = errors.New("a timer is already running")
+	ErrNoTimerRunning   = errors.New("no timer is running")
+	ErrInvalidTimeEntry = errors.New("invalid time entry")
+)
+
+// MaxTimeEntryNote is the longest note of a time entry in characters.
+const MaxTimeEntryNote = 1000
+
+// TimeEntry is time spent on a task. A running timer has no End yet; a user
+// has at most one running at a time.
+type TimeEntry struct {
+	ID     int        `json:"id"`
+	TaskID int        `json:"task_id"`
+	UserID int        `json:"user_id"`
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end"`
+	Note   string     `json:"note"`
+}
+
+// Running reports whether the timer of e has not been stopped.
+func (e TimeEntry) Running() bool {
+	return e.End == nil
+}
+
+// Duration is the time e covers, up to now for a running timer.
+func (e TimeEntry) Duration(now time.Time) time.Duration {
+	if e.End == nil {
+		return now.Sub(e.Start)
+	}
+	return e.End.Sub(e.Start)
+}
+
+// TimerRequest is the optional body of POST /api/v1/task/:id/timer/start.
+type TimerRequest struct {
+	Note string `json:"note"`
+}
+
+// TimeEntryRequest is the body of a manual entry and of an edit. End may
+// only be left out when editing a running timer.
+type TimeEntryRequest struct {
+	Start time.Time  `json:"start" binding:"required"`
+	End   *time.Time `json:"end"`
+	Note  string     `json:"note"`
+}
+
+// Validate checks the times and note of a time entry.
+func (r TimeEntryRequest) Validate() error {
+	if r.Start.IsZero() {
+		return fmt.Errorf("%w: start is required", ErrInvalidTimeEntry)
+	}
+	if r.End != nil && !r.End.After(r.Start) {
+		return fmt.Errorf("%w: end must be after start", ErrInvalidTimeEntry)
+	}
+	return validateTimeEntryNote(r.Note)
+}
+
+func validateTimeEntryNote(note string) error {
+	if utf8.RuneCountInString(note) > MaxTimeEntryNote {
+		return fmt.Errorf("%w: note is longer than %d characters", ErrInvalidTimeEntry, MaxTimeEntryNote)
+	}
+	return nil
+}
+
+// Validate checks the note of a timer.
+func (r TimerRequest) Validate() error {
+	return validateTimeEntryNote(r.Note)
+}
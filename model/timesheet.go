@@ -0,0 +1,197 @@
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTimeRange is returned for a timesheet range that cannot be read.
+var ErrInvalidTimeRange = errors.New("invalid time range")
+
+// MaxTimesheetDays is the longest range a timesheet covers.
+const MaxTimesheetDays = 366
+
+// TimesheetRow is the time spent on one task on one day.
+type TimesheetRow struct {
+	Date       string  `json:"date"`
+	CategoryID int     `json:"category_id"`
+	Category   string  `json:"category"`
+	TaskID     int     `json:"task_id"`
+	Task       string  `json:"task"`
+	Seconds    int64   `json:"seconds"`
+	Hours      float64 `json:"hours"`
+}
+
+// TimesheetDay is the time spent on one day.
+type TimesheetDay struct {
+	Date    string  `json:"date"`
+	Seconds int64   `json:"seconds"`
+	Hours   float64 `json:"hours"`
+}
+
+// TimesheetCategory is the time spent on the tasks of one category.
+type TimesheetCategory struct {
+	CategoryID int     `json:"category_id"`
+	Category   string  `json:"category"`
+	Seconds    int64   `json:"seconds"`
+	Hours      float64 `json:"hours"`
+}
+
+// Timesheet aggregates the time entries of a user over the days From to To,
+// both included, in the user's timezone. Rows are ordered by date, category
+// and task; only days and categories with time on them are listed.
+type Timesheet struct {
+	From         string              `json:"from"`
+	To           string              `json:"to"`
+	Timezone     string              `json:"timezone"`
+	Rows         []TimesheetRow      `json:"rows"`
+	Days         []TimesheetDay      `json:"days"`
+	Categories   []TimesheetCategory `json:"categories"`
+	TotalSeconds int64               `json:"total_seconds"`
+	TotalHours   float64             `json:"total_hours"`
+}
+
+// TimesheetRange reads the YYYY-MM-DD days from and to as the instants the
+// range starts and ends at in loc.
+func TimesheetRange(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	first, err := time.ParseInLocation("2006-01-02", from, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be a YYYY-MM-DD date", ErrInvalidTimeRange)
+	}
+	last, err := time.ParseInLocation("2006-01-02", to, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be a YYYY-MM-DD date", ErrInvalidTimeRange)
+	}
+	if last.Before(first) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to is before from", ErrInvalidTimeRange)
+	}
+	end := last.AddDate(0, 0, 1)
+	if end.After(first.AddDate(0, 0, MaxTimesheetDays)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: a timesheet covers at most %d days", ErrInvalidTimeRange, MaxTimesheetDays)
+	}
+	return first, end, nil
+}
+
+type timesheetKey struct {
+	date   string
+	taskID int
+}
+
+// BuildTimesheet adds up entries between start and end, as returned by
+// TimesheetRange, splitting the ones that run past midnight in loc over the
+// days they cover. A running timer counts up to now. tasks and categories
+// name the rows.
+func BuildTimesheet(entries []TimeEntry, tasks map[int]Task, categories map[int]Category, start, end time.Time, loc *time.Location, now time.Time) Timesheet {
+	spent := map[timesheetKey]time.Duration{}
+	for _, entry := range entries {
+		from, to := entry.Start, now
+		if entry.End != nil {
+			to = *entry.End
+		}
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+
+		for from.Before(to) {
+			day := from.In(loc)
+			midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+			chunkEnd := to
+			if midnight.Before(chunkEnd) {
+				chunkEnd = midnight
+			}
+			spent[timesheetKey{day.Format("2006-01-02"), entry.TaskID}] += chunkEnd.Sub(from)
+			from = chunkEnd
+		}
+	}
+
+	sheet := Timesheet{
+		From:       start.In(loc).Format("2006-01-02"),
+		To:         end.In(loc).AddDate(0, 0, -1).Format("2006-01-02"),
+		Timezone:   loc.String(),
+		Rows:       []TimesheetRow{},
+		Days:       []TimesheetDay{},
+		Categories: []TimesheetCategory{},
+	}
+
+	days := map[string]time.Duration{}
+	byCategory := map[int]time.Duration{}
+	var total time.Duration
+	for key, d := range spent {
+		task := tasks[key.taskID]
+		sheet.Rows = append(sheet.Rows, TimesheetRow{
+			Date:       key.date,
+			CategoryID: task.CategoryID,
+			Category:   categories[task.CategoryID].Name,
+			TaskID:     key.taskID,
+			Task:       task.Title,
+			Seconds:    int64(d / time.Second),
+			Hours:      hours(d),
+		})
+		days[key.date] += d
+		byCategory[task.CategoryID] += d
+		total += d
+	}
+	sort.Slice(sheet.Rows, func(i, j int) bool {
+		a, b := sheet.Rows[i], sheet.Rows[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.CategoryID != b.CategoryID {
+			return a.CategoryID < b.CategoryID
+		}
+		return a.TaskID < b.TaskID
+	})
+
+	for date, d := range days {
+		sheet.Days = append(sheet.Days, TimesheetDay{Date: date, Seconds: int64(d / time.Second), Hours: hours(d)})
+	}
+	sort.Slice(sheet.Days, func(i, j int) bool { return sheet.Days[i].Date < sheet.Days[j].Date })
+
+	for id, d := range byCategory {
+		sheet.Categories = append(sheet.Categories, TimesheetCategory{CategoryID: id, Category: categories[id].Name, Seconds: int64(d / time.Second), Hours: hours(d)})
+	}
+	sort.Slice(sheet.Categories, func(i, j int) bool { return sheet.Categories[i].CategoryID < sheet.Categories[j].CategoryID })
+
+	sheet.TotalSeconds = int64(total / time.Second)
+	sheet.TotalHours = hours(total)
+	return sheet
+}
+
+// hours rounds d to hundredths of an hour.
+func hours(d time.Duration) float64 {
+	return math.Round(d.Hours()*100) / 100
+}
+
+// WriteCSV writes the rows of s as CSV with a header line. Cells that a
+// spreadsheet would run as a formula are prefixed with a quote.
+func (s Timesheet) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"date", "category", "task", "hours"}); err != nil {
+		return err
+	}
+	for _, row := range s.Rows {
+		record := []string{row.Date, csvCell(row.Category), csvCell(row.Task), strconv.FormatFloat(row.Hours, 'f', 2, 64)}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRestoreConflict is returned for a trash entry that cannot be restored
+// as it was, such as a task whose category or parent is gone.
+var ErrRestoreConflict = errors.New("cannot restore")
+
+// TrashKind tells what was deleted into a trash entry.
+type TrashKind string
+
+const (
+	TrashTask     TrashKind = "task"
+	TrashCategory TrashKind = "category"
+)
+
+// TrashedTask is a task in the trash with the records that hang off it. A
+// timer that was running when the task was deleted is stopped then.
+type TrashedTask struct {
+	Task        Task         `json:"task"`
+	Comments    []Comment    `json:"comments"`
+	Attachments []Attachment `json:"attachments"` // the blobs stay until the entry is purged
+	TimeEntries []TimeEntry  `json:"time_entries"`
+}
+
+// TrashEntry is what one delete moved to the trash: a task with its
+// subtasks, or a category with the tasks deleted along with it. Restoring it
+// puts every record back under its own ID, top-level tasks first.
+type TrashEntry struct {
+	ID           int           `json:"id"`
+	UserID       int           `json:"user_id"`
+	Category     *Category     `json:"category"` // nil for a task
+	Tasks        []TrashedTask `json:"tasks"`
+	Dependencies []Dependency  `json:"dependencies"` // edges touching the tasks
+	DeletedAt    time.Time     `json:"deleted_at"`
+}
+
+// Kind is a category for an entry holding a category, a task otherwise.
+func (e TrashEntry) Kind() TrashKind {
+	if e.Category != nil {
+		return TrashCategory
+	}
+	return TrashTask
+}
+
+// Title is the name of the category or the title of the task of e.
+func (e TrashEntry) Title() string {
+	if e.Category != nil {
+		return e.Category.Name
+	}
+	if len(e.Tasks) == 0 {
+		return ""
+	}
+	return e.Tasks[0].Task.Title
+}
+
+// AttachmentKeys are the blob keys of the attachments in e, which must not be
+// swept while it is in the trash.
+func (e TrashEntry) AttachmentKeys() []string {
+	var keys []string
+	for _, t := range e.Tasks {
+		for _, a := range t.Attachments {
+			keys = append(keys, a.Key)
+		}
+	}
+	return keys
+}
+
+// HasTask reports whether the task with id is in e.
+func (e TrashEntry) HasTask(id int) bool {
+	for _, t := range e.Tasks {
+		if t.Task.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// RestoreOrder returns the tasks of e in the order they are put back:
+// top-level tasks before subtasks, so parents exist first.
+func (e TrashEntry) RestoreOrder() []TrashedTask {
+	tasks := make([]TrashedTask, 0, len(e.Tasks))
+	for _, top := range []bool{true, false} {
+		for _, t := range e.Tasks {
+			if (t.Task.ParentID == 0) == top {
+				tasks = append(tasks, t)
+			}
+		}
+	}
+	return tasks
+}
+
+// TrashedTimeEntry is entry as it goes to the trash at now, a running timer
+// being stopped.
+func TrashedTimeEntry(entry TimeEntry, now time.Time) TimeEntry {
+	if entry.Running() {
+		entry.End = &now
+	}
+	return entry
+}
+
+// TrashItem is a trash entry as the API lists it.
+type TrashItem struct {
+	ID        int        `json:"id"`
+	Kind      TrashKind  `json:"kind"`
+	Title     string     `json:"title"`
+	Tasks     int        `json:"tasks"` // tasks in the entry, subtasks included
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at"` // null when the trash is kept until emptied by hand
+}
+
+// NewTrashItem describes e for a trash kept for retention, zero meaning
+// forever.
+func NewTrashItem(e TrashEntry, retention time.Duration) TrashItem {
+	item := TrashItem{ID: e.ID, Kind: e.Kind(), Title: e.Title(), Tasks: len(e.Tasks), DeletedAt: e.DeletedAt}
+	if retention > 0 {
+		purgeAt := e.DeletedAt.Add(retention)
+		item.PurgeAt = &purgeAt
+	}
+	return item
+}
+
+// TrashedResponse answers a delete that moved records to the trash, TrashID
+// is the entry that restores them.
+type TrashedResponse struct {
+	Message string `json:"message"`
+	TrashID int    `json:"trash_id"`
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Saved views are named task list queries of a user, such as "overdue and
+// urgent", kept in the query string grammar of GET /api/v1/task/list.
+var (
+	ErrViewExists  = errors.New("view already exists")
+	ErrInvalidView = errors.New("invalid view")
+)
+
+// MaxViewQuery is the longest query string a view may hold.
+const MaxViewQuery = 1000
+
+type SavedView struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Query  string `json:"query"`  // e.g. status=In+Progress&priority_min=4&deadline_to=today
+	Pinned bool   `json:"pinned"` // listed in the web navigation
+	UserID int    `json:"user_id"`
+}
+
+// Validate checks the name of v and that its query parses as a task list
+// query, and rewrites the query with its parameters sorted. A view selects
+// and orders tasks, so it cannot hold limit or cursor.
+func (v *SavedView) Validate() error {
+	v.Name = strings.TrimSpace(v.Name)
+	if v.Name == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidView)
+	}
+	if len(v.Name) > 50 {
+		return fmt.Errorf("%w: name is longer than 50 characters", ErrInvalidView)
+	}
+	if len(v.Query) > MaxViewQuery {
+		return fmt.Errorf("%w: query is longer than %d characters", ErrInvalidView, MaxViewQuery)
+	}
+
+	values, err := url.ParseQuery(strings.TrimPrefix(v.Query, "?"))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidView, err)
+	}
+	for key := range values {
+		if key == "limit" || key == "cursor" {
+			return fmt.Errorf("%w: a view cannot hold %s", ErrInvalidView, key)
+		}
+		if !containsString(TaskQueryParams, key) {
+			return fmt.Errorf("%w: unknown parameter %q", ErrInvalidView, key)
+		}
+	}
+	query, err := ParseTaskQuery(values, time.Now())
+	if err == nil {
+		err = query.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidView, err)
+	}
+	v.Query = values.Encode()
+	return nil
+}
+
+// SameViewName reports whether two view names clash, names are unique per
+// user ignoring case and surrounding space.
+func SameViewName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// URL is the dashboard filtered by v, which can be bookmarked or sent to
+// another user.
+func (v SavedView) URL() string {
+	return "/client/dashboard?" + url.Values{"view": {v.Query}}.Encode()
+}
+
+// SavedViewResponse is a view as the API returns it.
+type SavedViewResponse struct {
+	SavedView
+	URL string `json:"url"`
+}
+
+func NewSavedViewResponse(v SavedView) SavedViewResponse {
+	return SavedViewResponse{SavedView: v, URL: v.URL()}
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StatusCategory groups the statuses of a workflow. A task in a StatusDone
+// status carries the time it got there in CompletedAt.
+type StatusCategory string
+
+const (
+	StatusTodo       StatusCategory = "todo"
+	StatusInProgress StatusCategory = "in_progress"
+	StatusDone       StatusCategory = "done"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("unknown status")
+	ErrIllegalTransition = errors.New("illegal status transition")
+	ErrInvalidWorkflow   = errors.New("invalid workflow")
+)
+
+type WorkflowStatus struct {
+	Name     string         `json:"name"`
+	Category StatusCategory `json:"category"`
+}
+
+// Workflow is the set of statuses the tasks of a user can be in and the moves
+// allowed between them. Transitions maps a status to the statuses a task may
+// move to from there, a status without an entry is final.
+type Workflow struct {
+	UserID      int                 `json:"user_id"`
+	Statuses    []WorkflowStatus    `json:"statuses"`
+	Transitions map[string][]string `json:"transitions"`
+}
+
+// DefaultWorkflow is used for users that have not configured their own. It
+// holds the statuses the task form always offered.
+func DefaultWorkflow(userID int) Workflow {
+	return Workflow{
+		UserID: userID,
+		Statuses: []WorkflowStatus{
+			{Name: "Not Started", Category: StatusTodo},
+			{Name: "In Progress", Category: StatusInProgress},
+			{Name: "Completed", Category: StatusDone},
+		},
+		Transitions: map[string][]string{
+			"Not Started": {"In Progress", "Completed"},
+			"In Progress": {"Not Started", "Completed"},
+			"Completed":   {"In Progress"},
+		},
+	}
+}
+
+// Status looks up a status by its exact name.
+func (w Workflow) Status(name string) (WorkflowStatus, bool) {
+	for _, s := range w.Statuses {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return WorkflowStatus{}, false
+}
+
+// CheckStatus fails with ErrUnknownStatus unless name is a status of w.
+func (w Workflow) CheckStatus(name string) error {
+	if _, ok := w.Status(name); ok {
+		return nil
+	}
+	names := make([]string, len(w.Statuses))
+	for i, s := range w.Statuses {
+		names[i] = s.Name
+	}
+	return fmt.Errorf("%w %q, use one of %s", ErrUnknownStatus, name, quoteAll(names))
+}
+
+// CheckTransition reports whether a task may move from one status to another.
+// A task whose status is not part of w, e.g. because the workflow changed
+// since, may move to any status.
+func (w Workflow) CheckTransition(from, to string) error {
+	if err := w.CheckStatus(to); err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+	if _, ok := w.Status(from); !ok {
+		return nil
+	}
+	for _, allowed := range w.Transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to, Allowed: w.Transitions[from]}
+}
+
+// Validate checks that w is usable: unique, non-empty status names with a
+// known category, at least one done status and transitions between statuses
+// of w only.
+func (w Workflow) Validate() error {
+	if len(w.Statuses) == 0 {
+		return fmt.Errorf("%w: no statuses", ErrInvalidWorkflow)
+	}
+
+	seen := map[string]bool{}
+	done := false
+	for _, s := range w.Statuses {
+		if strings.TrimSpace(s.Name) == "" {
+			return fmt.Errorf("%w: status without a name", ErrInvalidWorkflow)
+		}
+		key := strings.ToLower(s.Name)
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate status %q", ErrInvalidWorkflow, s.Name)
+		}
+		seen[key] = true
+
+		switch s.Category {
+		case StatusTodo, StatusInProgress:
+		case StatusDone:
+			done = true
+		default:
+			return fmt.Errorf("%w: status %q has unknown category %q", ErrInvalidWorkflow, s.Name, s.Category)
+		}
+	}
+	if !done {
+		return fmt.Errorf("%w: no status in category %q", ErrInvalidWorkflow, StatusDone)
+	}
+
+	for from, targets := range w.Transitions {
+		if _, ok := w.Status(from); !ok {
+			return fmt.Errorf("%w: transition from unknown status %q", ErrInvalidWorkflow, from)
+		}
+		for _, to := range targets {
+			if _, ok := w.Status(to); !ok {
+				return fmt.Errorf("%w: transition from %q to unknown status %q", ErrInvalidWorkflow, from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// TransitionError is returned for a move the workflow does not allow, it
+// matches ErrIllegalTransition.
+type TransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *TransitionError) Error() string {
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("cannot move task from %q to %q: %q is a final status", e.From, e.To, e.From)
+	}
+	return fmt.Sprintf("cannot move task from %q to %q, allowed from %q: %s", e.From, e.To, e.From, quoteAll(e.Allowed))
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+// TaskTransition is the body of POST /api/v1/task/:id/transition. Either
+// Status names the status to move to, or Category asks for a status of that
+// category reachable from the current one, see Workflow.Target.
+type TaskTransition struct {
+	Status   string         `json:"status" binding:"required_without=Category"`
+	Category StatusCategory `json:"category" binding:"omitempty,oneof=todo in_progress done"`
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return strings.Join(quoted, ", ")
+}
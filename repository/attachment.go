@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+type AttachmentRepository interface {
+	GetList(taskID int) ([]model.Attachment, error)
+	GetListByUserID(userID int) ([]model.Attachment, error)
+	GetTrashedKeys(userID int) ([]string, error)
+	GetByID(id int) (*model.Attachment, error)
+	Store(attachment *model.Attachment, quota int64) error
+	Delete(id int) error
+}
+
+type attachmentRepository struct {
+	store db.Store
+}
+
+func NewAttachmentRepo(store db.Store) *attachmentRepository {
+	return &attachmentRepository{store}
+}
+
+func (a *attachmentRepository) GetList(taskID int) ([]model.Attachment, error) {
+	return a.store.GetAttachments(taskID)
+}
+
+func (a *attachmentRepository) GetListByUserID(userID int) ([]model.Attachment, error) {
+	return a.store.GetAttachmentsByUserID(userID)
+}
+
+// GetTrashedKeys returns the blob keys of the attachments in the user's
+// trash.
+func (a *attachmentRepository) GetTrashedKeys(userID int) ([]string, error) {
+	entries, err := a.store.GetTrash(userID)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, entry := range entries {
+		keys = append(keys, entry.AttachmentKeys()...)
+	}
+	return keys, nil
+}
+
+func (a *attachmentRepository) GetByID(id int) (*model.Attachment, error) {
+	return a.store.GetAttachmentByID(id)
+}
+
+// Store adds attachment within the user's quota and sets its ID and CreatedAt.
+func (a *attachmentRepository) Store(attachment *model.Attachment, quota int64) error {
+	added, err := a.store.AddAttachment(*attachment, quota)
+	if err != nil {
+		return err
+	}
+	*attachment = added
+	return nil
+}
+
+func (a *attachmentRepository) Delete(id int) error {
+	return a.store.DeleteAttachment(id)
+}
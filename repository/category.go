@@ -1,57 +1,62 @@
 package repository
 
 import (
-	"a21hc3NpZ25tZW50/db/filebased"
+	"a21hc3NpZ25tZW50/db"
 	"a21hc3NpZ25tZW50/model"
 )
 
 type CategoryRepository interface {
 	Store(Category *model.Category) error
 	Update(id int, category model.Category) error
-	Delete(id int) error
+	Delete(id int, opts model.CategoryDelete) error
+	Trash(id int, opts model.CategoryDelete) (model.TrashEntry, error)
 	GetByID(id int) (*model.Category, error)
 	GetList() ([]model.Category, error)
 	GetListByUser(userID int) ([]model.Category, error)
 }
 
 type categoryRepository struct {
-	filebasedDb *filebased.Data
+	store db.Store
 }
 
-func NewCategoryRepo(filebasedDb *filebased.Data) *categoryRepository {
-	return &categoryRepository{filebasedDb}
+func NewCategoryRepo(store db.Store) *categoryRepository {
+	return &categoryRepository{store}
 }
 
 func (c *categoryRepository) Store(Category *model.Category) error {
-	err := c.filebasedDb.StoreCategory(*Category)
+	err := c.store.StoreCategory(*Category)
 	return err
 }
 
 func (c *categoryRepository) Update(id int, category model.Category) error {
-	err := c.filebasedDb.UpdateCategory(id, category)
+	err := c.store.UpdateCategory(id, category)
 	return err
 }
 
-func (c *categoryRepository) Delete(id int) error {
-	err := c.filebasedDb.DeleteCategory(id)
+func (c *categoryRepository) Delete(id int, opts model.CategoryDelete) error {
+	err := c.store.DeleteCategory(id, opts)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (c *categoryRepository) Trash(id int, opts model.CategoryDelete) (model.TrashEntry, error) {
+	return c.store.TrashCategory(id, opts)
+}
+
 func (c *categoryRepository) GetByID(id int) (*model.Category, error) {
-	category, err := c.filebasedDb.GetCategoryByID(id)
+	category, err := c.store.GetCategoryByID(id)
 
 	return category, err
 }
 
 func (c *categoryRepository) GetList() ([]model.Category, error) {
-	categories, err := c.filebasedDb.GetCategories()
+	categories, err := c.store.GetCategories()
 	return categories, err
 }
 
 func (c *categoryRepository) GetListByUser(userID int) ([]model.Category, error) {
-	categories, err := c.filebasedDb.GetCategoriesByUserID(userID)
+	categories, err := c.store.GetCategoriesByUserID(userID)
 	return categories, err
 }
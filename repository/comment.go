@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+type CommentRepository interface {
+	GetList(taskID int) ([]model.Comment, error)
+	GetByID(id int) (*model.Comment, error)
+	Store(comment *model.Comment) error
+	Update(id int, body string) (model.Comment, error)
+	Delete(id int) error
+}
+
+type commentRepository struct {
+	store db.Store
+}
+
+func NewCommentRepo(store db.Store) *commentRepository {
+	return &commentRepository{store}
+}
+
+func (c *commentRepository) GetList(taskID int) ([]model.Comment, error) {
+	return c.store.GetComments(taskID)
+}
+
+func (c *commentRepository) GetByID(id int) (*model.Comment, error) {
+	return c.store.GetCommentByID(id)
+}
+
+// Store adds comment and sets its ID and timestamps.
+func (c *commentRepository) Store(comment *model.Comment) error {
+	added, err := c.store.AddComment(*comment)
+	if err != nil {
+		return err
+	}
+	*comment = added
+	return nil
+}
+
+func (c *commentRepository) Update(id int, body string) (model.Comment, error) {
+	return c.store.UpdateComment(id, body)
+}
+
+func (c *commentRepository) Delete(id int) error {
+	return c.store.DeleteComment(id)
+}
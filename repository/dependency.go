@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+type DependencyRepository interface {
+	GetList(userID int) ([]model.Dependency, error)
+	Add(dep model.Dependency) error
+	Remove(dep model.Dependency) error
+}
+
+type dependencyRepository struct {
+	store db.Store
+}
+
+func NewDependencyRepo(store db.Store) *dependencyRepository {
+	return &dependencyRepository{store}
+}
+
+func (d *dependencyRepository) GetList(userID int) ([]model.Dependency, error) {
+	return d.store.GetDependencies(userID)
+}
+
+func (d *dependencyRepository) Add(dep model.Dependency) error {
+	return d.store.AddDependency(dep)
+}
+
+func (d *dependencyRepository) Remove(dep model.Dependency) error {
+	return d.store.RemoveDependency(dep)
+}
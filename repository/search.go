@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+type SearchRepository interface {
+	Search(userID int, terms []string) ([]model.SearchMatch, error)
+}
+
+type searchRepository struct {
+	store db.Store
+}
+
+func NewSearchRepo(store db.Store) *searchRepository {
+	return &searchRepository{store}
+}
+
+func (s *searchRepository) Search(userID int, terms []string) ([]model.SearchMatch, error) {
+	return s.store.SearchTasks(userID, terms)
+}
@@ -1,8 +1,9 @@
 package repository
 
 import (
-	"a21hc3NpZ25tZW50/db/filebased"
+	"a21hc3NpZ25tZW50/db"
 	"a21hc3NpZ25tZW50/model"
+	"errors"
 	"time"
 )
 
@@ -12,19 +13,27 @@ type SessionRepository interface {
 	UpdateSessions(session model.Session) error
 	SessionAvailEmail(email string) (model.Session, error)
 	SessionAvailToken(token string) (model.Session, error)
+	TokenValidity(token string) (model.Session, error)
+	DeleteSessionsByEmail(email string) error
+	DeleteSessionsByID(id int) error
+	GetSessionsByEmail(email string) ([]model.Session, error)
+	TouchSession(token string, at time.Time) error
+	SessionByRefreshToken(refreshToken string) (model.Session, error)
+	RotateSession(old model.Session, next model.Session) error
+	DeleteSessionFamily(familyID string) error
 	TokenExpired(session model.Session) bool
 }
 
 type sessionsRepo struct {
-	filebasedDb *filebased.Data
+	store db.Store
 }
 
-func NewSessionsRepo(filebasedDb *filebased.Data) *sessionsRepo {
-	return &sessionsRepo{filebasedDb}
+func NewSessionsRepo(store db.Store) *sessionsRepo {
+	return &sessionsRepo{store}
 }
 
 func (u *sessionsRepo) AddSessions(session model.Session) error {
-	err := u.filebasedDb.AddSession(session)
+	err := u.store.AddSession(session)
 	if err != nil {
 		return err
 	}
@@ -32,15 +41,31 @@ func (u *sessionsRepo) AddSessions(session model.Session) error {
 }
 
 func (u *sessionsRepo) DeleteSession(token string) error {
-	err := u.filebasedDb.DeleteSession(token)
+	err := u.store.DeleteSession(token)
 	if err != nil {
 		return err
 	}
 	return nil 
 }
 
+func (u *sessionsRepo) DeleteSessionsByEmail(email string) error {
+	return u.store.DeleteSessionsByEmail(email)
+}
+
+func (u *sessionsRepo) DeleteSessionsByID(id int) error {
+	return u.store.DeleteSessionsByID(id)
+}
+
+func (u *sessionsRepo) GetSessionsByEmail(email string) ([]model.Session, error) {
+	return u.store.GetSessionsByEmail(email)
+}
+
+func (u *sessionsRepo) TouchSession(token string, at time.Time) error {
+	return u.store.TouchSession(token, at)
+}
+
 func (u *sessionsRepo) UpdateSessions(session model.Session) error {
-	err := u.filebasedDb.UpdateSession(session)
+	err := u.store.UpdateSession(session)
 	if err != nil {
 		return err
 	}
@@ -48,7 +73,7 @@ func (u *sessionsRepo) UpdateSessions(session model.Session) error {
 }
 
 func (u *sessionsRepo) SessionAvailEmail(email string) (model.Session, error) {
-	session, err := u.filebasedDb.SessionAvailEmail(email)
+	session, err := u.store.SessionAvailEmail(email)
 	if err != nil {
 		return model.Session{}, err
 	}
@@ -56,13 +81,29 @@ func (u *sessionsRepo) SessionAvailEmail(email string) (model.Session, error) {
 }
 
 func (u *sessionsRepo) SessionAvailToken(token string) (model.Session, error) {
-	session, err := u.filebasedDb.SessionAvailToken(token)
+	session, err := u.store.SessionAvailToken(token)
 	if err != nil {
 		return model.Session{}, err
 	}
 	return session, nil // TODO: replace this
 }
 
+func (u *sessionsRepo) SessionByRefreshToken(refreshToken string) (model.Session, error) {
+	session, err := u.store.SessionByRefreshToken(refreshToken)
+	if err != nil {
+		return model.Session{}, err
+	}
+	return session, nil
+}
+
+func (u *sessionsRepo) RotateSession(old model.Session, next model.Session) error {
+	return u.store.RotateSession(old, next)
+}
+
+func (u *sessionsRepo) DeleteSessionFamily(familyID string) error {
+	return u.store.DeleteSessionFamily(familyID)
+}
+
 func (u *sessionsRepo) TokenValidity(token string) (model.Session, error) {
 	session, err := u.SessionAvailToken(token)
 	if err != nil {
@@ -74,7 +115,7 @@ func (u *sessionsRepo) TokenValidity(token string) (model.Session, error) {
 		if err != nil {
 			return model.Session{}, err
 		}
-		return model.Session{}, err
+		return model.Session{}, errors.New("session expired")
 	}
 
 	return session, nil
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+type TagRepository interface {
+	GetList(userID int) ([]model.Tag, error)
+	GetByID(id int) (*model.Tag, error)
+	Store(tag *model.Tag) error
+	Update(tag model.Tag) error
+	Delete(id int) error
+}
+
+type tagRepository struct {
+	store db.Store
+}
+
+func NewTagRepo(store db.Store) *tagRepository {
+	return &tagRepository{store}
+}
+
+func (t *tagRepository) GetList(userID int) ([]model.Tag, error) {
+	return t.store.GetTagsByUserID(userID)
+}
+
+func (t *tagRepository) GetByID(id int) (*model.Tag, error) {
+	return t.store.GetTagByID(id)
+}
+
+// Store creates tag and sets its ID.
+func (t *tagRepository) Store(tag *model.Tag) error {
+	created, err := t.store.CreateTag(*tag)
+	if err != nil {
+		return err
+	}
+	*tag = created
+	return nil
+}
+
+func (t *tagRepository) Update(tag model.Tag) error {
+	return t.store.UpdateTag(tag)
+}
+
+func (t *tagRepository) Delete(id int) error {
+	return t.store.DeleteTag(id)
+}
@@ -1,7 +1,7 @@
 package repository
 
 import (
-	"a21hc3NpZ25tZW50/db/filebased"
+	"a21hc3NpZ25tZW50/db"
 	"a21hc3NpZ25tZW50/model"
 )
 
@@ -9,55 +9,70 @@ type TaskRepository interface {
 	Store(task *model.Task) error
 	Update(taskID int, task *model.Task) error
 	Delete(id int) error
+	Trash(id int) (model.TrashEntry, error)
 	GetByID(id int) (*model.Task, error)
 	GetList(userID int) ([]model.Task, error)
+	GetPage(userID int, query model.TaskQuery) (model.TaskPage, error)
+	Write(writes []model.TaskWrite, atomic bool) error
 	GetTaskCategory(id int) ([]model.TaskCategory, error)
 	GetTaskCategoryByUser(categoryID, userID int) ([]model.TaskCategory, error)
 }
 
 type taskRepository struct {
-	filebased *filebased.Data
+	store db.Store
 }
 
-func NewTaskRepo(filebasedDb *filebased.Data) *taskRepository {
+func NewTaskRepo(store db.Store) *taskRepository {
 	return &taskRepository{
-		filebased: filebasedDb,
+		store: store,
 	}
 }
 
 func (t *taskRepository) Store(task *model.Task) error {
-	err := t.filebased.StoreTask(*task)
+	err := t.store.StoreTask(*task)
 	return err
 }
 
 func (t *taskRepository) Update(taskID int, task *model.Task) error {
-	err := t.filebased.UpdateTask(task.ID, *task)
+	err := t.store.UpdateTask(task.ID, *task)
 	return err
 }
 
 func (t *taskRepository) Delete(id int) error {
-	err := t.filebased.DeleteTask(id)
+	err := t.store.DeleteTask(id)
 	return err
 }
 
+func (t *taskRepository) Trash(id int) (model.TrashEntry, error) {
+	return t.store.TrashTask(id)
+}
+
 func (t *taskRepository) GetByID(id int) (*model.Task, error) {
-	task, err := t.filebased.GetTaskByID(id)
+	task, err := t.store.GetTaskByID(id)
 
 	return task, err // TODO: replace this
 }
 
 func (t *taskRepository) GetList(userID int) ([]model.Task, error) {
-	tasks, err := t.filebased.GetTasksByUserID(userID)
+	tasks, err := t.store.GetTasksByUserID(userID)
 	return tasks, err
 }
 
+func (t *taskRepository) GetPage(userID int, query model.TaskQuery) (model.TaskPage, error) {
+	return t.store.GetTaskPage(userID, query)
+}
+
+func (t *taskRepository) Write(writes []model.TaskWrite, atomic bool) error {
+	return t.store.WriteTasks(writes, atomic)
+}
+
 func (t *taskRepository) GetTaskCategory(id int) ([]model.TaskCategory, error) {
-	taskCategories, err := t.filebased.GetTaskListByCategory(id)
+	taskCategories, err := t.store.GetTaskListByCategory(id)
 
 	return taskCategories, err // TODO: replace this
 }
 
 func (t *taskRepository) GetTaskCategoryByUser(categoryID, userID int) ([]model.TaskCategory, error) {
-	taskCategories, err := t.filebased.GetTaskListByCategoryAndUser(categoryID, userID)
+	taskCategories, err := t.store.GetTaskListByCategoryAndUser(categoryID, userID)
 	return taskCategories, err
 }
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+	"time"
+)
+
+type TimeEntryRepository interface {
+	GetList(userID int, from, to time.Time) ([]model.TimeEntry, error)
+	GetListByTask(taskID int) ([]model.TimeEntry, error)
+	GetByID(id int) (*model.TimeEntry, error)
+	GetRunning(userID int) (*model.TimeEntry, error)
+	Store(entry *model.TimeEntry) error
+	Update(entry model.TimeEntry) error
+	Stop(userID int, end time.Time) (model.TimeEntry, error)
+	Delete(id int) error
+}
+
+type timeEntryRepository struct {
+	store db.Store
+}
+
+func NewTimeEntryRepo(store db.Store) *timeEntryRepository {
+	return &timeEntryRepository{store}
+}
+
+func (t *timeEntryRepository) GetList(userID int, from, to time.Time) ([]model.TimeEntry, error) {
+	return t.store.GetTimeEntries(userID, from, to)
+}
+
+func (t *timeEntryRepository) GetListByTask(taskID int) ([]model.TimeEntry, error) {
+	return t.store.GetTimeEntriesByTaskID(taskID)
+}
+
+func (t *timeEntryRepository) GetByID(id int) (*model.TimeEntry, error) {
+	return t.store.GetTimeEntryByID(id)
+}
+
+func (t *timeEntryRepository) GetRunning(userID int) (*model.TimeEntry, error) {
+	return t.store.GetRunningTimeEntry(userID)
+}
+
+// Store adds entry and sets its ID.
+func (t *timeEntryRepository) Store(entry *model.TimeEntry) error {
+	added, err := t.store.AddTimeEntry(*entry)
+	if err != nil {
+		return err
+	}
+	*entry = added
+	return nil
+}
+
+func (t *timeEntryRepository) Update(entry model.TimeEntry) error {
+	return t.store.UpdateTimeEntry(entry)
+}
+
+func (t *timeEntryRepository) Stop(userID int, end time.Time) (model.TimeEntry, error) {
+	return t.store.StopTimer(userID, end)
+}
+
+func (t *timeEntryRepository) Delete(id int) error {
+	return t.store.DeleteTimeEntry(id)
+}
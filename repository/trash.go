@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+	"time"
+)
+
+type TrashRepository interface {
+	GetList(userID int) ([]model.TrashEntry, error)
+	GetByID(id int) (*model.TrashEntry, error)
+	Restore(id int) (model.TrashEntry, error)
+	Purge(id int) error
+	PurgeBefore(t time.Time) ([]model.TrashEntry, error)
+}
+
+type trashRepository struct {
+	store db.Store
+}
+
+func NewTrashRepo(store db.Store) *trashRepository {
+	return &trashRepository{store}
+}
+
+func (t *trashRepository) GetList(userID int) ([]model.TrashEntry, error) {
+	return t.store.GetTrash(userID)
+}
+
+func (t *trashRepository) GetByID(id int) (*model.TrashEntry, error) {
+	return t.store.GetTrashEntry(id)
+}
+
+func (t *trashRepository) Restore(id int) (model.TrashEntry, error) {
+	return t.store.RestoreTrash(id)
+}
+
+func (t *trashRepository) Purge(id int) error {
+	return t.store.PurgeTrash(id)
+}
+
+// PurgeBefore drops the entries deleted before t and returns them.
+func (t *trashRepository) PurgeBefore(before time.Time) ([]model.TrashEntry, error) {
+	return t.store.PurgeTrashBefore(before)
+}
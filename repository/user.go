@@ -1,27 +1,28 @@
 package repository
 
 import (
-	"a21hc3NpZ25tZW50/db/filebased"
+	"a21hc3NpZ25tZW50/db"
 	"a21hc3NpZ25tZW50/model"
 )
 
 type UserRepository interface {
 	GetUserByEmail(email string) (model.User, error)
 	CreateUser(user model.User) (model.User, error)
+	UpdateUser(user model.User) error
 	GetUserTaskCategory() ([]model.UserTaskCategory, error)
 	GetUsers() ([]model.User, error)
 }
 
 type userRepository struct {
-	filebasedDb *filebased.Data
+	store db.Store
 }
 
-func NewUserRepo(filebasedDb *filebased.Data) *userRepository {
-	return &userRepository{filebasedDb}
+func NewUserRepo(store db.Store) *userRepository {
+	return &userRepository{store}
 }
 
 func (r *userRepository) GetUserByEmail(email string) (model.User, error) {
-	user, err := r.filebasedDb.GetUserByEmail(email)
+	user, err := r.store.GetUserByEmail(email)
 
 	if err != nil {
 		return model.User{}, err
@@ -30,7 +31,7 @@ func (r *userRepository) GetUserByEmail(email string) (model.User, error) {
 }
 
 func (r *userRepository) CreateUser(user model.User) (model.User, error) {
-	createdUser, err := r.filebasedDb.CreateUser(user)
+	createdUser, err := r.store.CreateUser(user)
 
 	if err != nil {
 		return model.User{}, err
@@ -39,13 +40,17 @@ func (r *userRepository) CreateUser(user model.User) (model.User, error) {
 	return createdUser, nil
 }
 
+func (r *userRepository) UpdateUser(user model.User) error {
+	return r.store.UpdateUser(user)
+}
+
 func (r *userRepository) GetUserTaskCategory() ([]model.UserTaskCategory, error) {
 	var UserTaskCategory []model.UserTaskCategory
-	UserTaskCategory, err := r.filebasedDb.GetUserTaskCategory()
+	UserTaskCategory, err := r.store.GetUserTaskCategory()
 
 	return UserTaskCategory, err // TODO: replace this
 }
 
 func (r *userRepository) GetUsers() ([]model.User, error) {
-	return r.filebasedDb.GetUsers()
+	return r.store.GetUsers()
 }
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+type ViewRepository interface {
+	GetList(userID int) ([]model.SavedView, error)
+	GetByID(id int) (*model.SavedView, error)
+	Store(view *model.SavedView) error
+	Update(view model.SavedView) error
+	Delete(id int) error
+}
+
+type viewRepository struct {
+	store db.Store
+}
+
+func NewViewRepo(store db.Store) *viewRepository {
+	return &viewRepository{store}
+}
+
+func (v *viewRepository) GetList(userID int) ([]model.SavedView, error) {
+	return v.store.GetViewsByUserID(userID)
+}
+
+func (v *viewRepository) GetByID(id int) (*model.SavedView, error) {
+	return v.store.GetViewByID(id)
+}
+
+// Store creates view and sets its ID.
+func (v *viewRepository) Store(view *model.SavedView) error {
+	created, err := v.store.CreateView(*view)
+	if err != nil {
+		return err
+	}
+	*view = created
+	return nil
+}
+
+func (v *viewRepository) Update(view model.SavedView) error {
+	return v.store.UpdateView(view)
+}
+
+func (v *viewRepository) Delete(id int) error {
+	return v.store.DeleteView(id)
+}
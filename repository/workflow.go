@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"a21hc3NpZ25tZW50/db"
+	"a21hc3NpZ25tZW50/model"
+)
+
+type WorkflowRepository interface {
+	Get(userID int) (*model.Workflow, error)
+	Save(workflow model.Workflow) error
+}
+
+type workflowRepository struct {
+	store db.Store
+}
+
+func NewWorkflowRepo(store db.Store) *workflowRepository {
+	return &workflowRepository{store}
+}
+
+func (w *workflowRepository) Get(userID int) (*model.Workflow, error) {
+	return w.store.GetWorkflow(userID)
+}
+
+func (w *workflowRepository) Save(workflow model.Workflow) error {
+	return w.store.SaveWorkflow(workflow)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"a21hc3NpZ25tZW50/db/blob"
+	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type AttachmentService interface {
+	GetList(taskID int) ([]model.Attachment, error)
+	GetByID(taskID, id int) (*model.Attachment, error)
+	Store(attachment *model.Attachment, content io.Reader) error
+	Open(attachment model.Attachment) (io.ReadCloser, error)
+	Delete(attachment model.Attachment) error
+	Sweep(userID int) (int, error)
+	MaxSize() int64
+}
+
+type attachmentService struct {
+	attachmentRepository repo.AttachmentRepository
+	blobs                blob.Store
+	maxSize              int64
+	quota                int64
+}
+
+// NewAttachmentService stores the content of attachments in blobs. A single
+// file may be up to maxSize bytes, the files of a user up to quota bytes in
+// total; zero means no quota.
+func NewAttachmentService(attachmentRepository repo.AttachmentRepository, blobs blob.Store, maxSize, quota int64) AttachmentService {
+	return &attachmentService{attachmentRepository, blobs, maxSize, quota}
+}
+
+func (s *attachmentService) GetList(taskID int) ([]model.Attachment, error) {
+	return s.attachmentRepository.GetList(taskID)
+}
+
+// GetByID returns an attachment of the task, model.ErrRecordNotFound if the
+// attachment is on another task.
+func (s *attachmentService) GetByID(taskID, id int) (*model.Attachment, error) {
+	attachment, err := s.attachmentRepository.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if attachment.TaskID != taskID {
+		return nil, model.ErrRecordNotFound
+	}
+	return attachment, nil
+}
+
+// Store saves content as an attachment of Size bytes. The content type is
+// sniffed from the first bytes. The record is written first so the quota is
+// checked before any content is, and it is removed again if the content
+// cannot be stored.
+func (s *attachmentService) Store(attachment *model.Attachment, content io.Reader) error {
+	if attachment.Size > s.maxSize {
+		return model.ErrAttachmentTooLarge
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	head = head[:n]
+	attachment.ContentType, err = model.SniffAttachmentType(head)
+	if err != nil {
+		return err
+	}
+
+	attachment.Name = model.AttachmentName(attachment.Name)
+	attachment.Key, err = newBlobKey(attachment.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.attachmentRepository.Store(attachment, s.quota); err != nil {
+		return err
+	}
+
+	// Never store more than was counted against the quota
+	rest := io.LimitReader(content, attachment.Size-int64(n))
+	err = s.blobs.Put(attachment.Key, io.MultiReader(bytes.NewReader(head), rest))
+	if err != nil {
+		if delErr := s.attachmentRepository.Delete(attachment.ID); delErr != nil {
+			return fmt.Errorf("%v, and removing its record failed: %v", err, delErr)
+		}
+		if errors.Is(err, blob.ErrTooLarge) {
+			return model.ErrAttachmentTooLarge
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *attachmentService) Open(attachment model.Attachment) (io.ReadCloser, error) {
+	return s.blobs.Open(attachment.Key)
+}
+
+// Delete removes the record before the content, so a failure leaves at most
+// a blob for Sweep.
+func (s *attachmentService) Delete(attachment model.Attachment) error {
+	if err := s.attachmentRepository.Delete(attachment.ID); err != nil {
+		return err
+	}
+	return s.blobs.Delete(attachment.Key)
+}
+
+// Sweep deletes the blobs of a user no attachment refers to any more, such as
+// those of purged tasks, and returns how many it deleted. The blobs are
+// listed before the records: an upload writes its record before its blob, so
+// a blob that is listed and in use always has a record that is listed too.
+// The trash is read before and after the attachments, so a record moving
+// either way between them is seen once.
+func (s *attachmentService) Sweep(userID int) (int, error) {
+	prefix := fmt.Sprintf("%d/", userID)
+	keys, err := s.blobs.List(prefix)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	trashed, err := s.attachmentRepository.GetTrashedKeys(userID)
+	if err != nil {
+		return 0, err
+	}
+	attachments, err := s.attachmentRepository.GetListByUserID(userID)
+	if err != nil {
+		return 0, err
+	}
+	trashedAfter, err := s.attachmentRepository.GetTrashedKeys(userID)
+	if err != nil {
+		return 0, err
+	}
+	inUse := make(map[string]bool, len(attachments))
+	for _, attachment := range attachments {
+		inUse[attachment.Key] = true
+	}
+	for _, key := range append(trashed, trashedAfter...) {
+		inUse[key] = true
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if inUse[key] {
+			continue
+		}
+		if err := s.blobs.Delete(key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// MaxSize is the size of the largest file that may be attached.
+func (s *attachmentService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// newBlobKey returns a random key under the user's prefix.
+func newBlobKey(userID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%s", userID, hex.EncodeToString(b)), nil
+}
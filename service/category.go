@@ -2,13 +2,18 @@ package service
 
 import (
 	"a21hc3NpZ25tZW50/model"
+	"fmt"
+	"strings"
+
 	repo "a21hc3NpZ25tZW50/repository"
 )
 
 type CategoryService interface {
 	Store(category *model.Category) error
 	Update(id int, category model.Category) error
-	Delete(id int) error
+	Patch(id int, patch model.Patch, version int) (*model.Category, error)
+	Delete(id int, opts model.CategoryDelete) error
+	Trash(id int, opts model.CategoryDelete) (model.TrashEntry, error)
 	DeleteByName(name string) error
 	GetByID(id int) (*model.Category, error)
 	GetList() ([]model.Category, error)
@@ -40,14 +45,59 @@ func (c *categoryService) Update(id int, category model.Category) error {
 	return nil
 }
 
-func (c *categoryService) Delete(id int) error {
-	err := c.categoryRepository.Delete(id)
+// Patch renames a category through a patch, see TaskService.Patch. The name
+// must stay set and unique among the user's categories.
+func (c *categoryService) Patch(id int, patch model.Patch, version int) (*model.Category, error) {
+	for attempt := 1; ; attempt++ {
+		existing, err := c.categoryRepository.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && existing.Version != version {
+			return nil, model.ErrVersionConflict
+		}
+
+		var category model.Category
+		if err := patch.ApplyTo(existing, &category); err != nil {
+			return nil, err
+		}
+		category.ID, category.UserID = existing.ID, existing.UserID
+		category.Version, category.UpdatedAt = existing.Version, existing.UpdatedAt
+
+		category.Name = strings.TrimSpace(category.Name)
+		if category.Name == "" {
+			return nil, fmt.Errorf("%w: name cannot be empty", model.ErrInvalidPatch)
+		}
+
+		// The store checks the name in the transaction that writes it
+		err = c.categoryRepository.Update(id, category)
+		if err == model.ErrVersionConflict && version == 0 && attempt < patchAttempts {
+			continue
+		}
+		if err == model.ErrCategoryExists {
+			return nil, fmt.Errorf("%w: category with this name already exists", model.ErrInvalidPatch)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return c.categoryRepository.GetByID(id)
+	}
+}
+
+func (c *categoryService) Delete(id int, opts model.CategoryDelete) error {
+	err := c.categoryRepository.Delete(id, opts)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Trash deletes the category into the trash, along with its tasks when
+// opts cascades.
+func (c *categoryService) Trash(id int, opts model.CategoryDelete) (model.TrashEntry, error) {
+	return c.categoryRepository.Trash(id, opts)
+}
+
 func (c *categoryService) DeleteByName(name string) error {
 	// First, get all categories to find the one with matching name
 	categories, err := c.categoryRepository.GetList()
@@ -58,7 +108,7 @@ func (c *categoryService) DeleteByName(name string) error {
 	// Find category with matching name
 	for _, category := range categories {
 		if category.Name == name {
-			return c.categoryRepository.Delete(category.ID)
+			return c.categoryRepository.Delete(category.ID, model.CategoryDelete{})
 		}
 	}
 
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
+)
+
+type CommentService interface {
+	GetList(taskID int) ([]model.Comment, error)
+	GetByID(taskID, id int) (*model.Comment, error)
+	Store(comment *model.Comment) error
+	Update(id int, body string) (model.Comment, error)
+	Delete(id int) error
+}
+
+type commentService struct {
+	commentRepository repo.CommentRepository
+}
+
+func NewCommentService(commentRepository repo.CommentRepository) CommentService {
+	return &commentService{commentRepository}
+}
+
+// GetList returns the thread of a task, oldest comment first.
+func (s *commentService) GetList(taskID int) ([]model.Comment, error) {
+	return s.commentRepository.GetList(taskID)
+}
+
+// GetByID returns a comment of the task, model.ErrRecordNotFound if the
+// comment is on another task.
+func (s *commentService) GetByID(taskID, id int) (*model.Comment, error) {
+	comment, err := s.commentRepository.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.TaskID != taskID {
+		return nil, model.ErrRecordNotFound
+	}
+	return comment, nil
+}
+
+func (s *commentService) Store(comment *model.Comment) error {
+	if err := (model.CommentRequest{Body: comment.Body}).Validate(); err != nil {
+		return err
+	}
+	return s.commentRepository.Store(comment)
+}
+
+// Update replaces the body of a comment, the old one goes to its history. An
+// unchanged body adds no revision.
+func (s *commentService) Update(id int, body string) (model.Comment, error) {
+	if err := (model.CommentRequest{Body: body}).Validate(); err != nil {
+		return model.Comment{}, err
+	}
+	comment, err := s.commentRepository.GetByID(id)
+	if err != nil {
+		return model.Comment{}, err
+	}
+	if comment.Body == body {
+		return *comment, nil
+	}
+	return s.commentRepository.Update(id, body)
+}
+
+func (s *commentService) Delete(id int) error {
+	return s.commentRepository.Delete(id)
+}
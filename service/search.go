@@ -0,0 +1,54 @@
+package service
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
+)
+
+type SearchService interface {
+	Search(userID int, query string, limit int) ([]model.SearchHit, error)
+}
+
+type searchService struct {
+	searchRepository repo.SearchRepository
+	taskRepository   repo.TaskRepository
+}
+
+func NewSearchService(searchRepository repo.SearchRepository, taskRepository repo.TaskRepository) SearchService {
+	return &searchService{searchRepository, taskRepository}
+}
+
+// Search returns the best limit tasks of a user matching every word of
+// query, with the tasks filled in. A query without words fails with
+// model.ErrInvalidSearch.
+func (s *searchService) Search(userID int, query string, limit int) ([]model.SearchHit, error) {
+	terms, err := model.ParseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.searchRepository.Search(userID, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []model.SearchHit{}
+	for _, hit := range model.RankSearch(terms, matches) {
+		if len(hits) == limit {
+			break
+		}
+		// A task deleted since it was matched is left out
+		task, err := s.taskRepository.GetByID(hit.TaskID)
+		if err == model.ErrRecordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if task.UserID != userID {
+			continue
+		}
+		hit.Task = *task
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
@@ -7,6 +7,7 @@ import (
 
 type SessionService interface {
 	GetSessionByEmail(email string) (model.Session, error)
+	GetSessionByToken(token string) (model.Session, error)
 }
 
 type sessionService struct {
@@ -24,3 +25,11 @@ func (c *sessionService) GetSessionByEmail(email string) (model.Session, error)
 	}
 	return session, nil // TODO: replace this
 }
+
+func (c *sessionService) GetSessionByToken(token string) (model.Session, error) {
+	session, err := c.sessionRepo.SessionAvailToken(token)
+	if err != nil {
+		return model.Session{}, err
+	}
+	return session, nil
+}
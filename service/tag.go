@@ -0,0 +1,58 @@
+package service
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
+	"strings"
+)
+
+type TagService interface {
+	GetList(userID int) ([]model.Tag, error)
+	GetByID(id int) (*model.Tag, error)
+	Store(tag *model.Tag) error
+	Update(tag model.Tag) error
+	Delete(id int) error
+}
+
+type tagService struct {
+	tagRepository repo.TagRepository
+}
+
+func NewTagService(tagRepository repo.TagRepository) TagService {
+	return &tagService{tagRepository}
+}
+
+func (s *tagService) GetList(userID int) ([]model.Tag, error) {
+	return s.tagRepository.GetList(userID)
+}
+
+func (s *tagService) GetByID(id int) (*model.Tag, error) {
+	return s.tagRepository.GetByID(id)
+}
+
+// Store creates a tag, in DefaultTagColor unless it has a color.
+func (s *tagService) Store(tag *model.Tag) error {
+	if err := checkTag(tag); err != nil {
+		return err
+	}
+	return s.tagRepository.Store(tag)
+}
+
+func (s *tagService) Update(tag model.Tag) error {
+	if err := checkTag(&tag); err != nil {
+		return err
+	}
+	return s.tagRepository.Update(tag)
+}
+
+func (s *tagService) Delete(id int) error {
+	return s.tagRepository.Delete(id)
+}
+
+func checkTag(tag *model.Tag) error {
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Color == "" {
+		tag.Color = model.DefaultTagColor
+	}
+	return tag.Validate()
+}
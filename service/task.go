@@ -1,45 +1,572 @@
 package service
 
 import (
+	"a21hc3NpZ25tZW50/db"
 	"a21hc3NpZ25tZW50/model"
 	repo "a21hc3NpZ25tZW50/repository"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 )
 
 type TaskService interface {
 	Store(task *model.Task) error
 	Update(id int, task *model.Task) error
+	Transition(id int, to model.TaskTransition, version int) (*model.Task, error)
+	Patch(id int, patch model.Patch, version int, timezone string) (*model.Task, error)
+	Bulk(userID int, ops []model.TaskOp, atomic bool, timezone string) ([]model.TaskWrite, error)
+	UpdateFuture(id int, task *model.Task) error
+	Generate(id, count int) ([]model.Task, error)
 	Delete(id int) error
+	Trash(id int) (model.TrashEntry, error)
 	GetByID(id int) (*model.Task, error)
 	GetList(userID int) ([]model.Task, error)
+	GetPage(userID int, query model.TaskQuery) (model.TaskPage, error)
+	PageRelations(userID int, tasks []model.Task) (model.TaskRelations, error)
+	Subtasks(task model.Task) ([]model.Task, error)
+	AddDependency(dep model.Dependency) error
+	RemoveDependency(dep model.Dependency) error
 	GetTaskCategory(id int) ([]model.TaskCategory, error)
 	GetTaskCategoryByUser(categoryID, userID int) ([]model.TaskCategory, error)
 }
 
 type taskService struct {
-	taskRepository repo.TaskRepository
+	taskRepository       repo.TaskRepository
+	workflowRepository   repo.WorkflowRepository
+	dependencyRepository repo.DependencyRepository
+	tagRepository        repo.TagRepository
 }
 
-func NewTaskService(taskRepository repo.TaskRepository) TaskService {
-	return &taskService{taskRepository}
+func NewTaskService(taskRepository repo.TaskRepository, workflowRepository repo.WorkflowRepository, dependencyRepository repo.DependencyRepository, tagRepository repo.TagRepository) TaskService {
+	return &taskService{taskRepository, workflowRepository, dependencyRepository, tagRepository}
 }
 
+// Store only accepts a status of the user's workflow. A new subtask without a
+// position goes after the existing subtasks of its parent.
 func (c *taskService) Store(task *model.Task) error {
-	err := c.taskRepository.Store(task)
+	if err := c.prepareStore(task); err != nil {
+		return err
+	}
+	return c.taskRepository.Store(task)
+}
+
+// prepareStore makes task ready to be stored, see Store.
+func (c *taskService) prepareStore(task *model.Task) error {
+	workflow, err := userWorkflow(c.workflowRepository, task.UserID)
 	if err != nil {
 		return err
 	}
+	if err := workflow.CheckStatus(task.Status); err != nil {
+		return err
+	}
+	if err := checkRecurrence(task); err != nil {
+		return err
+	}
+	task.TagIDs = model.NormalizeTagIDs(task.TagIDs)
+	stampCompleted(task, nil, workflow)
 
+	if task.ParentID != 0 && task.Position == 0 {
+		siblings, err := c.Subtasks(model.Task{ID: task.ParentID, UserID: task.UserID})
+		if err != nil {
+			return err
+		}
+		task.Position = 1
+		if n := len(siblings); n > 0 {
+			task.Position = siblings[n-1].Position + 1
+		}
+	}
 	return nil
 }
 
+// Update enforces the transitions of the user's workflow when the status
+// changes and keeps CompletedAt in step with it. A task waiting on tasks that
+// are not done yet may only change to a todo status. Completing a recurring
+// task creates its next occurrence.
 func (s *taskService) Update(id int, task *model.Task) error {
-	err := s.taskRepository.Update(id, task)
+	existing, err := s.taskRepository.GetByID(id)
+	if err != nil {
+		return err
+	}
+	workflow, err := s.prepareUpdate(existing, task)
 	if err != nil {
 		return err
 	}
+
+	err = s.taskRepository.Update(id, task)
+	if err != nil {
+		return err
+	}
+	if completesRecurrence(*existing, *task) {
+		return s.nextOccurrence(id, workflow)
+	}
 	return nil
 }
 
+// prepareUpdate makes task ready to replace existing, see Update, and
+// returns the workflow of its user.
+func (s *taskService) prepareUpdate(existing, task *model.Task) (model.Workflow, error) {
+	task.SeriesID = existing.SeriesID
+	if err := checkRecurrence(task); err != nil {
+		return model.Workflow{}, err
+	}
+	task.TagIDs = model.NormalizeTagIDs(task.TagIDs)
+
+	workflow, err := userWorkflow(s.workflowRepository, existing.UserID)
+	if err != nil {
+		return workflow, err
+	}
+	if err := workflow.CheckTransition(existing.Status, task.Status); err != nil {
+		return workflow, err
+	}
+	if status, _ := workflow.Status(task.Status); task.Status != existing.Status && status.Category != model.StatusTodo {
+		if err := s.checkBlockers(*existing, workflow); err != nil {
+			return workflow, err
+		}
+	}
+	stampCompleted(task, existing, workflow)
+	return workflow, nil
+}
+
+// completesRecurrence reports whether task, replacing existing, completes an
+// occurrence of a recurring task.
+func completesRecurrence(existing, task model.Task) bool {
+	return task.Recurrence != nil && task.CompletedAt != nil && existing.CompletedAt == nil
+}
+
+// Transition moves a task to to.Status, or to the status of to.Category the
+// workflow picks, and returns it as stored. It fails with
+// model.ErrVersionConflict if the task changed while being moved, or with
+// version set, is no longer at the version the client read.
+func (s *taskService) Transition(id int, to model.TaskTransition, version int) (*model.Task, error) {
+	task, err := s.taskRepository.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && task.Version != version {
+		return nil, model.ErrVersionConflict
+	}
+
+	status := to.Status
+	if status == "" {
+		workflow, err := userWorkflow(s.workflowRepository, task.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if status, err = workflow.Target(task.Status, to.Category); err != nil {
+			return nil, err
+		}
+	}
+
+	task.Status = status
+	if err := s.Update(id, task); err != nil {
+		return nil, err
+	}
+	return s.taskRepository.GetByID(id)
+}
+
+// patchAttempts is how often a patch is applied to a task or category that
+// keeps being written to under it.
+const patchAttempts = 3
+
+// Patch applies patch to the task with id and saves the result like Update,
+// the recurrence counting days in timezone. The store only writes over the
+// version the patch was applied to, so no concurrent write is lost: the
+// patch is applied again to the newer task, or with version set, the
+// version the client read, it fails with model.ErrVersionConflict.
+func (s *taskService) Patch(id int, patch model.Patch, version int, timezone string) (*model.Task, error) {
+	for attempt := 1; ; attempt++ {
+		existing, err := s.taskRepository.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && existing.Version != version {
+			return nil, model.ErrVersionConflict
+		}
+
+		task, err := patchTask(*existing, patch, timezone)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.Update(id, &task)
+		if err == model.ErrVersionConflict && version == 0 && attempt < patchAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s.taskRepository.GetByID(id)
+	}
+}
+
+// Bulk runs the operations of a bulk request for the user, see
+// db.Store.WriteTasks: it returns a write per operation, refused ones with
+// their Err. Each operation is checked like the request of its own would be,
+// against the tasks as the operations before it leave them, and the writes
+// are made in one transaction. When atomic, the first refused operation
+// stops the others and Bulk returns model.ErrBulkAborted. Occurrences that
+// follow completed recurring tasks are created after the transaction.
+func (s *taskService) Bulk(userID int, ops []model.TaskOp, atomic bool, timezone string) ([]model.TaskWrite, error) {
+	writes := make([]model.TaskWrite, len(ops))
+	pending := map[int]*model.Task{} // nil once deleted
+	var completes []int
+
+	for i, op := range ops {
+		writes[i].Op = op.Op
+		task, err := s.prepareOp(userID, op, pending, timezone)
+		if err != nil {
+			writes[i].Err = err
+			if atomic {
+				return writes, model.ErrBulkAborted
+			}
+			continue
+		}
+		writes[i].Task = task
+
+		switch op.Op {
+		case model.TaskOpDelete:
+			pending[op.ID] = nil
+		case model.TaskOpUpdate, model.TaskOpMove:
+			if existing := pending[op.ID]; completesRecurrence(*existing, task) {
+				completes = append(completes, i)
+			}
+			// The store bumps the version of each write
+			next := task
+			next.Version++
+			pending[op.ID] = &next
+		}
+	}
+
+	if err := s.taskRepository.Write(writes, atomic); err != nil {
+		return writes, err
+	}
+
+	if len(completes) > 0 {
+		workflow, err := userWorkflow(s.workflowRepository, userID)
+		if err != nil {
+			return writes, err
+		}
+		for _, i := range completes {
+			if writes[i].Err == nil {
+				if err := s.nextOccurrence(writes[i].Task.ID, workflow); err != nil {
+					return writes, err
+				}
+			}
+		}
+	}
+	return writes, nil
+}
+
+// prepareOp makes the task an operation of Bulk writes. pending holds the
+// tasks as the operations before it leave them, the task op reads is added.
+func (s *taskService) prepareOp(userID int, op model.TaskOp, pending map[int]*model.Task, timezone string) (model.Task, error) {
+	if op.Op == model.TaskOpCreate {
+		task := *op.Task
+		task.ID, task.UserID, task.SeriesID = 0, userID, 0
+		if task.Recurrence != nil && timezone != "" {
+			task.Recurrence.Timezone = timezone
+		}
+		if err := checkTaskFields(task, true); err != nil {
+			return task, err
+		}
+		return task, s.prepareStore(&task)
+	}
+
+	existing, ok := pending[op.ID]
+	if !ok {
+		var err error
+		if existing, err = s.taskRepository.GetByID(op.ID); err != nil {
+			return model.Task{}, err
+		}
+		pending[op.ID] = existing
+	}
+	if existing == nil {
+		return model.Task{}, model.ErrRecordNotFound
+	}
+	if existing.UserID != userID {
+		return model.Task{}, model.ErrTaskNotOwned
+	}
+	if op.Version != 0 && op.Version != existing.Version {
+		return model.Task{}, model.ErrVersionConflict
+	}
+
+	task := *existing
+	switch op.Op {
+	case model.TaskOpDelete:
+		return task, nil
+	case model.TaskOpUpdate:
+		var err error
+		if task, err = patchTask(*existing, model.Patch{Type: model.MergePatchType, Body: op.Fields}, timezone); err != nil {
+			return task, err
+		}
+	case model.TaskOpMove:
+		task.CategoryID = op.CategoryID
+	}
+	_, err := s.prepareUpdate(existing, &task)
+	return task, err
+}
+
+// patchTask applies patch to existing and checks the result, which keeps the
+// fields the store and Update look after.
+func patchTask(existing model.Task, patch model.Patch, timezone string) (model.Task, error) {
+	var task model.Task
+	if err := patch.ApplyTo(existing, &task); err != nil {
+		return task, err
+	}
+	task.ID, task.UserID, task.SeriesID = existing.ID, existing.UserID, existing.SeriesID
+	task.CompletedAt, task.Version, task.UpdatedAt = existing.CompletedAt, existing.Version, existing.UpdatedAt
+	if task.Recurrence != nil && timezone != "" {
+		task.Recurrence.Timezone = timezone
+	}
+	return task, checkTaskFields(task, !existing.Deadline.IsZero())
+}
+
+// checkTaskFields checks the fields every new task needs. Tasks whose
+// deadline a migration cleared keep going without one, so an update only
+// needs a deadline when the task had one.
+func checkTaskFields(task model.Task, needDeadline bool) error {
+	var problem string
+	switch {
+	case strings.TrimSpace(task.Title) == "":
+		problem = "title cannot be empty"
+	case needDeadline && task.Deadline.IsZero():
+		problem = "deadline cannot be empty"
+	case task.Status == "":
+		problem = "status cannot be empty"
+	case task.CategoryID <= 0:
+		problem = "invalid category ID"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", model.ErrInvalidTask, problem)
+}
+
+// checkBlockers fails with a *model.BlockedError while task waits on a task
+// that is not done.
+func (s *taskService) checkBlockers(task model.Task, workflow model.Workflow) error {
+	deps, err := s.dependencyRepository.GetList(task.UserID)
+	if err != nil {
+		return err
+	}
+
+	var predecessors []model.Task
+	for _, d := range deps {
+		if d.TaskID != task.ID {
+			continue
+		}
+		predecessor, err := s.taskRepository.GetByID(d.BlockedByID)
+		if err != nil {
+			return err
+		}
+		predecessors = append(predecessors, *predecessor)
+	}
+
+	if open := workflow.Blockers(predecessors, deps)[task.ID]; len(open) > 0 {
+		return &model.BlockedError{Open: open}
+	}
+	return nil
+}
+
+// stampCompleted sets CompletedAt when task enters a done status and clears
+// it when it leaves one. existing is the stored task, nil for a new one.
+func stampCompleted(task, existing *model.Task, workflow model.Workflow) {
+	status, _ := workflow.Status(task.Status)
+	switch {
+	case status.Category != model.StatusDone:
+		task.CompletedAt = nil
+	case existing != nil && existing.CompletedAt != nil:
+		task.CompletedAt = existing.CompletedAt
+	default:
+		now := db.Now()
+		task.CompletedAt = &now
+	}
+}
+
+// checkRecurrence validates the rule of a recurring task. A monthly rule
+// without a day is pinned to the day of the deadline, so that a deadline on
+// the 31st comes back on the 31st after a short month.
+func checkRecurrence(task *model.Task) error {
+	r := task.Recurrence
+	if r == nil {
+		return nil
+	}
+	if task.ParentID != 0 {
+		return fmt.Errorf("%w: subtasks cannot repeat", model.ErrInvalidRecurrence)
+	}
+	if task.Deadline.IsZero() {
+		return fmt.Errorf("%w: a repeating task needs a deadline", model.ErrInvalidRecurrence)
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	if r.Freq == model.RecurMonthly && r.ByMonthDay == 0 && !r.AfterCompletion {
+		at := task.Deadline.At
+		if !task.Deadline.DateOnly {
+			loc, _ := model.LoadTimezone(r.Timezone)
+			at = at.In(loc)
+		}
+		pinned := *r
+		pinned.ByMonthDay = at.Day()
+		task.Recurrence = &pinned
+	}
+	return nil
+}
+
+// series lists the occurrences of the series task belongs to by deadline.
+func (s *taskService) series(task model.Task) ([]model.Task, error) {
+	tasks, err := s.taskRepository.GetList(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var series []model.Task
+	for _, t := range tasks {
+		if t.Series() == task.Series() {
+			series = append(series, t)
+		}
+	}
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].Deadline.At.Before(series[j].Deadline.At)
+	})
+	return series, nil
+}
+
+// initialStatus is the status new occurrences start in, the first todo status
+// of the workflow.
+func initialStatus(workflow model.Workflow) string {
+	if status, err := workflow.Target("", model.StatusTodo); err == nil {
+		return status
+	}
+	return workflow.Statuses[0].Name
+}
+
+// nextOccurrence creates the occurrence after the completed task id, unless
+// one due later was created already.
+func (s *taskService) nextOccurrence(id int, workflow model.Workflow) error {
+	task, err := s.taskRepository.GetByID(id)
+	if err != nil {
+		return err
+	}
+	series, err := s.series(*task)
+	if err != nil {
+		return err
+	}
+	if last := series[len(series)-1]; last.Deadline.At.After(task.Deadline.At) {
+		return nil
+	}
+
+	next := task.Occurrence(task.Recurrence.Next(task.Deadline, task.CompletedAt), initialStatus(workflow))
+	return s.Store(&next)
+}
+
+// Generate creates the next count occurrences after the last one of the
+// series of task id, and returns them. Rules counted from completion cannot
+// know their deadlines ahead.
+func (s *taskService) Generate(id, count int) ([]model.Task, error) {
+	task, err := s.taskRepository.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Recurrence == nil {
+		return nil, fmt.Errorf("%w: task does not repeat", model.ErrInvalidRecurrence)
+	}
+	if task.Recurrence.AfterCompletion {
+		return nil, fmt.Errorf("%w: occurrences counted from completion cannot be created ahead", model.ErrInvalidRecurrence)
+	}
+
+	series, err := s.series(*task)
+	if err != nil {
+		return nil, err
+	}
+	return s.generate(series[len(series)-1], count)
+}
+
+func (s *taskService) generate(last model.Task, count int) ([]model.Task, error) {
+	workflow, err := userWorkflow(s.workflowRepository, last.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	after := last.Deadline.At
+	for i := 0; i < count; i++ {
+		next := last.Occurrence(last.Recurrence.Next(last.Deadline, nil), initialStatus(workflow))
+		if err := s.Store(&next); err != nil {
+			return nil, err
+		}
+		last = next
+	}
+
+	// the store assigns IDs without handing them back
+	series, err := s.series(last)
+	if err != nil {
+		return nil, err
+	}
+	var created []model.Task
+	for _, t := range series {
+		if t.Deadline.At.After(after) {
+			created = append(created, t)
+		}
+	}
+	return created, nil
+}
+
+// UpdateFuture updates task id like Update and carries the change over to the
+// occurrences of its series due after it that are not done. When the deadline
+// or the rule changes, those occurrences are created again from the updated
+// task; without a rule they are deleted.
+func (s *taskService) UpdateFuture(id int, task *model.Task) error {
+	existing, err := s.taskRepository.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	var later []model.Task
+	if existing.Recurrence != nil {
+		series, err := s.series(*existing)
+		if err != nil {
+			return err
+		}
+		for _, t := range series {
+			if t.Deadline.At.After(existing.Deadline.At) && t.CompletedAt == nil {
+				later = append(later, t)
+			}
+		}
+	}
+
+	if err := s.Update(id, task); err != nil {
+		return err
+	}
+	updated, err := s.taskRepository.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	sameDeadline := updated.Deadline.At.Equal(existing.Deadline.At) && updated.Deadline.DateOnly == existing.Deadline.DateOnly
+	if sameDeadline && reflect.DeepEqual(updated.Recurrence, existing.Recurrence) {
+		for _, t := range later {
+			t.Title, t.Priority, t.CategoryID = updated.Title, updated.Priority, updated.CategoryID
+			t.Version = 0
+			if err := s.taskRepository.Update(t.ID, &t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, t := range later {
+		if err := s.taskRepository.Delete(t.ID); err != nil {
+			return err
+		}
+	}
+	if updated.Recurrence == nil || updated.Recurrence.AfterCompletion || len(later) == 0 {
+		return nil
+	}
+	_, err = s.generate(*updated, len(later))
+	return err
+}
+
 func (s *taskService) Delete(id int) error {
 	err := s.taskRepository.Delete(id)
 	if err != nil {
@@ -48,6 +575,12 @@ func (s *taskService) Delete(id int) error {
 	return nil
 }
 
+// Trash deletes the task and its subtasks into the trash, see
+// model.TrashEntry.
+func (s *taskService) Trash(id int) (model.TrashEntry, error) {
+	return s.taskRepository.Trash(id)
+}
+
 func (s *taskService) GetByID(id int) (*model.Task, error) {
 	task, err := s.taskRepository.GetByID(id)
 	if err != nil {
@@ -65,6 +598,112 @@ func (s *taskService) GetList(userID int) ([]model.Task, error) {
 	return tasks, nil
 }
 
+// GetPage lists a page of the tasks of a user matching query, its TagNames
+// filtering on the tags of the user with those names. It fails with model.ErrInvalidTaskQuery or
+// model.ErrInvalidCursor for a query that does not validate.
+func (s *taskService) GetPage(userID int, query model.TaskQuery) (model.TaskPage, error) {
+	if err := query.Validate(); err != nil {
+		return model.TaskPage{}, err
+	}
+
+	if len(query.TagNames) > 0 {
+		owned, err := s.tagRepository.GetList(userID)
+		if err != nil {
+			return model.TaskPage{}, err
+		}
+		var ids []int
+		for _, name := range query.TagNames {
+			id := 0
+			for _, tag := range owned {
+				if model.SameTagName(tag.Name, name) {
+					id = tag.ID
+				}
+			}
+			ids = append(ids, id)
+		}
+		query.TagIDs = model.NormalizeTagIDs(ids)
+	}
+
+	return s.taskRepository.GetPage(userID, query)
+}
+
+// PageRelations computes the relations of tasks, a page of the tasks of the
+// user, reading only the subtasks and blockers they need rather than every
+// task.
+func (s *taskService) PageRelations(userID int, tasks []model.Task) (model.TaskRelations, error) {
+	if len(tasks) == 0 {
+		return model.TaskRelations{}, nil
+	}
+	workflow, err := userWorkflow(s.workflowRepository, userID)
+	if err != nil {
+		return model.TaskRelations{}, err
+	}
+	deps, err := s.dependencyRepository.GetList(userID)
+	if err != nil {
+		return model.TaskRelations{}, err
+	}
+
+	onPage := map[int]bool{}
+	parents := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		onPage[task.ID] = true
+		parents = append(parents, task.ID)
+	}
+	subtasks, err := s.taskRepository.GetPage(userID, model.TaskQuery{Sort: model.SortCreated, ParentIDs: parents})
+	if err != nil {
+		return model.TaskRelations{}, err
+	}
+
+	related := append([]model.Task{}, tasks...)
+	seen := map[int]bool{}
+	for _, task := range subtasks.Tasks {
+		if !onPage[task.ID] {
+			related, seen[task.ID] = append(related, task), true
+		}
+	}
+	var pageDeps []model.Dependency
+	for _, dep := range deps {
+		if !onPage[dep.TaskID] {
+			continue
+		}
+		pageDeps = append(pageDeps, dep)
+		if onPage[dep.BlockedByID] || seen[dep.BlockedByID] {
+			continue
+		}
+		blocker, err := s.taskRepository.GetByID(dep.BlockedByID)
+		if err != nil {
+			return model.TaskRelations{}, err
+		}
+		related, seen[dep.BlockedByID] = append(related, *blocker), true
+	}
+	return model.NewTaskRelations(related, pageDeps, workflow), nil
+}
+
+// Subtasks lists the subtasks of task in their order.
+func (s *taskService) Subtasks(task model.Task) ([]model.Task, error) {
+	tasks, err := s.taskRepository.GetList(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var subtasks []model.Task
+	for _, t := range tasks {
+		if t.ParentID == task.ID {
+			subtasks = append(subtasks, t)
+		}
+	}
+	model.SortSubtasks(subtasks)
+	return subtasks, nil
+}
+
+func (s *taskService) AddDependency(dep model.Dependency) error {
+	return s.dependencyRepository.Add(dep)
+}
+
+func (s *taskService) RemoveDependency(dep model.Dependency) error {
+	return s.dependencyRepository.Remove(dep)
+}
+
 func (s *taskService) GetTaskCategory(id int) ([]model.TaskCategory, error) {
 	taskCategories, err := s.taskRepository.GetTaskCategory(id)
 	if err != nil {
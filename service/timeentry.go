@@ -0,0 +1,177 @@
+package service
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
+	"fmt"
+	"time"
+)
+
+type TimeEntryService interface {
+	GetList(taskID int) ([]model.TimeEntry, error)
+	GetByID(taskID, id int) (*model.TimeEntry, error)
+	GetRunning(userID int) (*model.TimeEntry, error)
+	Start(task model.Task, note string) (model.TimeEntry, error)
+	Stop(userID int) (model.TimeEntry, error)
+	Store(task model.Task, request model.TimeEntryRequest) (model.TimeEntry, error)
+	Update(id int, request model.TimeEntryRequest) (model.TimeEntry, error)
+	Delete(id int) error
+	Timesheet(userID int, from, to string, loc *time.Location) (model.Timesheet, error)
+}
+
+type timeEntryService struct {
+	timeEntryRepository repo.TimeEntryRepository
+	taskRepository      repo.TaskRepository
+	categoryRepository  repo.CategoryRepository
+}
+
+func NewTimeEntryService(timeEntryRepository repo.TimeEntryRepository, taskRepository repo.TaskRepository, categoryRepository repo.CategoryRepository) TimeEntryService {
+	return &timeEntryService{timeEntryRepository, taskRepository, categoryRepository}
+}
+
+// timerNow is the time a timer starts or stops at. Entries are kept to the
+// second, which is what a timesheet needs.
+func timerNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+func (s *timeEntryService) GetList(taskID int) ([]model.TimeEntry, error) {
+	return s.timeEntryRepository.GetListByTask(taskID)
+}
+
+// GetByID returns a time entry of the task, model.ErrRecordNotFound if the
+// entry is on another task.
+func (s *timeEntryService) GetByID(taskID, id int) (*model.TimeEntry, error) {
+	entry, err := s.timeEntryRepository.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.TaskID != taskID {
+		return nil, model.ErrRecordNotFound
+	}
+	return entry, nil
+}
+
+// GetRunning returns the running timer of a user, model.ErrRecordNotFound
+// if there is none.
+func (s *timeEntryService) GetRunning(userID int) (*model.TimeEntry, error) {
+	return s.timeEntryRepository.GetRunning(userID)
+}
+
+// Start starts a timer on task for its user, model.ErrTimerRunning if the
+// user already has one running.
+func (s *timeEntryService) Start(task model.Task, note string) (model.TimeEntry, error) {
+	if err := (model.TimerRequest{Note: note}).Validate(); err != nil {
+		return model.TimeEntry{}, err
+	}
+	entry := model.TimeEntry{TaskID: task.ID, UserID: task.UserID, Start: timerNow(), Note: note}
+	if err := s.timeEntryRepository.Store(&entry); err != nil {
+		return model.TimeEntry{}, err
+	}
+	return entry, nil
+}
+
+// Stop stops the running timer of a user. A timer stopped within the second
+// it started still covers that second.
+func (s *timeEntryService) Stop(userID int) (model.TimeEntry, error) {
+	end := timerNow()
+	running, err := s.timeEntryRepository.GetRunning(userID)
+	if err == model.ErrRecordNotFound {
+		return model.TimeEntry{}, model.ErrNoTimerRunning
+	}
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	if !end.After(running.Start) {
+		end = running.Start.Add(time.Second)
+	}
+	return s.timeEntryRepository.Stop(userID, end)
+}
+
+// Store adds a manual entry on task, which needs an end.
+func (s *timeEntryService) Store(task model.Task, request model.TimeEntryRequest) (model.TimeEntry, error) {
+	if request.End == nil {
+		return model.TimeEntry{}, fmt.Errorf("%w: end is required, start a timer instead", model.ErrInvalidTimeEntry)
+	}
+	entry, err := timeEntryFrom(request)
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	entry.TaskID, entry.UserID = task.ID, task.UserID
+	if err := s.timeEntryRepository.Store(&entry); err != nil {
+		return model.TimeEntry{}, err
+	}
+	return entry, nil
+}
+
+// Update replaces the times and note of an entry. Only a running timer may
+// be left without an end, it keeps running.
+func (s *timeEntryService) Update(id int, request model.TimeEntryRequest) (model.TimeEntry, error) {
+	entry, err := timeEntryFrom(request)
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	existing, err := s.timeEntryRepository.GetByID(id)
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+	if request.End == nil && !existing.Running() {
+		return model.TimeEntry{}, fmt.Errorf("%w: end is required", model.ErrInvalidTimeEntry)
+	}
+
+	entry.ID, entry.TaskID, entry.UserID = existing.ID, existing.TaskID, existing.UserID
+	if err := s.timeEntryRepository.Update(entry); err != nil {
+		return model.TimeEntry{}, err
+	}
+	return entry, nil
+}
+
+func (s *timeEntryService) Delete(id int) error {
+	return s.timeEntryRepository.Delete(id)
+}
+
+// Timesheet adds up the time of a user from day to day in loc, see
+// model.BuildTimesheet.
+func (s *timeEntryService) Timesheet(userID int, from, to string, loc *time.Location) (model.Timesheet, error) {
+	start, end, err := model.TimesheetRange(from, to, loc)
+	if err != nil {
+		return model.Timesheet{}, err
+	}
+
+	entries, err := s.timeEntryRepository.GetList(userID, start, end)
+	if err != nil {
+		return model.Timesheet{}, err
+	}
+	userTasks, err := s.taskRepository.GetList(userID)
+	if err != nil {
+		return model.Timesheet{}, err
+	}
+
+	tasks := make(map[int]model.Task, len(userTasks))
+	categories := map[int]model.Category{}
+	for _, task := range userTasks {
+		tasks[task.ID] = task
+		if _, ok := categories[task.CategoryID]; ok || task.CategoryID == 0 {
+			continue
+		}
+		if category, err := s.categoryRepository.GetByID(task.CategoryID); err == nil {
+			categories[task.CategoryID] = *category
+		}
+	}
+
+	return model.BuildTimesheet(entries, tasks, categories, start, end, loc, timerNow()), nil
+}
+
+// timeEntryFrom reads the times of request in UTC to the second and checks
+// them.
+func timeEntryFrom(request model.TimeEntryRequest) (model.TimeEntry, error) {
+	request.Start = request.Start.UTC().Truncate(time.Second)
+	if request.End != nil {
+		end := request.End.UTC().Truncate(time.Second)
+		request.End = &end
+	}
+	if err := request.Validate(); err != nil {
+		return model.TimeEntry{}, err
+	}
+	return model.TimeEntry{Start: request.Start, End: request.End, Note: request.Note}, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type TrashService interface {
+	GetList(userID int) ([]model.TrashEntry, error)
+	GetByID(id int) (*model.TrashEntry, error)
+	Restore(id int) (model.TrashEntry, error)
+	Purge(entry model.TrashEntry) error
+	PurgeExpired(now time.Time) (int, error)
+	Retention() time.Duration
+}
+
+type trashService struct {
+	trashRepository   repo.TrashRepository
+	attachmentService AttachmentService
+	retention         time.Duration
+}
+
+// NewTrashService keeps trash entries for retention, zero meaning until they
+// are purged by hand. The blobs of purged attachments are swept with
+// attachmentService.
+func NewTrashService(trashRepository repo.TrashRepository, attachmentService AttachmentService, retention time.Duration) TrashService {
+	return &trashService{trashRepository, attachmentService, retention}
+}
+
+func (s *trashService) GetList(userID int) ([]model.TrashEntry, error) {
+	return s.trashRepository.GetList(userID)
+}
+
+func (s *trashService) GetByID(id int) (*model.TrashEntry, error) {
+	return s.trashRepository.GetByID(id)
+}
+
+// Restore puts the records of an entry back. An entry whose category or
+// parent task is gone is refused with an error wrapping
+// model.ErrRestoreConflict.
+func (s *trashService) Restore(id int) (model.TrashEntry, error) {
+	entry, err := s.trashRepository.Restore(id)
+	if errors.Is(err, model.ErrCategoryNotFound) || errors.Is(err, model.ErrCategoryNotOwned) || errors.Is(err, model.ErrParentNotFound) {
+		return entry, fmt.Errorf("%w: %v", model.ErrRestoreConflict, err)
+	}
+	return entry, err
+}
+
+// Purge drops an entry for good, with the content of its attachments.
+func (s *trashService) Purge(entry model.TrashEntry) error {
+	if err := s.trashRepository.Purge(entry.ID); err != nil {
+		return err
+	}
+	s.sweep(entry.UserID)
+	return nil
+}
+
+// PurgeExpired drops the entries older than the retention at now and returns
+// how many it dropped.
+func (s *trashService) PurgeExpired(now time.Time) (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	purged, err := s.trashRepository.PurgeBefore(now.Add(-s.retention))
+	if err != nil {
+		return 0, err
+	}
+	users := map[int]bool{}
+	for _, entry := range purged {
+		if !users[entry.UserID] {
+			users[entry.UserID] = true
+			s.sweep(entry.UserID)
+		}
+	}
+	return len(purged), nil
+}
+
+func (s *trashService) Retention() time.Duration {
+	return s.retention
+}
+
+// sweep removes the blobs left behind by purging. The purge already
+// succeeded, so a failure is only logged.
+func (s *trashService) sweep(userID int) {
+	if _, err := s.attachmentService.Sweep(userID); err != nil {
+		fmt.Printf("Warning: sweeping attachments of user %d: %v\n", userID, err)
+	}
+}
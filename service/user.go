@@ -1,10 +1,14 @@
 package service
 
 import (
+	"a21hc3NpZ25tZW50/config"
 	"a21hc3NpZ25tZW50/model"
 	repo "a21hc3NpZ25tZW50/repository"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -18,8 +22,14 @@ func (s *userService) GetUsers() ([]model.User, error) {
 
 type UserService interface {
 	Register(user *model.User) (model.User, error)
-	Login(user *model.User) (token *string, err error)
+	Login(user *model.User, device model.Device) (tokens *model.TokenPair, err error)
+	Refresh(refreshToken string) (tokens *model.TokenPair, err error)
+	Logout(token string) error
+	LogoutAll(email string) error
+	GetSessions(email, currentToken string) ([]model.SessionInfo, error)
+	RevokeSession(email string, id int) error
 	GetUserByEmail(email string) (model.User, error)
+	SetTimezone(email, timezone string) error
 	GetUserTaskCategory() ([]model.UserTaskCategory, error)
 	GetUsers() ([]model.User, error) // debug: ambil semua user
 }
@@ -43,6 +53,10 @@ func (s *userService) Register(user *model.User) (model.User, error) {
 		return *user, errors.New("email already exists")
 	}
 
+	if _, err := model.LoadTimezone(user.Timezone); err != nil {
+		return *user, err
+	}
+
 	// Hash password dengan bcrypt
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -62,7 +76,7 @@ func (s *userService) Register(user *model.User) (model.User, error) {
 	return newUser, nil
 }
 
-func (s *userService) Login(user *model.User) (token *string, err error) {
+func (s *userService) Login(user *model.User, device model.Device) (tokens *model.TokenPair, err error) {
 	dbUser, err := s.userRepo.GetUserByEmail(user.Email)
 	if err != nil {
 		return nil, err
@@ -78,38 +92,211 @@ func (s *userService) Login(user *model.User) (token *string, err error) {
 		return nil, errors.New("wrong email or password")
 	}
 
-	claims := &model.Claims{
-		ID:             dbUser.ID,
-		Email:          dbUser.Email,
-		StandardClaims: jwt.StandardClaims{}, // tanpa ExpiresAt, token tidak pernah expired
+	familyID, err := randomToken()
+	if err != nil {
+		return nil, err
 	}
 
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := t.SignedString(model.JwtKey)
+	// Setiap login membuat session sendiri, session di perangkat lain tetap berlaku
+	now := time.Now()
+	tokens, session, err := s.issueTokens(dbUser, model.Session{
+		FamilyID:  familyID,
+		UserAgent: device.UserAgent,
+		IP:        device.IP,
+		CreatedAt: now,
+		LastSeen:  now,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	session := model.Session{
-		Token: tokenString,
-		Email: user.Email,
-		// Expiry dikosongkan karena token tidak pernah expired
+	err = s.sessionsRepo.AddSessions(session)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// is single use: presenting one that was already rotated is treated as theft
+// and revokes every session descended from the same login.
+func (s *userService) Refresh(refreshToken string) (tokens *model.TokenPair, err error) {
+	if refreshToken == "" {
+		return nil, errors.New("invalid refresh token")
 	}
 
-	_, err = s.sessionsRepo.SessionAvailEmail(session.Email)
+	session, err := s.sessionsRepo.SessionByRefreshToken(refreshToken)
 	if err != nil {
-		err = s.sessionsRepo.AddSessions(session)
-		if err != nil {
-			return nil, err
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if session.Rotated {
+		if session.FamilyID != "" {
+			if err := s.sessionsRepo.DeleteSessionFamily(session.FamilyID); err != nil {
+				return nil, err
+			}
 		}
-	} else {
-		err = s.sessionsRepo.UpdateSessions(session)
-		if err != nil {
+		return nil, errors.New("refresh token reuse detected")
+	}
+
+	if s.sessionsRepo.TokenExpired(session) {
+		if err := s.sessionsRepo.DeleteSession(session.Token); err != nil {
 			return nil, err
 		}
+		return nil, errors.New("refresh token expired")
+	}
+
+	dbUser, err := s.userRepo.GetUserByEmail(session.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if dbUser.Email == "" || dbUser.ID == 0 {
+		return nil, errors.New("user not found")
+	}
+
+	// The rotated session is the same device, so it keeps its ID and history
+	tokens, next, err := s.issueTokens(dbUser, model.Session{
+		ID:        session.ID,
+		FamilyID:  session.FamilyID,
+		UserAgent: session.UserAgent,
+		IP:        session.IP,
+		CreatedAt: session.CreatedAt,
+		LastSeen:  time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.sessionsRepo.RotateSession(session, next)
+	if err != nil {
+		// Lost a race with another refresh of the same token
+		return nil, errors.New("refresh token reuse detected")
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes the session behind token so it stops working immediately,
+// along with the sessions it was rotated from.
+func (s *userService) Logout(token string) error {
+	session, err := s.sessionsRepo.SessionAvailToken(token)
+	if err == nil && session.FamilyID != "" {
+		return s.sessionsRepo.DeleteSessionFamily(session.FamilyID)
 	}
+	return s.sessionsRepo.DeleteSession(token)
+}
 
-	return &tokenString, nil
+// LogoutAll revokes every session of the user, on every device.
+func (s *userService) LogoutAll(email string) error {
+	return s.sessionsRepo.DeleteSessionsByEmail(email)
+}
+
+// GetSessions lists the devices the user is logged in on, newest login first.
+func (s *userService) GetSessions(email, currentToken string) ([]model.SessionInfo, error) {
+	sessions, err := s.sessionsRepo.GetSessionsByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []model.SessionInfo{}
+	for _, session := range sessions {
+		if s.sessionsRepo.TokenExpired(session) {
+			continue
+		}
+		infos = append(infos, model.SessionInfo{
+			ID:        session.ID,
+			UserAgent: session.UserAgent,
+			IP:        session.IP,
+			CreatedAt: session.CreatedAt,
+			LastSeen:  session.LastSeen,
+			Expiry:    session.Expiry,
+			Current:   session.Token == currentToken,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+
+	return infos, nil
+}
+
+// RevokeSession logs a single device out. Only sessions of the same user can
+// be revoked.
+func (s *userService) RevokeSession(email string, id int) error {
+	if id <= 0 {
+		return errors.New("session not found")
+	}
+
+	sessions, err := s.sessionsRepo.GetSessionsByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == id {
+			return s.sessionsRepo.DeleteSessionsByID(id)
+		}
+	}
+
+	return errors.New("session not found")
+}
+
+// issueTokens signs a short-lived access token for dbUser and pairs it with a
+// fresh refresh token, returning session filled in with both.
+func (s *userService) issueTokens(dbUser model.User, session model.Session) (*model.TokenPair, model.Session, error) {
+	now := time.Now()
+	accessExpiry := now.Add(model.AccessTokenTTL)
+
+	// jti keeps tokens issued in the same second distinct, they key the session
+	tokenID, err := randomToken()
+	if err != nil {
+		return nil, model.Session{}, err
+	}
+
+	claims := &model.Claims{
+		ID:    dbUser.ID,
+		Email: dbUser.Email,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: accessExpiry.Unix(),
+		},
+	}
+
+	tokenString, err := config.JwtKeys.Sign(claims)
+	if err != nil {
+		return nil, model.Session{}, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, model.Session{}, err
+	}
+
+	session.Token = tokenString
+	session.RefreshToken = refreshToken
+	session.Email = dbUser.Email
+	session.Expiry = now.Add(model.RefreshTokenTTL)
+
+	tokens := &model.TokenPair{
+		AccessToken:  tokenString,
+		RefreshToken: refreshToken,
+		ExpiresAt:    accessExpiry,
+	}
+
+	return tokens, session, nil
+}
+
+// randomToken returns 32 random bytes hex encoded, used for opaque tokens.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 func (s *userService) GetUserByEmail(email string) (model.User, error) {
@@ -120,6 +307,24 @@ func (s *userService) GetUserByEmail(email string) (model.User, error) {
 	return user, nil
 }
 
+// SetTimezone changes the timezone the date-only deadlines of a user fall due in.
+func (s *userService) SetTimezone(email, timezone string) error {
+	if _, err := model.LoadTimezone(timezone); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" || user.ID == 0 {
+		return errors.New("user not found")
+	}
+
+	user.Timezone = timezone
+	return s.userRepo.UpdateUser(user)
+}
+
 func (s *userService) GetUserTaskCategory() ([]model.UserTaskCategory, error) {
 	// Cek koneksi ke repo
 	users, err := s.userRepo.GetUsers()
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
+)
+
+type ViewService interface {
+	GetList(userID int) ([]model.SavedView, error)
+	GetByID(id int) (*model.SavedView, error)
+	Store(view *model.SavedView) error
+	Update(view model.SavedView) error
+	Delete(id int) error
+}
+
+type viewService struct {
+	viewRepository repo.ViewRepository
+}
+
+func NewViewService(viewRepository repo.ViewRepository) ViewService {
+	return &viewService{viewRepository}
+}
+
+func (s *viewService) GetList(userID int) ([]model.SavedView, error) {
+	return s.viewRepository.GetList(userID)
+}
+
+func (s *viewService) GetByID(id int) (*model.SavedView, error) {
+	return s.viewRepository.GetByID(id)
+}
+
+// Store creates a view once its query parses, see model.SavedView.Validate.
+func (s *viewService) Store(view *model.SavedView) error {
+	if err := view.Validate(); err != nil {
+		return err
+	}
+	return s.viewRepository.Store(view)
+}
+
+func (s *viewService) Update(view model.SavedView) error {
+	if err := view.Validate(); err != nil {
+		return err
+	}
+	return s.viewRepository.Update(view)
+}
+
+func (s *viewService) Delete(id int) error {
+	return s.viewRepository.Delete(id)
+}
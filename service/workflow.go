@@ -0,0 +1,42 @@
+package service
+
+import (
+	"a21hc3NpZ25tZW50/model"
+	repo "a21hc3NpZ25tZW50/repository"
+)
+
+type WorkflowService interface {
+	Get(userID int) (model.Workflow, error)
+	Save(workflow model.Workflow) error
+}
+
+type workflowService struct {
+	workflowRepository repo.WorkflowRepository
+}
+
+func NewWorkflowService(workflowRepository repo.WorkflowRepository) WorkflowService {
+	return &workflowService{workflowRepository}
+}
+
+// Get returns the workflow of a user, the default one until they save their own.
+func (s *workflowService) Get(userID int) (model.Workflow, error) {
+	return userWorkflow(s.workflowRepository, userID)
+}
+
+func (s *workflowService) Save(workflow model.Workflow) error {
+	if err := workflow.Validate(); err != nil {
+		return err
+	}
+	return s.workflowRepository.Save(workflow)
+}
+
+func userWorkflow(workflowRepository repo.WorkflowRepository, userID int) (model.Workflow, error) {
+	workflow, err := workflowRepository.Get(userID)
+	if err == model.ErrRecordNotFound {
+		return model.DefaultWorkflow(userID), nil
+	}
+	if err != nil {
+		return model.Workflow{}, err
+	}
+	return *workflow, nil
+}